@@ -4,12 +4,15 @@ package main
 import (
 	"leave-app/internal/db"
 	"leave-app/internal/handlers"
+	"leave-app/internal/metrics"
 	"leave-app/internal/service"
 	"leave-app/pkg/auth"
 	"log"
+	"os"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func main() {
@@ -41,6 +44,7 @@ func main() {
 
 	// Create Gin router
 	r := gin.Default()
+	r.Use(metrics.GinMiddleware())
 
 	// CORS Middleware
 	r.Use(func(c *gin.Context) {
@@ -60,6 +64,10 @@ func main() {
 	// Initialize handlers
 	h := handlers.NewHandler(database)
 
+	// Metrics collected on scrape
+	metrics.RegisterDBStats(database.Stats)
+	metrics.RegisterPendingLeaves(h.LeaveService.CountPendingLeaves)
+
 	// Setup routes
 	api := r.Group("/api")
 	api.Use(authenticator.AuthMiddleware())
@@ -73,7 +81,28 @@ func main() {
 		api.POST("/leaves", h.CreateLeave)
 		api.PUT("/leaves/:id", h.UpdateLeave) // Unified endpoint with RBAC for dates and status
 		api.DELETE("/leaves/:id", h.DeleteLeave)
+		api.POST("/leaves/:id/comments", h.AddLeaveComment)
 		api.GET("/holidays", h.GetHolidays)
+		api.GET("/encashments", h.GetEncashments)
+		api.POST("/encashments", h.CreateEncashment)
+		api.PUT("/encashments/:id", h.UpdateEncashment)
+		api.GET("/admin/encashments/export", h.GetPayrollExport)
+	}
+
+	// SCIM 2.0 user provisioning for the identity provider
+	if scimToken := os.Getenv("SCIM_BEARER_TOKEN"); scimToken != "" {
+		scim := r.Group("/scim/v2")
+		scim.Use(auth.SCIMMiddleware(scimToken))
+		{
+			scim.GET("/Users", h.ListSCIMUsers)
+			scim.POST("/Users", h.CreateSCIMUser)
+			scim.GET("/Users/:id", h.GetSCIMUser)
+			scim.PUT("/Users/:id", h.ReplaceSCIMUser)
+			scim.PATCH("/Users/:id", h.PatchSCIMUser)
+			scim.DELETE("/Users/:id", h.DeleteSCIMUser)
+		}
+	} else {
+		log.Println("SCIM_BEARER_TOKEN not set, SCIM provisioning endpoints disabled")
 	}
 
 	// A simple health check route
@@ -81,6 +110,10 @@ func main() {
 		c.JSON(200, gin.H{"message": "pong"})
 	})
 
+	// Readiness and Prometheus metrics
+	r.GET("/readyz", h.Readyz)
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Start server
 	if err := r.Run(":8080"); err != nil {
 		log.Fatalf("Failed to run server: %v", err)
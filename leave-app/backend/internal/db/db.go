@@ -2,10 +2,12 @@
 package db
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"io/ioutil"
 	"leave-app/internal/constants"
+	"leave-app/internal/metrics"
 	"log"
 	"os"
 	"sync"
@@ -58,6 +60,7 @@ func NewDatabase() (*Database, error) {
             database.mu.Unlock()
             if err != nil {
                 log.Printf("DB ping failed: %v", err)
+                metrics.DBPingFailures.Inc()
                 failCount++
             } else {
                 failCount = 0
@@ -70,6 +73,7 @@ func NewDatabase() (*Database, error) {
                 newDB, err := sql.Open("mysql", dsn)
                 if err != nil {
                     log.Printf("reconnect: sql.Open error: %v", err)
+                    metrics.DBReconnects.WithLabelValues("failure").Inc()
                     continue
                 }
                 newDB.SetConnMaxLifetime(time.Duration(constants.ConnMaxLifetimeMinutes) * time.Minute)
@@ -77,6 +81,7 @@ func NewDatabase() (*Database, error) {
                 newDB.SetMaxOpenConns(constants.MaxOpenConns)
                 if err := newDB.Ping(); err != nil {
                     log.Printf("reconnect: ping failed: %v", err)
+                    metrics.DBReconnects.WithLabelValues("failure").Inc()
                     _ = newDB.Close()
                     continue
                 }
@@ -88,6 +93,7 @@ func NewDatabase() (*Database, error) {
                 database.mu.Unlock()
                 _ = old.Close()
                 log.Println("DB reconnect successful")
+                metrics.DBReconnects.WithLabelValues("success").Inc()
                 failCount = 0
             }
         }
@@ -96,6 +102,22 @@ func NewDatabase() (*Database, error) {
     return d, nil
 }
 
+// Ping checks that the current connection can reach the database
+func (db *Database) Ping(ctx context.Context) error {
+    db.mu.Lock()
+    conn := db.Conn
+    db.mu.Unlock()
+    return conn.PingContext(ctx)
+}
+
+// Stats returns the pool statistics of the current connection
+func (db *Database) Stats() sql.DBStats {
+    db.mu.Lock()
+    conn := db.Conn
+    db.mu.Unlock()
+    return conn.Stats()
+}
+
 // Migrate runs the database migrations
 func (db *Database) Migrate() error {
     if os.Getenv("RUN_MIGRATIONS") != "true" {
@@ -116,6 +138,11 @@ func (db *Database) Migrate() error {
         "migrations/001_initial.sql",
         "migrations/002_initial_schema.sql",
         "migrations/002_insert_seed_data.sql",
+        "migrations/003_leave_comments.sql",
+        "migrations/004_leave_on_behalf_actions.sql",
+        "migrations/005_user_directory.sql",
+        "migrations/006_leave_encashments.sql",
+        "migrations/007_scim_deprovisioned_users.sql",
     }
 
     for _, migrationFile := range migrations {
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"leave-app/internal/constants"
+	"leave-app/internal/models"
+	"leave-app/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateEncashment requests encashment of unused annual leave for the current user
+func (h *Handler) CreateEncashment(c *gin.Context) {
+    email, _ := c.Get(constants.ContextUserEmailKey)
+
+    var req models.CreateEncashmentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    year := time.Now().Year()
+    if req.Year != nil {
+        year = *req.Year
+    }
+    if year > time.Now().Year() {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot encash leave for a future year"})
+        return
+    }
+    if year < time.Now().Year()-models.MaxEncashmentYearsBack {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Leave can only be encashed for the current or previous year"})
+        return
+    }
+
+    user, err := h.UserService.GetUserByEmail(email.(string))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+        return
+    }
+
+    encashment, err := h.EncashmentService.CreateEncashment(user.ID, year, req.Days)
+    if err != nil {
+        switch {
+        case errors.Is(err, service.ErrEncashmentExceedsPolicy):
+            c.JSON(http.StatusBadRequest, gin.H{"error": "At most " + strconv.Itoa(models.MaxEncashableAnnualDays) + " annual leave days can be encashed per year"})
+        case errors.Is(err, service.ErrEncashmentExceedsBalance):
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Not enough unused annual leave"})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create encashment"})
+        }
+        return
+    }
+
+    c.JSON(http.StatusCreated, encashment)
+}
+
+// GetEncashments returns encashments based on user role
+func (h *Handler) GetEncashments(c *gin.Context) {
+    email, _ := c.Get(constants.ContextUserEmailKey)
+    role, _ := c.Get(constants.ContextUserRoleKey)
+
+    userID := ""
+    if role != models.UserRoleAdmin {
+        user, err := h.UserService.GetUserByEmail(email.(string))
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+            return
+        }
+        userID = user.ID
+    }
+
+    encashments, err := h.EncashmentService.GetEncashments(userID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get encashments"})
+        return
+    }
+
+    c.JSON(http.StatusOK, encashments)
+}
+
+// UpdateEncashment approves or rejects a pending encashment (Admin only)
+func (h *Handler) UpdateEncashment(c *gin.Context) {
+    email, _ := c.Get(constants.ContextUserEmailKey)
+    role, _ := c.Get(constants.ContextUserRoleKey)
+    if role != models.UserRoleAdmin {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+        return
+    }
+
+    var req models.UpdateEncashmentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    if req.Status != models.LeaveStatusApproved && req.Status != models.LeaveStatusRejected {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Status must be 'approved' or 'rejected'"})
+        return
+    }
+
+    approver, err := h.UserService.GetUserByEmail(email.(string))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+        return
+    }
+
+    encashmentID := c.Param("id")
+    if err := h.EncashmentService.DecideEncashment(encashmentID, req.Status, req.Comment, approver.ID); err != nil {
+        switch {
+        case err == sql.ErrNoRows:
+            c.JSON(http.StatusNotFound, gin.H{"error": "Encashment not found"})
+        case errors.Is(err, service.ErrEncashmentNotPending):
+            c.JSON(http.StatusConflict, gin.H{"error": "Only pending encashments can be decided"})
+        case errors.Is(err, service.ErrEncashmentExceedsBalance):
+            c.JSON(http.StatusConflict, gin.H{"error": "User no longer has enough unused annual leave"})
+        default:
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update encashment"})
+        }
+        return
+    }
+
+    encashment, err := h.EncashmentService.GetEncashmentByID(encashmentID)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get updated encashment"})
+        return
+    }
+
+    c.JSON(http.StatusOK, encashment)
+}
+
+// GetPayrollExport returns approved encashments per user for a year (Admin only)
+// Pass format=csv to download the export as a CSV file
+func (h *Handler) GetPayrollExport(c *gin.Context) {
+    role, _ := c.Get(constants.ContextUserRoleKey)
+    if role != models.UserRoleAdmin {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Admin access required"})
+        return
+    }
+
+    year, err := strconv.Atoi(c.DefaultQuery("year", strconv.Itoa(time.Now().Year())))
+    if err != nil || year < 1 || year > 9999 {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+        return
+    }
+
+    records, err := h.EncashmentService.GetPayrollExport(year)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build payroll export"})
+        return
+    }
+
+    if c.Query("format") != "csv" {
+        c.JSON(http.StatusOK, records)
+        return
+    }
+
+    // Build the file before responding so a write error can still be reported
+    var buf bytes.Buffer
+    w := csv.NewWriter(&buf)
+    rows := [][]string{{"user_id", "email", "period_start", "period_end", "days_encashed"}}
+    for _, r := range records {
+        rows = append(rows, []string{r.UserID, r.UserEmail, r.PeriodStart, r.PeriodEnd, strconv.Itoa(r.DaysEncashed)})
+    }
+    if err := w.WriteAll(rows); err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write payroll export"})
+        return
+    }
+
+    c.Header("Content-Disposition", "attachment; filename=encashments-"+strconv.Itoa(year)+".csv")
+    c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
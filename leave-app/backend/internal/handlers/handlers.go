@@ -1,12 +1,15 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"leave-app/internal/constants"
 	"leave-app/internal/db"
+	"leave-app/internal/metrics"
 	"leave-app/internal/models"
 	"leave-app/internal/service"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -17,6 +20,7 @@ type Handler struct {
     UserService *service.UserService
     LeaveService *service.LeaveService
     HolidayService *service.HolidayService
+    EncashmentService *service.EncashmentService
 }
 
 func NewHandler(database *db.Database) *Handler {
@@ -25,6 +29,7 @@ func NewHandler(database *db.Database) *Handler {
         UserService: service.NewUserService(database),
         LeaveService: service.NewLeaveService(database),
         HolidayService: service.NewHolidayService(database),
+        EncashmentService: service.NewEncashmentService(database),
     }
 }
 
@@ -51,6 +56,14 @@ func (h *Handler) GetCurrentUser(c *gin.Context) {
         }
     }
 
+    // Encashed days are no longer available as leave, so the app subtracts them from the annual balance
+    encashed, err := h.EncashmentService.GetEncashedDays(user.ID, time.Now().Year())
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get encashed days"})
+        return
+    }
+    user.EncashedAnnual = encashed
+
     c.JSON(http.StatusOK, user)
 }
 
@@ -150,8 +163,10 @@ func (h *Handler) GetLeaves(c *gin.Context) {
 }
 
 // CreateLeave creates a new leave request
+// Admins can submit a leave on behalf of another user via userId, optionally auto-approved
 func (h *Handler) CreateLeave(c *gin.Context) {
     email, _ := c.Get(constants.ContextUserEmailKey)
+    role, _ := c.Get(constants.ContextUserRoleKey)
 
     var req models.CreateLeaveRequest
     if err := c.ShouldBindJSON(&req); err != nil {
@@ -159,6 +174,13 @@ func (h *Handler) CreateLeave(c *gin.Context) {
         return
     }
 
+    // On-behalf fields are admin only
+    autoApprove := req.AutoApprove != nil && *req.AutoApprove
+    if (req.UserID != nil || autoApprove) && role != models.UserRoleAdmin {
+        c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can submit leaves on behalf of other users"})
+        return
+    }
+
     // Validate leave type
     if req.Type != models.LeaveTypeSick && req.Type != models.LeaveTypeAnnual && req.Type != models.LeaveTypeCasual {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid leave type"})
@@ -239,22 +261,49 @@ func (h *Handler) CreateLeave(c *gin.Context) {
         return
     }
 
+    // Resolve the subject user when submitting on behalf of someone else
+    subject := user
+    if req.UserID != nil && *req.UserID != user.ID {
+        subject, err = h.UserService.GetUserByID(*req.UserID)
+        if err != nil {
+            if err == sql.ErrNoRows {
+                c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+                return
+            }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+            return
+        }
+    }
+
+    // Auto-approval is for leave entered on someone else's behalf; an admin's own
+    // leave goes through the normal approval by another admin
+    if autoApprove && subject.ID == user.ID {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot auto-approve your own leave"})
+        return
+    }
+
+    status := models.LeaveStatusPending
+    if autoApprove {
+        status = models.LeaveStatusApproved
+    }
+
     // Create leave with days in a single transaction
     leave := &models.Leave{
-        UserID:         user.ID,
+        UserID:         subject.ID,
         Type:           req.Type,
         StartDate:      req.StartDate,
         EndDate:        req.EndDate,
         TotalLeaveDays: totalLeaveDays,
         Reason:         req.Reason,
-        Status:         models.LeaveStatusPending,
+        Status:         status,
         CreatedAt:      time.Now(),
     }
 
-    if err := h.LeaveService.CreateLeaveWithTransaction(leave, workingDays, isHalfDay, halfDayPeriod); err != nil {
+    if err := h.LeaveService.CreateLeaveWithTransaction(leave, workingDays, isHalfDay, halfDayPeriod, user.ID); err != nil {
         c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create leave"})
         return
     }
+    metrics.LeavesCreated.WithLabelValues(string(leave.Type), string(leave.Status)).Inc()
 
     // Get the created leave with days
     createdLeave, err := h.LeaveService.GetLeaveByID(leave.ID)
@@ -268,6 +317,7 @@ func (h *Handler) CreateLeave(c *gin.Context) {
 
 // UpdateLeave updates leave with role-based access control
 // Regular users can update dates/half-day for their own pending leaves
+// Admins can update dates/half-day for any pending or approved leave
 // Admins can update status/comment for any leave
 func (h *Handler) UpdateLeave(c *gin.Context) {
     leaveID := c.Param("id")
@@ -300,17 +350,35 @@ func (h *Handler) UpdateLeave(c *gin.Context) {
         }
 
         // Validate status
-        if *req.Status != models.LeaveStatusPending && *req.Status != models.LeaveStatusApproved && *req.Status != models.LeaveStatusRejected {
+        if *req.Status != models.LeaveStatusPending && *req.Status != models.LeaveStatusApproved && *req.Status != models.LeaveStatusRejected && *req.Status != models.LeaveStatusChangesRequested {
             c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
             return
         }
 
+        // The requester needs to know what to change
+        if *req.Status == models.LeaveStatusChangesRequested && (req.Comment == nil || strings.TrimSpace(*req.Comment) == "") {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "A comment is required when requesting changes"})
+            return
+        }
+
+        approver, err := h.UserService.GetUserByEmail(email.(string))
+        if err != nil {
+            c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+            return
+        }
+
         // Update status
-        if err := h.LeaveService.UpdateLeaveStatus(leaveID, *req.Status, req.Comment); err != nil {
+        if err := h.LeaveService.UpdateLeaveStatus(leaveID, *req.Status, req.Comment, approver.ID); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave status"})
             return
         }
 
+        // Record decisions taken on leaves that were awaiting one
+        if leave.Status == models.LeaveStatusPending && *req.Status != models.LeaveStatusPending {
+            metrics.LeavesDecided.WithLabelValues(string(leave.Type), string(*req.Status)).Inc()
+            metrics.DecisionLatency.WithLabelValues(string(leave.Type), string(*req.Status)).Observe(time.Since(leave.CreatedAt).Seconds())
+        }
+
         // Get and return updated leave
         updatedLeave, err := h.LeaveService.GetLeaveByID(leaveID)
         if err != nil {
@@ -335,8 +403,14 @@ func (h *Handler) UpdateLeave(c *gin.Context) {
         return
     }
 
-    // Only pending leaves can have dates modified
-    if leave.Status != models.LeaveStatusPending {
+    // Only pending leaves, or leaves sent back for changes, can have dates modified
+    // Admins editing on behalf of another user may also modify approved leaves
+    if leave.UserID != user.ID {
+        if !service.IsEditableOnBehalfStatus(leave.Status) {
+            c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending or approved leaves can have dates modified"})
+            return
+        }
+    } else if !service.IsEditableStatus(leave.Status) {
         c.JSON(http.StatusBadRequest, gin.H{"error": "Only pending leaves can have dates modified"})
         return
     }
@@ -408,13 +482,13 @@ func (h *Handler) UpdateLeave(c *gin.Context) {
                 totalDays = 0.5
             }
 
-            if err := h.LeaveService.UpdateSingleDayLeaveWithTransaction(leaveID, *req.StartDate, *req.EndDate, totalDays, workingDays, isHalfDay, halfDayPeriod); err != nil {
+            if err := h.LeaveService.UpdateSingleDayLeaveWithTransaction(leaveID, *req.StartDate, *req.EndDate, totalDays, workingDays, isHalfDay, halfDayPeriod, user.ID); err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave"})
                 return
             }
         } else {
             // Only half-day status changed, no date change
-            if err := h.LeaveService.UpdateSingleDayLeaveHalfDay(leaveID, isHalfDay, halfDayPeriod); err != nil {
+            if err := h.LeaveService.UpdateSingleDayLeaveHalfDay(leaveID, isHalfDay, halfDayPeriod, user.ID); err != nil {
                 c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update half-day status"})
                 return
             }
@@ -468,7 +542,7 @@ func (h *Handler) UpdateLeave(c *gin.Context) {
         totalDays := float64(len(workingDays))
 
         // Replace leave days
-        if err := h.LeaveService.ReplaceLeaveDaysAndUpdateLeave(leaveID, newStartDate, newEndDate, totalDays, workingDays); err != nil {
+        if err := h.LeaveService.ReplaceLeaveDaysAndUpdateLeave(leaveID, newStartDate, newEndDate, totalDays, workingDays, user.ID); err != nil {
             c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update leave"})
             return
         }
@@ -515,6 +589,55 @@ func (h *Handler) GetLeaveByID(c *gin.Context) {
     c.JSON(http.StatusOK, leave)
 }
 
+// AddLeaveComment posts a comment to a leave's thread
+// The requester can comment on their own leaves; admins can comment on any leave
+func (h *Handler) AddLeaveComment(c *gin.Context) {
+    leaveID := c.Param("id")
+    email, _ := c.Get(constants.ContextUserEmailKey)
+    role, _ := c.Get(constants.ContextUserRoleKey)
+
+    var req models.CreateLeaveCommentRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+        return
+    }
+
+    comment := strings.TrimSpace(req.Comment)
+    if comment == "" {
+        c.JSON(http.StatusBadRequest, gin.H{"error": "Comment cannot be empty"})
+        return
+    }
+
+    leave, err := h.LeaveService.GetLeaveByID(leaveID)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            c.JSON(http.StatusNotFound, gin.H{"error": "Leave not found"})
+            return
+        }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get leave"})
+        return
+    }
+
+    user, err := h.UserService.GetUserByEmail(email.(string))
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get user"})
+        return
+    }
+
+    if leave.UserID != user.ID && role != models.UserRoleAdmin {
+        c.JSON(http.StatusForbidden, gin.H{"error": "You can only comment on your own leaves"})
+        return
+    }
+
+    created, err := h.LeaveService.AddComment(leaveID, user.ID, comment)
+    if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
+        return
+    }
+
+    c.JSON(http.StatusCreated, created)
+}
+
 // DeleteLeave deletes a leave request
 func (h *Handler) DeleteLeave(c *gin.Context) {
     leaveID := c.Param("id")
@@ -542,8 +665,8 @@ func (h *Handler) DeleteLeave(c *gin.Context) {
         return
     }
 
-    // Only allow deletion of pending leaves
-    if leave.Status != models.LeaveStatusPending {
+    // Only allow deletion of pending leaves (including those sent back for changes)
+    if !service.IsEditableStatus(leave.Status) {
         c.JSON(http.StatusForbidden, gin.H{"error": "Only pending leaves can be deleted"})
         return
     }
@@ -556,6 +679,19 @@ func (h *Handler) DeleteLeave(c *gin.Context) {
     c.Status(http.StatusNoContent)
 }
 
+// Readyz reports whether the service can reach its database
+func (h *Handler) Readyz(c *gin.Context) {
+    ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+    defer cancel()
+
+    if err := h.DB.Ping(ctx); err != nil {
+        c.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "error": "Database unreachable"})
+        return
+    }
+
+    c.JSON(http.StatusOK, gin.H{"status": "ready"})
+}
+
 // GetHolidays returns all public holidays
 func (h *Handler) GetHolidays(c *gin.Context) {
     holidays, err := h.HolidayService.GetAllHolidays()
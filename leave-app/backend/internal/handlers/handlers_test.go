@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+
+	"leave-app/internal/constants"
+	"leave-app/internal/db"
+	"leave-app/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+)
+
+func TestGetCurrentUserIncludesEncashedDays(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	defer conn.Close()
+
+	h := NewHandler(&db.Database{Conn: conn})
+	r := gin.New()
+	r.GET("/users/me", func(c *gin.Context) {
+		c.Set(constants.ContextUserEmailKey, "alice@example.com")
+	}, h.GetCurrentUser)
+
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE email = ?")).WithArgs("alice@example.com").WillReturnRows(userRow(true))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(days), 0) FROM leave_encashments WHERE user_id = ? AND year = ? AND status = ?")).
+		WithArgs(scimTestUserID, time.Now().Year(), models.LeaveStatusApproved).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(5))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/users/me", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var user models.User
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatal(err)
+	}
+	// The allowance stays the yearly entitlement; the encashed days are reported beside it
+	if user.Allowances.Annual != 20 || user.EncashedAnnual != 5 {
+		t.Fatalf("annual allowance %d, encashed %d; want 20 and 5", user.Allowances.Annual, user.EncashedAnnual)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,427 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"leave-app/internal/models"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+const (
+    scimContentType     = "application/scim+json"
+    scimDefaultCount    = 100
+    scimMaxCount        = 200
+    mysqlDuplicateEntry = 1062
+)
+
+// scimUserNameFilter matches the only filter our IdP sends: userName eq "value"
+var scimUserNameFilter = regexp.MustCompile(`^\s*userName\s+eq\s+"([^"]*)"\s*$`)
+
+// ListSCIMUsers returns provisioned users, optionally filtered by userName
+func (h *Handler) ListSCIMUsers(c *gin.Context) {
+    startIndex, err := strconv.Atoi(c.DefaultQuery("startIndex", "1"))
+    if err != nil || startIndex < 1 {
+        startIndex = 1
+    }
+    count, err := strconv.Atoi(c.DefaultQuery("count", strconv.Itoa(scimDefaultCount)))
+    if err != nil || count < 0 {
+        count = scimDefaultCount
+    }
+    if count > scimMaxCount {
+        count = scimMaxCount
+    }
+
+    email := ""
+    if filter := c.Query("filter"); filter != "" {
+        match := scimUserNameFilter.FindStringSubmatch(filter)
+        if match == nil {
+            scimError(c, http.StatusBadRequest, "invalidFilter", "Only 'userName eq' filters are supported")
+            return
+        }
+        email = match[1]
+    }
+
+    users, total, err := h.UserService.ListUsers(email, startIndex, count)
+    if err != nil {
+        scimError(c, http.StatusInternalServerError, "", "Failed to list users")
+        return
+    }
+
+    resources := make([]models.SCIMUser, 0, len(users))
+    for i := range users {
+        resources = append(resources, toSCIMUser(&users[i]))
+    }
+
+    scimJSON(c, http.StatusOK, models.SCIMListResponse{
+        Schemas:      []string{models.SCIMSchemaListResponse},
+        TotalResults: total,
+        StartIndex:   startIndex,
+        ItemsPerPage: len(resources),
+        Resources:    resources,
+    })
+}
+
+// GetSCIMUser returns a single provisioned user
+func (h *Handler) GetSCIMUser(c *gin.Context) {
+    user, ok := h.loadSCIMUser(c)
+    if !ok {
+        return
+    }
+
+    scimJSON(c, http.StatusOK, toSCIMUser(user))
+}
+
+// CreateSCIMUser provisions a user ahead of their first login
+func (h *Handler) CreateSCIMUser(c *gin.Context) {
+    var req models.SCIMUser
+    if err := c.ShouldBindJSON(&req); err != nil {
+        scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+        return
+    }
+
+    user := &models.User{Active: true}
+    if err := applySCIMUser(user, req); err != nil {
+        scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+        return
+    }
+
+    created, err := h.UserService.ProvisionUser(user)
+    if err != nil {
+        if isDuplicateEntry(err) {
+            scimError(c, http.StatusConflict, "uniqueness", "A user with this userName or externalId already exists")
+            return
+        }
+        scimError(c, http.StatusInternalServerError, "", "Failed to create user")
+        return
+    }
+
+    scimJSON(c, http.StatusCreated, toSCIMUser(created))
+}
+
+// ReplaceSCIMUser replaces a user's directory attributes (SCIM PUT)
+func (h *Handler) ReplaceSCIMUser(c *gin.Context) {
+    user, ok := h.loadSCIMUser(c)
+    if !ok {
+        return
+    }
+
+    var req models.SCIMUser
+    if err := c.ShouldBindJSON(&req); err != nil {
+        scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+        return
+    }
+
+    if err := applySCIMUser(user, req); err != nil {
+        scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+        return
+    }
+
+    h.saveSCIMUser(c, user)
+}
+
+// PatchSCIMUser applies SCIM PatchOp operations to a user, e.g. to deactivate them
+func (h *Handler) PatchSCIMUser(c *gin.Context) {
+    user, ok := h.loadSCIMUser(c)
+    if !ok {
+        return
+    }
+
+    var req models.SCIMPatchRequest
+    if err := c.ShouldBindJSON(&req); err != nil {
+        scimError(c, http.StatusBadRequest, "invalidSyntax", "Invalid request body")
+        return
+    }
+
+    current := toSCIMUser(user)
+    for _, op := range req.Operations {
+        if err := applySCIMPatch(&current, op); err != nil {
+            scimError(c, http.StatusBadRequest, "invalidPath", err.Error())
+            return
+        }
+    }
+
+    if err := applySCIMUser(user, current); err != nil {
+        scimError(c, http.StatusBadRequest, "invalidValue", err.Error())
+        return
+    }
+
+    h.saveSCIMUser(c, user)
+}
+
+// DeleteSCIMUser deprovisions a user. The account is deactivated rather than
+// removed so that leave history is preserved, but every later SCIM operation on it
+// returns 404. IdPs that may reactivate a user should PATCH active to false instead.
+func (h *Handler) DeleteSCIMUser(c *gin.Context) {
+    if err := h.UserService.DeprovisionUser(c.Param("id")); err != nil {
+        if err == sql.ErrNoRows {
+            scimError(c, http.StatusNotFound, "", "User not found")
+            return
+        }
+        scimError(c, http.StatusInternalServerError, "", "Failed to deactivate user")
+        return
+    }
+
+    c.Status(http.StatusNoContent)
+}
+
+// loadSCIMUser loads the user named in the path; users deleted through SCIM are not found
+func (h *Handler) loadSCIMUser(c *gin.Context) (*models.User, bool) {
+    user, err := h.UserService.GetUserByID(c.Param("id"))
+    if err != nil && err != sql.ErrNoRows {
+        scimError(c, http.StatusInternalServerError, "", "Failed to get user")
+        return nil, false
+    }
+    if err == sql.ErrNoRows || user.DeprovisionedAt != nil {
+        scimError(c, http.StatusNotFound, "", "User not found")
+        return nil, false
+    }
+    return user, true
+}
+
+func (h *Handler) saveSCIMUser(c *gin.Context, user *models.User) {
+    updated, err := h.UserService.UpdateProvisionedUser(user)
+    if err != nil {
+        if err == sql.ErrNoRows {
+            // Deleted since it was loaded
+            scimError(c, http.StatusNotFound, "", "User not found")
+            return
+        }
+        if isDuplicateEntry(err) {
+            scimError(c, http.StatusConflict, "uniqueness", "A user with this userName or externalId already exists")
+            return
+        }
+        scimError(c, http.StatusInternalServerError, "", "Failed to update user")
+        return
+    }
+
+    scimJSON(c, http.StatusOK, toSCIMUser(updated))
+}
+
+// toSCIMUser maps a user to its SCIM representation; userName is the login email
+func toSCIMUser(user *models.User) models.SCIMUser {
+    active := user.Active
+    out := models.SCIMUser{
+        Schemas:  []string{models.SCIMSchemaUser, models.SCIMSchemaEnterpriseUser, models.SCIMSchemaLeaveAppUser},
+        ID:       user.ID,
+        UserName: user.Email,
+        Active:   &active,
+        Emails:   []models.SCIMEmail{{Value: user.Email, Type: "work", Primary: true}},
+        Meta: &models.SCIMMeta{
+            ResourceType: "User",
+            Created:      user.CreatedAt,
+            Location:     "/scim/v2/Users/" + user.ID,
+        },
+    }
+    if user.ExternalID != nil {
+        out.ExternalID = *user.ExternalID
+    }
+    if user.DisplayName != nil {
+        out.DisplayName = *user.DisplayName
+    }
+    if user.Department != nil || user.Manager != nil {
+        out.Enterprise = &models.SCIMEnterpriseUser{}
+        if user.Department != nil {
+            out.Enterprise.Department = *user.Department
+        }
+        if user.Manager != nil {
+            out.Enterprise.Manager = &models.SCIMManager{Value: *user.Manager}
+        }
+    }
+    if user.JoinDate != nil {
+        out.LeaveApp = &models.SCIMLeaveAppUser{JoinDate: *user.JoinDate}
+    }
+    return out
+}
+
+// applySCIMUser copies the SCIM attributes onto user, replacing what was there
+// An omitted active keeps the user's current state, so a PUT never reactivates by accident
+func applySCIMUser(user *models.User, in models.SCIMUser) error {
+    email := strings.TrimSpace(in.UserName)
+    for _, e := range in.Emails {
+        if e.Primary && strings.TrimSpace(e.Value) != "" {
+            email = strings.TrimSpace(e.Value)
+            break
+        }
+    }
+    if email == "" {
+        return fmt.Errorf("userName is required")
+    }
+
+    user.Email = email
+    if in.Active != nil {
+        user.Active = *in.Active
+    }
+    user.ExternalID = optionalString(in.ExternalID)
+    user.DisplayName = optionalString(in.DisplayName)
+    user.Department = nil
+    user.Manager = nil
+    user.JoinDate = nil
+
+    if in.Enterprise != nil {
+        user.Department = optionalString(in.Enterprise.Department)
+        if in.Enterprise.Manager != nil {
+            user.Manager = optionalString(in.Enterprise.Manager.Value)
+        }
+    }
+    if in.LeaveApp != nil && in.LeaveApp.JoinDate != "" {
+        if _, err := time.Parse("2006-01-02", in.LeaveApp.JoinDate); err != nil {
+            return fmt.Errorf("joinDate must be in YYYY-MM-DD format")
+        }
+        user.JoinDate = optionalString(in.LeaveApp.JoinDate)
+    }
+    return nil
+}
+
+// applySCIMPatch applies one PatchOp operation to a SCIM user
+func applySCIMPatch(user *models.SCIMUser, op models.SCIMPatchOperation) error {
+    switch strings.ToLower(op.Op) {
+    case "add", "replace":
+        if op.Path == "" {
+            values, ok := op.Value.(map[string]interface{})
+            if !ok {
+                return fmt.Errorf("value must be an object when path is omitted")
+            }
+            for path, value := range values {
+                if err := setSCIMAttribute(user, path, value); err != nil {
+                    return err
+                }
+            }
+            return nil
+        }
+        return setSCIMAttribute(user, op.Path, op.Value)
+    case "remove":
+        if op.Path == "" {
+            return fmt.Errorf("path is required for remove")
+        }
+        // Removing active would read as false; deactivation must be explicit
+        if op.Path == "active" {
+            return fmt.Errorf("active cannot be removed")
+        }
+        return setSCIMAttribute(user, op.Path, nil)
+    default:
+        return fmt.Errorf("unsupported op %q", op.Op)
+    }
+}
+
+// setSCIMAttribute sets a single attribute by SCIM path; a nil value clears it
+func setSCIMAttribute(user *models.SCIMUser, path string, value interface{}) error {
+    enterprisePrefix := models.SCIMSchemaEnterpriseUser + ":"
+    leaveAppPrefix := models.SCIMSchemaLeaveAppUser + ":"
+
+    switch {
+    case path == models.SCIMSchemaEnterpriseUser || path == models.SCIMSchemaLeaveAppUser:
+        // Extension objects are patched attribute by attribute
+        values, ok := value.(map[string]interface{})
+        if !ok {
+            return fmt.Errorf("%s must be an object", path)
+        }
+        for attr, v := range values {
+            if err := setSCIMAttribute(user, path+":"+attr, v); err != nil {
+                return err
+            }
+        }
+        return nil
+    case strings.HasPrefix(path, enterprisePrefix):
+        if user.Enterprise == nil {
+            user.Enterprise = &models.SCIMEnterpriseUser{}
+        }
+        switch strings.TrimPrefix(path, enterprisePrefix) {
+        case "department":
+            user.Enterprise.Department = scimString(value)
+        case "manager", "manager.value":
+            // Managers arrive either as {"value": "..."} or as a bare string
+            if m, ok := value.(map[string]interface{}); ok {
+                value = m["value"]
+            }
+            user.Enterprise.Manager = &models.SCIMManager{Value: scimString(value)}
+        default:
+            return fmt.Errorf("unsupported path %q", path)
+        }
+        return nil
+    case strings.HasPrefix(path, leaveAppPrefix):
+        if strings.TrimPrefix(path, leaveAppPrefix) != "joinDate" {
+            return fmt.Errorf("unsupported path %q", path)
+        }
+        user.LeaveApp = &models.SCIMLeaveAppUser{JoinDate: scimString(value)}
+        return nil
+    }
+
+    switch path {
+    case "active":
+        active, err := scimBool(value)
+        if err != nil {
+            return err
+        }
+        user.Active = &active
+    case "userName":
+        user.UserName = scimString(value)
+        user.Emails = nil
+    case "displayName":
+        user.DisplayName = scimString(value)
+    case "externalId":
+        user.ExternalID = scimString(value)
+    default:
+        return fmt.Errorf("unsupported path %q", path)
+    }
+    return nil
+}
+
+func scimString(value interface{}) string {
+    if value == nil {
+        return ""
+    }
+    if s, ok := value.(string); ok {
+        return s
+    }
+    return fmt.Sprint(value)
+}
+
+// scimBool accepts JSON booleans as well as the "True"/"False" strings some IdPs send
+func scimBool(value interface{}) (bool, error) {
+    switch v := value.(type) {
+    case bool:
+        return v, nil
+    case string:
+        b, err := strconv.ParseBool(strings.ToLower(v))
+        if err != nil {
+            return false, fmt.Errorf("active must be a boolean")
+        }
+        return b, nil
+    default:
+        return false, fmt.Errorf("active must be a boolean")
+    }
+}
+
+func optionalString(s string) *string {
+    s = strings.TrimSpace(s)
+    if s == "" {
+        return nil
+    }
+    return &s
+}
+
+func isDuplicateEntry(err error) bool {
+    mysqlErr, ok := err.(*mysql.MySQLError)
+    return ok && mysqlErr.Number == mysqlDuplicateEntry
+}
+
+func scimJSON(c *gin.Context, status int, body interface{}) {
+    c.Header("Content-Type", scimContentType)
+    c.JSON(status, body)
+}
+
+func scimError(c *gin.Context, status int, scimType, detail string) {
+    scimJSON(c, status, models.SCIMError{
+        Schemas:  []string{models.SCIMSchemaError},
+        Status:   strconv.Itoa(status),
+        ScimType: scimType,
+        Detail:   detail,
+    })
+}
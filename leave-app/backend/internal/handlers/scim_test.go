@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"leave-app/internal/db"
+	"leave-app/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gin-gonic/gin"
+	"github.com/go-sql-driver/mysql"
+)
+
+const scimTestUserID = "u-1"
+
+// newSCIMTestServer routes the SCIM endpoints to a handler backed by a mock database
+func newSCIMTestServer(t *testing.T) (*gin.Engine, sqlmock.Sqlmock) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+
+	h := NewHandler(&db.Database{Conn: conn})
+	r := gin.New()
+	scim := r.Group("/scim/v2")
+	scim.POST("/Users", h.CreateSCIMUser)
+	scim.GET("/Users/:id", h.GetSCIMUser)
+	scim.PUT("/Users/:id", h.ReplaceSCIMUser)
+	scim.PATCH("/Users/:id", h.PatchSCIMUser)
+	scim.DELETE("/Users/:id", h.DeleteSCIMUser)
+	return r, mock
+}
+
+// userRow returns a users row in the column order the user service selects
+func userRow(active bool) *sqlmock.Rows {
+	return userRowDeprovisioned(active, nil)
+}
+
+// userRowDeprovisioned is userRow for a user deleted through SCIM at deprovisionedAt
+func userRowDeprovisioned(active bool, deprovisionedAt interface{}) *sqlmock.Rows {
+	columns := []string{"id", "email", "role", "sick_allowance", "annual_allowance", "casual_allowance", "active",
+		"external_id", "display_name", "department", "manager", "join_date", "created_at", "deprovisioned_at"}
+	return sqlmock.NewRows(columns).AddRow(
+		scimTestUserID, "alice@example.com", "user", 10, 20, 5, active,
+		"ext-1", "Alice", "Engineering", "bob@example.com", nil, time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC), deprovisionedAt,
+	)
+}
+
+func expectUserByID(mock sqlmock.Sqlmock, active bool) {
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE id = ?")).WithArgs(scimTestUserID).WillReturnRows(userRow(active))
+}
+
+func expectWithdrawLeaves(mock sqlmock.Sqlmock) {
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE leaves SET status = ? WHERE user_id = ? AND status IN (?, ?)")).
+		WithArgs(models.LeaveStatusWithdrawn, scimTestUserID, models.LeaveStatusPending, models.LeaveStatusChangesRequested).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+}
+
+func doSCIM(r *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/scim+json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func patchBody(ops string) string {
+	return `{"schemas":["` + models.SCIMSchemaPatchOp + `"],"Operations":[` + ops + `]}`
+}
+
+func TestPatchSCIMUserDeactivationWithdrawsLeaves(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	expectUserByID(mock, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email = ?, active = ?")).
+		WithArgs("alice@example.com", false, "ext-1", "Alice", "Engineering", "bob@example.com", nil, scimTestUserID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectWithdrawLeaves(mock)
+	mock.ExpectCommit()
+	expectUserByID(mock, false)
+
+	// Some IdPs send booleans as strings
+	w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/"+scimTestUserID, patchBody(`{"op":"replace","path":"active","value":"False"}`))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+	var user models.SCIMUser
+	if err := json.Unmarshal(w.Body.Bytes(), &user); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if user.Active == nil || *user.Active {
+		t.Fatalf("active = %v, want false", user.Active)
+	}
+}
+
+func TestPatchSCIMUserCannotRemoveActive(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	// The user is loaded, but nothing is written
+	expectUserByID(mock, true)
+	w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/"+scimTestUserID, patchBody(`{"op":"remove","path":"active"}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400: %s", w.Code, w.Body)
+	}
+
+	expectUserByID(mock, true)
+	w = doSCIM(r, http.MethodPatch, "/scim/v2/Users/"+scimTestUserID, patchBody(`{"op":"replace","path":"active","value":null}`))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("null active: status %d, want 400: %s", w.Code, w.Body)
+	}
+}
+
+func TestPatchSCIMUserKeepsLeavesOfActiveUsers(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	expectUserByID(mock, true)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email = ?, active = ?")).
+		WithArgs("alice@example.com", true, "ext-1", "Alice", "Sales", "bob@example.com", nil, scimTestUserID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	expectUserByID(mock, true)
+
+	body := patchBody(`{"op":"replace","path":"` + models.SCIMSchemaEnterpriseUser + `:department","value":"Sales"}`)
+	if w := doSCIM(r, http.MethodPatch, "/scim/v2/Users/"+scimTestUserID, body); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestDeleteSCIMUserDeactivatesAndWithdrawsLeaves(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM users WHERE id = ? AND deprovisioned_at IS NULL FOR UPDATE")).
+		WithArgs(scimTestUserID).WillReturnRows(sqlmock.NewRows([]string{"1"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET active = FALSE, deprovisioned_at = NOW() WHERE id = ?")).
+		WithArgs(scimTestUserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	expectWithdrawLeaves(mock)
+	mock.ExpectCommit()
+
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/"+scimTestUserID, ""); w.Code != http.StatusNoContent {
+		t.Fatalf("status %d, want 204: %s", w.Code, w.Body)
+	}
+}
+
+func TestDeleteSCIMUserNotFound(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM users WHERE id = ? AND deprovisioned_at IS NULL FOR UPDATE")).
+		WithArgs("missing").WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if w := doSCIM(r, http.MethodDelete, "/scim/v2/Users/missing", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("status %d, want 404: %s", w.Code, w.Body)
+	}
+}
+
+func TestDeletedSCIMUserIsNotFound(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+	path := "/scim/v2/Users/" + scimTestUserID
+	deletedAt := time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)
+
+	// The row is kept for leave history, but nothing is written through SCIM
+	for _, tc := range []struct{ method, body string }{
+		{http.MethodGet, ""},
+		{http.MethodPut, `{"schemas":["` + models.SCIMSchemaUser + `"],"userName":"alice@example.com","active":true}`},
+		{http.MethodPatch, patchBody(`{"op":"replace","path":"active","value":true}`)},
+	} {
+		mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE id = ?")).WithArgs(scimTestUserID).
+			WillReturnRows(userRowDeprovisioned(false, deletedAt))
+		if w := doSCIM(r, tc.method, path, tc.body); w.Code != http.StatusNotFound {
+			t.Fatalf("%s: status %d, want 404: %s", tc.method, w.Code, w.Body)
+		}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT 1 FROM users WHERE id = ? AND deprovisioned_at IS NULL FOR UPDATE")).
+		WithArgs(scimTestUserID).WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+	if w := doSCIM(r, http.MethodDelete, path, ""); w.Code != http.StatusNotFound {
+		t.Fatalf("DELETE: status %d, want 404: %s", w.Code, w.Body)
+	}
+}
+
+func TestReplaceSCIMUserKeepsActiveWhenOmitted(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	expectUserByID(mock, false)
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE users SET email = ?, active = ?")).
+		WithArgs("alice@example.com", false, "ext-1", "Alice", nil, nil, nil, scimTestUserID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	expectWithdrawLeaves(mock)
+	mock.ExpectCommit()
+	expectUserByID(mock, false)
+
+	body := `{"schemas":["` + models.SCIMSchemaUser + `"],"userName":"alice@example.com","externalId":"ext-1","displayName":"Alice"}`
+	if w := doSCIM(r, http.MethodPut, "/scim/v2/Users/"+scimTestUserID, body); w.Code != http.StatusOK {
+		t.Fatalf("status %d: %s", w.Code, w.Body)
+	}
+}
+
+func TestCreateSCIMUser(t *testing.T) {
+	r, mock := newSCIMTestServer(t)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users")).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM users WHERE id = ?")).WillReturnRows(userRow(true))
+	body := `{"schemas":["` + models.SCIMSchemaUser + `"],"userName":"alice@example.com","externalId":"ext-1"}`
+	if w := doSCIM(r, http.MethodPost, "/scim/v2/Users", body); w.Code != http.StatusCreated {
+		t.Fatalf("status %d, want 201: %s", w.Code, w.Body)
+	}
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO users")).WillReturnError(&mysql.MySQLError{Number: mysqlDuplicateEntry})
+	if w := doSCIM(r, http.MethodPost, "/scim/v2/Users", body); w.Code != http.StatusConflict {
+		t.Fatalf("duplicate: status %d, want 409: %s", w.Code, w.Body)
+	}
+
+	if w := doSCIM(r, http.MethodPost, "/scim/v2/Users", `{"schemas":["`+models.SCIMSchemaUser+`"]}`); w.Code != http.StatusBadRequest {
+		t.Fatalf("missing userName: status %d, want 400: %s", w.Code, w.Body)
+	}
+}
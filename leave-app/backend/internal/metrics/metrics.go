@@ -0,0 +1,146 @@
+// internal/metrics/metrics.go
+package metrics
+
+import (
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "leave_app"
+
+var (
+	// HTTPRequestDuration tracks request latency per route
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// LeavesCreated counts leaves created, by type and initial status
+	LeavesCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leaves_created_total",
+		Help:      "Leaves created by type and initial status.",
+	}, []string{"type", "status"})
+
+	// LeavesDecided counts approver decisions, by leave type and resulting status
+	LeavesDecided = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "leaves_decided_total",
+		Help:      "Leave status decisions by type and resulting status.",
+	}, []string{"type", "status"})
+
+	// DecisionLatency tracks the time from leave submission to an approver decision
+	DecisionLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "leave_decision_latency_seconds",
+		Help:      "Time from leave submission to approval, rejection or change request.",
+		// 1 minute up to ~2 weeks
+		Buckets: prometheus.ExponentialBuckets(60, 3, 10),
+	}, []string{"type", "status"})
+
+	// DBPingFailures counts failed background database pings
+	DBPingFailures = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_ping_failures_total",
+		Help:      "Failed background database pings.",
+	})
+
+	// DBReconnects counts database reconnect attempts, by result
+	DBReconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_reconnects_total",
+		Help:      "Database reconnect attempts by result.",
+	}, []string{"result"})
+)
+
+// GinMiddleware records request latency labelled by the matched route template
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		HTTPRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Observe(time.Since(start).Seconds())
+	}
+}
+
+// RegisterDBStats exposes connection pool statistics. stats is called on every
+// scrape so that it follows the current connection after a reconnect.
+func RegisterDBStats(stats func() sql.DBStats) {
+	prometheus.MustRegister(&dbStatsCollector{stats: stats})
+}
+
+// RegisterPendingLeaves exposes the number of pending leaves by type. count is
+// called on every scrape.
+func RegisterPendingLeaves(count func() (map[string]int, error)) {
+	prometheus.MustRegister(&pendingLeavesCollector{count: count})
+}
+
+var (
+	dbOpenDesc         = prometheus.NewDesc(namespace+"_db_open_connections", "Established connections, both in use and idle.", nil, nil)
+	dbInUseDesc        = prometheus.NewDesc(namespace+"_db_in_use_connections", "Connections currently in use.", nil, nil)
+	dbIdleDesc         = prometheus.NewDesc(namespace+"_db_idle_connections", "Idle connections.", nil, nil)
+	dbMaxOpenDesc      = prometheus.NewDesc(namespace+"_db_max_open_connections", "Maximum number of open connections.", nil, nil)
+	dbWaitCountDesc    = prometheus.NewDesc(namespace+"_db_wait_count_total", "Connections waited for.", nil, nil)
+	dbWaitDurationDesc = prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total", "Time blocked waiting for a new connection.", nil, nil)
+	dbMaxIdleDesc      = prometheus.NewDesc(namespace+"_db_max_idle_closed_total", "Connections closed due to SetMaxIdleConns.", nil, nil)
+	dbMaxLifetimeDesc  = prometheus.NewDesc(namespace+"_db_max_lifetime_closed_total", "Connections closed due to SetConnMaxLifetime.", nil, nil)
+	pendingLeavesDesc  = prometheus.NewDesc(namespace+"_pending_leaves", "Leaves awaiting a decision, by type.", []string{"type"}, nil)
+)
+
+type dbStatsCollector struct {
+	stats func() sql.DBStats
+}
+
+func (c *dbStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbOpenDesc
+	ch <- dbInUseDesc
+	ch <- dbIdleDesc
+	ch <- dbMaxOpenDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+	ch <- dbMaxIdleDesc
+	ch <- dbMaxLifetimeDesc
+}
+
+func (c *dbStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.stats()
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(s.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(s.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(s.Idle))
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(s.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(s.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, s.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(dbMaxIdleDesc, prometheus.CounterValue, float64(s.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(dbMaxLifetimeDesc, prometheus.CounterValue, float64(s.MaxLifetimeClosed))
+}
+
+type pendingLeavesCollector struct {
+	count func() (map[string]int, error)
+}
+
+func (c *pendingLeavesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- pendingLeavesDesc
+}
+
+func (c *pendingLeavesCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := c.count()
+	if err != nil {
+		log.Printf("metrics: failed to count pending leaves: %v", err)
+		return
+	}
+	for leaveType, n := range counts {
+		ch <- prometheus.MustNewConstMetric(pendingLeavesDesc, prometheus.GaugeValue, float64(n), leaveType)
+	}
+}
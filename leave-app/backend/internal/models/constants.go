@@ -12,12 +12,20 @@ const (
 	DefaultCasualAllowance = 5
 )
 
+// Encashment policy for unused annual leave
+const (
+	MaxEncashableAnnualDays = 5 // maximum annual leave days a user can encash per year
+	MaxEncashmentYearsBack  = 1 // how many past years unused leave can still be encashed for
+)
+
 type LeaveStatus string
 // Leave status constants
 const (
-	LeaveStatusPending  LeaveStatus = "pending"
-	LeaveStatusApproved LeaveStatus = "approved"
-	LeaveStatusRejected LeaveStatus = "rejected"
+	LeaveStatusPending          LeaveStatus = "pending"
+	LeaveStatusApproved         LeaveStatus = "approved"
+	LeaveStatusRejected         LeaveStatus = "rejected"
+	LeaveStatusChangesRequested LeaveStatus = "changes_requested" // sent back to the requester for editing
+	LeaveStatusWithdrawn        LeaveStatus = "withdrawn"         // withdrawn when the requester is deprovisioned
 )
 
 type LeaveType string
@@ -29,6 +37,14 @@ const (
 	LeaveTypeCasual LeaveType = "casual"
 )
 
+type OnBehalfAction string
+
+// On-behalf action constants, recorded when a privileged user acts on another user's leave
+const (
+	OnBehalfActionCreate OnBehalfAction = "create"
+	OnBehalfActionUpdate OnBehalfAction = "update"
+)
+
 type UserRole string	
 
 // User role constants
@@ -3,11 +3,21 @@ package models
 import "time"
 
 type User struct {
-    ID         string     `json:"id"`
-    Email      string     `json:"email"`
-    Role       UserRole   `json:"role"`
-    Allowances Allowances `json:"allowances"`
-	CreatedAt  time.Time  `json:"createdAt"`
+    ID          string     `json:"id"`
+    Email       string     `json:"email"`
+    Role        UserRole   `json:"role"`
+    Allowances  Allowances `json:"allowances"`
+    Active      bool       `json:"active"`
+    ExternalID  *string    `json:"externalId,omitempty"`
+    DisplayName *string    `json:"displayName,omitempty"`
+    Department  *string    `json:"department,omitempty"`
+    Manager     *string    `json:"manager,omitempty"`
+    JoinDate    *string    `json:"joinDate,omitempty"`
+	CreatedAt   time.Time  `json:"createdAt"`
+    // Set when the user was deleted through SCIM; SCIM then treats them as gone
+    DeprovisionedAt *time.Time `json:"-"`
+    // Approved annual days encashed this year, which come off the annual balance; only set by /users/me
+    EncashedAnnual int `json:"encashedAnnual"`
 }
 
 type Allowances struct {
@@ -17,18 +27,29 @@ type Allowances struct {
 }
 
 type Leave struct {
-    ID              string      `json:"id"`
-    UserID          string      `json:"userId"`
-    UserEmail       string      `json:"userEmail,omitempty"`
-    Type            LeaveType   `json:"type"`
-    StartDate       string      `json:"startDate"`
-    EndDate         string      `json:"endDate"`
-    TotalLeaveDays  float64     `json:"totalLeaveDays"`
-    Reason          string      `json:"reason"`
-    Status          LeaveStatus `json:"status"`
-    ApproverComment *string     `json:"approverComment"`
-    CreatedAt       time.Time   `json:"createdAt"`
-    Days            []LeaveDay  `json:"days"`
+    ID              string         `json:"id"`
+    UserID          string         `json:"userId"`
+    UserEmail       string         `json:"userEmail,omitempty"`
+    Type            LeaveType      `json:"type"`
+    StartDate       string         `json:"startDate"`
+    EndDate         string         `json:"endDate"`
+    TotalLeaveDays  float64        `json:"totalLeaveDays"`
+    Reason          string         `json:"reason"`
+    Status          LeaveStatus    `json:"status"`
+    ApproverComment *string        `json:"approverComment"`
+    CreatedAt       time.Time      `json:"createdAt"`
+    Days            []LeaveDay     `json:"days"`
+    Comments        []LeaveComment `json:"comments,omitempty"`
+}
+
+// LeaveComment is a single message in the conversation thread on a leave
+type LeaveComment struct {
+    ID        string    `json:"id"`
+    LeaveID   string    `json:"leaveId"`
+    UserID    string    `json:"userId"`
+    UserEmail string    `json:"userEmail"`
+    Comment   string    `json:"comment"`
+    CreatedAt time.Time `json:"createdAt"`
 }
 
 type LeaveDay struct {
@@ -46,11 +67,17 @@ type CreateLeaveRequest struct {
     Reason         string         `json:"reason" binding:"required"`
     IsHalfDay      *bool          `json:"isHalfDay"`
     HalfDayPeriod  *HalfDayPeriod `json:"halfDayPeriod"`
+
+    // On-behalf fields (admin only): submit for another user, optionally pre-approved
+    UserID         *string        `json:"userId"`
+    AutoApprove    *bool          `json:"autoApprove"`
 }
 
 // UpdateLeaveRequest represents a unified request to update leave details
 // Regular users can update dates/half-day fields for their own pending leaves
-// Admins can update status/comment fields for any leave
+// Admins can update dates/half-day fields for any pending or approved leave
+// Admins can update status/comment fields for any leave; a comment given with
+// a status change is also appended to the leave's comment thread
 type UpdateLeaveRequest struct {
     // Date fields (user can update for their own pending leaves)
     StartDate     *string        `json:"startDate"`
@@ -63,6 +90,11 @@ type UpdateLeaveRequest struct {
     Comment       *string      `json:"comment"`
 }
 
+// CreateLeaveCommentRequest represents a new comment posted to a leave thread
+type CreateLeaveCommentRequest struct {
+    Comment string `json:"comment" binding:"required"`
+}
+
 // UpdateUserRoleRequest represents the request to update user role
 type UpdateUserRoleRequest struct {
     Role UserRole `json:"role" binding:"required"`
@@ -75,6 +107,42 @@ type UpdateAllowancesRequest struct {
     Casual *int `json:"casual"`
 }
 
+// LeaveEncashment is a request to convert unused annual leave days into pay.
+// It follows the same pending/approved/rejected lifecycle as a leave.
+type LeaveEncashment struct {
+    ID              string      `json:"id"`
+    UserID          string      `json:"userId"`
+    UserEmail       string      `json:"userEmail,omitempty"`
+    Year            int         `json:"year"`
+    Days            int         `json:"days"`
+    Status          LeaveStatus `json:"status"`
+    ApproverComment *string     `json:"approverComment"`
+    DecidedAt       *time.Time  `json:"decidedAt"`
+    CreatedAt       time.Time   `json:"createdAt"`
+}
+
+// CreateEncashmentRequest represents a request to encash unused annual leave
+// Year defaults to the current year
+type CreateEncashmentRequest struct {
+    Days int  `json:"days" binding:"required,min=1"`
+    Year *int `json:"year"`
+}
+
+// UpdateEncashmentRequest represents an approver's decision on an encashment (Admin only)
+type UpdateEncashmentRequest struct {
+    Status  LeaveStatus `json:"status" binding:"required"`
+    Comment *string     `json:"comment"`
+}
+
+// PayrollExportRecord is the total approved encashment for one user over a period
+type PayrollExportRecord struct {
+    UserID       string `json:"userId"`
+    UserEmail    string `json:"userEmail"`
+    PeriodStart  string `json:"periodStart"`
+    PeriodEnd    string `json:"periodEnd"`
+    DaysEncashed int    `json:"daysEncashed"`
+}
+
 // Holiday represents a public holiday
 type Holiday struct {
     ID   string `json:"id"`
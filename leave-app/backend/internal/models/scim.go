@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// SCIM 2.0 schema URNs (RFC 7643 / RFC 7644)
+const (
+    SCIMSchemaUser           = "urn:ietf:params:scim:schemas:core:2.0:User"
+    SCIMSchemaEnterpriseUser = "urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"
+    SCIMSchemaLeaveAppUser   = "urn:ietf:params:scim:schemas:extension:leaveapp:2.0:User"
+    SCIMSchemaListResponse   = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+    SCIMSchemaPatchOp        = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+    SCIMSchemaError          = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIMUser is the SCIM representation of a leave-app user
+type SCIMUser struct {
+    Schemas     []string            `json:"schemas"`
+    ID          string              `json:"id,omitempty"`
+    ExternalID  string              `json:"externalId,omitempty"`
+    UserName    string              `json:"userName"`
+    DisplayName string              `json:"displayName,omitempty"`
+    Active      *bool               `json:"active,omitempty"`
+    Emails      []SCIMEmail         `json:"emails,omitempty"`
+    Enterprise  *SCIMEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+    LeaveApp    *SCIMLeaveAppUser   `json:"urn:ietf:params:scim:schemas:extension:leaveapp:2.0:User,omitempty"`
+    Meta        *SCIMMeta           `json:"meta,omitempty"`
+}
+
+type SCIMEmail struct {
+    Value   string `json:"value"`
+    Type    string `json:"type,omitempty"`
+    Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMEnterpriseUser carries the enterprise extension attributes we store
+type SCIMEnterpriseUser struct {
+    Department string       `json:"department,omitempty"`
+    Manager    *SCIMManager `json:"manager,omitempty"`
+}
+
+type SCIMManager struct {
+    Value string `json:"value,omitempty"`
+}
+
+// SCIMLeaveAppUser carries leave-app specific attributes not covered by the core schemas
+type SCIMLeaveAppUser struct {
+    JoinDate string `json:"joinDate,omitempty"`
+}
+
+type SCIMMeta struct {
+    ResourceType string    `json:"resourceType"`
+    Created      time.Time `json:"created"`
+    Location     string    `json:"location,omitempty"`
+}
+
+// SCIMListResponse is returned by the user list/filter endpoint
+type SCIMListResponse struct {
+    Schemas      []string   `json:"schemas"`
+    TotalResults int        `json:"totalResults"`
+    StartIndex   int        `json:"startIndex"`
+    ItemsPerPage int        `json:"itemsPerPage"`
+    Resources    []SCIMUser `json:"Resources"`
+}
+
+// SCIMPatchRequest is a SCIM PatchOp message
+type SCIMPatchRequest struct {
+    Schemas    []string             `json:"schemas"`
+    Operations []SCIMPatchOperation `json:"Operations" binding:"required"`
+}
+
+type SCIMPatchOperation struct {
+    Op    string      `json:"op"`
+    Path  string      `json:"path,omitempty"`
+    Value interface{} `json:"value,omitempty"`
+}
+
+// SCIMError is the SCIM error response body
+type SCIMError struct {
+    Schemas  []string `json:"schemas"`
+    Status   string   `json:"status"`
+    ScimType string   `json:"scimType,omitempty"`
+    Detail   string   `json:"detail"`
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"leave-app/internal/db"
+	"leave-app/internal/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrEncashmentExceedsPolicy is returned when a request would take the user past the yearly encashment cap
+	ErrEncashmentExceedsPolicy = errors.New("encashment exceeds the yearly policy limit")
+	// ErrEncashmentExceedsBalance is returned when the user does not have enough unused annual leave
+	ErrEncashmentExceedsBalance = errors.New("encashment exceeds the unused annual leave balance")
+	// ErrEncashmentNotPending is returned when deciding an encashment that was already decided
+	ErrEncashmentNotPending = errors.New("encashment is not pending")
+)
+
+// EncashmentService contains business logic for encashing unused annual leave.
+type EncashmentService struct {
+	DB *db.Database
+}
+
+// NewEncashmentService constructs an EncashmentService.
+func NewEncashmentService(d *db.Database) *EncashmentService {
+	return &EncashmentService{DB: d}
+}
+
+// querier is satisfied by both *sql.DB and *sql.Tx
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// approvedEncashedDays returns the days of the user's approved encashments in year
+func approvedEncashedDays(ctx context.Context, q querier, userID string, year int) (int, error) {
+	var encashed int
+	query := "SELECT COALESCE(SUM(days), 0) FROM leave_encashments WHERE user_id = ? AND year = ? AND status = ?"
+	err := q.QueryRowContext(ctx, query, userID, year, models.LeaveStatusApproved).Scan(&encashed)
+	return encashed, err
+}
+
+// unusedAnnualDays returns the user's annual allowance minus approved annual leave taken
+// and approved encashments in year. The allowance itself is the yearly entitlement and
+// is never reduced by an encashment.
+// Allowances are not kept per year, so a previous year is checked against the current
+// allowance; an allowance changed since then makes that check approximate.
+func unusedAnnualDays(ctx context.Context, q querier, userID string, year int) (float64, error) {
+	var allowance int
+	if err := q.QueryRowContext(ctx, "SELECT annual_allowance FROM users WHERE id = ?", userID).Scan(&allowance); err != nil {
+		return 0, err
+	}
+
+	encashed, err := approvedEncashedDays(ctx, q, userID, year)
+	if err != nil {
+		return 0, err
+	}
+
+	var used float64
+	query := `
+		SELECT COALESCE(SUM(CASE WHEN d.is_half_day THEN 0.5 ELSE 1 END), 0)
+		FROM leave_days d
+		JOIN leaves l ON d.leave_id = l.id
+		WHERE l.user_id = ? AND l.type = ? AND l.status = ? AND YEAR(d.date) = ?
+	`
+	if err := q.QueryRowContext(ctx, query, userID, models.LeaveTypeAnnual, models.LeaveStatusApproved, year).Scan(&used); err != nil {
+		return 0, err
+	}
+
+	return float64(allowance-encashed) - used, nil
+}
+
+// CreateEncashment validates a request against the policy cap and unused annual balance and stores it as pending
+func (s *EncashmentService) CreateEncashment(userID string, year, days int) (*models.LeaveEncashment, error) {
+	ctx := context.Background()
+	tx, err := s.DB.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// lock the user row so concurrent requests are checked one at a time
+	var locked string
+	if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&locked); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	// days already requested or encashed this year
+	var requested int
+	query := "SELECT COALESCE(SUM(days), 0) FROM leave_encashments WHERE user_id = ? AND year = ? AND status IN (?, ?)"
+	if err := tx.QueryRowContext(ctx, query, userID, year, models.LeaveStatusPending, models.LeaveStatusApproved).Scan(&requested); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if requested+days > models.MaxEncashableAnnualDays {
+		tx.Rollback()
+		return nil, ErrEncashmentExceedsPolicy
+	}
+
+	// pending requests are not part of the unused balance yet
+	var pending int
+	query = "SELECT COALESCE(SUM(days), 0) FROM leave_encashments WHERE user_id = ? AND year = ? AND status = ?"
+	if err := tx.QueryRowContext(ctx, query, userID, year, models.LeaveStatusPending).Scan(&pending); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	unused, err := unusedAnnualDays(ctx, tx, userID, year)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if float64(days+pending) > unused {
+		tx.Rollback()
+		return nil, ErrEncashmentExceedsBalance
+	}
+
+	id := uuid.New().String()
+	insert := "INSERT INTO leave_encashments (id, user_id, year, days, status) VALUES (?, ?, ?, ?, ?)"
+	if _, err := tx.ExecContext(ctx, insert, id, userID, year, days, models.LeaveStatusPending); err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s.GetEncashmentByID(id)
+}
+
+// DecideEncashment approves or rejects a pending encashment. Approval re-checks the
+// unused balance, which approved encashments count against from then on.
+func (s *EncashmentService) DecideEncashment(id string, status models.LeaveStatus, comment *string, actorID string) error {
+	ctx := context.Background()
+	tx, err := s.DB.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	var userID string
+	var year, days int
+	var current models.LeaveStatus
+	if err := tx.QueryRowContext(ctx, "SELECT user_id, year, days, status FROM leave_encashments WHERE id = ? FOR UPDATE", id).Scan(&userID, &year, &days, &current); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if current != models.LeaveStatusPending {
+		tx.Rollback()
+		return ErrEncashmentNotPending
+	}
+
+	if status == models.LeaveStatusApproved {
+		var locked string
+		if err := tx.QueryRowContext(ctx, "SELECT id FROM users WHERE id = ? FOR UPDATE", userID).Scan(&locked); err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		// the balance may have changed since the request was made
+		unused, err := unusedAnnualDays(ctx, tx, userID, year)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if float64(days) > unused {
+			tx.Rollback()
+			return ErrEncashmentExceedsBalance
+		}
+	}
+
+	update := "UPDATE leave_encashments SET status = ?, approver_comment = ?, decided_by = ?, decided_at = ? WHERE id = ?"
+	if _, err := tx.ExecContext(ctx, update, status, comment, actorID, time.Now(), id); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetEncashedDays returns the days of the user's approved encashments in year,
+// which the app deducts from the annual balance
+func (s *EncashmentService) GetEncashedDays(userID string, year int) (int, error) {
+	return approvedEncashedDays(context.Background(), s.DB.Conn, userID, year)
+}
+
+// GetEncashmentByID returns a single encashment
+func (s *EncashmentService) GetEncashmentByID(id string) (*models.LeaveEncashment, error) {
+	query := `
+		SELECT e.id, e.user_id, u.email, e.year, e.days, e.status, e.approver_comment, e.decided_at, e.created_at
+		FROM leave_encashments e
+		JOIN users u ON e.user_id = u.id
+		WHERE e.id = ?
+	`
+	e := &models.LeaveEncashment{}
+	err := s.DB.Conn.QueryRow(query, id).Scan(&e.ID, &e.UserID, &e.UserEmail, &e.Year, &e.Days, &e.Status, &e.ApproverComment, &e.DecidedAt, &e.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// GetEncashments returns encashments for a user, or for everyone when userID is empty
+func (s *EncashmentService) GetEncashments(userID string) ([]models.LeaveEncashment, error) {
+	query := `
+		SELECT e.id, e.user_id, u.email, e.year, e.days, e.status, e.approver_comment, e.decided_at, e.created_at
+		FROM leave_encashments e
+		JOIN users u ON e.user_id = u.id
+	`
+	args := make([]interface{}, 0, 1)
+	if userID != "" {
+		query += " WHERE e.user_id = ?"
+		args = append(args, userID)
+	}
+	query += " ORDER BY e.created_at DESC"
+
+	rows, err := s.DB.Conn.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	encashments := make([]models.LeaveEncashment, 0)
+	for rows.Next() {
+		var e models.LeaveEncashment
+		if err := rows.Scan(&e.ID, &e.UserID, &e.UserEmail, &e.Year, &e.Days, &e.Status, &e.ApproverComment, &e.DecidedAt, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		encashments = append(encashments, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return encashments, nil
+}
+
+// GetPayrollExport returns one record per user with the approved days encashed for year
+func (s *EncashmentService) GetPayrollExport(year int) ([]models.PayrollExportRecord, error) {
+	query := `
+		SELECT e.user_id, u.email, SUM(e.days)
+		FROM leave_encashments e
+		JOIN users u ON e.user_id = u.id
+		WHERE e.year = ? AND e.status = ?
+		GROUP BY e.user_id, u.email
+		ORDER BY u.email
+	`
+	rows, err := s.DB.Conn.Query(query, year, models.LeaveStatusApproved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	periodStart := fmt.Sprintf("%04d-01-01", year)
+	periodEnd := fmt.Sprintf("%04d-12-31", year)
+
+	records := make([]models.PayrollExportRecord, 0)
+	for rows.Next() {
+		record := models.PayrollExportRecord{PeriodStart: periodStart, PeriodEnd: periodEnd}
+		if err := rows.Scan(&record.UserID, &record.UserEmail, &record.DaysEncashed); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"regexp"
+	"testing"
+
+	"leave-app/internal/db"
+	"leave-app/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func newEncashmentTestService(t *testing.T) (*EncashmentService, sqlmock.Sqlmock) {
+	t.Helper()
+	conn, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock: %v", err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("unmet expectations: %v", err)
+		}
+	})
+	return NewEncashmentService(&db.Database{Conn: conn}), mock
+}
+
+// expectUnusedBalance expects the queries of unusedAnnualDays
+func expectUnusedBalance(mock sqlmock.Sqlmock, allowance, encashed int, used float64) {
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT annual_allowance FROM users WHERE id = ?")).
+		WithArgs("u-1").WillReturnRows(sqlmock.NewRows([]string{"annual_allowance"}).AddRow(allowance))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(SUM(days), 0) FROM leave_encashments WHERE user_id = ? AND year = ? AND status = ?")).
+		WithArgs("u-1", 2026, models.LeaveStatusApproved).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(encashed))
+	mock.ExpectQuery(regexp.QuoteMeta("FROM leave_days d")).
+		WithArgs("u-1", models.LeaveTypeAnnual, models.LeaveStatusApproved, 2026).WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(used))
+}
+
+func expectPendingEncashment(mock sqlmock.Sqlmock, days int) {
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT user_id, year, days, status FROM leave_encashments WHERE id = ? FOR UPDATE")).
+		WithArgs("e-1").WillReturnRows(sqlmock.NewRows([]string{"user_id", "year", "days", "status"}).AddRow("u-1", 2026, days, models.LeaveStatusPending))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM users WHERE id = ? FOR UPDATE")).
+		WithArgs("u-1").WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("u-1"))
+}
+
+func TestApprovingEncashmentLeavesAllowanceUntouched(t *testing.T) {
+	s, mock := newEncashmentTestService(t)
+
+	// 10 days allowance, 3 encashed and 4 taken leave 3 unused
+	expectPendingEncashment(mock, 3)
+	expectUnusedBalance(mock, 10, 3, 4)
+	// Only the encashment row changes; any UPDATE users would be an unexpected call
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE leave_encashments SET status = ?")).
+		WithArgs(models.LeaveStatusApproved, nil, "admin-1", sqlmock.AnyArg(), "e-1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if err := s.DecideEncashment("e-1", models.LeaveStatusApproved, nil, "admin-1"); err != nil {
+		t.Fatalf("DecideEncashment: %v", err)
+	}
+}
+
+func TestApprovingEncashmentCountsEarlierEncashments(t *testing.T) {
+	s, mock := newEncashmentTestService(t)
+
+	// 10 days allowance, 3 encashed and 5 taken leave 2 unused, short of 3
+	expectPendingEncashment(mock, 3)
+	expectUnusedBalance(mock, 10, 3, 5)
+	mock.ExpectRollback()
+
+	if err := s.DecideEncashment("e-1", models.LeaveStatusApproved, nil, "admin-1"); err != ErrEncashmentExceedsBalance {
+		t.Fatalf("DecideEncashment = %v, want ErrEncashmentExceedsBalance", err)
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"strings"
 	"time"
@@ -22,8 +23,55 @@ func NewLeaveService(d *db.Database) *LeaveService {
 	return &LeaveService{DB: d}
 }
 
+// IsEditableStatus reports whether a leave in the given status may still have its
+// dates changed or be withdrawn by the requester
+func IsEditableStatus(status models.LeaveStatus) bool {
+	return status == models.LeaveStatusPending || status == models.LeaveStatusChangesRequested
+}
+
+// IsEditableOnBehalfStatus reports whether a privileged user may change the dates
+// of another user's leave in the given status
+func IsEditableOnBehalfStatus(status models.LeaveStatus) bool {
+	return IsEditableStatus(status) || status == models.LeaveStatusApproved
+}
+
+// lockLeaveForEdit locks the leave row and returns its owner and the status the
+// leave should carry after the edit. A requester editing their own leave
+// resubmits it as pending; an edit on behalf of the owner keeps the status.
+func lockLeaveForEdit(ctx context.Context, tx *sql.Tx, leaveID, actorID string) (string, models.LeaveStatus, error) {
+	var ownerID string
+	var status models.LeaveStatus
+	if err := tx.QueryRowContext(ctx, "SELECT user_id, status FROM leaves WHERE id = ? FOR UPDATE", leaveID).Scan(&ownerID, &status); err != nil {
+		return "", "", err
+	}
+
+	if actorID != ownerID {
+		if !IsEditableOnBehalfStatus(status) {
+			return "", "", fmt.Errorf("leave not editable (status=%s)", status)
+		}
+		return ownerID, status, nil
+	}
+
+	if !IsEditableStatus(status) {
+		return "", "", fmt.Errorf("leave not editable (status=%s)", status)
+	}
+	return ownerID, models.LeaveStatusPending, nil
+}
+
+// recordOnBehalfAction records that actorID acted on subjectID's leave, if they differ
+func recordOnBehalfAction(ctx context.Context, tx *sql.Tx, leaveID, actorID, subjectID string, action models.OnBehalfAction) error {
+	if actorID == subjectID {
+		return nil
+	}
+	query := "INSERT INTO leave_on_behalf_actions (id, leave_id, actor_user_id, subject_user_id, action) VALUES (?, ?, ?, ?, ?)"
+	_, err := tx.ExecContext(ctx, query, uuid.New().String(), leaveID, actorID, subjectID, action)
+	return err
+}
+
 // CreateLeaveWithTransaction creates a leave and its leave days in a single transaction
-func (s *LeaveService) CreateLeaveWithTransaction(leave *models.Leave, dates []time.Time, isHalfDay bool, halfDayPeriod *models.HalfDayPeriod) error {
+// actorID is the user submitting the leave; when it differs from leave.UserID the
+// submission is recorded as an on-behalf action
+func (s *LeaveService) CreateLeaveWithTransaction(leave *models.Leave, dates []time.Time, isHalfDay bool, halfDayPeriod *models.HalfDayPeriod, actorID string) error {
 	ctx := context.Background()
 	tx, err := s.DB.Conn.BeginTx(ctx, nil)
 	if err != nil {
@@ -58,6 +106,11 @@ func (s *LeaveService) CreateLeaveWithTransaction(leave *models.Leave, dates []t
 		}
 	}
 
+	if err := recordOnBehalfAction(ctx, tx, leave.ID, actorID, leave.UserID, models.OnBehalfActionCreate); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return tx.Commit()
 }
 
@@ -215,26 +268,134 @@ func (s *LeaveService) GetLeaveByID(leaveID string) (*models.Leave, error) {
 	}
 	leave.Days = days
 
+	// Populate comment thread
+	comments, err := s.getLeaveComments(leave.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get leave comments: %w", err)
+	}
+	leave.Comments = comments
+
 	return leave, nil
 }
 
-func (s *LeaveService) UpdateLeaveStatus(leaveID string, status models.LeaveStatus, comment *string) error {
+// UpdateLeaveStatus sets a leave's status and approver comment. A non-empty
+// comment is also appended to the leave's comment thread on behalf of actorID.
+func (s *LeaveService) UpdateLeaveStatus(leaveID string, status models.LeaveStatus, comment *string, actorID string) error {
+	ctx := context.Background()
+	tx, err := s.DB.Conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
 	query := "UPDATE leaves SET status = ?, approver_comment = ? WHERE id = ?"
-	result, err := s.DB.Conn.Exec(query, status, comment, leaveID)
+	result, err := tx.ExecContext(ctx, query, status, comment, leaveID)
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
-	
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
+		tx.Rollback()
 		return err
 	}
-	
+
 	if rowsAffected == 0 {
+		tx.Rollback()
 		return fmt.Errorf("no leave found with ID: %s", leaveID)
 	}
-	
-	return nil
+
+	if comment != nil && strings.TrimSpace(*comment) != "" {
+		insert := "INSERT INTO leave_comments (id, leave_id, user_id, comment) VALUES (?, ?, ?, ?)"
+		if _, err := tx.ExecContext(ctx, insert, uuid.New().String(), leaveID, actorID, strings.TrimSpace(*comment)); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// AddComment appends a comment from userID to the leave's comment thread
+func (s *LeaveService) AddComment(leaveID, userID, comment string) (*models.LeaveComment, error) {
+	id := uuid.New().String()
+	query := "INSERT INTO leave_comments (id, leave_id, user_id, comment) VALUES (?, ?, ?, ?)"
+	if _, err := s.DB.Conn.Exec(query, id, leaveID, userID, comment); err != nil {
+		return nil, err
+	}
+
+	created := &models.LeaveComment{}
+	query = `
+		SELECT c.id, c.leave_id, c.user_id, u.email, c.comment, c.created_at
+		FROM leave_comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.id = ?
+	`
+	err := s.DB.Conn.QueryRow(query, id).Scan(&created.ID, &created.LeaveID, &created.UserID, &created.UserEmail, &created.Comment, &created.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// getLeaveComments returns the comment thread for a leave, oldest first
+func (s *LeaveService) getLeaveComments(leaveID string) ([]models.LeaveComment, error) {
+	query := `
+		SELECT c.id, c.leave_id, c.user_id, u.email, c.comment, c.created_at
+		FROM leave_comments c
+		JOIN users u ON c.user_id = u.id
+		WHERE c.leave_id = ?
+		ORDER BY c.created_at, c.id
+	`
+	rows, err := s.DB.Conn.Query(query, leaveID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	comments := make([]models.LeaveComment, 0)
+	for rows.Next() {
+		var comment models.LeaveComment
+		if err := rows.Scan(&comment.ID, &comment.LeaveID, &comment.UserID, &comment.UserEmail, &comment.Comment, &comment.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, comment)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return comments, nil
+}
+
+// CountPendingLeaves returns the number of leaves awaiting a decision, by leave type
+func (s *LeaveService) CountPendingLeaves() (map[string]int, error) {
+	rows, err := s.DB.Conn.Query("SELECT type, COUNT(*) FROM leaves WHERE status = ? GROUP BY type", models.LeaveStatusPending)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := map[string]int{
+		string(models.LeaveTypeSick):   0,
+		string(models.LeaveTypeAnnual): 0,
+		string(models.LeaveTypeCasual): 0,
+	}
+	for rows.Next() {
+		var leaveType string
+		var n int
+		if err := rows.Scan(&leaveType, &n); err != nil {
+			return nil, err
+		}
+		counts[leaveType] = n
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return counts, nil
 }
 
 func (s *LeaveService) DeleteLeave(leaveID string) error {
@@ -271,13 +432,19 @@ func (s *LeaveService) getLeaveDays(leaveID string) ([]models.LeaveDay, error) {
 }
 
 // UpdateSingleDayLeaveHalfDay updates the half-day status and total_days for a single-day leave
-func (s *LeaveService) UpdateSingleDayLeaveHalfDay(leaveID string, isHalfDay bool, halfDayPeriod *models.HalfDayPeriod) error {
+func (s *LeaveService) UpdateSingleDayLeaveHalfDay(leaveID string, isHalfDay bool, halfDayPeriod *models.HalfDayPeriod, actorID string) error {
 	ctx := context.Background()
 	tx, err := s.DB.Conn.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
 
+	ownerID, newStatus, err := lockLeaveForEdit(ctx, tx, leaveID, actorID)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	// Calculate total days based on half-day status
 	totalDays := 1.0
 	if isHalfDay {
@@ -290,8 +457,13 @@ func (s *LeaveService) UpdateSingleDayLeaveHalfDay(leaveID string, isHalfDay boo
 		return err
 	}
 
-	// Update leaves table total_days
-	if _, err := tx.ExecContext(ctx, "UPDATE leaves SET total_days = ? WHERE id = ?", totalDays, leaveID); err != nil {
+	// Update leaves table total_days; a requester's edit resubmits a leave sent back for changes
+	if _, err := tx.ExecContext(ctx, "UPDATE leaves SET total_days = ?, status = ? WHERE id = ?", totalDays, newStatus, leaveID); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := recordOnBehalfAction(ctx, tx, leaveID, actorID, ownerID, models.OnBehalfActionUpdate); err != nil {
 		tx.Rollback()
 		return err
 	}
@@ -300,7 +472,7 @@ func (s *LeaveService) UpdateSingleDayLeaveHalfDay(leaveID string, isHalfDay boo
 }
 
 // UpdateSingleDayLeaveWithTransaction updates both dates and half-day status in a single transaction
-func (s *LeaveService) UpdateSingleDayLeaveWithTransaction(leaveID, startDate, endDate string, totalDays float64, days []time.Time, isHalfDay bool, halfDayPeriod *models.HalfDayPeriod) error {
+func (s *LeaveService) UpdateSingleDayLeaveWithTransaction(leaveID, startDate, endDate string, totalDays float64, days []time.Time, isHalfDay bool, halfDayPeriod *models.HalfDayPeriod, actorID string) error {
 	if len(days) == 0 {
 		return fmt.Errorf("no working days in date range")
 	}
@@ -311,16 +483,12 @@ func (s *LeaveService) UpdateSingleDayLeaveWithTransaction(leaveID, startDate, e
 		return err
 	}
 
-	// ensure the leave is pending
-	var status string
-	if err := tx.QueryRowContext(ctx, "SELECT status FROM leaves WHERE id = ? FOR UPDATE", leaveID).Scan(&status); err != nil {
+	// ensure the leave is editable by the actor
+	ownerID, newStatus, err := lockLeaveForEdit(ctx, tx, leaveID, actorID)
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	if status != string(models.LeaveStatusPending) {
-		tx.Rollback()
-		return fmt.Errorf("leave not editable (status=%s)", status)
-	}
 
 	// Delete old leave days
 	if _, err := tx.ExecContext(ctx, "DELETE FROM leave_days WHERE leave_id = ?", leaveID); err != nil {
@@ -345,7 +513,7 @@ func (s *LeaveService) UpdateSingleDayLeaveWithTransaction(leaveID, startDate, e
 	}
 
 	// Update leave record
-	res, err := tx.ExecContext(ctx, "UPDATE leaves SET start_date = ?, end_date = ?, total_days = ? WHERE id = ?", startDate, endDate, totalDays, leaveID)
+	res, err := tx.ExecContext(ctx, "UPDATE leaves SET start_date = ?, end_date = ?, total_days = ?, status = ? WHERE id = ?", startDate, endDate, totalDays, newStatus, leaveID)
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -355,11 +523,16 @@ func (s *LeaveService) UpdateSingleDayLeaveWithTransaction(leaveID, startDate, e
 		return fmt.Errorf("leave not found")
 	}
 
+	if err := recordOnBehalfAction(ctx, tx, leaveID, actorID, ownerID, models.OnBehalfActionUpdate); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return tx.Commit()
 }
 
 // ReplaceLeaveDaysAndUpdateLeave replaces all leave day records for a leave and updates the leave
-func (s *LeaveService) ReplaceLeaveDaysAndUpdateLeave(leaveID, startDate, endDate string, totalDays float64, days []time.Time) error {
+func (s *LeaveService) ReplaceLeaveDaysAndUpdateLeave(leaveID, startDate, endDate string, totalDays float64, days []time.Time, actorID string) error {
 	if len(days) == 0 {
 		return fmt.Errorf("no working days in date range")
 	}
@@ -370,16 +543,12 @@ func (s *LeaveService) ReplaceLeaveDaysAndUpdateLeave(leaveID, startDate, endDat
 		return err
 	}
 
-	// ensure the leave is pending (prevent races)
-	var status string
-	if err := tx.QueryRowContext(ctx, "SELECT status FROM leaves WHERE id = ? FOR UPDATE", leaveID).Scan(&status); err != nil {
+	// ensure the leave is editable by the actor (prevent races)
+	ownerID, newStatus, err := lockLeaveForEdit(ctx, tx, leaveID, actorID)
+	if err != nil {
 		tx.Rollback()
 		return err
 	}
-	if status != string(models.LeaveStatusPending) {
-		tx.Rollback()
-		return fmt.Errorf("leave not editable (status=%s)", status)
-	}
 
 	if _, err := tx.ExecContext(ctx, "DELETE FROM leave_days WHERE leave_id = ?", leaveID); err != nil {
 		tx.Rollback()
@@ -402,7 +571,7 @@ func (s *LeaveService) ReplaceLeaveDaysAndUpdateLeave(leaveID, startDate, endDat
 		}
 	}
 
-	res, err := tx.ExecContext(ctx, "UPDATE leaves SET start_date = ?, end_date = ?, total_days = ? WHERE id = ?", startDate, endDate, totalDays, leaveID)
+	res, err := tx.ExecContext(ctx, "UPDATE leaves SET start_date = ?, end_date = ?, total_days = ?, status = ? WHERE id = ?", startDate, endDate, totalDays, newStatus, leaveID)
 	if err != nil {
 		tx.Rollback()
 		return err
@@ -412,6 +581,11 @@ func (s *LeaveService) ReplaceLeaveDaysAndUpdateLeave(leaveID, startDate, endDat
 		return fmt.Errorf("leave not found")
 	}
 
+	if err := recordOnBehalfAction(ctx, tx, leaveID, actorID, ownerID, models.OnBehalfActionUpdate); err != nil {
+		tx.Rollback()
+		return err
+	}
+
 	return tx.Commit()
 }
 
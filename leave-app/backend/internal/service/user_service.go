@@ -1,6 +1,8 @@
 package service
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 
 	"leave-app/internal/db"
@@ -19,16 +21,40 @@ func NewUserService(d *db.Database) *UserService {
     return &UserService{DB: d}
 }
 
-func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
+// userColumns lists the users columns in the order scanUser expects them
+const userColumns = "id, email, role, sick_allowance, annual_allowance, casual_allowance, active, external_id, display_name, department, manager, join_date, created_at, deprovisioned_at"
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows
+type rowScanner interface {
+    Scan(dest ...interface{}) error
+}
+
+func scanUser(row rowScanner) (*models.User, error) {
     user := &models.User{}
-    query := "SELECT id, email, role, sick_allowance, annual_allowance, casual_allowance, created_at FROM users WHERE email = ?"
-    err := s.DB.Conn.QueryRow(query, email).Scan(&user.ID, &user.Email, &user.Role, &user.Allowances.Sick, &user.Allowances.Annual, &user.Allowances.Casual, &user.CreatedAt)
+    var joinDate, deprovisionedAt sql.NullTime
+    err := row.Scan(&user.ID, &user.Email, &user.Role, &user.Allowances.Sick, &user.Allowances.Annual, &user.Allowances.Casual, &user.Active, &user.ExternalID, &user.DisplayName, &user.Department, &user.Manager, &joinDate, &user.CreatedAt, &deprovisionedAt)
     if err != nil {
         return nil, err
     }
+    if deprovisionedAt.Valid {
+        user.DeprovisionedAt = &deprovisionedAt.Time
+    }
+    if joinDate.Valid {
+        formatted := joinDate.Time.Format("2006-01-02")
+        user.JoinDate = &formatted
+    }
     return user, nil
 }
 
+func (s *UserService) GetUserByEmail(email string) (*models.User, error) {
+    return scanUser(s.DB.Conn.QueryRow("SELECT "+userColumns+" FROM users WHERE email = ?", email))
+}
+
+// GetUserByID returns the user with the given ID
+func (s *UserService) GetUserByID(userID string) (*models.User, error) {
+    return scanUser(s.DB.Conn.QueryRow("SELECT "+userColumns+" FROM users WHERE id = ?", userID))
+}
+
 func (s *UserService) UpdateUserRole(userID string, role models.UserRole) error {
     query := "UPDATE users SET role = ? WHERE id = ?"
     _, err := s.DB.Conn.Exec(query, role, userID)
@@ -36,7 +62,7 @@ func (s *UserService) UpdateUserRole(userID string, role models.UserRole) error
 }
 
 func (s *UserService) GetAllUsers() ([]models.User, error) {
-    rows, err := s.DB.Conn.Query("SELECT id, email, role, sick_allowance, annual_allowance, casual_allowance, created_at FROM users")
+    rows, err := s.DB.Conn.Query("SELECT " + userColumns + " FROM users")
     if err != nil {
         return nil, err
     }
@@ -44,11 +70,11 @@ func (s *UserService) GetAllUsers() ([]models.User, error) {
 
     users := make([]models.User, 0)
     for rows.Next() {
-        var user models.User
-        if err := rows.Scan(&user.ID, &user.Email, &user.Role, &user.Allowances.Sick, &user.Allowances.Annual, &user.Allowances.Casual, &user.CreatedAt); err != nil {
+        user, err := scanUser(rows)
+        if err != nil {
             return nil, err
         }
-        users = append(users, user)
+        users = append(users, *user)
     }
     return users, nil
 }
@@ -82,3 +108,131 @@ func (s *UserService) CreateUser(email string) (*models.User, error) {
     }
     return s.GetUserByEmail(email)
 }
+
+// ListUsers returns a page of users not deprovisioned through SCIM, optionally filtered
+// by exact email, with the total match count. startIndex is 1-based as in SCIM
+func (s *UserService) ListUsers(email string, startIndex, count int) ([]models.User, int, error) {
+    where := " WHERE deprovisioned_at IS NULL"
+    args := make([]interface{}, 0, 3)
+    if email != "" {
+        where += " AND email = ?"
+        args = append(args, email)
+    }
+
+    var total int
+    if err := s.DB.Conn.QueryRow("SELECT COUNT(*) FROM users"+where, args...).Scan(&total); err != nil {
+        return nil, 0, err
+    }
+
+    args = append(args, count, startIndex-1)
+    rows, err := s.DB.Conn.Query("SELECT "+userColumns+" FROM users"+where+" ORDER BY created_at, id LIMIT ? OFFSET ?", args...)
+    if err != nil {
+        return nil, 0, err
+    }
+    defer rows.Close()
+
+    users := make([]models.User, 0)
+    for rows.Next() {
+        user, err := scanUser(rows)
+        if err != nil {
+            return nil, 0, err
+        }
+        users = append(users, *user)
+    }
+    if err := rows.Err(); err != nil {
+        return nil, 0, err
+    }
+    return users, total, nil
+}
+
+// ProvisionUser creates a user ahead of their first login with default role and allowances
+func (s *UserService) ProvisionUser(user *models.User) (*models.User, error) {
+    user.ID = uuid.New().String()
+    user.Role = models.DefaultUserRole
+    user.Allowances = models.Allowances{
+        Annual: models.DefaultAnnualAllowance,
+        Sick:   models.DefaultSickAllowance,
+        Casual: models.DefaultCasualAllowance,
+    }
+    query := `INSERT INTO users (id, email, role, annual_allowance, sick_allowance, casual_allowance, active, external_id, display_name, department, manager, join_date)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+    _, err := s.DB.Conn.Exec(query, user.ID, user.Email, user.Role, user.Allowances.Annual, user.Allowances.Sick, user.Allowances.Casual,
+        user.Active, user.ExternalID, user.DisplayName, user.Department, user.Manager, user.JoinDate)
+    if err != nil {
+        return nil, err
+    }
+    return s.GetUserByID(user.ID)
+}
+
+// UpdateProvisionedUser replaces the directory attributes of a user. Deactivating a
+// user withdraws their open leaves in the same transaction.
+func (s *UserService) UpdateProvisionedUser(user *models.User) (*models.User, error) {
+    ctx := context.Background()
+    tx, err := s.DB.Conn.BeginTx(ctx, nil)
+    if err != nil {
+        return nil, err
+    }
+
+    query := "UPDATE users SET email = ?, active = ?, external_id = ?, display_name = ?, department = ?, manager = ?, join_date = ? WHERE id = ? AND deprovisioned_at IS NULL"
+    res, err := tx.ExecContext(ctx, query, user.Email, user.Active, user.ExternalID, user.DisplayName, user.Department, user.Manager, user.JoinDate, user.ID)
+    if err != nil {
+        tx.Rollback()
+        return nil, err
+    }
+    if ra, _ := res.RowsAffected(); ra == 0 {
+        // MySQL reports zero rows when nothing changed, so confirm the user exists
+        var exists int
+        if err := tx.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = ? AND deprovisioned_at IS NULL", user.ID).Scan(&exists); err != nil {
+            tx.Rollback()
+            return nil, err
+        }
+    }
+
+    if !user.Active {
+        if err := withdrawOpenLeaves(ctx, tx, user.ID); err != nil {
+            tx.Rollback()
+            return nil, err
+        }
+    }
+
+    if err := tx.Commit(); err != nil {
+        return nil, err
+    }
+    return s.GetUserByID(user.ID)
+}
+
+// DeprovisionUser blocks a user from signing in, withdraws their open leaves and marks
+// them deprovisioned. The row is kept for leave history; a user already deprovisioned
+// is reported as sql.ErrNoRows
+func (s *UserService) DeprovisionUser(userID string) error {
+    ctx := context.Background()
+    tx, err := s.DB.Conn.BeginTx(ctx, nil)
+    if err != nil {
+        return err
+    }
+
+    var exists int
+    if err := tx.QueryRowContext(ctx, "SELECT 1 FROM users WHERE id = ? AND deprovisioned_at IS NULL FOR UPDATE", userID).Scan(&exists); err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    if _, err := tx.ExecContext(ctx, "UPDATE users SET active = FALSE, deprovisioned_at = NOW() WHERE id = ?", userID); err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    if err := withdrawOpenLeaves(ctx, tx, userID); err != nil {
+        tx.Rollback()
+        return err
+    }
+
+    return tx.Commit()
+}
+
+// withdrawOpenLeaves marks a user's undecided leaves as withdrawn
+func withdrawOpenLeaves(ctx context.Context, tx *sql.Tx, userID string) error {
+    query := "UPDATE leaves SET status = ? WHERE user_id = ? AND status IN (?, ?)"
+    _, err := tx.ExecContext(ctx, query, models.LeaveStatusWithdrawn, userID, models.LeaveStatusPending, models.LeaveStatusChangesRequested)
+    return err
+}
@@ -0,0 +1,15 @@
+-- 003_leave_comments.sql
+
+ALTER TABLE leaves
+  MODIFY COLUMN status ENUM('pending', 'approved', 'rejected', 'changes_requested') NOT NULL DEFAULT 'pending';
+
+CREATE TABLE IF NOT EXISTS leave_comments (
+    id VARCHAR(255) PRIMARY KEY,
+    leave_id VARCHAR(255) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    comment TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (leave_id) REFERENCES leaves(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    INDEX idx_leave_comments_leave (leave_id, created_at)
+);
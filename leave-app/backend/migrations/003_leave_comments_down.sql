@@ -0,0 +1,6 @@
+-- 003_leave_comments_down.sql
+
+DROP TABLE IF EXISTS leave_comments;
+UPDATE leaves SET status = 'pending' WHERE status = 'changes_requested';
+ALTER TABLE leaves
+  MODIFY COLUMN status ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending';
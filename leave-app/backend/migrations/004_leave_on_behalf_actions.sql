@@ -0,0 +1,15 @@
+-- 004_leave_on_behalf_actions.sql
+
+CREATE TABLE IF NOT EXISTS leave_on_behalf_actions (
+    id VARCHAR(255) PRIMARY KEY,
+    leave_id VARCHAR(255) NOT NULL,
+    actor_user_id VARCHAR(255) NOT NULL,
+    subject_user_id VARCHAR(255) NOT NULL,
+    action VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    -- The audit trail outlives the leave it describes, so leave_id is not a foreign key,
+    -- and users with on-behalf actions cannot be deleted
+    FOREIGN KEY (actor_user_id) REFERENCES users(id) ON DELETE RESTRICT,
+    FOREIGN KEY (subject_user_id) REFERENCES users(id) ON DELETE RESTRICT,
+    INDEX idx_on_behalf_leave (leave_id)
+);
@@ -0,0 +1,3 @@
+-- 004_leave_on_behalf_actions_down.sql
+
+DROP TABLE IF EXISTS leave_on_behalf_actions;
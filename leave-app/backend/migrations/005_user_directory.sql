@@ -0,0 +1,13 @@
+-- 005_user_directory.sql
+
+ALTER TABLE users
+  ADD COLUMN active BOOLEAN NOT NULL DEFAULT TRUE,
+  ADD COLUMN external_id VARCHAR(255) NULL DEFAULT NULL,
+  ADD COLUMN display_name VARCHAR(255) NULL DEFAULT NULL,
+  ADD COLUMN department VARCHAR(255) NULL DEFAULT NULL,
+  ADD COLUMN manager VARCHAR(255) NULL DEFAULT NULL,
+  ADD COLUMN join_date DATE NULL DEFAULT NULL,
+  ADD UNIQUE KEY uq_users_external_id (external_id);
+
+ALTER TABLE leaves
+  MODIFY COLUMN status ENUM('pending', 'approved', 'rejected', 'changes_requested', 'withdrawn') NOT NULL DEFAULT 'pending';
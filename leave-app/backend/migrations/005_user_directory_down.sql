@@ -0,0 +1,15 @@
+-- 005_user_directory_down.sql
+
+-- Withdrawn leaves were never taken, so they map onto rejected rather than being lost
+UPDATE leaves SET status = 'rejected' WHERE status = 'withdrawn';
+ALTER TABLE leaves
+  MODIFY COLUMN status ENUM('pending', 'approved', 'rejected', 'changes_requested') NOT NULL DEFAULT 'pending';
+
+ALTER TABLE users
+  DROP INDEX uq_users_external_id,
+  DROP COLUMN join_date,
+  DROP COLUMN manager,
+  DROP COLUMN department,
+  DROP COLUMN display_name,
+  DROP COLUMN external_id,
+  DROP COLUMN active;
@@ -0,0 +1,17 @@
+-- 006_leave_encashments.sql
+
+CREATE TABLE IF NOT EXISTS leave_encashments (
+    id VARCHAR(255) PRIMARY KEY,
+    user_id VARCHAR(255) NOT NULL,
+    year INT NOT NULL,
+    days INT NOT NULL,
+    status ENUM('pending', 'approved', 'rejected') NOT NULL DEFAULT 'pending',
+    approver_comment TEXT,
+    decided_by VARCHAR(255) NULL DEFAULT NULL,
+    decided_at TIMESTAMP NULL DEFAULT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (decided_by) REFERENCES users(id) ON DELETE SET NULL,
+    INDEX idx_encashments_user_year (user_id, year),
+    INDEX idx_encashments_year_status (year, status)
+);
@@ -0,0 +1,3 @@
+-- 006_leave_encashments_down.sql
+
+DROP TABLE IF EXISTS leave_encashments;
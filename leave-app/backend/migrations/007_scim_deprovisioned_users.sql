@@ -0,0 +1,5 @@
+-- 007_scim_deprovisioned_users.sql
+
+-- Users deleted through SCIM stay in the table for their leave history but are gone for SCIM
+ALTER TABLE users
+  ADD COLUMN deprovisioned_at DATETIME NULL DEFAULT NULL;
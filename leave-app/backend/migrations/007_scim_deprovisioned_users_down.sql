@@ -0,0 +1,4 @@
+-- 007_scim_deprovisioned_users_down.sql
+
+ALTER TABLE users
+  DROP COLUMN deprovisioned_at;
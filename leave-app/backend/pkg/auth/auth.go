@@ -3,12 +3,14 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"leave-app/internal/constants"
 	"leave-app/internal/models"
 	"leave-app/internal/service"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -131,6 +133,12 @@ func (a *Authenticator) AuthMiddleware() gin.HandlerFunc {
 			}
 		}
 
+		// Users deprovisioned through SCIM cannot sign in
+		if !user.Active {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Account is deactivated"})
+			return
+		}
+
 		// override role if claim provided
 		if roleFromToken != "" {
 			user.Role = models.UserRole(roleFromToken)
@@ -146,3 +154,21 @@ func (a *Authenticator) AuthMiddleware() gin.HandlerFunc {
 	}
 
 }
+
+// SCIMMiddleware authenticates SCIM provisioning requests against a shared bearer secret
+func SCIMMiddleware(secret string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authHeader := c.GetHeader("Authorization")
+		token := strings.TrimPrefix(authHeader, "Bearer ")
+		if authHeader == "" || token == authHeader || subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, models.SCIMError{
+				Schemas: []string{models.SCIMSchemaError},
+				Status:  strconv.Itoa(http.StatusUnauthorized),
+				Detail:  "Invalid or missing bearer token",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
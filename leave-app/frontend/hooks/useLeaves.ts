@@ -72,12 +72,16 @@ export const useLeaves = ({ token, isAdmin, user }: UseLeavesProps) => {
       }
     });
 
+    // Encashed days are paid out and can no longer be taken as leave
+    const encashed = user.encashedAnnual ?? 0;
+
     return {
       sick: Math.max(0, user.allowances.sick - used.sick),
-      annual: Math.max(0, user.allowances.annual - used.annual),
+      annual: Math.max(0, user.allowances.annual - encashed - used.annual),
       casual: Math.max(0, user.allowances.casual - used.casual),
       total: user.allowances,
       used,
+      encashed,
     };
   }, [rawLeaves, user, holidays]);
 
@@ -16,6 +16,7 @@ export interface UserInfo {
   role: Role;
   avatarUrl?: string;
   allowances: Allowances;
+  encashedAnnual?: number; // approved annual days encashed this year
 }
 
 export interface LeaveDay {
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"memo-app/internal/api"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/ratelimit"
+)
+
+// sendLimits reads the sending limits from the environment, falling back to the defaults in config
+func sendLimits(limiter ratelimit.Limiter) (api.SendLimits, error) {
+	limits := api.SendLimits{Limiter: limiter, RoleQuotas: map[models.UserRole]ratelimit.Quota{}}
+
+	roleQuotas := []struct {
+		role     models.UserRole
+		variable string
+		fallback string
+	}{
+		{models.RoleUser, "SEND_QUOTA_USER", config.DefaultSendQuotaUser},
+		{models.RoleBroadcaster, "SEND_QUOTA_BROADCASTER", config.DefaultSendQuotaBroadcaster},
+		{models.RoleAdmin, "SEND_QUOTA_ADMIN", config.DefaultSendQuotaAdmin},
+	}
+	for _, rq := range roleQuotas {
+		quota, err := quotaFromEnv(rq.variable, rq.fallback)
+		if err != nil {
+			return limits, err
+		}
+		limits.RoleQuotas[rq.role] = quota
+	}
+
+	var err error
+	if limits.BroadcastQuota, err = quotaFromEnv("BROADCAST_QUOTA", config.DefaultBroadcastQuota); err != nil {
+		return limits, err
+	}
+	if limits.MaxMessageBytes, err = intFromEnv("MAX_MESSAGE_BYTES", config.DefaultMaxMessageBytes); err != nil {
+		return limits, err
+	}
+	if limits.MaxRecipients, err = intFromEnv("MAX_RECIPIENTS", config.DefaultMaxRecipients); err != nil {
+		return limits, err
+	}
+
+	log.Printf("Sending limits: user %s, broadcaster %s, admin %s, broadcasts %s, message %d bytes, %d recipients",
+		limits.RoleQuotas[models.RoleUser], limits.RoleQuotas[models.RoleBroadcaster], limits.RoleQuotas[models.RoleAdmin],
+		limits.BroadcastQuota, limits.MaxMessageBytes, limits.MaxRecipients)
+	return limits, nil
+}
+
+// quotaFromEnv parses the quota in the named variable, or fallback if it is not set
+func quotaFromEnv(variable string, fallback string) (ratelimit.Quota, error) {
+	value := os.Getenv(variable)
+	if value == "" {
+		value = fallback
+	}
+	quota, err := ratelimit.ParseQuota(value)
+	if err != nil {
+		return quota, fmt.Errorf("%s: %w", variable, err)
+	}
+	return quota, nil
+}
+
+// intFromEnv parses the non-negative number in the named variable, or returns fallback if it is not set
+func intFromEnv(variable string, fallback int) (int, error) {
+	value := os.Getenv(variable)
+	if value == "" {
+		return fallback, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("%s: %q is not a non-negative number", variable, value)
+	}
+	return n, nil
+}
@@ -14,9 +14,15 @@ import (
 
 	"memo-app/internal/api"
 	"memo-app/internal/auth"
+	"memo-app/internal/blob"
 	"memo-app/internal/cache"
 	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+	"memo-app/internal/ratelimit"
 	"memo-app/internal/store"
+	"memo-app/internal/tickets"
 )
 
 func main() {
@@ -28,12 +34,12 @@ func main() {
 		}
 	}
 
-	// Initialize JWT authentication (optional)
-	if err := auth.InitJWKS(); err != nil {
-		log.Printf("Warning: JWKS initialization failed: %v", err)
-		log.Printf("Running without JWT authentication...")
-	} else {
-		log.Println("JWKS initialized successfully")
+	// `memo-app migrate ...` changes the database schema instead of serving
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrate(os.Args[2:]); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		return
 	}
 
 	// Create Gin router
@@ -44,56 +50,191 @@ func main() {
 	r.Use(cors.New(cors.Config{
 		AllowOrigins: 	  []string{"*"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-User-Email"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "X-User-Email", "X-User-Role", "X-User-Name", "X-User-Avatar", "X-User-Department", "X-User-Location", "Last-Event-ID"},
+		ExposeHeaders:    []string{"Content-Length", "Content-Disposition", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
 
 	// Initialize database store
-	dbURL := os.Getenv("DATABASE_URL")
-	if dbURL == "" {
-		// Default MySQL connection for local development
-		// Format: username:password@tcp(host:port)/database?params
-		dbURL = config.DefaultDatabaseURL
-		log.Printf("DATABASE_URL not set, using default: %s", dbURL)
-	}
+	dbURL := databaseURL()
 
-	// Initialize in-memory cache
+	// Initialize cache: in-memory for a single instance, Redis when running several
 	cacheTTL := 5 * time.Minute // Default cache TTL
 	if ttl := os.Getenv("CACHE_TTL_MINUTES"); ttl != "" {
 		if parsed, err := strconv.Atoi(ttl); err == nil {
 			cacheTTL = time.Duration(parsed) * time.Minute
 		}
 	}
-	
-	cacheManager := cache.NewCacheManager(cacheTTL)
 
-	dbStore, err := store.NewDBStore(dbURL, cacheManager)
+	// Send quotas and stream tickets use the same backend, so they hold across instances with Redis
+	var cacheManager cache.Cache
+	var limiter ratelimit.Limiter
+	var streamTickets tickets.Store
+	switch backend := os.Getenv("CACHE_BACKEND"); backend {
+	case "", config.CacheBackendMemory:
+		cacheManager = cache.NewMemoryCache(cacheTTL)
+		limiter = ratelimit.NewMemoryLimiter()
+		streamTickets = tickets.NewMemoryStore()
+	case config.CacheBackendRedis:
+		redisURL := os.Getenv("REDIS_URL")
+		if redisURL == "" {
+			redisURL = config.DefaultRedisURL
+		}
+		redisCache, err := cache.NewRedisCache(redisURL, cacheTTL)
+		if err != nil {
+			log.Fatalf("Failed to connect to Redis cache: %v", err)
+		}
+		defer redisCache.Close()
+		cacheManager = redisCache
+		limiter = ratelimit.NewRedisLimiter(redisCache.Client(), config.RedisKeyPrefix)
+		streamTickets = tickets.NewRedisStore(redisCache.Client(), config.RedisKeyPrefix)
+	default:
+		log.Fatalf("Unknown CACHE_BACKEND %q: use %q or %q", backend, config.CacheBackendMemory, config.CacheBackendRedis)
+	}
+
+	// In-process pub/sub hub for real-time memo delivery
+	hub := events.NewHub(config.SSEReplayBufferSize, config.SSESubscriberBuffer)
+
+	// Attachment files are kept on local disk
+	attachmentDir := os.Getenv("ATTACHMENT_DIR")
+	if attachmentDir == "" {
+		attachmentDir = config.DefaultAttachmentDir
+	}
+	blobs, err := blob.NewLocalStore(attachmentDir)
+	if err != nil {
+		log.Fatalf("Failed to open attachment storage: %v", err)
+	}
+
+	// Push notifications to the mobile app are off unless a gateway is configured
+	var pushGateway push.Gateway
+	switch gateway := os.Getenv("PUSH_GATEWAY"); gateway {
+	case "":
+		log.Println("PUSH_GATEWAY not set, push notifications disabled")
+	case config.PushGatewayFCM:
+		serverKey := os.Getenv("FCM_SERVER_KEY")
+		if serverKey == "" {
+			log.Fatalf("PUSH_GATEWAY=%s requires FCM_SERVER_KEY", gateway)
+		}
+		endpoint := os.Getenv("FCM_ENDPOINT")
+		if endpoint == "" {
+			endpoint = config.DefaultFCMEndpoint
+		}
+		pushGateway = push.NewFCMGateway(endpoint, serverKey, &http.Client{Timeout: config.PushTimeoutSeconds * time.Second})
+		log.Printf("Push notifications enabled through %s", endpoint)
+	default:
+		log.Fatalf("Unknown PUSH_GATEWAY %q: leave it empty or use %q", gateway, config.PushGatewayFCM)
+	}
+
+	dbStore, err := store.NewDBStore(dbURL, cacheManager, hub, blobs, pushGateway)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
 	}
 
+	// Select authentication: JWT by default, header-based only when explicitly in dev mode
+	var authMiddleware gin.HandlerFunc
+	authMode := os.Getenv("AUTH_MODE")
+	switch authMode {
+	case config.AuthModeDev:
+		log.Println("WARNING: AUTH_MODE=dev - callers are identified by the X-User-Email header without verification. Do not use in production.")
+		authMiddleware = auth.DevAuthMiddleware(dbStore)
+	case "", config.AuthModeJWT:
+		if err := auth.InitJWKS(); err != nil {
+			log.Fatalf("JWKS initialization failed: %v (set AUTH_MODE=dev for local development)", err)
+		}
+		log.Println("JWKS initialized successfully")
+		authMiddleware = auth.AuthMiddleware(dbStore)
+	default:
+		log.Fatalf("Unknown AUTH_MODE %q (expected %q or %q)", authMode, config.AuthModeJWT, config.AuthModeDev)
+	}
+
 	// Start cleanup routine for expired memos
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 	go dbStore.StartCleanup(ctx)
 
-	apiGroup := r.Group("/api")
-	
-	// Note: Authentication is currently bypassed for testing
-	// Uncomment the following lines to enable JWT authentication for API endpoints:
-	if os.Getenv("JWKS_URL") != "" {
-		apiGroup.Use(auth.AuthMiddleware(dbStore))
+	// Release scheduled memos when they are due
+	go dbStore.StartScheduler(ctx)
+
+	// Send queued push notifications
+	go dbStore.StartPushDispatcher(ctx)
+
+	// Remind recipients who have not acknowledged memos that require it
+	go dbStore.StartAckReminders(ctx)
+
+	// Real-time stream; EventSource cannot set headers, so clients authenticate with a
+	// single-use ticket from POST /api/memos/stream/ticket. Registered outside the /api
+	// group, which requires the Authorization header
+	if authMode == config.AuthModeDev {
+		r.GET("/api/memos/stream", authMiddleware, api.HandleStream(hub))
+	} else {
+		r.GET("/api/memos/stream", auth.StreamTicketMiddleware(streamTickets, dbStore), api.HandleStream(hub))
 	}
 
-	// Memo CRUD endpoints (protected by AuthMiddleware when enabled)
-	apiGroup.POST("/memos", api.HandleSendMemo(dbStore))
+	limits, err := sendLimits(limiter)
+	if err != nil {
+		log.Fatalf("Invalid sending limits: %v", err)
+	}
+	limitSending := api.LimitSending(limits)
+	limitSize := api.LimitSize(limits)
+
+	apiGroup := r.Group("/api")
+	apiGroup.Use(authMiddleware)
+
+	// Memo CRUD endpoints; sending is rate limited and edits are held to the same size limits
+	apiGroup.POST("/memos", limitSending, api.HandleSendMemo(dbStore))
 	apiGroup.GET("/memos/sent", api.HandleGetSentMemos(dbStore))
 	apiGroup.GET("/memos/received", api.HandleGetReceivedMemos(dbStore))
+	apiGroup.GET("/memos/search", api.HandleSearchMemos(dbStore))
+	apiGroup.POST("/memos/stream/ticket", api.HandleIssueStreamTicket(streamTickets))
+	apiGroup.GET("/memos/unread-count", api.HandleGetUnreadCount(dbStore))
+	apiGroup.GET("/memos/scheduled", api.HandleGetScheduledMemos(dbStore))
+	apiGroup.PUT("/memos/:id/schedule", limitSize, api.HandleUpdateScheduledMemo(dbStore))
+	apiGroup.DELETE("/memos/:id/schedule", api.HandleCancelScheduledMemo(dbStore))
 	apiGroup.PUT("/memos/:id/status", api.HandleUpdateStatus(dbStore))
+	apiGroup.GET("/memos/:id/receipts", api.HandleGetReceipts(dbStore))
+	apiGroup.POST("/memos/:id/ack", api.HandleAcknowledgeMemo(dbStore))
+	apiGroup.POST("/memos/:id/recall", api.HandleRecallMemo(dbStore))
+	apiGroup.PUT("/memos/:id", limitSize, api.HandleEditMemo(dbStore))
+	apiGroup.GET("/memos/:id/revisions", api.HandleGetRevisions(dbStore))
+	apiGroup.GET("/memos/:id/acks", api.HandleGetAckReport(dbStore))
+	apiGroup.POST("/memos/:id/reply", limitSending, api.HandleReplyMemo(dbStore))
+	apiGroup.GET("/threads/:id", api.HandleGetThread(dbStore))
+	apiGroup.POST("/attachments", api.HandleUploadAttachment(dbStore))
+	apiGroup.GET("/attachments/:id", api.HandleDownloadAttachment(dbStore))
 	apiGroup.DELETE("/memos/:id", api.HandleDeleteMemo(dbStore))
-	apiGroup.GET("/users", api.HandleGetActiveUsers(dbStore))
+	apiGroup.GET("/users", api.HandleSearchUsers(dbStore))
+	apiGroup.GET("/addresses", api.HandleLookupAddresses(dbStore))
+	apiGroup.GET("/audiences", api.HandleGetAudienceOptions(dbStore))
+
+	// Push notifications: device registration and per-sender or per-category mutes
+	apiGroup.POST("/devices", api.HandleRegisterDevice(dbStore))
+	apiGroup.DELETE("/devices/:token", api.HandleUnregisterDevice(dbStore))
+	apiGroup.GET("/push/mutes", api.HandleGetPushMutes(dbStore))
+	apiGroup.PUT("/push/mutes", api.HandleAddPushMute(dbStore))
+	apiGroup.DELETE("/push/mutes", api.HandleRemovePushMute(dbStore))
+
+	// Distribution lists: readable by everyone for addressing, managed by admins
+	apiGroup.GET("/lists", api.HandleGetLists(dbStore))
+	apiGroup.GET("/lists/:name", api.HandleGetList(dbStore))
+
+	// Retention policies: readable by everyone to pick a memo category, managed by admins
+	apiGroup.GET("/retention-policies", api.HandleGetRetentionPolicies(dbStore))
+
+	// Memo templates: usable by everyone, managed by admins
+	apiGroup.GET("/templates", api.HandleGetTemplates(dbStore))
+	apiGroup.GET("/templates/:name", api.HandleGetTemplate(dbStore))
+	apiGroup.POST("/templates/:name/send", limitSize, api.HandleSendTemplate(dbStore, limits))
+
+	adminGroup := apiGroup.Group("/admin", auth.RequireRole(models.RoleAdmin))
+	adminGroup.POST("/lists", api.HandleCreateList(dbStore))
+	adminGroup.PUT("/lists/:name", api.HandleUpdateList(dbStore))
+	adminGroup.DELETE("/lists/:name", api.HandleDeleteList(dbStore))
+	adminGroup.PUT("/retention-policies/:category", api.HandleSaveRetentionPolicy(dbStore))
+	adminGroup.DELETE("/retention-policies/:category", api.HandleDeleteRetentionPolicy(dbStore))
+	adminGroup.PUT("/memos/:id/legal-hold", api.HandleSetLegalHold(dbStore))
+	adminGroup.PUT("/templates/:name", api.HandleSaveTemplate(dbStore))
+	adminGroup.DELETE("/templates/:name", api.HandleDeleteTemplate(dbStore))
 
 	// Health check endpoint
 	r.GET("/health", func(c *gin.Context) {
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"memo-app/internal/config"
+	"memo-app/internal/store"
+)
+
+const migrateUsage = "usage: memo-app migrate up | down [steps] | status"
+
+// databaseURL returns DATABASE_URL, or the local development database when it is not set
+func databaseURL() string {
+	dbURL := os.Getenv("DATABASE_URL")
+	if dbURL == "" {
+		// Default MySQL connection for local development
+		// Format: username:password@tcp(host:port)/database?params
+		dbURL = config.DefaultDatabaseURL
+		log.Printf("DATABASE_URL not set, using default: %s", dbURL)
+	}
+	return dbURL
+}
+
+// runMigrate implements the migrate command: up applies pending migrations,
+// down rolls back the latest one (or steps of them) and status lists them all
+func runMigrate(args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	migrator, err := store.NewMigrator(databaseURL())
+	if err != nil {
+		return fmt.Errorf("connecting to database: %w", err)
+	}
+	defer migrator.Close()
+	ctx := context.Background()
+
+	switch args[0] {
+	case "up":
+		if len(args) != 1 {
+			return errors.New(migrateUsage)
+		}
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			return err
+		}
+		log.Printf("Database is up to date (%d migrations applied)", len(applied))
+	case "down":
+		steps := 1
+		if len(args) == 2 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				return fmt.Errorf("steps must be a positive number; %s", migrateUsage)
+			}
+		} else if len(args) > 2 {
+			return errors.New(migrateUsage)
+		}
+		rolledBack, err := migrator.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		log.Printf("Rolled back %d migrations", len(rolledBack))
+	case "status":
+		status, err := migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			applied := "pending"
+			if m.AppliedAt != nil {
+				applied = "applied " + m.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d  %-32s  %s\n", m.Version, m.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate command %q; %s", args[0], migrateUsage)
+	}
+	return nil
+}
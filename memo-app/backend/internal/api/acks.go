@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/csv"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleAcknowledgeMemo records that the requesting user has read and confirms a memo
+// Only recipients of a memo that requires acknowledgement can acknowledge it
+func HandleAcknowledgeMemo(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		delivery, err := store.Acknowledge(c.Param("id"), userEmail)
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotMemoRecipient, "Failed to acknowledge memo")
+			return
+		}
+
+		log.Printf("Memo %s acknowledged by %s", delivery.MemoID, userEmail)
+		c.JSON(http.StatusOK, delivery)
+	}
+}
+
+// HandleGetAckReport lists who has and has not acknowledged a memo, as JSON or,
+// with ?format=csv, as a CSV download. Only the sender or an admin can view it
+func HandleGetAckReport(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report, err := store.GetAckReport(c.Param("id"), auth.GetUser(c))
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotAckViewer, "Failed to load acknowledgements")
+			return
+		}
+
+		if c.Query("format") != "csv" {
+			c.JSON(http.StatusOK, report)
+			return
+		}
+
+		filename := "memo-" + report.MemoID + "-acknowledgements.csv"
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+		c.Status(http.StatusOK)
+		if err := writeAckCSV(c.Writer, report); err != nil {
+			log.Printf("Error writing acknowledgements of memo %s: %v", report.MemoID, err)
+		}
+	}
+}
+
+// writeAckCSV writes one row per recipient with RFC 3339 timestamps, empty when missing
+func writeAckCSV(w http.ResponseWriter, report *models.AckReport) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"recipient", "kind", "acknowledged", "acknowledged_at", "read_at", "reminders"})
+	for _, d := range report.Recipients {
+		out.Write([]string{
+			d.Recipient,
+			string(d.Kind),
+			strconv.FormatBool(d.AcknowledgedAt != nil),
+			formatOptionalTime(d.AcknowledgedAt),
+			formatOptionalTime(d.ReadAt),
+			strconv.Itoa(d.AckReminders),
+		})
+	}
+	out.Flush()
+	return out.Error()
+}
+
+// formatOptionalTime formats t as RFC 3339, or returns "" for nil
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
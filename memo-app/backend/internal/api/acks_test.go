@@ -0,0 +1,101 @@
+package api
+
+import (
+	"encoding/csv"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+)
+
+func TestAcknowledgeMemo(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "subject": "Policy", "message": "Please confirm", "requiresAck": true})
+
+	var delivery models.MemoDelivery
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "bob@example.com", nil), http.StatusOK, &delivery)
+	if delivery.AcknowledgedAt == nil || delivery.ReadAt == nil {
+		t.Fatalf("delivery = %+v, want acknowledged and read", delivery)
+	}
+	if s.unread("bob@example.com") != 0 {
+		t.Error("an acknowledged memo still counts as unread")
+	}
+
+	var report models.AckReport
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks", "alice@example.com", nil), http.StatusOK, &report)
+	if report.Total != 2 || report.Acknowledged != 1 || report.Pending != 1 || report.Recipients[0].Recipient != "carol@example.com" {
+		t.Errorf("report = %+v, want carol pending first", report)
+	}
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks", "bob@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks", "admin@example.com", nil, asAdmin()...), http.StatusOK, nil)
+
+	plain := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "FYI", "message": "No action"})
+	s.expect(s.do(http.MethodPost, "/api/memos/"+plain+"/ack", "bob@example.com", nil), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodGet, "/api/memos/"+plain+"/acks", "alice@example.com", nil), http.StatusConflict, nil)
+}
+
+func TestAcknowledgeMemoTwiceKeepsTheFirstTime(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "mallory@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Policy", "message": "Please confirm", "requiresAck": true})
+	var first, second models.MemoDelivery
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "bob@example.com", nil), http.StatusOK, &first)
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "bob@example.com", nil), http.StatusOK, &second)
+	if first.AcknowledgedAt == nil || second.AcknowledgedAt == nil || !second.AcknowledgedAt.Equal(*first.AcknowledgedAt) {
+		t.Fatalf("acknowledged at %v, then %v", first.AcknowledgedAt, second.AcknowledgedAt)
+	}
+
+	// Only recipients can acknowledge, including the sender of the memo
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "mallory@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "alice@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/missing/ack", "bob@example.com", nil), http.StatusNotFound, nil)
+
+	var report models.AckReport
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks", "alice@example.com", nil), http.StatusOK, &report)
+	if report.Total != 1 || report.Acknowledged != 1 {
+		t.Fatalf("report = %+v, want bob acknowledged once", report)
+	}
+}
+
+func TestAckReportAsCSV(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "cc": "carol@example.com", "subject": "Policy", "message": "Please confirm", "requiresAck": true})
+	var delivery models.MemoDelivery
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "bob@example.com", nil), http.StatusOK, &delivery)
+
+	w := s.do(http.MethodGet, "/api/memos/"+id+"/acks?format=csv", "alice@example.com", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Type"); got != "text/csv; charset=utf-8" {
+		t.Errorf("Content-Type = %q", got)
+	}
+	if got, want := w.Header().Get("Content-Disposition"), "attachment; filename=memo-"+id+"-acknowledgements.csv"; got != want {
+		t.Errorf("Content-Disposition = %q, want %q", got, want)
+	}
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	acknowledged := delivery.AcknowledgedAt.Format(time.RFC3339)
+	want := [][]string{
+		{"recipient", "kind", "acknowledged", "acknowledged_at", "read_at", "reminders"},
+		{"carol@example.com", "cc", "false", "", "", "0"},
+		{"bob@example.com", "to", "true", acknowledged, delivery.ReadAt.Format(time.RFC3339), "0"},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("csv rows %q, want %q", rows, want)
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks?format=csv", "bob@example.com", nil), http.StatusForbidden, nil)
+}
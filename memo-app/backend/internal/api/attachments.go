@@ -0,0 +1,79 @@
+package api
+
+import (
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/store"
+)
+
+// HandleUploadAttachment stores a file from the multipart "file" field
+// The returned ID is passed in attachmentIds when sending the memo
+func HandleUploadAttachment(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		// Leave room for the multipart framing around the file itself
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, config.MaxAttachmentBytes+1<<20)
+
+		header, err := c.FormFile("file")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrAttachmentRequired})
+			return
+		}
+		file, err := header.Open()
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrAttachmentRequired})
+			return
+		}
+		defer file.Close()
+
+		attachment, err := store.SaveAttachment(c.Request.Context(), userEmail, header.Filename, file)
+		if err != nil {
+			respondStoreError(c, err, config.ErrAttachmentForbidden, "Failed to store attachment")
+			return
+		}
+
+		log.Printf("Attachment %s uploaded by %s (%s, %d bytes)", attachment.ID, userEmail, attachment.ContentType, attachment.Size)
+		c.JSON(http.StatusCreated, attachment)
+	}
+}
+
+// HandleDownloadAttachment streams an attachment to its uploader or to the
+// sender and recipients of the memo it was sent with
+func HandleDownloadAttachment(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		attachment, content, err := store.OpenAttachment(c.Request.Context(), c.Param("id"), userEmail)
+		if err != nil {
+			respondStoreError(c, err, config.ErrAttachmentForbidden, "Failed to load attachment")
+			return
+		}
+		defer content.Close()
+
+		c.Header("Content-Type", attachment.ContentType)
+		c.Header("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Status(http.StatusOK)
+		if _, err := io.Copy(c.Writer, content); err != nil {
+			log.Printf("Error streaming attachment %s: %v", attachment.ID, err)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+// upload posts content as a multipart file upload by user
+func (s *testServer) upload(user string, filename string, content []byte) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", filename)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/attachments", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-User-Email", user)
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestAttachmentsAreVisibleToParticipants(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "mallory@example.com")
+
+	content := []byte("Minutes of the board meeting\n")
+	var attachment models.Attachment
+	s.expect(s.upload("alice@example.com", "../minutes.txt", content), http.StatusCreated, &attachment)
+	if attachment.Size != int64(len(content)) || attachment.Filename != "minutes.txt" || attachment.ContentType != "text/plain; charset=utf-8" {
+		t.Fatalf("attachment = %+v", attachment)
+	}
+	path := "/api/attachments/" + attachment.ID
+
+	// Until it is sent, only the uploader can fetch it
+	s.expect(s.do(http.MethodGet, path, "bob@example.com", nil), http.StatusNotFound, nil)
+	if w := s.do(http.MethodGet, path, "alice@example.com", nil); w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("uploader download: status %d body %q", w.Code, w.Body)
+	}
+
+	s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Minutes", "message": "See attached", "attachmentIds": []string{attachment.ID}})
+	w := s.do(http.MethodGet, path, "bob@example.com", nil)
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), content) {
+		t.Fatalf("recipient download: status %d body %q", w.Code, w.Body)
+	}
+	if got := w.Header().Get("Content-Disposition"); got != `attachment; filename=minutes.txt` {
+		t.Errorf("Content-Disposition = %q", got)
+	}
+	s.expect(s.do(http.MethodGet, path, "mallory@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodGet, "/api/attachments/missing", "alice@example.com", nil), http.StatusNotFound, nil)
+
+	// An attachment can only be sent once
+	s.expect(s.do(http.MethodPost, "/api/memos", "alice@example.com",
+		gin.H{"to": "bob@example.com", "subject": "Again", "message": "x", "attachmentIds": []string{attachment.ID}}), http.StatusBadRequest, nil)
+}
+
+func TestUploadAttachmentChecksTheFile(t *testing.T) {
+	s := newDBTestServer(t)
+
+	// Windows executables sniff as application/octet-stream, which is not allowed
+	s.expect(s.upload("alice@example.com", "setup.exe", append([]byte("MZ\x90\x00\x03\x00\x00\x00"), make([]byte, 64)...)), http.StatusUnsupportedMediaType, nil)
+	s.expect(s.upload("alice@example.com", "empty.txt", nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/attachments", "alice@example.com", nil), http.StatusBadRequest, nil)
+	s.expect(s.upload("alice@example.com", "big.txt", bytes.Repeat([]byte("x"), config.MaxAttachmentBytes+1)), http.StatusRequestEntityTooLarge, nil)
+
+	// Others cannot send someone else's upload
+	var attachment models.Attachment
+	s.expect(s.upload("alice@example.com", "notes.txt", []byte("notes")), http.StatusCreated, &attachment)
+	s.signIn("bob@example.com")
+	s.expect(s.do(http.MethodPost, "/api/memos", "bob@example.com",
+		gin.H{"to": "alice@example.com", "subject": "Mine", "message": "x", "attachmentIds": []string{attachment.ID}}), http.StatusBadRequest, nil)
+}
@@ -1,6 +1,7 @@
 package api
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -15,7 +16,7 @@ import (
 )
 
 // HandleSendMemo processes memo creation requests
-func HandleSendMemo(store *store.DBStore) gin.HandlerFunc {
+func HandleSendMemo(store store.MemoStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Get sender email from JWT token (or fallback for testing)
 		userEmail := auth.GetUserEmail(c)
@@ -38,41 +39,65 @@ func HandleSendMemo(store *store.DBStore) gin.HandlerFunc {
 			return
 		}
 
-		// Determine if this is a broadcast message
-		isBroadcast := req.IsBroadcast || req.To == config.BroadcastRecipient
-		recipient := req.To
-		if isBroadcast {
-			recipient = config.BroadcastRecipient
+		isBroadcast := isBroadcastRequest(req.IsBroadcast, req.Audience, req.To)
+		if !isBroadcast && len(req.To) == 0 && len(req.CC) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrNoRecipients})
+			return
 		}
 
-		// Create and save the memo
+		// Create and save the memo; the store expands distribution lists into recipients
 		memo := &models.Memo{
-			From:        userEmail,
-			To:          recipient,
-			Subject:     req.Subject,
-			Message:     req.Message,
-			IsBroadcast: isBroadcast,
-			TTLDays:     req.TTLDays,
+			From:          userEmail,
+			Subject:       req.Subject,
+			Message:       req.Message,
+			IsBroadcast:   isBroadcast,
+			Audience:      req.Audience,
+			TTLDays:       req.TTLDays,
+			Category:      req.Category,
+			RequiresAck:   req.RequiresAck,
+			AttachmentIDs: req.AttachmentIDs,
+			SendAt:        req.SendAt,
+			VisibleUntil:  req.VisibleUntil,
 		}
 
-		memoID := store.Add(memo)
-		if memoID == "" {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": config.ErrFailedToCreateMemo})
+		memoID, err := store.Add(memo, req.To, req.CC)
+		if err != nil {
+			respondStoreError(c, err, config.ErrBroadcastForbidden, config.ErrFailedToCreateMemo)
 			return
 		}
 
-		log.Printf("Memo created: %s -> %s (broadcast=%v, ttl=%v)", userEmail, recipient, isBroadcast, req.TTLDays)
+		log.Printf("Memo created: %s -> %v cc %v (broadcast=%v, ttl=%v)", userEmail, req.To, req.CC, isBroadcast, req.TTLDays)
+
+		message := config.MsgMemoSentSuccess
+		if memo.Status == models.StatusScheduled {
+			message = config.MsgMemoScheduledSuccess
+		}
 
 		c.JSON(http.StatusCreated, gin.H{
 			"id":      memoID,
 			"status":  memo.Status,
-			"message": config.MsgMemoSentSuccess,
+			"message": message,
 		})
 	}
 }
 
-// HandleGetSentMemos retrieves all memos sent by the requesting user
-func HandleGetSentMemos(store *store.DBStore) gin.HandlerFunc {
+// isBroadcastRequest tells whether a send request is a broadcast: flagged as one,
+// addressed to the broadcast recipient, or targeted at an audience
+func isBroadcastRequest(isBroadcast bool, audience *models.Audience, to models.AddressList) bool {
+	if isBroadcast || !audience.IsEmpty() {
+		return true
+	}
+	for _, addr := range to {
+		if addr == config.BroadcastRecipient {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleGetSentMemos retrieves a page of memos sent by the requesting user
+// With groupBy=thread, returns threads with their latest memo instead; pass nextCursor back as cursor for more
+func HandleGetSentMemos(store store.MemoStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userEmail := auth.GetUserEmail(c)
 		if userEmail == "" {
@@ -80,34 +105,39 @@ func HandleGetSentMemos(store *store.DBStore) gin.HandlerFunc {
 			return
 		}
 
-		// Get pagination parameters
+		// Get pagination parameters; cursor is the nextCursor of the previous page
 		limit := 20 // Default limit
-		offset := 0 // Default offset
-
 		if limitStr := c.Query("limit"); limitStr != "" {
 			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
 				limit = l
 			}
 		}
+		cursor := c.Query("cursor")
 
-		if offsetStr := c.Query("offset"); offsetStr != "" {
-			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-				offset = o
+		if c.Query("groupBy") == config.GroupByThread {
+			threads, err := store.GetSentThreads(userEmail, cursor, limit)
+			if err != nil {
+				respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load threads")
+				return
 			}
+			c.JSON(http.StatusOK, threads)
+			return
 		}
 
-		memos := store.GetSentMemos(userEmail, limit, offset)
-		if memos == nil {
-			memos = []*models.Memo{}
+		page, err := store.GetSentMemos(userEmail, cursor, limit)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load memos")
+			return
 		}
 
-		c.JSON(http.StatusOK, memos)
+		c.JSON(http.StatusOK, page)
 	}
 }
 
-// HandleGetReceivedMemos retrieves all memos received by the requesting user
+// HandleGetReceivedMemos retrieves a page of memos received by the requesting user
 // Includes both direct messages and broadcast messages
-func HandleGetReceivedMemos(store *store.DBStore) gin.HandlerFunc {
+// With groupBy=thread, returns threads with their latest memo instead; pass nextCursor back as cursor for more
+func HandleGetReceivedMemos(store store.MemoStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userEmail := auth.GetUserEmail(c)
 		if userEmail == "" {
@@ -115,34 +145,97 @@ func HandleGetReceivedMemos(store *store.DBStore) gin.HandlerFunc {
 			return
 		}
 
-		// Get pagination parameters
+		// Get pagination parameters; cursor is the nextCursor of the previous page
 		limit := 20 // Default limit
-		offset := 0 // Default offset
-
 		if limitStr := c.Query("limit"); limitStr != "" {
 			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
 				limit = l
 			}
 		}
+		cursor := c.Query("cursor")
 
-		if offsetStr := c.Query("offset"); offsetStr != "" {
-			if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-				offset = o
+		if c.Query("groupBy") == config.GroupByThread {
+			threads, err := store.GetReceivedThreads(userEmail, cursor, limit)
+			if err != nil {
+				respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load threads")
+				return
+			}
+			c.JSON(http.StatusOK, threads)
+			return
+		}
+
+		page, err := store.GetReceivedMemos(userEmail, cursor, limit)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load memos")
+			return
+		}
+
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// HandleGetUnreadCount returns how many received memos the requesting user has not read yet
+func HandleGetUnreadCount(store store.MemoStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		unread, err := store.UnreadCount(userEmail)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load unread count")
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"unread": unread})
+	}
+}
+
+// HandleSearchMemos finds memos the requesting user sent or received matching q
+// Supports "quoted phrases" and prefix* terms; pass nextCursor back as cursor for more
+func HandleSearchMemos(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		query := c.Query("q")
+		if query == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrSearchQueryRequired})
+			return
+		}
+
+		limit := 20 // Default limit
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
+				limit = l
 			}
 		}
 
-		memos := store.GetReceivedMemos(userEmail, limit, offset)
-		if memos == nil {
-			memos = []*models.Memo{}
+		page, err := store.Search(userEmail, query, c.Query("cursor"), limit)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to search memos")
+			return
 		}
 
-		c.JSON(http.StatusOK, memos)
+		c.JSON(http.StatusOK, page)
 	}
 }
 
-// HandleUpdateStatus updates the delivery status of a memo
-func HandleUpdateStatus(store *store.DBStore) gin.HandlerFunc {
+// HandleUpdateStatus records that the requesting user has received or read a memo
+// Only recipients of the memo can change its delivery state
+func HandleUpdateStatus(store store.MemoStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
 		memoID := c.Param("id")
 
 		var req struct {
@@ -154,23 +247,49 @@ func HandleUpdateStatus(store *store.DBStore) gin.HandlerFunc {
 			return
 		}
 
-		if !store.UpdateStatus(memoID, req.Status) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Memo not found"})
+		if req.Status != models.StatusDelivered && req.Status != models.StatusRead {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrInvalidStatus})
+			return
+		}
+
+		if err := store.UpdateStatus(memoID, userEmail, req.Status); err != nil {
+			respondStoreError(c, err, config.ErrNotMemoRecipient, "Failed to update status")
 			return
 		}
 
-		log.Printf("Memo %s status updated to: %s", memoID, req.Status)
+		log.Printf("Memo %s status updated to: %s by %s", memoID, req.Status, userEmail)
 		c.JSON(http.StatusOK, gin.H{"message": "Status updated successfully"})
 	}
 }
 
+// HandleGetReceipts returns per-recipient delivery and read state for a memo
+// Only the sender of the memo can view its receipts
+func HandleGetReceipts(store store.MemoStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		memo, ok := store.Get(c.Param("id"))
+		if !ok || memo.From != userEmail {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Memo not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, store.GetReceipts(memo.ID))
+	}
+}
+
 // HandleDeleteMemo removes a memo from the database
-func HandleDeleteMemo(store *store.DBStore) gin.HandlerFunc {
+// Only the sender or an admin can delete a memo
+func HandleDeleteMemo(store store.MemoStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		memoID := c.Param("id")
 
-		if !store.Delete(memoID) {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Memo not found"})
+		if err := store.Delete(memoID, auth.GetUser(c)); err != nil {
+			respondStoreError(c, err, config.ErrNotMemoOwner, "Failed to delete memo")
 			return
 		}
 
@@ -179,10 +298,80 @@ func HandleDeleteMemo(store *store.DBStore) gin.HandlerFunc {
 	}
 }
 
-// HandleGetActiveUsers retrieves a list of all known user emails
-func HandleGetActiveUsers(store *store.DBStore) gin.HandlerFunc {
+// HandleSearchUsers returns a page of the user directory with display names and avatars
+// The optional q query parameter keeps users whose email or display name starts with it,
+// for recipient autocompletion
+func HandleSearchUsers(store store.MemoStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		users := store.GetActiveUsers()
-		c.JSON(http.StatusOK, users)
+		limit := config.DefaultUserSearchLimit
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= config.MaxPageLimit {
+				limit = l
+			}
+		}
+
+		page, err := store.SearchUsers(c.Query("q"), c.Query("cursor"), limit)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to search users")
+			return
+		}
+		c.JSON(http.StatusOK, page)
+	}
+}
+
+// HandleGetAudienceOptions lists the roles, departments and locations a broadcast can be
+// targeted at, with how many users each reaches. Only users who can broadcast can see them
+func HandleGetAudienceOptions(store store.MemoStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := auth.GetUser(c)
+		if user == nil || !user.Role.CanBroadcast() {
+			c.JSON(http.StatusForbidden, gin.H{"error": config.ErrBroadcastForbidden})
+			return
+		}
+
+		options, err := store.GetAudienceOptions()
+		if err != nil {
+			respondStoreError(c, err, config.ErrBroadcastForbidden, "Failed to load audiences")
+			return
+		}
+		c.JSON(http.StatusOK, options)
+	}
+}
+
+// respondStoreError maps store errors to HTTP responses
+// forbiddenMsg and failureMsg are used for ErrForbidden and unexpected errors respectively
+func respondStoreError(c *gin.Context, err error, forbiddenMsg string, failureMsg string) {
+	switch {
+	case errors.Is(err, store.ErrMemoNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": config.ErrMemoNotFound})
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": forbiddenMsg})
+	case errors.Is(err, store.ErrInvalidAddress):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrListNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrListExists):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrAttachmentNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrAttachmentType):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrAttachmentTooLarge), errors.Is(err, store.ErrAttachmentQuota):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrPolicyNotFound), errors.Is(err, store.ErrDeviceNotFound), errors.Is(err, store.ErrMuteNotFound),
+		errors.Is(err, store.ErrTemplateNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrNotScheduled), errors.Is(err, store.ErrLegalHold), errors.Is(err, store.ErrAckNotRequired),
+		errors.Is(err, store.ErrRecalled), errors.Is(err, store.ErrNotSent), errors.Is(err, store.ErrEditWindowClosed),
+		errors.Is(err, store.ErrEditConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrInvalidAttachment), errors.Is(err, store.ErrInvalidSearch), errors.Is(err, store.ErrInvalidCursor),
+		errors.Is(err, store.ErrInvalidSchedule), errors.Is(err, store.ErrInvalidPolicy), errors.Is(err, store.ErrInvalidCategory),
+		errors.Is(err, store.ErrInvalidDevice), errors.Is(err, store.ErrInvalidMute), errors.Is(err, store.ErrInvalidEdit),
+		errors.Is(err, store.ErrInvalidAudience), errors.Is(err, store.ErrInvalidTemplate), errors.Is(err, store.ErrMissingVariable):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		log.Printf("Store error: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failureMsg})
 	}
 }
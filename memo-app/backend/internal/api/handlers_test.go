@@ -0,0 +1,855 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/blob"
+	"memo-app/internal/cache"
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+	"memo-app/internal/ratelimit"
+	"memo-app/internal/store"
+	"memo-app/internal/store/storetest"
+	"memo-app/internal/tickets"
+)
+
+// testServer serves the memo handlers behind dev authentication
+// newTestServer uses the in-memory store; newDBTestServer serves every route from MySQL
+type testServer struct {
+	t      *testing.T
+	router *gin.Engine
+	store  store.MemoStore
+	cache  cache.Cache
+
+	// Only set by newDBTestServer
+	db   *store.DBStore
+	push *push.FakeGateway
+	hub  *events.Hub
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newLimitedTestServer(t, SendLimits{Limiter: ratelimit.NewMemoryLimiter()})
+}
+
+// newLimitedTestServer is newTestServer with the given limits on sending
+func newLimitedTestServer(t *testing.T, limits SendLimits) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	memoCache := cache.NewMemoryCache(time.Minute)
+	memoStore := store.NewMemoryStore(memoCache, events.NewHub(config.SSEReplayBufferSize, config.SSESubscriberBuffer))
+
+	r := gin.New()
+	apiGroup := r.Group("/api", auth.DevAuthMiddleware(memoStore))
+	apiGroup.POST("/memos", LimitSending(limits), HandleSendMemo(memoStore))
+	apiGroup.GET("/memos/sent", HandleGetSentMemos(memoStore))
+	apiGroup.GET("/memos/received", HandleGetReceivedMemos(memoStore))
+	apiGroup.GET("/memos/unread-count", HandleGetUnreadCount(memoStore))
+	apiGroup.PUT("/memos/:id/status", HandleUpdateStatus(memoStore))
+	apiGroup.GET("/memos/:id/receipts", HandleGetReceipts(memoStore))
+	apiGroup.DELETE("/memos/:id", HandleDeleteMemo(memoStore))
+	apiGroup.GET("/users", HandleSearchUsers(memoStore))
+	apiGroup.GET("/audiences", HandleGetAudienceOptions(memoStore))
+
+	return &testServer{t: t, router: r, store: memoStore, cache: memoCache}
+}
+
+// newDBTestServer serves the routes of cmd/server from a freshly migrated MySQL database
+// Push notifications go to a fake gateway and attachments to a temporary directory.
+// Sending is not limited. Tests using it are skipped unless storetest.DSNEnv names a MySQL server
+func newDBTestServer(t *testing.T) *testServer {
+	t.Helper()
+	return newLimitedDBTestServer(t, SendLimits{Limiter: ratelimit.NewMemoryLimiter()})
+}
+
+// newLimitedDBTestServer is newDBTestServer with the given sending limits
+func newLimitedDBTestServer(t *testing.T, limits SendLimits) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dsn := storetest.NewDatabase(t)
+	migrator, err := store.NewMigrator(dsn)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	defer migrator.Close()
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	gateway := push.NewFakeGateway()
+	hub := events.NewHub(config.SSEReplayBufferSize, config.SSESubscriberBuffer)
+	memoCache := cache.NewMemoryCache(time.Minute)
+	dbStore, err := store.NewDBStore(dsn, memoCache, hub, blobs, gateway)
+	if err != nil {
+		t.Fatalf("NewDBStore: %v", err)
+	}
+	streamTickets := tickets.NewMemoryStore()
+	limitSending := LimitSending(limits)
+	limitSize := LimitSize(limits)
+
+	r := gin.New()
+	r.GET("/api/memos/stream", auth.StreamTicketMiddleware(streamTickets, dbStore), HandleStream(hub))
+
+	apiGroup := r.Group("/api", auth.DevAuthMiddleware(dbStore))
+	apiGroup.POST("/memos", limitSending, HandleSendMemo(dbStore))
+	apiGroup.GET("/memos/sent", HandleGetSentMemos(dbStore))
+	apiGroup.GET("/memos/received", HandleGetReceivedMemos(dbStore))
+	apiGroup.GET("/memos/search", HandleSearchMemos(dbStore))
+	apiGroup.POST("/memos/stream/ticket", HandleIssueStreamTicket(streamTickets))
+	apiGroup.GET("/memos/unread-count", HandleGetUnreadCount(dbStore))
+	apiGroup.GET("/memos/scheduled", HandleGetScheduledMemos(dbStore))
+	apiGroup.PUT("/memos/:id/schedule", limitSize, HandleUpdateScheduledMemo(dbStore))
+	apiGroup.DELETE("/memos/:id/schedule", HandleCancelScheduledMemo(dbStore))
+	apiGroup.PUT("/memos/:id/status", HandleUpdateStatus(dbStore))
+	apiGroup.GET("/memos/:id/receipts", HandleGetReceipts(dbStore))
+	apiGroup.POST("/memos/:id/ack", HandleAcknowledgeMemo(dbStore))
+	apiGroup.POST("/memos/:id/recall", HandleRecallMemo(dbStore))
+	apiGroup.PUT("/memos/:id", limitSize, HandleEditMemo(dbStore))
+	apiGroup.GET("/memos/:id/revisions", HandleGetRevisions(dbStore))
+	apiGroup.GET("/memos/:id/acks", HandleGetAckReport(dbStore))
+	apiGroup.POST("/memos/:id/reply", limitSending, HandleReplyMemo(dbStore))
+	apiGroup.GET("/threads/:id", HandleGetThread(dbStore))
+	apiGroup.POST("/attachments", HandleUploadAttachment(dbStore))
+	apiGroup.GET("/attachments/:id", HandleDownloadAttachment(dbStore))
+	apiGroup.DELETE("/memos/:id", HandleDeleteMemo(dbStore))
+	apiGroup.GET("/users", HandleSearchUsers(dbStore))
+	apiGroup.GET("/addresses", HandleLookupAddresses(dbStore))
+	apiGroup.GET("/audiences", HandleGetAudienceOptions(dbStore))
+	apiGroup.POST("/devices", HandleRegisterDevice(dbStore))
+	apiGroup.DELETE("/devices/:token", HandleUnregisterDevice(dbStore))
+	apiGroup.GET("/push/mutes", HandleGetPushMutes(dbStore))
+	apiGroup.PUT("/push/mutes", HandleAddPushMute(dbStore))
+	apiGroup.DELETE("/push/mutes", HandleRemovePushMute(dbStore))
+	apiGroup.GET("/lists", HandleGetLists(dbStore))
+	apiGroup.GET("/lists/:name", HandleGetList(dbStore))
+	apiGroup.GET("/retention-policies", HandleGetRetentionPolicies(dbStore))
+	apiGroup.GET("/templates", HandleGetTemplates(dbStore))
+	apiGroup.GET("/templates/:name", HandleGetTemplate(dbStore))
+	apiGroup.POST("/templates/:name/send", limitSize, HandleSendTemplate(dbStore, limits))
+
+	adminGroup := apiGroup.Group("/admin", auth.RequireRole(models.RoleAdmin))
+	adminGroup.POST("/lists", HandleCreateList(dbStore))
+	adminGroup.PUT("/lists/:name", HandleUpdateList(dbStore))
+	adminGroup.DELETE("/lists/:name", HandleDeleteList(dbStore))
+	adminGroup.PUT("/retention-policies/:category", HandleSaveRetentionPolicy(dbStore))
+	adminGroup.DELETE("/retention-policies/:category", HandleDeleteRetentionPolicy(dbStore))
+	adminGroup.PUT("/memos/:id/legal-hold", HandleSetLegalHold(dbStore))
+	adminGroup.PUT("/templates/:name", HandleSaveTemplate(dbStore))
+	adminGroup.DELETE("/templates/:name", HandleDeleteTemplate(dbStore))
+
+	return &testServer{t: t, router: r, store: dbStore, cache: memoCache, db: dbStore, push: gateway, hub: hub}
+}
+
+// asAdmin returns the headers of a request by an admin, for use with do and send
+func asAdmin() []string {
+	return []string{"X-User-Role", string(models.RoleAdmin)}
+}
+
+// do sends a request as user, who may be "" for an anonymous request; headers are name, value pairs
+func (s *testServer) do(method, path, user string, body interface{}, headers ...string) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if raw, ok := body.(string); ok {
+			payload.WriteString(raw)
+		} else if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatal(err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if user != "" {
+		req.Header.Set("X-User-Email", user)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	return w
+}
+
+// signIn makes users known to the store, as their first authenticated request would
+func (s *testServer) signIn(users ...string) {
+	s.t.Helper()
+	for _, user := range users {
+		s.expect(s.do(http.MethodGet, "/api/memos/unread-count", user, nil), http.StatusOK, nil)
+	}
+}
+
+// expect fails the test unless w has the given status, and decodes its body into out if set
+func (s *testServer) expect(w *httptest.ResponseRecorder, status int, out interface{}) {
+	s.t.Helper()
+	if w.Code != status {
+		s.t.Fatalf("got status %d, want %d: %s", w.Code, status, w.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("decoding %s: %v", w.Body.String(), err)
+		}
+	}
+}
+
+// send creates a memo as user and returns its ID
+func (s *testServer) send(user string, body gin.H, headers ...string) string {
+	s.t.Helper()
+	var created struct {
+		ID string `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/memos", user, body, headers...), http.StatusCreated, &created)
+	return created.ID
+}
+
+// page fetches a memo listing as user
+func (s *testServer) page(path, user string) models.MemoPage {
+	s.t.Helper()
+	var page models.MemoPage
+	s.expect(s.do(http.MethodGet, path, user, nil), http.StatusOK, &page)
+	return page
+}
+
+// received returns the IDs on the first page of memos user received
+func (s *testServer) received(user string) []string {
+	s.t.Helper()
+	return memoIDs(s.page("/api/memos/received", user).Memos)
+}
+
+func (s *testServer) unread(user string) int64 {
+	s.t.Helper()
+	var body struct {
+		Unread int64 `json:"unread"`
+	}
+	s.expect(s.do(http.MethodGet, "/api/memos/unread-count", user, nil), http.StatusOK, &body)
+	return body.Unread
+}
+
+// assertCacheConsistent checks that what users get, possibly from cache, is what the store
+// holds: every listing must be unchanged after the cache is emptied
+func (s *testServer) assertCacheConsistent(users ...string) {
+	s.t.Helper()
+	paths := []string{"/api/memos/sent", "/api/memos/received", "/api/memos/sent?limit=5", "/api/memos/received?limit=5"}
+	before := map[string]string{}
+	for _, user := range users {
+		for _, path := range paths {
+			before[user+path] = s.do(http.MethodGet, path, user, nil).Body.String()
+		}
+	}
+
+	s.cache.InvalidateBroadcastMemos()
+	for _, user := range users {
+		for _, path := range paths {
+			if after := s.do(http.MethodGet, path, user, nil).Body.String(); after != before[user+path] {
+				s.t.Fatalf("stale cache for %s %s:\ncached: %s\nstored: %s", user, path, before[user+path], after)
+			}
+		}
+	}
+}
+
+func memoIDs(memos []*models.Memo) []string {
+	ids := make([]string, 0, len(memos))
+	for _, m := range memos {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func errorOf(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decoding %s: %v", w.Body.String(), err)
+	}
+	return body.Error
+}
+
+func TestSendMemoRequiresAuthentication(t *testing.T) {
+	s := newTestServer(t)
+	w := s.do(http.MethodPost, "/api/memos", "", gin.H{"to": "bob@example.com", "subject": "Hi", "message": "Hello"})
+	s.expect(w, http.StatusUnauthorized, nil)
+}
+
+func TestSendMemoValidatesTTL(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com")
+	for _, ttl := range []int{0, -1} {
+		w := s.do(http.MethodPost, "/api/memos", "alice@example.com",
+			gin.H{"to": "bob@example.com", "subject": "Hi", "message": "Hello", "ttlDays": ttl})
+		s.expect(w, http.StatusBadRequest, nil)
+		if got := errorOf(t, w); got != config.ErrInvalidTTL {
+			t.Errorf("ttlDays %d: error %q, want %q", ttl, got, config.ErrInvalidTTL)
+		}
+	}
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Hi", "message": "Hello", "ttlDays": 1})
+	memo, ok := s.store.Get(id)
+	if !ok || memo.TTLDays == nil || *memo.TTLDays != 1 {
+		t.Fatalf("memo stored with ttl %v", memo.TTLDays)
+	}
+	if got := s.received("bob@example.com"); !reflect.DeepEqual(got, []string{id}) {
+		t.Fatalf("bob received %v, want [%s]", got, id)
+	}
+}
+
+func TestSendMemoValidatesBody(t *testing.T) {
+	s := newTestServer(t)
+	tests := map[string]struct {
+		body interface{}
+		want string
+	}{
+		"malformed json":  {body: `{"to": `},
+		"missing subject": {body: gin.H{"to": "bob@example.com", "message": "Hello"}},
+		"missing message": {body: gin.H{"to": "bob@example.com", "subject": "Hi"}},
+		"no recipients":   {body: gin.H{"subject": "Hi", "message": "Hello"}, want: config.ErrNoRecipients},
+		"unknown list":    {body: gin.H{"to": "engineering", "subject": "Hi", "message": "Hello"}},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := s.do(http.MethodPost, "/api/memos", "alice@example.com", tc.body)
+			s.expect(w, http.StatusBadRequest, nil)
+			if tc.want != "" && errorOf(t, w) != tc.want {
+				t.Fatalf("error %q, want %q", errorOf(t, w), tc.want)
+			}
+		})
+	}
+}
+
+func TestSendMemoDeliversToAndCC(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com", "carol@example.com", "dave@example.com")
+	id := s.send("alice@example.com", gin.H{
+		"to":      []string{"bob@example.com", "carol@example.com"},
+		"cc":      "carol@example.com, dave@example.com",
+		"subject": "Plan",
+		"message": "Details",
+	})
+
+	for _, user := range []string{"bob@example.com", "carol@example.com", "dave@example.com"} {
+		if got := s.received(user); !reflect.DeepEqual(got, []string{id}) {
+			t.Errorf("%s received %v, want [%s]", user, got, id)
+		}
+	}
+	if got := s.received("alice@example.com"); len(got) != 0 {
+		t.Errorf("sender received %v", got)
+	}
+
+	sent := s.page("/api/memos/sent", "alice@example.com").Memos
+	if len(sent) != 1 || sent[0].Receipts == nil || sent[0].Receipts.Total != 3 {
+		t.Fatalf("sent listing: %+v", sent)
+	}
+	if !reflect.DeepEqual(sent[0].Recipients, []string{"bob@example.com", "carol@example.com"}) ||
+		!reflect.DeepEqual(sent[0].CC, []string{"dave@example.com"}) {
+		t.Fatalf("recipients %v cc %v", sent[0].Recipients, sent[0].CC)
+	}
+}
+
+func TestBroadcastRequiresPrivilegedRole(t *testing.T) {
+	s := newTestServer(t)
+	for _, body := range []gin.H{
+		{"isBroadcast": true, "subject": "All hands", "message": "Now"},
+		{"to": config.BroadcastRecipient, "subject": "All hands", "message": "Now"},
+	} {
+		w := s.do(http.MethodPost, "/api/memos", "alice@example.com", body)
+		s.expect(w, http.StatusForbidden, nil)
+		if got := errorOf(t, w); got != config.ErrBroadcastForbidden {
+			t.Fatalf("error %q, want %q", got, config.ErrBroadcastForbidden)
+		}
+	}
+}
+
+func TestBroadcastReachesEveryUserKnownAtSendTime(t *testing.T) {
+	s := newTestServer(t)
+	for _, user := range []string{"bob@example.com", "carol@example.com"} {
+		s.received(user) // signs the user in
+	}
+
+	id := s.send("boss@example.com", gin.H{"to": config.BroadcastRecipient, "subject": "All hands", "message": "Now"},
+		"X-User-Role", string(models.RoleBroadcaster))
+
+	for _, user := range []string{"bob@example.com", "carol@example.com"} {
+		memos := s.page("/api/memos/received", user).Memos
+		if len(memos) != 1 || memos[0].ID != id || !memos[0].IsBroadcast || memos[0].To != config.BroadcastRecipient {
+			t.Fatalf("%s received %+v", user, memos)
+		}
+	}
+	if got := s.received("boss@example.com"); len(got) != 0 {
+		t.Fatalf("broadcaster received own broadcast: %v", got)
+	}
+	if got := s.received("newcomer@example.com"); len(got) != 0 {
+		t.Fatalf("user who joined later received %v", got)
+	}
+}
+
+func TestTargetedBroadcastReachesOnlyItsAudience(t *testing.T) {
+	s := newTestServer(t)
+	s.received("hr1@example.com")
+	s.do(http.MethodGet, "/api/memos/received", "hr2@example.com", nil, "X-User-Department", "hr", "X-User-Location", "berlin")
+	s.do(http.MethodGet, "/api/memos/received", "hr1@example.com", nil, "X-User-Department", "hr", "X-User-Location", "paris")
+	s.do(http.MethodGet, "/api/memos/received", "eng@example.com", nil, "X-User-Department", "engineering")
+
+	id := s.send("boss@example.com", gin.H{
+		"subject":  "Benefits",
+		"message":  "Update",
+		"audience": gin.H{"departments": []string{"hr"}, "locations": []string{"berlin"}},
+	}, "X-User-Role", string(models.RoleAdmin))
+
+	if got := s.received("hr2@example.com"); !reflect.DeepEqual(got, []string{id}) {
+		t.Errorf("hr in berlin received %v", got)
+	}
+	for _, user := range []string{"hr1@example.com", "eng@example.com"} {
+		if got := s.received(user); len(got) != 0 {
+			t.Errorf("%s outside the audience received %v", user, got)
+		}
+	}
+
+	w := s.do(http.MethodPost, "/api/memos", "boss@example.com",
+		gin.H{"subject": "Nobody", "message": "Here", "audience": gin.H{"departments": []string{"legal"}}})
+	s.expect(w, http.StatusBadRequest, nil)
+
+	w = s.do(http.MethodPost, "/api/memos", "boss@example.com",
+		gin.H{"subject": "Bad", "message": "Role", "audience": gin.H{"roles": []string{"owner"}}})
+	s.expect(w, http.StatusBadRequest, nil)
+}
+
+func TestListingPaginationBounds(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com")
+	var ids []string
+	for i := 0; i < 25; i++ {
+		ids = append(ids, s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": fmt.Sprintf("Memo %d", i), "message": "Body"}))
+	}
+
+	for _, listing := range []struct{ path, user string }{
+		{"/api/memos/sent", "alice@example.com"},
+		{"/api/memos/received", "bob@example.com"},
+	} {
+		t.Run(listing.path, func(t *testing.T) {
+			tests := map[string]struct {
+				query string
+				want  int
+				more  bool
+			}{
+				"default":          {query: "", want: 20, more: true},
+				"zero":             {query: "?limit=0", want: 20, more: true},
+				"negative":         {query: "?limit=-5", want: 20, more: true},
+				"not a number":     {query: "?limit=ten", want: 20, more: true},
+				"over the maximum": {query: "?limit=101", want: 20, more: true},
+				"maximum":          {query: "?limit=100", want: 25},
+				"one":              {query: "?limit=1", want: 1, more: true},
+				"exact":            {query: "?limit=25", want: 25},
+			}
+			for name, tc := range tests {
+				page := s.page(listing.path+tc.query, listing.user)
+				if len(page.Memos) != tc.want || (page.NextCursor != "") != tc.more {
+					t.Errorf("%s: got %d memos (cursor %q), want %d (more %v)", name, len(page.Memos), page.NextCursor, tc.want, tc.more)
+				}
+			}
+
+			// Walking the cursor visits every memo once, in the order of a single full page
+			all := memoIDs(s.page(listing.path+"?limit=100", listing.user).Memos)
+			var walked []string
+			path := listing.path + "?limit=10"
+			for pages := 0; ; pages++ {
+				if pages > 3 {
+					t.Fatal("cursor never ran out")
+				}
+				page := s.page(path, listing.user)
+				walked = append(walked, memoIDs(page.Memos)...)
+				if page.NextCursor == "" {
+					break
+				}
+				path = listing.path + "?limit=10&cursor=" + page.NextCursor
+			}
+			if !reflect.DeepEqual(walked, all) || len(walked) != len(ids) {
+				t.Fatalf("walked %v, want %v", walked, all)
+			}
+			if all[0] != ids[len(ids)-1] {
+				t.Fatalf("newest memo is %s, want %s", all[0], ids[len(ids)-1])
+			}
+
+			s.expect(s.do(http.MethodGet, listing.path+"?cursor=not-a-cursor!", listing.user, nil), http.StatusBadRequest, nil)
+		})
+	}
+}
+
+func TestListingsGroupedByThread(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com")
+	first := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "One", "message": "Body"})
+	second := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Two", "message": "Body"})
+
+	var page models.ThreadPage
+	s.expect(s.do(http.MethodGet, "/api/memos/received?groupBy=thread&limit=1", "bob@example.com", nil), http.StatusOK, &page)
+	if len(page.Threads) != 1 || page.Threads[0].ID != second || page.Threads[0].Unread != 1 || page.NextCursor == "" {
+		t.Fatalf("first page: %+v", page)
+	}
+	var next models.ThreadPage
+	s.expect(s.do(http.MethodGet, "/api/memos/received?groupBy=thread&limit=1&cursor="+page.NextCursor, "bob@example.com", nil), http.StatusOK, &next)
+	if len(next.Threads) != 1 || next.Threads[0].ID != first || next.NextCursor != "" {
+		t.Fatalf("second page: %+v", next)
+	}
+
+	var sent models.ThreadPage
+	s.expect(s.do(http.MethodGet, "/api/memos/sent?groupBy=thread", "alice@example.com", nil), http.StatusOK, &sent)
+	if len(sent.Threads) != 2 || sent.Threads[0].Latest == nil || sent.Threads[0].Latest.Subject != "Two" {
+		t.Fatalf("sent threads: %+v", sent)
+	}
+}
+
+func TestUpdateStatus(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com", "carol@example.com")
+	id := s.send("alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "subject": "Hi", "message": "Hello"})
+	if got := s.unread("bob@example.com"); got != 1 {
+		t.Fatalf("bob has %d unread, want 1", got)
+	}
+
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id+"/status", "bob@example.com", gin.H{"status": "read"}), http.StatusOK, nil)
+	if got := s.unread("bob@example.com"); got != 0 {
+		t.Fatalf("bob has %d unread after reading, want 0", got)
+	}
+	memos := s.page("/api/memos/received", "bob@example.com").Memos
+	if len(memos) != 1 || memos[0].Status != models.StatusRead || memos[0].ReadAt == nil {
+		t.Fatalf("bob's view after reading: %+v", memos)
+	}
+	if memos := s.page("/api/memos/received", "carol@example.com").Memos; memos[0].Status != models.StatusSent {
+		t.Fatalf("carol's view changed with bob's read: %s", memos[0].Status)
+	}
+
+	// The memo is delivered once every recipient has received it
+	if memo, _ := s.store.Get(id); memo.Status != models.StatusSent {
+		t.Fatalf("memo status %s before carol received it", memo.Status)
+	}
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id+"/status", "carol@example.com", gin.H{"status": "delivered"}), http.StatusOK, nil)
+	if memo, _ := s.store.Get(id); memo.Status != models.StatusDelivered || memo.DeliveredAt == nil {
+		t.Fatalf("memo status %s after everyone received it", memo.Status)
+	}
+
+	tests := map[string]struct {
+		id, user string
+		body     interface{}
+		want     int
+	}{
+		"not a recipient": {id: id, user: "mallory@example.com", body: gin.H{"status": "read"}, want: http.StatusForbidden},
+		"unknown memo":    {id: "missing", user: "bob@example.com", body: gin.H{"status": "read"}, want: http.StatusNotFound},
+		"invalid status":  {id: id, user: "bob@example.com", body: gin.H{"status": "archived"}, want: http.StatusBadRequest},
+		"missing status":  {id: id, user: "bob@example.com", body: gin.H{}, want: http.StatusBadRequest},
+	}
+	for name, tc := range tests {
+		if w := s.do(http.MethodPut, "/api/memos/"+tc.id+"/status", tc.user, tc.body); w.Code != tc.want {
+			t.Errorf("%s: status %d, want %d: %s", name, w.Code, tc.want, w.Body.String())
+		}
+	}
+}
+
+func TestGetReceiptsOnlyForSender(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com", "carol@example.com")
+	id := s.send("alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "subject": "Hi", "message": "Hello"})
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id+"/status", "bob@example.com", gin.H{"status": "read"}), http.StatusOK, nil)
+
+	var receipts models.MemoReceipts
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/receipts", "alice@example.com", nil), http.StatusOK, &receipts)
+	want := models.ReceiptSummary{Total: 2, Delivered: 1, Read: 1}
+	if receipts.Summary != want || len(receipts.Recipients) != 2 || receipts.Recipients[0].Recipient != "bob@example.com" {
+		t.Fatalf("receipts: %+v", receipts)
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/receipts", "bob@example.com", nil), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, "/api/memos/missing/receipts", "alice@example.com", nil), http.StatusNotFound, nil)
+}
+
+func TestDeleteMemo(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com")
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Hi", "message": "Hello"})
+
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id, "bob@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id, "alice@example.com", nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id, "alice@example.com", nil), http.StatusNotFound, nil)
+	if got := s.received("bob@example.com"); len(got) != 0 {
+		t.Fatalf("bob still receives deleted memo: %v", got)
+	}
+
+	// Admins can delete anyone's memo
+	id = s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Again", "message": "Hello"})
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id, "root@example.com", nil, "X-User-Role", string(models.RoleAdmin)), http.StatusOK, nil)
+	if _, ok := s.store.Get(id); ok {
+		t.Fatal("memo still stored after admin deleted it")
+	}
+}
+
+func TestSearchMemos(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "mallory@example.com")
+
+	budget := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Quarterly budget", "message": "The budget review is on Friday"})
+	s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Lunch", "message": "Pizza on Friday"})
+
+	for _, query := range []string{"budget", "budg*", `"budget review"`, "BUDGET friday"} {
+		var page models.SearchPage
+		s.expect(s.do(http.MethodGet, "/api/memos/search?q="+url.QueryEscape(query), "bob@example.com", nil), http.StatusOK, &page)
+		if len(page.Results) != 1 || page.Results[0].Memo.ID != budget {
+			t.Errorf("search %q found %d results, want the budget memo", query, len(page.Results))
+			continue
+		}
+		if !strings.Contains(page.Results[0].Snippet, "<mark>") {
+			t.Errorf("search %q snippet %q has no highlight", query, page.Results[0].Snippet)
+		}
+	}
+
+	var page models.SearchPage
+	s.expect(s.do(http.MethodGet, "/api/memos/search?q=friday&limit=1", "alice@example.com", nil), http.StatusOK, &page)
+	if len(page.Results) != 1 || page.NextCursor == "" {
+		t.Fatalf("first page = %d results, cursor %q; want 1 and a cursor", len(page.Results), page.NextCursor)
+	}
+	first := page.Results[0].Memo.ID
+	s.expect(s.do(http.MethodGet, "/api/memos/search?q=friday&limit=1&cursor="+page.NextCursor, "alice@example.com", nil), http.StatusOK, &page)
+	if len(page.Results) != 1 || page.Results[0].Memo.ID == first || page.NextCursor != "" {
+		t.Errorf("second page = %d results, cursor %q; want the other memo and no cursor", len(page.Results), page.NextCursor)
+	}
+
+	// Outsiders find nothing; queries without searchable terms are rejected
+	s.expect(s.do(http.MethodGet, "/api/memos/search?q=budget", "mallory@example.com", nil), http.StatusOK, &page)
+	if len(page.Results) != 0 {
+		t.Errorf("mallory found %d memos of others", len(page.Results))
+	}
+	s.expect(s.do(http.MethodGet, "/api/memos/search", "bob@example.com", nil), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodGet, "/api/memos/search?q=a+%2B-", "bob@example.com", nil), http.StatusBadRequest, nil)
+}
+
+func TestSearchUsers(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodGet, "/api/users", "alice@example.com", nil, "X-User-Name", "Alice Liddell", "X-User-Avatar", "https://example.com/alice.png")
+	s.do(http.MethodGet, "/api/users", "bob@example.com", nil, "X-User-Name", "Bob Stone")
+	s.do(http.MethodGet, "/api/users", "carol@example.com", nil, "X-User-Avatar", "javascript:alert(1)")
+	s.do(http.MethodGet, "/api/users", "albert@example.com", nil, "X-User-Name", "Zed")
+
+	search := func(query string) []string {
+		t.Helper()
+		var page models.UserPage
+		s.expect(s.do(http.MethodGet, "/api/users?"+query, "alice@example.com", nil), http.StatusOK, &page)
+		emails := []string{}
+		for _, u := range page.Users {
+			emails = append(emails, u.Email)
+		}
+		return emails
+	}
+	tests := map[string][]string{
+		"":          {"albert@example.com", "alice@example.com", "bob@example.com", "carol@example.com"},
+		"q=al":      {"albert@example.com", "alice@example.com"},
+		"q=ALI":     {"alice@example.com"},
+		"q=bob+st":  {"bob@example.com"},
+		"q=ze":      {"albert@example.com"},
+		"q=stone":   {},
+		"q=%25":     {},
+		"q=example": {},
+	}
+	for query, want := range tests {
+		if got := search(query); !reflect.DeepEqual(got, want) {
+			t.Errorf("%q: got %v, want %v", query, got, want)
+		}
+	}
+
+	var page models.UserPage
+	s.expect(s.do(http.MethodGet, "/api/users?q=alice", "bob@example.com", nil), http.StatusOK, &page)
+	if len(page.Users) != 1 || page.Users[0].DisplayName != "Alice Liddell" || page.Users[0].AvatarURL != "https://example.com/alice.png" {
+		t.Fatalf("alice's profile: %+v", page.Users)
+	}
+	var carol models.UserPage
+	s.expect(s.do(http.MethodGet, "/api/users?q=carol", "bob@example.com", nil), http.StatusOK, &carol)
+	if carol.Users[0].AvatarURL != "" {
+		t.Fatalf("non-web avatar stored: %q", carol.Users[0].AvatarURL)
+	}
+
+	// Walking the directory a page at a time visits everyone once
+	var walked []string
+	cursor := ""
+	for i := 0; i < 10; i++ {
+		var next models.UserPage
+		s.expect(s.do(http.MethodGet, "/api/users?limit=1&cursor="+cursor, "alice@example.com", nil), http.StatusOK, &next)
+		for _, u := range next.Users {
+			walked = append(walked, u.Email)
+		}
+		if cursor = next.NextCursor; cursor == "" {
+			break
+		}
+	}
+	if want := search(""); !reflect.DeepEqual(walked, want) {
+		t.Fatalf("walked %v, want %v", walked, want)
+	}
+	s.expect(s.do(http.MethodGet, "/api/users?cursor=not-a-cursor", "alice@example.com", nil), http.StatusBadRequest, nil)
+}
+
+func TestSendToUnknownUserIsRejected(t *testing.T) {
+	s := newTestServer(t)
+	s.signIn("bob@example.com")
+	w := s.do(http.MethodPost, "/api/memos", "alice@example.com",
+		gin.H{"to": "bob@example.com", "cc": "bbo@example.com", "subject": "Hi", "message": "Hello"})
+	s.expect(w, http.StatusBadRequest, nil)
+	if got := errorOf(t, w); !strings.Contains(got, "bbo@example.com") {
+		t.Fatalf("error %q does not name the unknown address", got)
+	}
+	if got := s.received("bob@example.com"); len(got) != 0 {
+		t.Fatalf("bob received %v from a rejected memo", got)
+	}
+	if _, err := s.store.GetUserByEmail("bbo@example.com"); err == nil {
+		t.Fatal("unknown address was registered as a user")
+	}
+}
+
+func TestGetAudienceOptions(t *testing.T) {
+	s := newTestServer(t)
+	s.do(http.MethodGet, "/api/users", "a@example.com", nil, "X-User-Department", "hr", "X-User-Location", "berlin")
+	s.do(http.MethodGet, "/api/users", "b@example.com", nil, "X-User-Department", "hr")
+
+	s.expect(s.do(http.MethodGet, "/api/audiences", "a@example.com", nil), http.StatusForbidden, nil)
+
+	var options models.AudienceOptions
+	s.expect(s.do(http.MethodGet, "/api/audiences", "boss@example.com", nil, "X-User-Role", string(models.RoleBroadcaster)), http.StatusOK, &options)
+	if want := []models.AudienceValue{{Value: "hr", Users: 2}}; !reflect.DeepEqual(options.Departments, want) {
+		t.Errorf("departments %v, want %v", options.Departments, want)
+	}
+	if want := []models.AudienceValue{{Value: "berlin", Users: 1}}; !reflect.DeepEqual(options.Locations, want) {
+		t.Errorf("locations %v, want %v", options.Locations, want)
+	}
+	if want := []models.AudienceValue{{Value: "broadcaster", Users: 1}, {Value: "user", Users: 2}}; !reflect.DeepEqual(options.Roles, want) {
+		t.Errorf("roles %v, want %v", options.Roles, want)
+	}
+}
+
+func TestCacheStaysConsistentWithStore(t *testing.T) {
+	s := newTestServer(t)
+	users := []string{"alice@example.com", "bob@example.com", "carol@example.com", "boss@example.com"}
+	for _, user := range users {
+		s.received(user)
+	}
+	s.do(http.MethodGet, "/api/users", "boss@example.com", nil, "X-User-Role", string(models.RoleBroadcaster))
+	s.assertCacheConsistent(users...)
+
+	steps := []struct {
+		name string
+		run  func() string
+	}{
+		{"direct memo", func() string {
+			return s.send("alice@example.com", gin.H{"to": "bob@example.com", "cc": "carol@example.com", "subject": "Hi", "message": "Hello"})
+		}},
+		{"broadcast", func() string {
+			return s.send("boss@example.com", gin.H{"isBroadcast": true, "subject": "All", "message": "Hands"})
+		}},
+		{"memo to a user who signed in later", func() string {
+			return s.send("bob@example.com", gin.H{"to": "newcomer@example.com", "subject": "Welcome", "message": "Hello"})
+		}},
+	}
+	var ids []string
+	for _, step := range steps {
+		for _, user := range users {
+			s.received(user) // warm the caches before every change
+			s.page("/api/memos/sent", user)
+		}
+		ids = append(ids, step.run())
+		s.assertCacheConsistent(append(users, "newcomer@example.com")...)
+	}
+
+	if got := s.received("bob@example.com"); !reflect.DeepEqual(got, []string{ids[1], ids[0]}) {
+		t.Fatalf("bob received %v, want %v", got, []string{ids[1], ids[0]})
+	}
+
+	s.expect(s.do(http.MethodPut, "/api/memos/"+ids[0]+"/status", "bob@example.com", gin.H{"status": "read"}), http.StatusOK, nil)
+	s.assertCacheConsistent(users...)
+	if sent := s.page("/api/memos/sent", "alice@example.com").Memos; sent[0].Receipts.Read != 1 {
+		t.Fatalf("sender's cached receipts not refreshed: %+v", sent[0].Receipts)
+	}
+
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+ids[1], "boss@example.com", nil), http.StatusOK, nil)
+	s.assertCacheConsistent(users...)
+	for _, user := range []string{"alice@example.com", "bob@example.com", "carol@example.com"} {
+		for _, id := range s.received(user) {
+			if id == ids[1] {
+				t.Fatalf("%s still lists the deleted broadcast", user)
+			}
+		}
+	}
+	if _, ok := s.store.Get(ids[1]); ok {
+		t.Fatal("deleted memo still served from cache")
+	}
+
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+ids[0], "alice@example.com", nil), http.StatusOK, nil)
+	s.assertCacheConsistent(users...)
+	if got := strings.Join(s.received("carol@example.com"), ","); got != "" {
+		t.Fatalf("carol still lists %s", got)
+	}
+}
+
+func TestSendingIsRateLimited(t *testing.T) {
+	s := newLimitedTestServer(t, SendLimits{
+		Limiter: ratelimit.NewMemoryLimiter(),
+		RoleQuotas: map[models.UserRole]ratelimit.Quota{
+			models.RoleUser:        {Burst: 2, Period: time.Hour},
+			models.RoleBroadcaster: {Burst: 10, Period: time.Hour},
+		},
+		BroadcastQuota: ratelimit.Quota{Burst: 1, Period: time.Hour},
+	})
+	s.signIn("bob@example.com")
+	memo := gin.H{"to": "bob@example.com", "subject": "Hi", "message": "Hello"}
+
+	s.send("alice@example.com", memo)
+	s.send("alice@example.com", memo)
+	w := s.do(http.MethodPost, "/api/memos", "alice@example.com", memo)
+	s.expect(w, http.StatusTooManyRequests, nil)
+	if retry, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || retry < 1 || retry > 1800 {
+		t.Fatalf("Retry-After %q, want the seconds until a send refills", w.Header().Get("Retry-After"))
+	}
+	// Quotas are per user
+	s.send("carol@example.com", memo)
+
+	broadcaster := []string{"X-User-Role", string(models.RoleBroadcaster)}
+	s.send("boss@example.com", gin.H{"isBroadcast": true, "subject": "All", "message": "Hands"}, broadcaster...)
+	w = s.do(http.MethodPost, "/api/memos", "boss@example.com", gin.H{"to": "broadcast", "subject": "Again", "message": "Hands"}, broadcaster...)
+	s.expect(w, http.StatusTooManyRequests, nil)
+	// The broadcast quota doesn't hold back direct memos
+	s.send("boss@example.com", memo, broadcaster...)
+}
+
+func TestSendingRejectsOversizedMemos(t *testing.T) {
+	s := newLimitedTestServer(t, SendLimits{Limiter: ratelimit.NewMemoryLimiter(), MaxMessageBytes: 1000, MaxRecipients: 2})
+	s.signIn("bob@example.com", "carol@example.com", "dave@example.com")
+
+	s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Hi", "message": strings.Repeat("x", 1000)})
+	w := s.do(http.MethodPost, "/api/memos", "alice@example.com", gin.H{"to": "bob@example.com", "subject": "Hi", "message": strings.Repeat("x", 1001)})
+	s.expect(w, http.StatusRequestEntityTooLarge, nil)
+	// Bodies far over the limit are cut off while reading
+	w = s.do(http.MethodPost, "/api/memos", "alice@example.com", gin.H{"to": "bob@example.com", "subject": "Hi", "message": strings.Repeat("x", 1<<20)})
+	s.expect(w, http.StatusRequestEntityTooLarge, nil)
+
+	w = s.do(http.MethodPost, "/api/memos", "alice@example.com",
+		gin.H{"to": "bob@example.com, carol@example.com", "cc": "dave@example.com", "subject": "Hi", "message": "Hello"})
+	s.expect(w, http.StatusBadRequest, nil)
+	s.send("alice@example.com", gin.H{"to": "bob@example.com", "cc": "carol@example.com", "subject": "Hi", "message": "Hello"})
+
+	// Malformed requests still get the handler's error
+	s.expect(s.do(http.MethodPost, "/api/memos", "alice@example.com", `{"to": `), http.StatusBadRequest, nil)
+}
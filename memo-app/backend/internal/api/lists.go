@@ -0,0 +1,118 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleGetLists returns every distribution list with its members
+func HandleGetLists(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		lists, err := store.ListDistributionLists()
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load distribution lists")
+			return
+		}
+		c.JSON(http.StatusOK, lists)
+	}
+}
+
+// HandleGetList returns a single distribution list
+func HandleGetList(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		list, err := store.GetDistributionList(c.Param("name"))
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load distribution list")
+			return
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// HandleCreateList creates a distribution list (admin only)
+func HandleCreateList(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.DistributionListRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		list := &models.DistributionList{
+			Name:        req.Name,
+			Description: req.Description,
+			CreatedBy:   auth.GetUserEmail(c),
+			Members:     req.Members,
+		}
+		if err := store.CreateDistributionList(list); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to create distribution list")
+			return
+		}
+
+		log.Printf("Distribution list %s created by %s with %d members", list.Name, list.CreatedBy, len(list.Members))
+		c.JSON(http.StatusCreated, list)
+	}
+}
+
+// HandleUpdateList replaces the description and members of a distribution list (admin only)
+func HandleUpdateList(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.DistributionListRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		list, err := store.UpdateDistributionList(c.Param("name"), req.Description, req.Members)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to update distribution list")
+			return
+		}
+
+		log.Printf("Distribution list %s updated by %s", list.Name, auth.GetUserEmail(c))
+		c.JSON(http.StatusOK, list)
+	}
+}
+
+// HandleDeleteList removes a distribution list (admin only)
+// Memos already sent to the list are not affected
+func HandleDeleteList(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := store.DeleteDistributionList(name); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to delete distribution list")
+			return
+		}
+
+		log.Printf("Distribution list %s deleted by %s", name, auth.GetUserEmail(c))
+		c.JSON(http.StatusOK, gin.H{"message": "Distribution list deleted successfully"})
+	}
+}
+
+// HandleLookupAddresses returns users and distribution lists matching the q query parameter
+// for recipient autocompletion
+func HandleLookupAddresses(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := config.DefaultAddressLookupLimit
+		if limitStr := c.Query("limit"); limitStr != "" {
+			if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= config.MaxPageLimit {
+				limit = l
+			}
+		}
+
+		addresses, err := store.LookupAddresses(c.Query("q"), limit)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to look up addresses")
+			return
+		}
+		c.JSON(http.StatusOK, addresses)
+	}
+}
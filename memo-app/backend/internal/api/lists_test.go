@@ -0,0 +1,101 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+)
+
+func TestDistributionListsAreManagedByAdmins(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	list := gin.H{"name": "engineering", "description": "All engineers", "members": []string{"bob@example.com", "carol@example.com"}}
+	s.expect(s.do(http.MethodPost, "/api/admin/lists", "alice@example.com", list), http.StatusForbidden, nil)
+
+	var created models.DistributionList
+	s.expect(s.do(http.MethodPost, "/api/admin/lists", "admin@example.com", list, asAdmin()...), http.StatusCreated, &created)
+	if len(created.Members) != 2 {
+		t.Fatalf("created list members = %v", created.Members)
+	}
+	s.expect(s.do(http.MethodPost, "/api/admin/lists", "admin@example.com", list, asAdmin()...), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodPost, "/api/admin/lists", "admin@example.com",
+		gin.H{"name": "typos", "members": []string{"bbo@example.com"}}, asAdmin()...), http.StatusBadRequest, nil)
+
+	var updated models.DistributionList
+	s.expect(s.do(http.MethodPut, "/api/admin/lists/engineering", "admin@example.com",
+		gin.H{"description": "Platform", "members": []string{"bob@example.com"}}, asAdmin()...), http.StatusOK, &updated)
+	if updated.Description != "Platform" || len(updated.Members) != 1 || updated.Members[0] != "bob@example.com" {
+		t.Errorf("updated list = %+v", updated)
+	}
+
+	var lists []*models.DistributionList
+	s.expect(s.do(http.MethodGet, "/api/lists", "alice@example.com", nil), http.StatusOK, &lists)
+	if len(lists) != 1 || lists[0].Name != "engineering" {
+		t.Errorf("lists = %+v, want [engineering]", lists)
+	}
+	s.expect(s.do(http.MethodGet, "/api/lists/engineering", "alice@example.com", nil), http.StatusOK, &updated)
+
+	s.expect(s.do(http.MethodDelete, "/api/admin/lists/engineering", "admin@example.com", nil, asAdmin()...), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/lists/engineering", "alice@example.com", nil), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, "/api/admin/lists/engineering", "admin@example.com", nil, asAdmin()...), http.StatusNotFound, nil)
+}
+
+func TestSendToDistributionList(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com")
+	s.expect(s.do(http.MethodPost, "/api/admin/lists", "admin@example.com",
+		gin.H{"name": "engineering", "members": []string{"alice@example.com", "bob@example.com", "carol@example.com"}}, asAdmin()...), http.StatusCreated, nil)
+
+	id := s.send("alice@example.com", gin.H{"to": "engineering", "cc": "dave@example.com", "subject": "Standup", "message": "Moved to 10"})
+	for _, user := range []string{"bob@example.com", "carol@example.com", "dave@example.com"} {
+		if !slices.Contains(s.received(user), id) {
+			t.Errorf("%s did not receive the memo", user)
+		}
+	}
+	// The sender is skipped when only reached through the list
+	if slices.Contains(s.received("alice@example.com"), id) {
+		t.Error("alice received her own memo through the list")
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/memos", "alice@example.com", gin.H{"to": "no-such-list", "subject": "x", "message": "x"}), http.StatusBadRequest, nil)
+}
+
+func TestLookupAddresses(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("bob@example.com", "bea@example.com", "carol@example.com")
+	s.expect(s.do(http.MethodGet, "/api/users", "brian@example.com", nil, "X-User-Name", "Zed"), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/admin/lists", "admin@example.com",
+		gin.H{"name": "backend", "description": "Backend team", "members": []string{"bob@example.com", "bea@example.com"}}, asAdmin()...), http.StatusCreated, nil)
+
+	var addresses []models.Address
+	s.expect(s.do(http.MethodGet, "/api/addresses?q=b", "carol@example.com", nil), http.StatusOK, &addresses)
+	want := []models.Address{
+		{Address: "backend", Type: models.AddressTypeList, Description: "Backend team", MemberCount: 2},
+		{Address: "bea@example.com", Type: models.AddressTypeUser},
+		{Address: "bob@example.com", Type: models.AddressTypeUser},
+		{Address: "brian@example.com", Type: models.AddressTypeUser},
+	}
+	if !slices.Equal(addresses, want) {
+		t.Errorf("addresses = %+v, want %+v", addresses, want)
+	}
+
+	// Display names match by prefix too; the middle of an address does not
+	s.expect(s.do(http.MethodGet, "/api/addresses?q=zed", "carol@example.com", nil), http.StatusOK, &addresses)
+	if len(addresses) != 1 || addresses[0].Address != "brian@example.com" {
+		t.Errorf("display name lookup = %+v, want brian", addresses)
+	}
+	s.expect(s.do(http.MethodGet, "/api/addresses?q=example", "carol@example.com", nil), http.StatusOK, &addresses)
+	if len(addresses) != 0 {
+		t.Errorf("lookup by domain = %+v, want none", addresses)
+	}
+
+	s.expect(s.do(http.MethodGet, "/api/addresses?q=b&limit=2", "carol@example.com", nil), http.StatusOK, &addresses)
+	if len(addresses) != 2 || addresses[0].Address != "backend" || addresses[1].Address != "bea@example.com" {
+		t.Errorf("limited lookup = %+v", addresses)
+	}
+}
@@ -0,0 +1,88 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleRegisterDevice registers the caller's device for push notifications
+// The mobile app calls it at every start, so registering a known token is not an error
+func HandleRegisterDevice(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RegisterDeviceRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		userEmail := auth.GetUserEmail(c)
+		device, err := store.RegisterDevice(userEmail, req.Token, req.Platform)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to register device")
+			return
+		}
+
+		log.Printf("Device registered for %s (%s)", userEmail, device.Platform)
+		c.JSON(http.StatusOK, device)
+	}
+}
+
+// HandleUnregisterDevice stops push notifications to one of the caller's devices
+func HandleUnregisterDevice(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := store.UnregisterDevice(auth.GetUserEmail(c), c.Param("token")); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to unregister device")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Device unregistered successfully"})
+	}
+}
+
+// HandleGetPushMutes lists the senders and categories the caller has muted
+func HandleGetPushMutes(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		mutes, err := store.ListMutes(auth.GetUserEmail(c))
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load mutes")
+			return
+		}
+		c.JSON(http.StatusOK, mutes)
+	}
+}
+
+// HandleAddPushMute mutes push notifications from a sender or of a category
+func HandleAddPushMute(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PushMuteRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		mute, err := store.AddMute(auth.GetUserEmail(c), req.Kind, req.Value)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to add mute")
+			return
+		}
+		c.JSON(http.StatusOK, mute)
+	}
+}
+
+// HandleRemovePushMute unmutes a sender or category given as ?kind=&value=
+func HandleRemovePushMute(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind := models.MuteKind(c.Query("kind"))
+		if err := store.RemoveMute(auth.GetUserEmail(c), kind, c.Query("value")); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to remove mute")
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Mute removed successfully"})
+	}
+}
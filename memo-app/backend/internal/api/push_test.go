@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+)
+
+func TestRegisterDevice(t *testing.T) {
+	s := newDBTestServer(t)
+
+	var device models.DeviceToken
+	s.expect(s.do(http.MethodPost, "/api/devices", "alice@example.com", gin.H{"token": "tok-1", "platform": "android"}), http.StatusOK, &device)
+	if device.Email != "alice@example.com" || device.Platform != "android" {
+		t.Errorf("device = %+v", device)
+	}
+	// Registering again is not an error; a handed-over phone moves to its new owner
+	s.expect(s.do(http.MethodPost, "/api/devices", "alice@example.com", gin.H{"token": "tok-1"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/devices", "bob@example.com", gin.H{"token": "tok-1", "platform": "ios"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, "/api/devices/tok-1", "alice@example.com", nil), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, "/api/devices/tok-1", "bob@example.com", nil), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/devices", "alice@example.com", gin.H{}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/devices", "alice@example.com", gin.H{"token": "   "}), http.StatusBadRequest, nil)
+}
+
+func TestPushMutes(t *testing.T) {
+	s := newDBTestServer(t)
+
+	mute := gin.H{"kind": models.MuteSender, "value": "newsletter@example.com"}
+	s.expect(s.do(http.MethodPut, "/api/push/mutes", "alice@example.com", mute), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/api/push/mutes", "alice@example.com", mute), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/api/push/mutes", "alice@example.com", gin.H{"kind": models.MuteCategory, "value": "social"}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/api/push/mutes", "alice@example.com", gin.H{"kind": "subject", "value": "lunch"}), http.StatusBadRequest, nil)
+
+	var mutes []*models.PushMute
+	s.expect(s.do(http.MethodGet, "/api/push/mutes", "alice@example.com", nil), http.StatusOK, &mutes)
+	if len(mutes) != 2 || mutes[0].Kind != models.MuteCategory || mutes[1].Value != "newsletter@example.com" {
+		t.Fatalf("mutes = %+v, want the category and sender mutes", mutes)
+	}
+	s.expect(s.do(http.MethodGet, "/api/push/mutes", "bob@example.com", nil), http.StatusOK, &mutes)
+	if len(mutes) != 0 {
+		t.Errorf("bob sees alice's mutes: %+v", mutes)
+	}
+
+	s.expect(s.do(http.MethodDelete, "/api/push/mutes?kind=sender&value=newsletter@example.com", "alice@example.com", nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, "/api/push/mutes?kind=sender&value=newsletter@example.com", "alice@example.com", nil), http.StatusNotFound, nil)
+}
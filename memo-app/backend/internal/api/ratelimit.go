@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/ratelimit"
+)
+
+// SendLimits are the abuse controls on requests that send memos
+type SendLimits struct {
+	Limiter         ratelimit.Limiter
+	RoleQuotas      map[models.UserRole]ratelimit.Quota // Sends per user by role; roles without a quota are unlimited
+	BroadcastQuota  ratelimit.Quota                     // Broadcasts per user, taken on top of the send quota
+	MaxMessageBytes int                                 // Largest message accepted; 0 for no limit
+	MaxRecipients   int                                 // Most to and cc addresses; 0 for no limit
+}
+
+// sendShape is the part of a send request the limits look at
+// It matches SendMemoRequest, SendTemplateRequest and ReplyMemoRequest
+type sendShape struct {
+	To          models.AddressList `json:"to"`
+	CC          models.AddressList `json:"cc"`
+	IsBroadcast bool               `json:"isBroadcast"`
+	Audience    *models.Audience   `json:"audience"`
+	Message     string             `json:"message"`
+}
+
+// LimitSending rejects send requests whose message or address list is too large (413 and 400)
+// and requests over the sender's send or broadcast quota (429 with Retry-After).
+// It runs after authentication; if the limiter is unavailable, sends are let through.
+func LimitSending(limits SendLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user := auth.GetUser(c)
+		if user == nil {
+			c.Next()
+			return
+		}
+		req, ok := limits.checkSize(c)
+		if !ok {
+			return
+		}
+		if !limits.take(c, user, 1, isBroadcastRequest(req.IsBroadcast, req.Audience, req.To)) {
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitSize rejects requests whose message or address list is too large (413 and 400),
+// without taking from any quota. It guards requests that change what a memo says or
+// who it goes to, such as edits, and template sends, whose handler takes the quota itself
+func LimitSize(limits SendLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if auth.GetUser(c) == nil {
+			c.Next()
+			return
+		}
+		if _, ok := limits.checkSize(c); !ok {
+			return
+		}
+		c.Next()
+	}
+}
+
+// checkSize reads the request body, leaving it for the handler, and aborts the request
+// if its message or address list is too large. It returns the parts of the body it checked
+func (limits SendLimits) checkSize(c *gin.Context) (sendShape, bool) {
+	var req sendShape
+	if limits.MaxMessageBytes > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, int64(limits.MaxMessageBytes)+config.SendEnvelopeBytes)
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": config.ErrMessageTooLarge})
+			return req, false
+		}
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return req, false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	// Malformed bodies are left for the handler to reject
+	_ = json.Unmarshal(body, &req)
+
+	if !limits.checkMessage(c, req.Message) {
+		return req, false
+	}
+	if limits.MaxRecipients > 0 && len(req.To)+len(req.CC) > limits.MaxRecipients {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("%s: at most %d addresses; use a distribution list", config.ErrTooManyRecipients, limits.MaxRecipients),
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// checkMessage aborts the request with 413 if message is larger than MaxMessageBytes
+func (limits SendLimits) checkMessage(c *gin.Context, message string) bool {
+	if limits.MaxMessageBytes > 0 && len(message) > limits.MaxMessageBytes {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{
+			"error": fmt.Sprintf("%s: at most %d bytes", config.ErrMessageTooLarge, limits.MaxMessageBytes),
+		})
+		return false
+	}
+	return true
+}
+
+// take charges user's send quota for count memos, and the broadcast quota for one
+// broadcast if broadcast is set. It aborts the request with 429 if a quota is exhausted;
+// if the limiter is unavailable, the send is let through
+func (limits SendLimits) take(c *gin.Context, user *models.User, count int, broadcast bool) bool {
+	if limits.Limiter == nil {
+		return true
+	}
+	buckets := []ratelimit.Bucket{{Key: "send:" + user.Email, Quota: limits.RoleQuotas[user.Role], Cost: count}}
+	if broadcast {
+		buckets = append(buckets, ratelimit.Bucket{Key: "broadcast:" + user.Email, Quota: limits.BroadcastQuota})
+	}
+	wait, err := limits.Limiter.Take(c.Request.Context(), buckets...)
+	if err != nil {
+		log.Printf("Rate limiter unavailable, not limiting %s: %v", user.Email, err)
+		return true
+	}
+	if wait > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+		c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": config.ErrRateLimited})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleRecallMemo withdraws a sent memo from recipients who have not read it yet
+// Only the sender or an admin can recall a memo
+func HandleRecallMemo(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memo, err := store.Recall(c.Param("id"), auth.GetUser(c))
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotMemoOwner, "Failed to recall memo")
+			return
+		}
+
+		log.Printf("Memo %s recalled by %s", memo.ID, auth.GetUserEmail(c))
+		c.JSON(http.StatusOK, memo)
+	}
+}
+
+// HandleEditMemo corrects the subject or body of a memo shortly after it was sent
+// Only the sender can edit a memo; the previous text is kept as a revision
+func HandleEditMemo(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.EditMemoRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		memo, err := store.Edit(c.Param("id"), auth.GetUser(c), &req)
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotMemoSender, "Failed to edit memo")
+			return
+		}
+
+		log.Printf("Memo %s edited by %s (revision %d)", memo.ID, memo.From, memo.Revision)
+		c.JSON(http.StatusOK, memo)
+	}
+}
+
+// HandleGetRevisions returns the earlier texts of a memo to its sender, its recipients and admins
+func HandleGetRevisions(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revisions, err := store.GetRevisions(c.Param("id"), auth.GetUser(c))
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load revisions")
+			return
+		}
+		c.JSON(http.StatusOK, revisions)
+	}
+}
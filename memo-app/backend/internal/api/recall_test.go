@@ -0,0 +1,139 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+	"memo-app/internal/ratelimit"
+)
+
+func TestEditMemoKeepsRevisions(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "mallory@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Meeting at 3", "message": "Room 1"})
+	path := "/api/memos/" + id
+
+	var memo models.Memo
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"subject": "Meeting at 4"}), http.StatusOK, &memo)
+	if memo.Subject != "Meeting at 4" || memo.Message != "Room 1" || memo.Revision != 1 || memo.EditedAt == nil {
+		t.Errorf("edited memo = %q/%q revision %d edited %v", memo.Subject, memo.Message, memo.Revision, memo.EditedAt)
+	}
+
+	s.expect(s.do(http.MethodPut, path, "bob@example.com", gin.H{"subject": "Cancelled"}), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"subject": "  "}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, "/api/memos/missing", "alice@example.com", gin.H{"subject": "x"}), http.StatusNotFound, nil)
+
+	var revisions []*models.MemoRevision
+	s.expect(s.do(http.MethodGet, path+"/revisions", "bob@example.com", nil), http.StatusOK, &revisions)
+	if len(revisions) != 1 || revisions[0].Subject != "Meeting at 3" || revisions[0].Revision != 0 {
+		t.Fatalf("revisions = %+v, want the original text as revision 0", revisions)
+	}
+	s.expect(s.do(http.MethodGet, path+"/revisions", "mallory@example.com", nil), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodGet, path+"/revisions", "admin@example.com", nil, asAdmin()...), http.StatusOK, &revisions)
+	if len(revisions) != 1 {
+		t.Errorf("admin sees %d revisions, want 1", len(revisions))
+	}
+}
+
+func TestEditMemoAsksForAcknowledgementAgain(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "subject": "Policy", "message": "Version 1", "requiresAck": true})
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "bob@example.com", nil), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id, "alice@example.com", gin.H{"message": "Version 2"}), http.StatusOK, nil)
+
+	var report models.AckReport
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks", "alice@example.com", nil), http.StatusOK, &report)
+	if report.Acknowledged != 0 || report.Pending != 2 {
+		t.Fatalf("report after edit = %+v, want both recipients pending", report)
+	}
+	for _, d := range report.Recipients {
+		if d.AckReminders != 0 || d.LastRemindedAt == nil {
+			t.Errorf("delivery to %s = %d reminders, last at %v; want reminders restarted at the edit", d.Recipient, d.AckReminders, d.LastRemindedAt)
+		}
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/ack", "bob@example.com", nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/memos/"+id+"/acks", "alice@example.com", nil), http.StatusOK, &report)
+	if report.Acknowledged != 1 || report.Recipients[len(report.Recipients)-1].Recipient != "bob@example.com" {
+		t.Errorf("report = %+v, want bob's acknowledgement of the edited text", report)
+	}
+}
+
+func TestRecallHidesUnreadMemos(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "subject": "Oops", "message": "Wrong list"})
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id+"/status", "carol@example.com", gin.H{"status": models.StatusRead}), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/recall", "bob@example.com", nil), http.StatusForbidden, nil)
+	var memo models.Memo
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/recall", "alice@example.com", nil), http.StatusOK, &memo)
+	if memo.RecalledAt == nil {
+		t.Fatal("recalled memo has no recalledAt")
+	}
+
+	if slices.Contains(s.received("bob@example.com"), id) {
+		t.Error("bob still sees a memo recalled before he read it")
+	}
+	if s.unread("bob@example.com") != 0 {
+		t.Error("the recalled memo still counts as unread for bob")
+	}
+	if !slices.Contains(s.received("carol@example.com"), id) {
+		t.Error("carol lost a memo she had read before the recall")
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/recall", "alice@example.com", nil), http.StatusConflict, nil)
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id, "alice@example.com", gin.H{"subject": "Fixed"}), http.StatusConflict, nil)
+}
+
+func TestRecallHidesAttachmentsAndReplies(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	var attachment models.Attachment
+	s.expect(s.upload("alice@example.com", "salaries.txt", []byte("Everyone's salary\n")), http.StatusCreated, &attachment)
+	id := s.send("alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "subject": "Salaries",
+		"message": "Wrong list", "attachmentIds": []string{attachment.ID}})
+	s.expect(s.do(http.MethodPut, "/api/memos/"+id+"/status", "carol@example.com", gin.H{"status": models.StatusRead}), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/recall", "alice@example.com", nil), http.StatusOK, nil)
+
+	// Bob had not read it, so he can neither download the file nor reply
+	path := "/api/attachments/" + attachment.ID
+	s.expect(s.do(http.MethodGet, path, "bob@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/reply", "bob@example.com", gin.H{"message": "What's this?"}), http.StatusForbidden, nil)
+	if got := s.received("alice@example.com"); len(got) != 0 {
+		t.Fatalf("alice received %v, want no reply to the recalled memo", got)
+	}
+
+	// Carol read it before the recall and keeps both
+	s.expect(s.do(http.MethodGet, path, "carol@example.com", nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/reply", "carol@example.com", gin.H{"message": "Deleted"}), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodGet, path, "alice@example.com", nil), http.StatusOK, nil)
+}
+
+func TestEditsAreHeldToSendLimits(t *testing.T) {
+	s := newLimitedDBTestServer(t, SendLimits{Limiter: ratelimit.NewMemoryLimiter(), MaxMessageBytes: 1000, MaxRecipients: 2})
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com")
+
+	sent := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Hi", "message": "Hello"})
+	s.expect(s.do(http.MethodPut, "/api/memos/"+sent, "alice@example.com", gin.H{"message": strings.Repeat("x", 1001)}), http.StatusRequestEntityTooLarge, nil)
+	s.expect(s.do(http.MethodPut, "/api/memos/"+sent, "alice@example.com", gin.H{"message": strings.Repeat("x", 1000)}), http.StatusOK, nil)
+
+	scheduled := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Later", "message": "Hello", "sendAt": time.Now().Add(time.Hour)})
+	path := "/api/memos/" + scheduled + "/schedule"
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"message": strings.Repeat("x", 1001)}), http.StatusRequestEntityTooLarge, nil)
+	s.expect(s.do(http.MethodPut, path, "alice@example.com",
+		gin.H{"to": []string{"bob@example.com", "carol@example.com"}, "cc": "dave@example.com"}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"to": []string{"bob@example.com", "carol@example.com"}}), http.StatusOK, nil)
+}
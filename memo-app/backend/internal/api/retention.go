@@ -0,0 +1,87 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleGetRetentionPolicies returns the retention policy of every memo category
+// Categories with a policy are the ones memos can be sent with
+func HandleGetRetentionPolicies(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policies, err := store.ListRetentionPolicies()
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load retention policies")
+			return
+		}
+		c.JSON(http.StatusOK, policies)
+	}
+}
+
+// HandleSaveRetentionPolicy creates or replaces the retention policy of a category (admin only)
+func HandleSaveRetentionPolicy(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.RetentionPolicyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		policy := &models.RetentionPolicy{
+			Category:         c.Param("category"),
+			ArchiveAfterDays: req.ArchiveAfterDays,
+			PurgeAfterDays:   req.PurgeAfterDays,
+			LegalHold:        req.LegalHold,
+			UpdatedBy:        auth.GetUserEmail(c),
+		}
+		if err := store.SaveRetentionPolicy(policy); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to save retention policy")
+			return
+		}
+
+		log.Printf("Retention policy %s set by %s: archive=%v purge=%d hold=%v",
+			policy.Category, policy.UpdatedBy, policy.ArchiveAfterDays, policy.PurgeAfterDays, policy.LegalHold)
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// HandleDeleteRetentionPolicy removes the retention policy of a category (admin only)
+func HandleDeleteRetentionPolicy(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		category := c.Param("category")
+		if err := store.DeleteRetentionPolicy(category); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to delete retention policy")
+			return
+		}
+
+		log.Printf("Retention policy %s deleted by %s", category, auth.GetUserEmail(c))
+		c.JSON(http.StatusOK, gin.H{"message": "Retention policy deleted successfully"})
+	}
+}
+
+// HandleSetLegalHold places or releases a legal hold on a memo (admin only)
+func HandleSetLegalHold(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.LegalHoldRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		memo, err := store.SetLegalHold(c.Param("id"), req.LegalHold)
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to update legal hold")
+			return
+		}
+
+		log.Printf("Legal hold on memo %s set to %v by %s", memo.ID, req.LegalHold, auth.GetUserEmail(c))
+		c.JSON(http.StatusOK, memo)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+func TestRetentionPolicies(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com")
+
+	var policies []*models.RetentionPolicy
+	s.expect(s.do(http.MethodGet, "/api/retention-policies", "alice@example.com", nil), http.StatusOK, &policies)
+	categories := make([]string, 0, len(policies))
+	for _, p := range policies {
+		categories = append(categories, p.Category)
+	}
+	if want := []string{config.DefaultMemoCategory, "acknowledgment"}; !slices.Equal(categories, want) {
+		t.Fatalf("categories = %v, want the default and the seeded %v", categories, want)
+	}
+
+	memo := gin.H{"to": "bob@example.com", "subject": "Payroll", "message": "Attached", "category": "hr"}
+	s.expect(s.do(http.MethodPost, "/api/memos", "alice@example.com", memo), http.StatusBadRequest, nil)
+
+	policy := gin.H{"archiveAfterDays": 30, "purgeAfterDays": 365}
+	s.expect(s.do(http.MethodPut, "/api/admin/retention-policies/hr", "alice@example.com", policy), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPut, "/api/admin/retention-policies/hr", "admin@example.com", policy, asAdmin()...), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/api/admin/retention-policies/hr", "admin@example.com",
+		gin.H{"archiveAfterDays": 400, "purgeAfterDays": 365}, asAdmin()...), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, "/api/admin/retention-policies/Not%20Valid", "admin@example.com", policy, asAdmin()...), http.StatusBadRequest, nil)
+
+	id := s.send("alice@example.com", memo)
+	if sent, _ := s.store.Get(id); sent.Category != "hr" {
+		t.Errorf("memo category = %q, want hr", sent.Category)
+	}
+
+	s.expect(s.do(http.MethodDelete, "/api/admin/retention-policies/hr", "admin@example.com", nil, asAdmin()...), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, "/api/admin/retention-policies/hr", "admin@example.com", nil, asAdmin()...), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos", "alice@example.com", memo), http.StatusBadRequest, nil)
+}
+
+func TestLegalHoldBlocksDeletion(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Contract", "message": "Signed"})
+	s.expect(s.do(http.MethodPut, "/api/admin/memos/"+id+"/legal-hold", "alice@example.com", gin.H{"legalHold": true}), http.StatusForbidden, nil)
+
+	var held models.Memo
+	s.expect(s.do(http.MethodPut, "/api/admin/memos/"+id+"/legal-hold", "admin@example.com", gin.H{"legalHold": true}, asAdmin()...), http.StatusOK, &held)
+	if !held.LegalHold {
+		t.Fatal("legal hold not set")
+	}
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id, "alice@example.com", nil), http.StatusConflict, nil)
+
+	s.expect(s.do(http.MethodPut, "/api/admin/memos/"+id+"/legal-hold", "admin@example.com", gin.H{"legalHold": false}, asAdmin()...), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id, "alice@example.com", nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodPut, "/api/admin/memos/"+id+"/legal-hold", "admin@example.com", gin.H{"legalHold": true}, asAdmin()...), http.StatusNotFound, nil)
+}
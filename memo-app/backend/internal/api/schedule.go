@@ -0,0 +1,75 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleGetScheduledMemos lists the requesting user's memos waiting to be released
+// Memos whose release failed are included with status "failed" so they can be fixed
+func HandleGetScheduledMemos(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		memos := store.GetScheduledMemos(userEmail)
+		if memos == nil {
+			memos = []*models.Memo{}
+		}
+
+		c.JSON(http.StatusOK, memos)
+	}
+}
+
+// HandleUpdateScheduledMemo edits a scheduled memo before it is released
+// Only the author can edit it
+func HandleUpdateScheduledMemo(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.UpdateScheduledMemoRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Validate TTL if provided (must be at least 1 day if not nil)
+		if req.TTLDays != nil && *req.TTLDays < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrInvalidTTL})
+			return
+		}
+
+		memo, err := store.UpdateScheduled(c.Param("id"), auth.GetUser(c), &req)
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotScheduledAuthor, "Failed to update scheduled memo")
+			return
+		}
+
+		log.Printf("Scheduled memo %s updated, sending at %s", memo.ID, memo.SendAt.Format(time.RFC3339))
+		c.JSON(http.StatusOK, memo)
+	}
+}
+
+// HandleCancelScheduledMemo deletes a scheduled memo before it is released
+// Only the author can cancel it
+func HandleCancelScheduledMemo(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		memoID := c.Param("id")
+		if err := store.CancelScheduled(memoID, auth.GetUser(c)); err != nil {
+			respondStoreError(c, err, config.ErrNotScheduledAuthor, "Failed to cancel scheduled memo")
+			return
+		}
+
+		log.Printf("Scheduled memo %s cancelled", memoID)
+		c.JSON(http.StatusOK, gin.H{"message": "Scheduled memo cancelled"})
+	}
+}
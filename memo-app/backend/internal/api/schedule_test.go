@@ -0,0 +1,72 @@
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+)
+
+func TestScheduledMemoIsHiddenUntilReleased(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com")
+
+	sendAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Launch", "message": "Tomorrow", "sendAt": sendAt})
+	if received := s.received("bob@example.com"); len(received) != 0 {
+		t.Fatalf("bob received %v before the send time", received)
+	}
+
+	var scheduled []*models.Memo
+	s.expect(s.do(http.MethodGet, "/api/memos/scheduled", "alice@example.com", nil), http.StatusOK, &scheduled)
+	if len(scheduled) != 1 || scheduled[0].ID != id || scheduled[0].Status != models.StatusScheduled {
+		t.Fatalf("scheduled = %+v, want memo %s", scheduled, id)
+	}
+	s.expect(s.do(http.MethodGet, "/api/memos/scheduled", "bob@example.com", nil), http.StatusOK, &scheduled)
+	if len(scheduled) != 0 {
+		t.Errorf("bob sees alice's scheduled memos: %+v", scheduled)
+	}
+}
+
+func TestUpdateScheduledMemo(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Launch", "message": "Tomorrow", "sendAt": time.Now().Add(time.Hour)})
+	path := "/api/memos/" + id + "/schedule"
+
+	later := time.Now().Add(2 * time.Hour).UTC().Truncate(time.Second)
+	var memo models.Memo
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"subject": "Launch moved", "to": "carol@example.com", "sendAt": later}), http.StatusOK, &memo)
+	if memo.Subject != "Launch moved" || !memo.SendAt.Equal(later) {
+		t.Errorf("updated memo = %q at %v, want %q at %v", memo.Subject, memo.SendAt, "Launch moved", later)
+	}
+
+	s.expect(s.do(http.MethodPut, path, "bob@example.com", gin.H{"subject": "Mine now"}), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"sendAt": time.Now().Add(-time.Minute)}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"to": "nobody@example.com"}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPut, path, "alice@example.com", gin.H{"ttlDays": 0}), http.StatusBadRequest, nil)
+
+	// Sent memos are no longer scheduled
+	sent := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Now", "message": "Now"})
+	s.expect(s.do(http.MethodPut, "/api/memos/"+sent+"/schedule", "alice@example.com", gin.H{"subject": "Later"}), http.StatusConflict, nil)
+}
+
+func TestCancelScheduledMemo(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Launch", "message": "Tomorrow", "sendAt": time.Now().Add(time.Hour)})
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id+"/schedule", "bob@example.com", nil), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id+"/schedule", "alice@example.com", nil), http.StatusOK, nil)
+	s.expect(s.do(http.MethodDelete, "/api/memos/"+id+"/schedule", "alice@example.com", nil), http.StatusNotFound, nil)
+
+	var scheduled []*models.Memo
+	s.expect(s.do(http.MethodGet, "/api/memos/scheduled", "alice@example.com", nil), http.StatusOK, &scheduled)
+	if len(scheduled) != 0 {
+		t.Errorf("scheduled after cancelling = %+v, want none", scheduled)
+	}
+}
@@ -0,0 +1,116 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/tickets"
+)
+
+// HandleIssueStreamTicket issues the caller a single-use ticket for opening the event stream
+// EventSource cannot send an Authorization header, so the stream is authenticated by the
+// ticket instead of putting the JWT in the URL
+func HandleIssueStreamTicket(ticketStore tickets.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		ticket, err := ticketStore.Issue(c.Request.Context(), userEmail, config.SSETicketSeconds*time.Second)
+		if err != nil {
+			log.Printf("Failed to issue stream ticket for %s: %v", userEmail, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue stream ticket"})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"ticket": ticket, "expiresIn": config.SSETicketSeconds})
+	}
+}
+
+// HandleStream pushes memo events to the requesting user over Server-Sent Events
+// Clients resuming after a disconnect send Last-Event-ID (header or lastEventId query)
+// to replay events they missed while the replay buffer still holds them
+func HandleStream(hub *events.Hub) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		lastEventID := c.GetHeader("Last-Event-ID")
+		if lastEventID == "" {
+			lastEventID = c.Query("lastEventId")
+		}
+		var lastID uint64
+		if lastEventID != "" {
+			if id, err := strconv.ParseUint(lastEventID, 10, 64); err == nil {
+				lastID = id
+			}
+		}
+
+		sub, replay := hub.Subscribe(userEmail, lastID)
+		defer hub.Unsubscribe(sub)
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		c.Header("X-Accel-Buffering", "no") // disable proxy buffering
+		c.Status(http.StatusOK)
+
+		for _, evt := range replay {
+			if err := writeEvent(c, evt); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+
+		log.Printf("SSE stream opened for %s (replayed %d events)", userEmail, len(replay))
+
+		heartbeat := time.NewTicker(config.SSEHeartbeatSeconds * time.Second)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				log.Printf("SSE stream closed for %s", userEmail)
+				return
+			case evt, ok := <-sub.Events:
+				if !ok {
+					// Dropped by the hub for falling behind; the client will reconnect and replay
+					return
+				}
+				if err := writeEvent(c, evt); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(c.Writer, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// writeEvent writes a single event in SSE wire format
+func writeEvent(c *gin.Context, evt events.Event) error {
+	data, err := json.Marshal(evt.Data)
+	if err != nil {
+		log.Printf("SSE: failed to encode event %d: %v", evt.ID, err)
+		return nil
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", evt.ID, evt.Type, data)
+	return err
+}
@@ -0,0 +1,75 @@
+package api
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/events"
+)
+
+// issueTicket returns a stream ticket for user
+func (s *testServer) issueTicket(user string) string {
+	s.t.Helper()
+	var body struct {
+		Ticket    string `json:"ticket"`
+		ExpiresIn int    `json:"expiresIn"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/memos/stream/ticket", user, nil), http.StatusCreated, &body)
+	if body.Ticket == "" || body.ExpiresIn <= 0 {
+		s.t.Fatalf("ticket response = %+v", body)
+	}
+	return body.Ticket
+}
+
+func TestStreamRequiresATicket(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("bob@example.com")
+
+	s.expect(s.do(http.MethodGet, "/api/memos/stream", "", nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodGet, "/api/memos/stream?ticket=forged", "", nil), http.StatusUnauthorized, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/stream/ticket", "", nil), http.StatusUnauthorized, nil)
+}
+
+func TestStreamDeliversNewMemos(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com")
+	server := httptest.NewServer(s.router)
+	defer server.Close()
+
+	ticket := s.issueTicket("bob@example.com")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/api/memos/stream?ticket="+ticket, nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("stream: status %d, content type %q", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Ping", "message": "Live"})
+	lines := bufio.NewScanner(resp.Body)
+	for lines.Scan() {
+		if lines.Text() == "event: "+string(events.MemoCreated) {
+			if !lines.Scan() || !strings.Contains(lines.Text(), id) {
+				t.Fatalf("event data %q does not hold memo %s", lines.Text(), id)
+			}
+			break
+		}
+	}
+	if err := lines.Err(); err != nil {
+		t.Fatalf("reading the stream: %v", err)
+	}
+
+	// Tickets are single-use
+	s.expect(s.do(http.MethodGet, "/api/memos/stream?ticket="+ticket, "", nil), http.StatusUnauthorized, nil)
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleGetTemplates returns every memo template with the variables it uses
+func HandleGetTemplates(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		templates, err := store.ListTemplates()
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load templates")
+			return
+		}
+		c.JSON(http.StatusOK, templates)
+	}
+}
+
+// HandleGetTemplate returns a single memo template
+func HandleGetTemplate(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tmpl, err := store.GetTemplate(c.Param("name"))
+		if err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to load template")
+			return
+		}
+		c.JSON(http.StatusOK, tmpl)
+	}
+}
+
+// HandleSaveTemplate creates or replaces a memo template (admin only)
+func HandleSaveTemplate(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.MemoTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tmpl := &models.MemoTemplate{
+			Name:        c.Param("name"),
+			Description: req.Description,
+			Subject:     req.Subject,
+			Message:     req.Message,
+			UpdatedBy:   auth.GetUserEmail(c),
+		}
+		if err := store.SaveTemplate(tmpl); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to save template")
+			return
+		}
+
+		log.Printf("Template %s saved by %s with variables %v", tmpl.Name, tmpl.UpdatedBy, tmpl.Variables)
+		c.JSON(http.StatusOK, tmpl)
+	}
+}
+
+// HandleDeleteTemplate removes a memo template (admin only)
+func HandleDeleteTemplate(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		if err := store.DeleteTemplate(name); err != nil {
+			respondStoreError(c, err, config.ErrInsufficientRole, "Failed to delete template")
+			return
+		}
+
+		log.Printf("Template %s deleted by %s", name, auth.GetUserEmail(c))
+		c.JSON(http.StatusOK, gin.H{"message": "Template deleted successfully"})
+	}
+}
+
+// HandleSendTemplate sends a memo rendered from a template
+// Templates using directory fields send one memo per recipient, so it responds with every memo ID.
+// Every rendered message must fit the size limit, and each memo counts against the send quota
+func HandleSendTemplate(store *store.DBStore, limits SendLimits) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrSenderEmailNotFound})
+			return
+		}
+
+		var req models.SendTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if req.TTLDays != nil && *req.TTLDays < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrInvalidTTL})
+			return
+		}
+		isBroadcast := isBroadcastRequest(req.IsBroadcast, req.Audience, req.To)
+		if !isBroadcast && len(req.To) == 0 && len(req.CC) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrNoRecipients})
+			return
+		}
+
+		memo := &models.Memo{
+			From:          userEmail,
+			IsBroadcast:   isBroadcast,
+			Audience:      req.Audience,
+			TTLDays:       req.TTLDays,
+			Category:      req.Category,
+			RequiresAck:   req.RequiresAck,
+			AttachmentIDs: req.AttachmentIDs,
+			SendAt:        req.SendAt,
+			VisibleUntil:  req.VisibleUntil,
+		}
+		name := c.Param("name")
+		rendered, err := store.RenderTemplate(name, memo, req.To, req.CC, req.Variables)
+		if err != nil {
+			respondStoreError(c, err, config.ErrBroadcastForbidden, config.ErrFailedToCreateMemo)
+			return
+		}
+		for _, m := range rendered.Memos {
+			if !limits.checkMessage(c, m.Message) {
+				return
+			}
+		}
+		if user := auth.GetUser(c); user != nil && !limits.take(c, user, len(rendered.Memos), isBroadcast) {
+			return
+		}
+
+		memos, err := store.SendRendered(rendered)
+		if err != nil {
+			respondStoreError(c, err, config.ErrBroadcastForbidden, config.ErrFailedToCreateMemo)
+			return
+		}
+
+		ids := make([]string, 0, len(memos))
+		for _, m := range memos {
+			ids = append(ids, m.ID)
+		}
+		log.Printf("Memo created from template %s: %s -> %v cc %v (%d memos, broadcast=%v)",
+			name, userEmail, req.To, req.CC, len(memos), isBroadcast)
+
+		message := config.MsgMemoSentSuccess
+		if memos[0].Status == models.StatusScheduled {
+			message = config.MsgMemoScheduledSuccess
+		}
+		c.JSON(http.StatusCreated, gin.H{
+			"ids":     ids,
+			"status":  memos[0].Status,
+			"message": message,
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+	"memo-app/internal/ratelimit"
+)
+
+func TestTemplatesAreManagedByAdmins(t *testing.T) {
+	s := newDBTestServer(t)
+
+	tmpl := gin.H{"description": "Office closure", "subject": "Office closed on {{.Date}}", "message": "The office is closed on {{ .Date }}."}
+	s.expect(s.do(http.MethodPut, "/api/admin/templates/closure", "alice@example.com", tmpl), http.StatusForbidden, nil)
+
+	var saved models.MemoTemplate
+	s.expect(s.do(http.MethodPut, "/api/admin/templates/closure", "admin@example.com", tmpl, asAdmin()...), http.StatusOK, &saved)
+	if !slices.Equal(saved.Variables, []string{"Date"}) {
+		t.Errorf("variables = %v, want [Date]", saved.Variables)
+	}
+	s.expect(s.do(http.MethodPut, "/api/admin/templates/broken", "admin@example.com",
+		gin.H{"subject": "{{if .Date}}x{{end}}", "message": "x"}, asAdmin()...), http.StatusBadRequest, nil)
+
+	var templates []*models.MemoTemplate
+	s.expect(s.do(http.MethodGet, "/api/templates", "alice@example.com", nil), http.StatusOK, &templates)
+	if len(templates) != 1 || templates[0].Name != "closure" {
+		t.Errorf("templates = %+v, want [closure]", templates)
+	}
+	s.expect(s.do(http.MethodGet, "/api/templates/closure", "alice@example.com", nil), http.StatusOK, nil)
+
+	s.expect(s.do(http.MethodDelete, "/api/admin/templates/closure", "admin@example.com", nil, asAdmin()...), http.StatusOK, nil)
+	s.expect(s.do(http.MethodGet, "/api/templates/closure", "alice@example.com", nil), http.StatusNotFound, nil)
+	s.expect(s.do(http.MethodDelete, "/api/admin/templates/closure", "admin@example.com", nil, asAdmin()...), http.StatusNotFound, nil)
+}
+
+func TestSendTemplate(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com")
+	s.expect(s.do(http.MethodPut, "/api/admin/templates/closure", "admin@example.com",
+		gin.H{"subject": "Office closed on {{.Date}}", "message": "Closed on {{.Date}}."}, asAdmin()...), http.StatusOK, nil)
+
+	var sent struct {
+		IDs []string `json:"ids"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/templates/closure/send", "alice@example.com",
+		gin.H{"to": "bob@example.com", "variables": gin.H{"Date": "May 1"}}), http.StatusCreated, &sent)
+	if len(sent.IDs) != 1 {
+		t.Fatalf("ids = %v, want one memo", sent.IDs)
+	}
+	memo, _ := s.store.Get(sent.IDs[0])
+	if memo.Subject != "Office closed on May 1" || memo.Message != "Closed on May 1." {
+		t.Errorf("rendered memo = %q / %q", memo.Subject, memo.Message)
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/templates/closure/send", "alice@example.com", gin.H{"to": "bob@example.com"}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/templates/closure/send", "alice@example.com", gin.H{"variables": gin.H{"Date": "May 1"}}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/templates/missing/send", "alice@example.com", gin.H{"to": "bob@example.com"}), http.StatusNotFound, nil)
+}
+
+func TestSendTemplatePerRecipient(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com")
+	s.do(http.MethodGet, "/api/memos/unread-count", "bob@example.com", nil, "X-User-Name", "Bob Builder", "X-User-Department", "ops")
+	s.do(http.MethodGet, "/api/memos/unread-count", "carol@example.com", nil, "X-User-Department", "hr")
+	s.signIn("dave@example.com")
+	s.expect(s.do(http.MethodPut, "/api/admin/templates/welcome", "admin@example.com",
+		gin.H{"subject": "Hi {{.Name}}", "message": "Welcome to {{.Department}}. {{.Note}}"}, asAdmin()...), http.StatusOK, nil)
+
+	var sent struct {
+		IDs []string `json:"ids"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/templates/welcome/send", "alice@example.com",
+		gin.H{"to": "bob@example.com", "cc": "carol@example.com", "variables": gin.H{"Note": "Lunch is at 12."}}), http.StatusCreated, &sent)
+	if len(sent.IDs) != 2 {
+		t.Fatalf("ids = %v, want a memo for bob and one for carol", sent.IDs)
+	}
+	bob, _ := s.store.Get(sent.IDs[0])
+	if bob.Subject != "Hi Bob Builder" || bob.Message != "Welcome to ops. Lunch is at 12." || !slices.Equal(bob.Recipients, []string{"bob@example.com"}) {
+		t.Errorf("bob's memo = %q / %q to %v", bob.Subject, bob.Message, bob.Recipients)
+	}
+	carol, _ := s.store.Get(sent.IDs[1])
+	if carol.Subject != "Hi carol@example.com" || carol.Message != "Welcome to hr. Lunch is at 12." || !slices.Equal(carol.CC, []string{"carol@example.com"}) {
+		t.Errorf("carol's memo = %q / %q cc %v", carol.Subject, carol.Message, carol.CC)
+	}
+	if got := s.received("carol@example.com"); !slices.Equal(got, sent.IDs[1:]) {
+		t.Errorf("carol received %v, want only her own memo", got)
+	}
+
+	// Dave has no department, so nobody gets a memo
+	s.expect(s.do(http.MethodPost, "/api/templates/welcome/send", "alice@example.com",
+		gin.H{"to": []string{"bob@example.com", "dave@example.com"}, "variables": gin.H{"Note": "Again"}}), http.StatusBadRequest, nil)
+	if got := s.received("bob@example.com"); len(got) != 1 {
+		t.Errorf("bob received %d memos, want only the first one", len(got))
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/templates/welcome/send", "alice@example.com",
+		gin.H{"to": "nobody@example.com", "variables": gin.H{"Note": "x"}}), http.StatusBadRequest, nil)
+	s.expect(s.do(http.MethodPost, "/api/templates/welcome/send", "admin@example.com",
+		gin.H{"isBroadcast": true, "variables": gin.H{"Note": "x"}}, asAdmin()...), http.StatusBadRequest, nil)
+}
+
+func TestSendTemplateIsLimitedPerMemo(t *testing.T) {
+	s := newLimitedDBTestServer(t, SendLimits{
+		Limiter:         ratelimit.NewMemoryLimiter(),
+		RoleQuotas:      map[models.UserRole]ratelimit.Quota{models.RoleUser: {Burst: 3, Period: time.Hour}},
+		MaxMessageBytes: 1000,
+	})
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com", "erin@example.com")
+	s.expect(s.do(http.MethodPut, "/api/admin/templates/hello", "admin@example.com",
+		gin.H{"subject": "Hi {{.Name}}", "message": "{{.Note}} {{.Note}}"}, asAdmin()...), http.StatusOK, nil)
+
+	// The variables fit the limit, but the rendered message doesn't
+	s.expect(s.do(http.MethodPost, "/api/templates/hello/send", "alice@example.com",
+		gin.H{"to": "bob@example.com", "variables": gin.H{"Note": strings.Repeat("x", 600)}}), http.StatusRequestEntityTooLarge, nil)
+
+	s.expect(s.do(http.MethodPost, "/api/templates/hello/send", "alice@example.com",
+		gin.H{"to": "bob@example.com", "variables": gin.H{"Note": "Hello"}}), http.StatusCreated, nil)
+
+	// Two sends are left, so a merge producing three memos is refused whole
+	others := []string{"carol@example.com", "dave@example.com", "erin@example.com"}
+	s.expect(s.do(http.MethodPost, "/api/templates/hello/send", "alice@example.com",
+		gin.H{"to": others, "variables": gin.H{"Note": "Hello"}}), http.StatusTooManyRequests, nil)
+	if got := s.received("carol@example.com"); len(got) != 0 {
+		t.Fatalf("carol received %v from a send over the quota", got)
+	}
+
+	s.expect(s.do(http.MethodPost, "/api/templates/hello/send", "alice@example.com",
+		gin.H{"to": others[:2], "variables": gin.H{"Note": "Hello"}}), http.StatusCreated, nil)
+	s.expect(s.do(http.MethodPost, "/api/templates/hello/send", "alice@example.com",
+		gin.H{"to": "erin@example.com", "variables": gin.H{"Note": "Hello"}}), http.StatusTooManyRequests, nil)
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/auth"
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/store"
+)
+
+// HandleReplyMemo sends a reply to a memo in the same thread
+// Replies go to the original sender, or to every participant with replyAll
+func HandleReplyMemo(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrSenderEmailNotFound})
+			return
+		}
+
+		var req models.ReplyMemoRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// Validate TTL if provided (must be at least 1 day if not nil)
+		if req.TTLDays != nil && *req.TTLDays < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrInvalidTTL})
+			return
+		}
+
+		memo := &models.Memo{
+			From:          userEmail,
+			Subject:       req.Subject,
+			Message:       req.Message,
+			TTLDays:       req.TTLDays,
+			AttachmentIDs: req.AttachmentIDs,
+		}
+
+		parentID := c.Param("id")
+		memoID, err := store.Reply(parentID, memo, req.ReplyAll)
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotMemoParticipant, config.ErrFailedToCreateMemo)
+			return
+		}
+
+		log.Printf("Reply created: %s -> %s in thread %s (replyAll=%v)", userEmail, parentID, memo.ThreadID, req.ReplyAll)
+
+		c.JSON(http.StatusCreated, gin.H{
+			"id":       memoID,
+			"threadId": memo.ThreadID,
+			"status":   memo.Status,
+			"message":  config.MsgMemoSentSuccess,
+		})
+	}
+}
+
+// HandleGetThread returns the conversation of a thread in order
+// Only memos the requesting user sent or received are included
+func HandleGetThread(store *store.DBStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userEmail := auth.GetUserEmail(c)
+		if userEmail == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": config.ErrUserEmailNotFound})
+			return
+		}
+
+		thread, err := store.GetThread(c.Param("id"), userEmail)
+		if err != nil {
+			respondStoreError(c, err, config.ErrNotMemoParticipant, "Failed to load thread")
+			return
+		}
+
+		c.JSON(http.StatusOK, thread)
+	}
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"memo-app/internal/models"
+)
+
+func TestReplyStaysInThread(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "carol@example.com")
+
+	first := s.send("alice@example.com", gin.H{"to": "bob@example.com", "cc": "carol@example.com", "subject": "Offsite", "message": "Friday?"})
+
+	var reply struct {
+		ID       string `json:"id"`
+		ThreadID string `json:"threadId"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/memos/"+first+"/reply", "bob@example.com", gin.H{"message": "Works for me"}), http.StatusCreated, &reply)
+
+	var thread models.Thread
+	s.expect(s.do(http.MethodGet, "/api/threads/"+reply.ThreadID, "alice@example.com", nil), http.StatusOK, &thread)
+	if ids := memoIDs(thread.Memos); len(ids) != 2 || ids[0] != first || ids[1] != reply.ID {
+		t.Fatalf("thread memos = %v, want [%s %s]", ids, first, reply.ID)
+	}
+	if thread.Memos[1].Subject != "Re: Offsite" {
+		t.Errorf("reply subject = %q, want the default %q", thread.Memos[1].Subject, "Re: Offsite")
+	}
+
+	// A plain reply goes to the sender only; carol sees just the memo she was copied on
+	s.expect(s.do(http.MethodGet, "/api/threads/"+reply.ThreadID, "carol@example.com", nil), http.StatusOK, &thread)
+	if ids := memoIDs(thread.Memos); len(ids) != 1 || ids[0] != first {
+		t.Errorf("carol's thread = %v, want [%s]", ids, first)
+	}
+
+	// replyAll reaches every participant of the memo replied to
+	var all struct {
+		ID string `json:"id"`
+	}
+	s.expect(s.do(http.MethodPost, "/api/memos/"+first+"/reply", "bob@example.com", gin.H{"message": "Booked", "replyAll": true}), http.StatusCreated, &all)
+	for _, user := range []string{"alice@example.com", "carol@example.com"} {
+		if !slices.Contains(s.received(user), all.ID) {
+			t.Errorf("%s did not receive the reply-all", user)
+		}
+	}
+}
+
+func TestThreadsAreOnlyForParticipants(t *testing.T) {
+	s := newDBTestServer(t)
+	s.signIn("alice@example.com", "bob@example.com", "mallory@example.com")
+
+	id := s.send("alice@example.com", gin.H{"to": "bob@example.com", "subject": "Salaries", "message": "Private"})
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/reply", "mallory@example.com", gin.H{"message": "Me too"}), http.StatusForbidden, nil)
+	s.expect(s.do(http.MethodPost, "/api/memos/"+id+"/reply", "bob@example.com", gin.H{}), http.StatusBadRequest, nil)
+
+	memo, _ := s.store.Get(id)
+	w := s.do(http.MethodGet, "/api/threads/"+memo.ThreadID, "mallory@example.com", nil)
+	if w.Code != http.StatusForbidden && w.Code != http.StatusNotFound {
+		t.Errorf("outsider reading the thread got status %d, want 403 or 404", w.Code)
+	}
+}
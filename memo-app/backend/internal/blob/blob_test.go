@@ -0,0 +1,117 @@
+package blob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// failingReader returns some data and then an error, like an upload cut off midway
+type failingReader struct{ sent bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, errors.New("connection reset")
+	}
+	r.sent = true
+	return copy(p, "partial"), nil
+}
+
+// read returns the contents of the blob under key
+func read(t *testing.T, s *LocalStore, key string) string {
+	t.Helper()
+	rc, err := s.Open(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", key, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestLocalStoreRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStore(t.TempDir() + "/nested/blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "a1.txt", strings.NewReader("first")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, s, "a1.txt"); got != "first" {
+		t.Fatalf("read %q, want first", got)
+	}
+	if err := s.Put(ctx, "a1.txt", strings.NewReader("second")); err != nil {
+		t.Fatal(err)
+	}
+	if got := read(t, s, "a1.txt"); got != "second" {
+		t.Fatalf("read %q after replacing, want second", got)
+	}
+
+	if err := s.Delete(ctx, "a1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Open(ctx, "a1.txt"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open after Delete: %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, "a1.txt"); err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+}
+
+func TestLocalStoreKeepsFailedPutsInvisible(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	s, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(ctx, "upload", &failingReader{}); err == nil {
+		t.Fatal("Put succeeded with a failing reader")
+	}
+	if _, err := s.Open(ctx, "upload"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Open after a failed Put: %v, want ErrNotFound", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.Put(cancelled, "upload", strings.NewReader("data")); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Put with a cancelled context: %v", err)
+	}
+
+	// Neither attempt leaves its temporary file behind
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("directory holds %d files after failed puts", len(entries))
+	}
+}
+
+func TestLocalStoreRejectsUnsafeKeys(t *testing.T) {
+	ctx := context.Background()
+	s, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "../escape", "a/b", ".hidden", "a\\b"} {
+		if err := s.Put(ctx, key, strings.NewReader("x")); err == nil {
+			t.Errorf("Put(%q) accepted", key)
+		}
+		if _, err := s.Open(ctx, key); err == nil || errors.Is(err, ErrNotFound) {
+			t.Errorf("Open(%q) = %v, want an invalid key error", key, err)
+		}
+		if err := s.Delete(ctx, key); err == nil {
+			t.Errorf("Delete(%q) accepted", key)
+		}
+	}
+}
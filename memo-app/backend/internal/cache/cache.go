@@ -2,114 +2,57 @@ package cache
 
 import (
 	"fmt"
-	"log"
-	"time"
 
-	"github.com/patrickmn/go-cache"
 	"memo-app/internal/models"
 )
 
-// CacheManager manages in-memory cache using go-cache
-type CacheManager struct {
-	cache *cache.Cache
-}
-
-// NewCacheManager creates a new cache manager with in-memory cache
-func NewCacheManager(ttl time.Duration) *CacheManager {
-	// Create cache with default TTL and cleanup interval of 1 minute
-	c := cache.New(ttl, 1*time.Minute)
-	log.Printf("Cache manager initialized with in-memory cache (TTL: %v)", ttl)
-	return &CacheManager{
-		cache: c,
-	}
-}
-
-// GetMemo retrieves a memo from cache
-func (cm *CacheManager) GetMemo(id string) (*models.Memo, bool) {
-	key := fmt.Sprintf("memo:%s", id)
-	
-	if val, found := cm.cache.Get(key); found {
-		if memo, ok := val.(*models.Memo); ok {
-			return memo, true
-		}
-	}
-	
-	return nil, false
-}
-
-// SetMemo stores a memo in cache
-func (cm *CacheManager) SetMemo(memo *models.Memo) {
-	key := fmt.Sprintf("memo:%s", memo.ID)
-	cm.cache.Set(key, memo, cache.DefaultExpiration)
-}
-
-// GetMemoList retrieves a cached list of memos
-func (cm *CacheManager) GetMemoList(cacheKey string) ([]*models.Memo, bool) {
-	if val, found := cm.cache.Get(cacheKey); found {
-		if memos, ok := val.([]*models.Memo); ok {
-			return memos, true
-		}
-	}
-	
-	return nil, false
-}
-
-// SetMemoList stores a list of memos in cache
-func (cm *CacheManager) SetMemoList(cacheKey string, memos []*models.Memo) {
-	cm.cache.Set(cacheKey, memos, cache.DefaultExpiration)
-}
-
-// InvalidateMemo removes a memo from cache
-func (cm *CacheManager) InvalidateMemo(id string) {
-	key := fmt.Sprintf("memo:%s", id)
-	cm.cache.Delete(key)
-}
-
-// InvalidateUserMemos removes all cached memo lists for a user
-func (cm *CacheManager) InvalidateUserMemos(userEmail string) {
-	// Invalidate sent memos cache for all pages
-	sentPattern := fmt.Sprintf("sent:%s:", userEmail)
-	cm.deleteByPrefix(sentPattern)
-	
-	// Invalidate received memos cache for all pages
-	receivedPattern := fmt.Sprintf("received:%s:", userEmail)
-	cm.deleteByPrefix(receivedPattern)
-}
-
-// InvalidateBroadcastMemos invalidates all users' received memo caches (for broadcasts)
-func (cm *CacheManager) InvalidateBroadcastMemos() {
-	// Invalidate all received memo caches
-	cm.deleteByPrefix("received:")
-}
-
-// deleteByPrefix removes all cache items with keys starting with the given prefix
-func (cm *CacheManager) deleteByPrefix(prefix string) {
-	// Get all items and filter by prefix
-	items := cm.cache.Items()
-	for key := range items {
-		if len(key) >= len(prefix) && key[:len(prefix)] == prefix {
-			cm.cache.Delete(key)
-		}
-	}
-}
-
-// GetUserList retrieves the cached list of all users
-func (cm *CacheManager) GetUserList() ([]string, bool) {
-	if val, found := cm.cache.Get("users:all"); found {
-		if users, ok := val.([]string); ok {
-			return users, true
-		}
-	}
-	
-	return nil, false
-}
-
-// SetUserList stores the list of all users in cache
-func (cm *CacheManager) SetUserList(users []string) {
-	cm.cache.Set("users:all", users, cache.DefaultExpiration)
-}
-
-// InvalidateUserList removes the cached user list
-func (cm *CacheManager) InvalidateUserList() {
-	cm.cache.Delete("users:all")
+// Cache caches memos, memo listings and search results in front of the database
+//
+// Listings are cached per user. Rather than deleting a user's entries, invalidation
+// moves the user to a new key namespace by bumping a version counter; entries in
+// the old namespace are never read again and expire with the cache TTL. A global
+// broadcast version is part of every namespace, so a broadcast invalidates everyone's
+// listings with a single write.
+//
+// Callers take the namespace before reading the database and store what they read
+// under it. A listing read just before an invalidation then lands in the old
+// namespace, where no later reader looks, instead of being served stale.
+type Cache interface {
+	// GetMemo retrieves a memo from cache
+	GetMemo(id string) (*models.Memo, bool)
+	// SetMemo stores a memo in cache
+	SetMemo(memo *models.Memo)
+	// InvalidateMemo removes a memo from cache
+	InvalidateMemo(id string)
+
+	// Namespace returns the current namespace of user's lists and search results
+	// It reports false if the cache cannot be used for them right now
+	Namespace(user string) (string, bool)
+	// GetMemoList retrieves a cached list of memos from a namespace
+	GetMemoList(namespace string, key string) ([]*models.Memo, bool)
+	// SetMemoList stores a list of memos in a namespace
+	SetMemoList(namespace string, key string, memos []*models.Memo)
+	// GetSearchPage retrieves a cached page of search results from a namespace
+	GetSearchPage(namespace string, key string) (*models.SearchPage, bool)
+	// SetSearchPage stores a page of search results in a namespace
+	SetSearchPage(namespace string, key string, page *models.SearchPage)
+	// InvalidateUserMemos invalidates every cached list and search result of a user
+	InvalidateUserMemos(user string)
+	// InvalidateBroadcastMemos invalidates every user's cached lists and search results
+	InvalidateBroadcastMemos()
+}
+
+// memoKey returns the key of a single cached memo
+func memoKey(id string) string {
+	return fmt.Sprintf("memo:%s", id)
+}
+
+// listNamespace names the namespace of the given user and broadcast versions
+func listNamespace(user string, userVersion int64, broadcastVersion int64) string {
+	return fmt.Sprintf("list:%s:%d:%d", user, userVersion, broadcastVersion)
+}
+
+// namespacedKey places key in namespace
+func namespacedKey(namespace string, key string) string {
+	return namespace + ":" + key
 }
@@ -0,0 +1,229 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+
+	"memo-app/internal/models"
+)
+
+const testTTL = time.Minute
+
+// newTestRedisCache returns a RedisCache backed by an in-process Redis stand-in
+func newTestRedisCache(t *testing.T, server *miniredis.Miniredis) *RedisCache {
+	t.Helper()
+	rc, err := NewRedisCache("redis://"+server.Addr(), testTTL)
+	if err != nil {
+		t.Fatalf("NewRedisCache: %v", err)
+	}
+	t.Cleanup(func() { rc.Close() })
+	return rc
+}
+
+// namespace returns user's current list namespace, failing the test if there is none
+func namespace(t *testing.T, c Cache, user string) string {
+	t.Helper()
+	ns, ok := c.Namespace(user)
+	if !ok {
+		t.Fatalf("Namespace(%q) reported the cache unusable", user)
+	}
+	return ns
+}
+
+// backends returns a fresh instance of every Cache implementation
+func backends(t *testing.T) map[string]Cache {
+	return map[string]Cache{
+		"memory": NewMemoryCache(testTTL),
+		"redis":  newTestRedisCache(t, miniredis.RunT(t)),
+	}
+}
+
+func TestMemoRoundTrip(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, ok := c.GetMemo("m1"); ok {
+				t.Fatal("GetMemo on empty cache reported a hit")
+			}
+
+			c.SetMemo(&models.Memo{ID: "m1", From: "alice@example.com", Subject: "Hi", Recipients: []string{"bob@example.com"}})
+			memo, ok := c.GetMemo("m1")
+			if !ok {
+				t.Fatal("GetMemo after SetMemo reported a miss")
+			}
+			if memo.From != "alice@example.com" || memo.Subject != "Hi" || len(memo.Recipients) != 1 {
+				t.Errorf("GetMemo = %+v, want the stored memo", memo)
+			}
+
+			c.InvalidateMemo("m1")
+			if _, ok := c.GetMemo("m1"); ok {
+				t.Error("GetMemo after InvalidateMemo reported a hit")
+			}
+		})
+	}
+}
+
+func TestInvalidateUserMemos(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.SetMemoList(namespace(t, c, "alice@example.com"), "sent:10:0", []*models.Memo{{ID: "m1"}})
+			c.SetMemoList(namespace(t, c, "bob@example.com"), "sent:10:0", []*models.Memo{{ID: "m2"}})
+			c.SetSearchPage(namespace(t, c, "alice@example.com"), "search:+hello::10", &models.SearchPage{NextCursor: "next"})
+
+			memos, ok := c.GetMemoList(namespace(t, c, "alice@example.com"), "sent:10:0")
+			if !ok || len(memos) != 1 || memos[0].ID != "m1" {
+				t.Fatalf("GetMemoList = %v, %v; want [m1], true", memos, ok)
+			}
+			if page, ok := c.GetSearchPage(namespace(t, c, "alice@example.com"), "search:+hello::10"); !ok || page.NextCursor != "next" {
+				t.Fatalf("GetSearchPage = %v, %v; want the stored page", page, ok)
+			}
+
+			c.InvalidateUserMemos("alice@example.com")
+			if _, ok := c.GetMemoList(namespace(t, c, "alice@example.com"), "sent:10:0"); ok {
+				t.Error("alice's list survived InvalidateUserMemos")
+			}
+			if _, ok := c.GetSearchPage(namespace(t, c, "alice@example.com"), "search:+hello::10"); ok {
+				t.Error("alice's search page survived InvalidateUserMemos")
+			}
+			if _, ok := c.GetMemoList(namespace(t, c, "bob@example.com"), "sent:10:0"); !ok {
+				t.Error("bob's list was invalidated with alice's")
+			}
+
+			// The new namespace is usable straight away
+			c.SetMemoList(namespace(t, c, "alice@example.com"), "sent:10:0", []*models.Memo{{ID: "m3"}})
+			if memos, ok := c.GetMemoList(namespace(t, c, "alice@example.com"), "sent:10:0"); !ok || memos[0].ID != "m3" {
+				t.Errorf("GetMemoList after re-set = %v, %v; want [m3], true", memos, ok)
+			}
+		})
+	}
+}
+
+func TestInvalidateBroadcastMemos(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			c.SetMemoList(namespace(t, c, "alice@example.com"), "received:10:0", []*models.Memo{{ID: "m1"}})
+			c.SetMemoList(namespace(t, c, "bob@example.com"), "received:10:0", []*models.Memo{{ID: "m1"}})
+
+			c.InvalidateBroadcastMemos()
+			for _, user := range []string{"alice@example.com", "bob@example.com"} {
+				if _, ok := c.GetMemoList(namespace(t, c, user), "received:10:0"); ok {
+					t.Errorf("%s's list survived InvalidateBroadcastMemos", user)
+				}
+			}
+		})
+	}
+}
+
+// A list read before an invalidation but stored after it must not be served
+func TestListReadBeforeInvalidationIsNotServed(t *testing.T) {
+	for name, c := range backends(t) {
+		t.Run(name, func(t *testing.T) {
+			for _, invalidate := range []func(){
+				func() { c.InvalidateUserMemos("alice@example.com") },
+				c.InvalidateBroadcastMemos,
+			} {
+				before := namespace(t, c, "alice@example.com")
+				invalidate()
+				c.SetMemoList(before, "received:10:0", []*models.Memo{{ID: "stale"}})
+				c.SetSearchPage(before, "search:+hello::10", &models.SearchPage{NextCursor: "stale"})
+
+				after := namespace(t, c, "alice@example.com")
+				if after == before {
+					t.Fatalf("namespace %q survived the invalidation", before)
+				}
+				if memos, ok := c.GetMemoList(after, "received:10:0"); ok {
+					t.Errorf("GetMemoList served %v stored under the old namespace", memos)
+				}
+				if page, ok := c.GetSearchPage(after, "search:+hello::10"); ok {
+					t.Errorf("GetSearchPage served %+v stored under the old namespace", page)
+				}
+			}
+		})
+	}
+}
+
+// Memory versions expire with the entries, but namespaces are never reused
+func TestMemoryVersionsExpire(t *testing.T) {
+	c := NewMemoryCache(testTTL)
+	first := namespace(t, c, "alice@example.com")
+	c.InvalidateUserMemos("alice@example.com")
+	c.InvalidateUserMemos("bob@example.com")
+	if n := c.userVersions.ItemCount(); n != 2 {
+		t.Fatalf("tracking %d user versions, want 2", n)
+	}
+	second := namespace(t, c, "alice@example.com")
+
+	// Expire the versions as if the TTL had passed
+	c.userVersions.Flush()
+	if got := namespace(t, c, "alice@example.com"); got != first {
+		t.Errorf("namespace after expiry = %q, want the initial %q", got, first)
+	}
+	c.InvalidateUserMemos("alice@example.com")
+	if got := namespace(t, c, "alice@example.com"); got == first || got == second {
+		t.Errorf("namespace %q was used before", got)
+	}
+}
+
+// Two instances sharing one Redis server must see each other's invalidations
+func TestRedisInvalidationReachesOtherInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	a := newTestRedisCache(t, server)
+	b := newTestRedisCache(t, server)
+
+	a.SetMemoList(namespace(t, a, "alice@example.com"), "received:10:0", []*models.Memo{{ID: "m1"}})
+	a.SetMemo(&models.Memo{ID: "m1"})
+	if _, ok := b.GetMemoList(namespace(t, b, "alice@example.com"), "received:10:0"); !ok {
+		t.Fatal("instance b missed a list cached by instance a")
+	}
+
+	b.InvalidateUserMemos("alice@example.com")
+	b.InvalidateMemo("m1")
+	if _, ok := a.GetMemoList(namespace(t, a, "alice@example.com"), "received:10:0"); ok {
+		t.Error("instance a still serves a list invalidated by instance b")
+	}
+	if _, ok := a.GetMemo("m1"); ok {
+		t.Error("instance a still serves a memo invalidated by instance b")
+	}
+}
+
+func TestRedisEntriesExpire(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := newTestRedisCache(t, server)
+
+	c.SetMemoList(namespace(t, c, "alice@example.com"), "sent:10:0", []*models.Memo{{ID: "m1"}})
+	c.InvalidateUserMemos("alice@example.com")
+
+	server.FastForward(testTTL + time.Second)
+	for _, key := range server.Keys() {
+		if key != c.userVersionKey("alice@example.com") {
+			t.Errorf("key %q outlived the cache TTL", key)
+		}
+	}
+}
+
+// A Redis outage degrades to cache misses instead of failing requests
+func TestRedisUnavailableIsAMiss(t *testing.T) {
+	server := miniredis.RunT(t)
+	c := newTestRedisCache(t, server)
+	c.SetMemo(&models.Memo{ID: "m1"})
+	server.Close()
+
+	if _, ok := c.GetMemo("m1"); ok {
+		t.Error("GetMemo reported a hit with the server down")
+	}
+	if _, ok := c.Namespace("alice@example.com"); ok {
+		t.Error("Namespace reported a usable cache with the server down")
+	}
+	if _, ok := c.GetMemoList("list:alice@example.com:0:0", "sent:10:0"); ok {
+		t.Error("GetMemoList reported a hit with the server down")
+	}
+	c.SetMemoList("list:alice@example.com:0:0", "sent:10:0", nil)
+	c.InvalidateUserMemos("alice@example.com")
+}
+
+func TestNewRedisCacheRejectsBadURL(t *testing.T) {
+	if _, err := NewRedisCache("not a url", testTTL); err == nil {
+		t.Error("NewRedisCache accepted an invalid URL")
+	}
+}
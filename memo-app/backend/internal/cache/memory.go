@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+	"memo-app/internal/models"
+)
+
+// MemoryCache is an in-process Cache using go-cache
+// It is only suitable for a single instance: invalidations are not seen by other replicas
+//
+// User versions are kept for one TTL after their last bump, so only recently
+// invalidated users take memory. A user whose version expired is back at version 0,
+// whose entries have expired too; bumps take the next value of one counter shared
+// by all users, so no other namespace is ever used twice.
+type MemoryCache struct {
+	cache        *cache.Cache
+	userVersions *cache.Cache
+
+	mu               sync.Mutex
+	lastVersion      int64
+	broadcastVersion int64
+}
+
+// NewMemoryCache creates a new in-memory cache whose entries expire after ttl
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	// Create cache with default TTL and cleanup interval of 1 minute
+	c := cache.New(ttl, 1*time.Minute)
+	log.Printf("Cache manager initialized with in-memory cache (TTL: %v)", ttl)
+	return &MemoryCache{
+		cache:        c,
+		userVersions: cache.New(ttl, 1*time.Minute),
+	}
+}
+
+// GetMemo retrieves a memo from cache
+func (mc *MemoryCache) GetMemo(id string) (*models.Memo, bool) {
+	if val, found := mc.cache.Get(memoKey(id)); found {
+		if memo, ok := val.(*models.Memo); ok {
+			return memo, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetMemo stores a memo in cache
+func (mc *MemoryCache) SetMemo(memo *models.Memo) {
+	mc.cache.Set(memoKey(memo.ID), memo, cache.DefaultExpiration)
+}
+
+// InvalidateMemo removes a memo from cache
+func (mc *MemoryCache) InvalidateMemo(id string) {
+	mc.cache.Delete(memoKey(id))
+}
+
+// Namespace returns the current namespace of user's lists and search results
+func (mc *MemoryCache) Namespace(user string) (string, bool) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	var userVersion int64
+	if val, found := mc.userVersions.Get(user); found {
+		userVersion = val.(int64)
+	}
+	return listNamespace(user, userVersion, mc.broadcastVersion), true
+}
+
+// GetMemoList retrieves a cached list of memos
+func (mc *MemoryCache) GetMemoList(namespace string, key string) ([]*models.Memo, bool) {
+	if val, found := mc.cache.Get(namespacedKey(namespace, key)); found {
+		if memos, ok := val.([]*models.Memo); ok {
+			return memos, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetMemoList stores a list of memos in cache
+func (mc *MemoryCache) SetMemoList(namespace string, key string, memos []*models.Memo) {
+	mc.cache.Set(namespacedKey(namespace, key), memos, cache.DefaultExpiration)
+}
+
+// GetSearchPage retrieves a cached page of search results
+func (mc *MemoryCache) GetSearchPage(namespace string, key string) (*models.SearchPage, bool) {
+	if val, found := mc.cache.Get(namespacedKey(namespace, key)); found {
+		if page, ok := val.(*models.SearchPage); ok {
+			return page, true
+		}
+	}
+
+	return nil, false
+}
+
+// SetSearchPage stores a page of search results in cache
+func (mc *MemoryCache) SetSearchPage(namespace string, key string, page *models.SearchPage) {
+	mc.cache.Set(namespacedKey(namespace, key), page, cache.DefaultExpiration)
+}
+
+// InvalidateUserMemos moves a user's lists and search results to a new namespace
+func (mc *MemoryCache) InvalidateUserMemos(user string) {
+	mc.mu.Lock()
+	mc.lastVersion++
+	mc.userVersions.Set(user, mc.lastVersion, cache.DefaultExpiration)
+	mc.mu.Unlock()
+}
+
+// InvalidateBroadcastMemos moves every user's lists and search results to a new namespace
+func (mc *MemoryCache) InvalidateBroadcastMemos() {
+	mc.mu.Lock()
+	mc.broadcastVersion++
+	mc.mu.Unlock()
+}
@@ -0,0 +1,200 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+// RedisCache is a Cache shared by every instance through a Redis-protocol server
+// Values are stored as JSON, so only fields visible in the API survive a round trip.
+// Cache errors are logged and treated as misses; the database stays the source of truth.
+type RedisCache struct {
+	client *redis.Client
+	ttl    time.Duration
+	prefix string
+}
+
+// NewRedisCache connects to the Redis server at url, e.g. redis://localhost:6379/0,
+// and returns a cache whose entries expire after ttl
+func NewRedisCache(url string, ttl time.Duration) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, err
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.RedisTimeoutSeconds*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("redis ping: %w", err)
+	}
+
+	log.Printf("Cache manager initialized with Redis cache at %s (TTL: %v)", opts.Addr, ttl)
+	return &RedisCache{client: client, ttl: ttl, prefix: config.RedisKeyPrefix}, nil
+}
+
+// Client returns the connection to the Redis server, for other state shared between instances
+func (rc *RedisCache) Client() *redis.Client {
+	return rc.client
+}
+
+// Close closes the connection to the Redis server
+func (rc *RedisCache) Close() error {
+	return rc.client.Close()
+}
+
+// GetMemo retrieves a memo from cache
+func (rc *RedisCache) GetMemo(id string) (*models.Memo, bool) {
+	var memo models.Memo
+	if !rc.get(rc.prefix+memoKey(id), &memo) {
+		return nil, false
+	}
+	return &memo, true
+}
+
+// SetMemo stores a memo in cache
+func (rc *RedisCache) SetMemo(memo *models.Memo) {
+	rc.set(rc.prefix+memoKey(memo.ID), memo)
+}
+
+// InvalidateMemo removes a memo from cache
+func (rc *RedisCache) InvalidateMemo(id string) {
+	rc.del(rc.prefix + memoKey(id))
+}
+
+// GetMemoList retrieves a cached list of memos
+func (rc *RedisCache) GetMemoList(namespace string, key string) ([]*models.Memo, bool) {
+	var memos []*models.Memo
+	if !rc.get(rc.prefix+namespacedKey(namespace, key), &memos) {
+		return nil, false
+	}
+	return memos, true
+}
+
+// SetMemoList stores a list of memos in cache
+func (rc *RedisCache) SetMemoList(namespace string, key string, memos []*models.Memo) {
+	rc.set(rc.prefix+namespacedKey(namespace, key), memos)
+}
+
+// GetSearchPage retrieves a cached page of search results
+func (rc *RedisCache) GetSearchPage(namespace string, key string) (*models.SearchPage, bool) {
+	var page models.SearchPage
+	if !rc.get(rc.prefix+namespacedKey(namespace, key), &page) {
+		return nil, false
+	}
+	return &page, true
+}
+
+// SetSearchPage stores a page of search results in cache
+func (rc *RedisCache) SetSearchPage(namespace string, key string, page *models.SearchPage) {
+	rc.set(rc.prefix+namespacedKey(namespace, key), page)
+}
+
+// InvalidateUserMemos moves a user's lists and search results to a new namespace
+func (rc *RedisCache) InvalidateUserMemos(user string) {
+	rc.incr(rc.userVersionKey(user))
+}
+
+// InvalidateBroadcastMemos moves every user's lists and search results to a new namespace
+func (rc *RedisCache) InvalidateBroadcastMemos() {
+	rc.incr(rc.broadcastVersionKey())
+}
+
+// userVersionKey holds the namespace version of a user's lists
+// Version keys never expire so that a namespace is never reused
+func (rc *RedisCache) userVersionKey(user string) string {
+	return rc.prefix + "version:user:" + user
+}
+
+// broadcastVersionKey holds the namespace version shared by every user
+func (rc *RedisCache) broadcastVersionKey() string {
+	return rc.prefix + "version:broadcast"
+}
+
+// Namespace returns the current namespace of user's lists and search results
+// It reports false if the versions cannot be read, so callers skip the cache
+func (rc *RedisCache) Namespace(user string) (string, bool) {
+	ctx, cancel := rc.context()
+	defer cancel()
+
+	values, err := rc.client.MGet(ctx, rc.userVersionKey(user), rc.broadcastVersionKey()).Result()
+	if err != nil {
+		log.Printf("Redis cache: failed to read versions for %s: %v", user, err)
+		return "", false
+	}
+	versions := make([]int64, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			versions[i], _ = strconv.ParseInt(s, 10, 64)
+		}
+	}
+	return listNamespace(user, versions[0], versions[1]), true
+}
+
+// get decodes the JSON value at key into dest and reports whether it was found
+func (rc *RedisCache) get(key string, dest interface{}) bool {
+	ctx, cancel := rc.context()
+	defer cancel()
+
+	data, err := rc.client.Get(ctx, key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			log.Printf("Redis cache: failed to get %s: %v", key, err)
+		}
+		return false
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		log.Printf("Redis cache: failed to decode %s: %v", key, err)
+		return false
+	}
+	return true
+}
+
+// set stores value at key as JSON with the cache TTL
+func (rc *RedisCache) set(key string, value interface{}) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Redis cache: failed to encode %s: %v", key, err)
+		return
+	}
+
+	ctx, cancel := rc.context()
+	defer cancel()
+	if err := rc.client.Set(ctx, key, data, rc.ttl).Err(); err != nil {
+		log.Printf("Redis cache: failed to set %s: %v", key, err)
+	}
+}
+
+// del removes key
+func (rc *RedisCache) del(key string) {
+	ctx, cancel := rc.context()
+	defer cancel()
+	if err := rc.client.Del(ctx, key).Err(); err != nil {
+		log.Printf("Redis cache: failed to delete %s: %v", key, err)
+	}
+}
+
+// incr increments the counter at key
+func (rc *RedisCache) incr(key string) {
+	ctx, cancel := rc.context()
+	defer cancel()
+	if err := rc.client.Incr(ctx, key).Err(); err != nil {
+		log.Printf("Redis cache: failed to increment %s: %v", key, err)
+	}
+}
+
+// context bounds a single cache operation
+func (rc *RedisCache) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), config.RedisTimeoutSeconds*time.Second)
+}
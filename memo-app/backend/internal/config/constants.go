@@ -5,18 +5,40 @@ const (
 	ErrSenderEmailNotFound = "Sender email not found"
 	ErrUserEmailNotFound   = "User email not found"
 	ErrInvalidToken        = "Invalid or expired token"
-	ErrTokenRequired       = "Token required for SSE subscription"
+	ErrTicketRequired      = "Stream ticket required for SSE subscription"
+	ErrInvalidTicket       = "Invalid, expired or already used stream ticket"
 	ErrInvalidTTL          = "TTL must be at least 1 day if specified"
 	ErrFailedToCreateMemo  = "Failed to create memo"
+	ErrInvalidStatus       = "Status must be 'delivered' or 'read'"
+	ErrMemoNotFound        = "Memo not found"
+	ErrNotMemoOwner        = "Only the sender or an admin can modify this memo"
+	ErrNotMemoRecipient    = "Only recipients can update the status of this memo"
+	ErrBroadcastForbidden  = "Only privileged users can send broadcasts"
+	ErrNoRecipients        = "At least one recipient is required"
+	ErrInsufficientRole    = "Insufficient permissions"
+	ErrNotMemoParticipant  = "Only participants of a memo can reply to it"
+	ErrAttachmentForbidden = "Only the sender and recipients of a memo can download its attachments"
+	ErrAttachmentRequired  = "A file is required in the 'file' form field"
+	ErrSearchQueryRequired = "Search query q is required"
+	ErrNotScheduledAuthor  = "Only the author can change a scheduled memo"
+	ErrNotAckViewer        = "Only the sender or an admin can view acknowledgements"
+	ErrNotMemoSender       = "Only the sender can edit this memo"
+	ErrRateLimited         = "Too many memos sent; try again later"
+	ErrMessageTooLarge     = "Message is too large"
+	ErrTooManyRecipients   = "Too many recipients"
 
-	MsgMemoSentSuccess = "Memo sent successfully"
+	MsgMemoSentSuccess      = "Memo sent successfully"
+	MsgMemoScheduledSuccess = "Memo scheduled successfully"
 )
 
 // Database Defaults
 const (
-	DefaultTTLDays   = 7   // Default memo retention period in days
+	DefaultTTLDays   = 7   // Default memo retention period in days, used for categories without a retention policy
 	DefaultPageLimit = 10  // Default number of memos per page
 	MaxPageLimit     = 100 // Maximum allowed memos per page
+
+	DefaultAddressLookupLimit = 20 // Default number of address lookup results
+	DefaultUserSearchLimit    = 20 // Default number of users per directory page
 )
 
 // Server Configuration
@@ -25,11 +47,121 @@ const (
 	DefaultDatabaseURL = "root:password@tcp(localhost:3306)/memo_db?charset=utf8mb4&parseTime=True&loc=Local"
 )
 
+// Cache backends (CACHE_BACKEND)
+const (
+	CacheBackendMemory = "memory" // In-process cache; single instance only (default)
+	CacheBackendRedis  = "redis"  // Shared cache on the Redis server at REDIS_URL
+
+	DefaultRedisURL     = "redis://localhost:6379/0"
+	RedisKeyPrefix      = "memo-app:" // prefix of every key the cache writes
+	RedisTimeoutSeconds = 2           // timeout of a single cache operation
+)
+
+// Authentication modes (AUTH_MODE)
+const (
+	AuthModeJWT = "jwt" // Validate Bearer JWTs against JWKS_URL (default)
+	AuthModeDev = "dev" // Trust the X-User-Email header; local development only
+)
+
 // Service Names
 const (
 	ServiceName = "memo-app"
 )
 
+// Real-time streaming (SSE)
+const (
+	SSEHeartbeatSeconds = 25   // interval between keep-alive comments on idle streams
+	SSEReplayBufferSize = 1000 // number of recent events kept for Last-Event-ID replay
+	SSESubscriberBuffer = 64   // undelivered events per connection before it is dropped
+	SSETicketSeconds    = 30   // how long a stream ticket can be redeemed for
+)
+
+// Attachments
+const (
+	DefaultAttachmentDir    = "./data/attachments" // used when ATTACHMENT_DIR is not set
+	MaxAttachmentBytes      = 10 << 20             // largest single file accepted (10 MiB)
+	MaxAttachmentsPerMemo   = 10                   // files that can be linked to one memo
+	AttachmentQuotaBytes    = 200 << 20            // total size of files a user may have stored (200 MiB)
+	UnlinkedAttachmentHours = 24                   // uploads never sent with a memo are removed after this
+	AttachmentSniffBytes    = 512                  // bytes inspected to detect the content type
+)
+
+// AllowedAttachmentTypes lists the sniffed content types accepted for upload
+// Office documents are zip containers and are detected as application/zip
+var AllowedAttachmentTypes = []string{
+	"application/pdf",
+	"application/zip",
+	"image/gif",
+	"image/jpeg",
+	"image/png",
+	"image/webp",
+	"text/plain",
+}
+
+// Scheduled send
+const (
+	SchedulerIntervalSeconds = 30  // how often due scheduled memos are released
+	SchedulerBatchSize       = 100 // scheduled memos released, and ended visibility windows expired, per run
+)
+
+// Retention
+const (
+	DefaultMemoCategory    = "general"        // category of memos sent without one
+	AckMemoCategory        = "acknowledgment" // category of memos requiring acknowledgement sent without one
+	CleanupIntervalMinutes = 60               // how often memos are archived and purged
+	RetentionBatchSize     = 500              // memos archived or purged per statement
+)
+
+// Recall and edit
+const (
+	EditGraceMinutes = 15 // how long after sending a memo its sender can still edit it
+)
+
+// Sending limits, overridable with SEND_QUOTA_USER, SEND_QUOTA_BROADCASTER, SEND_QUOTA_ADMIN,
+// BROADCAST_QUOTA, MAX_MESSAGE_BYTES and MAX_RECIPIENTS; quotas are burst/period
+const (
+	DefaultSendQuotaUser        = "60/1h"  // memos a user can send at once, refilled evenly over the period
+	DefaultSendQuotaBroadcaster = "200/1h" // same for broadcasters
+	DefaultSendQuotaAdmin       = "500/1h" // same for admins
+	DefaultBroadcastQuota       = "10/1h"  // broadcasts per sender, taken on top of the send quota
+	DefaultMaxMessageBytes      = 64 << 10 // largest message accepted (64 KiB)
+	DefaultMaxRecipients        = 100      // most to and cc addresses per memo; a distribution list counts once
+	SendEnvelopeBytes           = 64 << 10 // request body allowed beyond the message, for subject, addresses and options
+)
+
+// Templates
+const (
+	MaxTemplateMergeRecipients = 1000 // recipients of a templated memo that is rendered for each of them
+)
+
+// Acknowledgements
+const (
+	AckReminderCheckMinutes  = 15  // how often unacknowledged memos are looked for
+	AckReminderIntervalHours = 24  // time between reminders to the same recipient
+	AckMaxReminders          = 3   // reminders sent before giving up on a recipient
+	AckReminderBatchSize     = 100 // memos reminded about per run
+)
+
+// Push notifications (PUSH_GATEWAY)
+const (
+	PushGatewayFCM     = "fcm" // FCM-style HTTP gateway at FCM_ENDPOINT authenticated with FCM_SERVER_KEY
+	DefaultFCMEndpoint = "https://fcm.googleapis.com/fcm/send"
+
+	PushIntervalSeconds      = 5   // how often the outbox is polled
+	PushJobsPerRun           = 50  // outbox entries claimed per poll
+	PushBatchSize            = 500 // recipients notified per gateway call
+	PushLeaseSeconds         = 120 // time a claimed entry is reserved for one server
+	PushMaxAttempts          = 5   // failed attempts before an entry is given up
+	PushRetryBaseSeconds     = 30  // first retry delay, doubled after every failure
+	PushOutboxRetentionHours = 72  // processed entries are removed after this
+	PushTimeoutSeconds       = 10  // timeout of a single gateway request
+)
+
+// Listing options
+const (
+	GroupByThread = "thread" // groupBy value that lists threads instead of memos
+)
+
 // Broadcast identifier
 const (
 	BroadcastRecipient = "broadcast"
@@ -43,3 +175,9 @@ const (
 	MaxOpenConns           = 50 // maximum open connections allowed
 	ReconnectFailThreshold = 3  // consecutive ping failures before reconnect attempt
 )
+
+// Schema migrations (memo-app migrate)
+const (
+	MigrationLockName           = "memo-app:migrate" // MySQL advisory lock held while migrating
+	MigrationLockTimeoutSeconds = 60                 // how long to wait for another instance's migration
+)
@@ -0,0 +1,158 @@
+package events
+
+import (
+	"log"
+	"sync"
+)
+
+// EventType identifies the kind of change pushed to subscribers
+type EventType string
+
+const (
+	MemoCreated       EventType = "memo.created"        // A memo was sent to the subscriber
+	MemoStatusChanged EventType = "memo.status_changed" // Delivery status of a memo changed
+	MemoDeleted       EventType = "memo.deleted"        // A memo was removed
+	MemoExpired       EventType = "memo.expired"        // A memo's visibility window ended
+	MemoAcknowledged  EventType = "memo.acknowledged"   // A recipient acknowledged a memo
+	MemoAckReminder   EventType = "memo.ack_reminder"   // The subscriber still has to acknowledge a memo
+	MemoEdited        EventType = "memo.edited"         // The sender corrected a memo
+	MemoRecalled      EventType = "memo.recalled"       // The sender recalled a memo
+)
+
+// Event is a single change published through the hub
+type Event struct {
+	ID         uint64      `json:"id"`
+	Type       EventType   `json:"type"`
+	Data       interface{} `json:"data"`
+	Recipients []string    `json:"-"` // Users the event is addressed to
+	Broadcast  bool        `json:"-"` // True if every subscriber should receive the event
+}
+
+// visibleTo reports whether the event is addressed to the given user
+func (e Event) visibleTo(user string) bool {
+	if e.Broadcast {
+		return true
+	}
+	for _, r := range e.Recipients {
+		if r == user {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscription is a single client connection receiving events for a user
+type Subscription struct {
+	User   string
+	Events <-chan Event
+
+	ch chan Event
+}
+
+// Hub is an in-process pub/sub hub with per-user fan-out and a bounded replay buffer
+type Hub struct {
+	mu          sync.Mutex
+	nextID      uint64
+	history     []Event
+	historySize int
+	bufferSize  int
+	subscribers map[string]map[*Subscription]struct{}
+}
+
+// NewHub creates a hub that keeps the last historySize events for replay.
+// Each subscriber can have up to bufferSize undelivered events before it is dropped.
+func NewHub(historySize, bufferSize int) *Hub {
+	return &Hub{
+		history:     make([]Event, 0, historySize),
+		historySize: historySize,
+		bufferSize:  bufferSize,
+		subscribers: make(map[string]map[*Subscription]struct{}),
+	}
+}
+
+// Publish assigns the event an ID, records it for replay and fans it out to subscribers
+func (h *Hub) Publish(evt Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.nextID++
+	evt.ID = h.nextID
+
+	if len(h.history) == h.historySize {
+		copy(h.history, h.history[1:])
+		h.history = h.history[:len(h.history)-1]
+	}
+	h.history = append(h.history, evt)
+
+	if evt.Broadcast {
+		for _, subs := range h.subscribers {
+			h.deliver(subs, evt)
+		}
+		return
+	}
+	for _, user := range evt.Recipients {
+		h.deliver(h.subscribers[user], evt)
+	}
+}
+
+// deliver sends evt to each subscription without blocking. Subscribers that have
+// fallen behind are dropped; they can reconnect and replay with Last-Event-ID.
+// Must be called with h.mu held.
+func (h *Hub) deliver(subs map[*Subscription]struct{}, evt Event) {
+	for sub := range subs {
+		select {
+		case sub.ch <- evt:
+		default:
+			log.Printf("events: dropping slow subscriber for %s", sub.User)
+			h.remove(sub)
+		}
+	}
+}
+
+// Subscribe registers a subscription for user. Events published after lastEventID
+// that are still in the replay buffer are returned so the caller can send them first.
+func (h *Hub) Subscribe(user string, lastEventID uint64) (*Subscription, []Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan Event, h.bufferSize)
+	sub := &Subscription{User: user, Events: ch, ch: ch}
+	if h.subscribers[user] == nil {
+		h.subscribers[user] = make(map[*Subscription]struct{})
+	}
+	h.subscribers[user][sub] = struct{}{}
+
+	var replay []Event
+	if lastEventID > 0 {
+		for _, evt := range h.history {
+			if evt.ID > lastEventID && evt.visibleTo(user) {
+				replay = append(replay, evt)
+			}
+		}
+	}
+
+	return sub, replay
+}
+
+// Unsubscribe removes the subscription and closes its channel
+func (h *Hub) Unsubscribe(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.remove(sub)
+}
+
+// remove must be called with h.mu held
+func (h *Hub) remove(sub *Subscription) {
+	subs, ok := h.subscribers[sub.User]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub]; !ok {
+		return
+	}
+	delete(subs, sub)
+	close(sub.ch)
+	if len(subs) == 0 {
+		delete(h.subscribers, sub.User)
+	}
+}
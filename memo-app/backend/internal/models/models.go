@@ -1,6 +1,8 @@
 package models
 
 import (
+	"encoding/json"
+	"strings"
 	"time"
 )
 
@@ -9,34 +11,498 @@ type MemoStatus string
 
 const (
 	StatusSent      MemoStatus = "sent"      // Memo has been sent but not yet delivered
-	StatusDelivered MemoStatus = "delivered" // Memo has been read by recipient
+	StatusDelivered MemoStatus = "delivered" // Memo has been downloaded by the recipient (by every recipient at memo level)
+	StatusRead      MemoStatus = "read"      // Memo has been opened by the recipient
+	StatusScheduled MemoStatus = "scheduled" // Memo is waiting for its sendAt time and hidden from recipients
+	StatusFailed    MemoStatus = "failed"    // Scheduled memo could not be released, e.g. its distribution list was deleted
 )
 
 // Memo represents a message between users with optional broadcast and TTL settings
 type Memo struct {
 	ID          string     `json:"id" gorm:"primaryKey;type:varchar(36)"`
 	From        string     `json:"from" gorm:"index;type:varchar(255)"`
-	To          string     `json:"to" gorm:"index;type:varchar(255)"` // User email or "broadcast" for broadcast messages
+	To          string     `json:"to" gorm:"index;type:varchar(255)"` // First recipient's email, or "broadcast" for broadcast messages
 	Subject     string     `json:"subject" gorm:"type:text"`
 	Message     string     `json:"message" gorm:"type:text"`
 	Status      MemoStatus `json:"status" gorm:"index;type:varchar(20)"`
-	IsBroadcast bool       `json:"isBroadcast" gorm:"index"` // True if this memo should be visible to all users
-	TTLDays     *int       `json:"ttlDays,omitempty"`        // Custom time-to-live in days, nil means use default TTL
+	IsBroadcast bool       `json:"isBroadcast" gorm:"index"` // True if this memo should be visible to all users, or to its audience
+	TTLDays     *int       `json:"ttlDays,omitempty"`        // Custom time-to-live in days before archiving, nil means use the category's retention policy
 	CreatedAt   time.Time  `json:"createdAt" gorm:"autoCreateTime"`
-	DeliveredAt *time.Time `json:"deliveredAt,omitempty" gorm:"index"` // Timestamp when status changed to delivered
+	DeliveredAt *time.Time `json:"deliveredAt,omitempty" gorm:"index"`               // Timestamp when status changed to delivered
+	ParentID    *string    `json:"parentId,omitempty" gorm:"type:varchar(36);index"` // Memo this one replies to, nil for a new conversation
+	ThreadID    string     `json:"threadId" gorm:"type:varchar(36);index"`           // ID of the first memo in the conversation
+
+	// Audience narrows a broadcast to the users matching it; nil broadcasts to everyone
+	Audience *Audience `json:"audience,omitempty" gorm:"serializer:json;type:text"`
+
+	// Retention: the category selects the retention policy; archived memos are hidden
+	// from listings until purged and memos under legal hold are never purged
+	Category   string     `json:"category" gorm:"type:varchar(50);not null;default:general;index"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty" gorm:"index"`
+	LegalHold  bool       `json:"legalHold,omitempty" gorm:"not null;default:false"`
+
+	// RequiresAck asks every recipient to explicitly acknowledge the memo; unacknowledged
+	// recipients are reminded until they do
+	RequiresAck bool `json:"requiresAck,omitempty" gorm:"not null;default:false;index"`
+
+	// Recall and edits: a recalled memo disappears for recipients who had not read it and
+	// is flagged for those who had; every edit keeps the previous text as a MemoRevision
+	RecalledAt *time.Time `json:"recalledAt,omitempty" gorm:"index"`
+	EditedAt   *time.Time `json:"editedAt,omitempty"`
+	Revision   int        `json:"revision,omitempty" gorm:"not null;default:0"` // Number of edits so far
+
+	// Scheduling: recipients only see the memo between SendAt and VisibleUntil
+	SendAt       *time.Time `json:"sendAt,omitempty" gorm:"index"`       // Release time of a scheduled memo, nil once sent immediately
+	VisibleUntil *time.Time `json:"visibleUntil,omitempty" gorm:"index"` // Hidden from recipients after this time, nil for no limit
+	ScheduledTo  []string   `json:"-" gorm:"serializer:json;type:text"`  // Addresses as entered, resolved on release
+	ScheduledCC  []string   `json:"-" gorm:"serializer:json;type:text"`
+	ExpiredAt    *time.Time `json:"-" gorm:"index"` // When the scheduler handled the end of the visibility window
+
+	// Addressees of direct memos, loaded from the delivery rows frozen at send time
+	Recipients []string `json:"recipients,omitempty" gorm:"-"`
+	CC         []string `json:"cc,omitempty" gorm:"-"`
+
+	// Files attached to the memo; AttachmentIDs links previously uploaded files when sending
+	Attachments   []*Attachment `json:"attachments,omitempty" gorm:"-"`
+	AttachmentIDs []string      `json:"-" gorm:"-"`
+
+	// Per-caller fields, filled in by the store and not persisted on the memo row
+	ReadAt         *time.Time      `json:"readAt,omitempty" gorm:"-"`         // When the calling recipient read the memo
+	AcknowledgedAt *time.Time      `json:"acknowledgedAt,omitempty" gorm:"-"` // When the calling recipient acknowledged the memo
+	Receipts       *ReceiptSummary `json:"receipts,omitempty" gorm:"-"`       // Delivery summary, only for the sender
+}
+
+// Attachment is a file uploaded by a user and linked to a memo when it is sent
+// Uploads not linked to a memo are removed by cleanup after a day
+type Attachment struct {
+	ID          string    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	MemoID      *string   `json:"memoId,omitempty" gorm:"type:varchar(36);index"`
+	Uploader    string    `json:"uploader" gorm:"type:varchar(255);index"`
+	Filename    string    `json:"filename" gorm:"type:varchar(255)"`
+	ContentType string    `json:"contentType" gorm:"type:varchar(100)"` // Sniffed from the content, not taken from the client
+	Size        int64     `json:"size"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime;index"`
+}
+
+// AttachmentUsage is the total size of a user's stored attachments
+// Uploads lock the row so that the quota check and the insert happen together
+type AttachmentUsage struct {
+	Uploader string `gorm:"primaryKey;type:varchar(255)"`
+	Bytes    int64  `gorm:"not null;default:0"`
+}
+
+// RecipientKind tells whether a recipient was addressed directly or copied
+type RecipientKind string
+
+const (
+	RecipientTo RecipientKind = "to" // Addressed directly (also used for broadcasts)
+	RecipientCC RecipientKind = "cc" // Carbon copy
+)
+
+// MemoDelivery tracks delivery and read state of a memo for a single recipient
+// One row is created per recipient when the memo is sent, for direct and broadcast memos alike
+type MemoDelivery struct {
+	MemoID      string        `json:"memoId" gorm:"primaryKey;type:varchar(36)"`
+	Recipient   string        `json:"recipient" gorm:"primaryKey;type:varchar(255);index"`
+	Kind        RecipientKind `json:"kind" gorm:"type:varchar(10);not null;default:to"`
+	DeliveredAt *time.Time    `json:"deliveredAt,omitempty"`
+	ReadAt      *time.Time    `json:"readAt,omitempty"`
+	CreatedAt   time.Time     `json:"createdAt" gorm:"autoCreateTime"`
+
+	// Acknowledgement of memos that require it
+	AcknowledgedAt *time.Time `json:"acknowledgedAt,omitempty"`
+	AckReminders   int        `json:"ackReminders,omitempty" gorm:"not null;default:0"` // Reminders sent so far
+	LastRemindedAt *time.Time `json:"lastRemindedAt,omitempty"`
+}
+
+// ReceiptSummary reports how many recipients have received and read a memo
+type ReceiptSummary struct {
+	Total        int `json:"total"`
+	Delivered    int `json:"delivered"`
+	Read         int `json:"read"`
+	Acknowledged int `json:"acknowledged"` // Always 0 for memos that do not require acknowledgement
+}
+
+// MemoReceipts is the detailed receipt view returned to a memo's sender
+type MemoReceipts struct {
+	Summary    ReceiptSummary  `json:"summary"`
+	Recipients []*MemoDelivery `json:"recipients"`
+}
+
+// MemoRevision is the text of a memo before one of its edits
+// Revision 0 is the memo as originally sent
+type MemoRevision struct {
+	MemoID    string    `json:"memoId" gorm:"primaryKey;type:varchar(36)"`
+	Revision  int       `json:"revision" gorm:"primaryKey"`
+	Subject   string    `json:"subject" gorm:"type:text"`
+	Message   string    `json:"message" gorm:"type:text"`
+	CreatedAt time.Time `json:"createdAt"` // When this text was sent or last edited
+}
+
+// EditMemoRequest corrects the subject or body of a sent memo; omitted fields keep their value
+type EditMemoRequest struct {
+	Subject *string `json:"subject,omitempty"`
+	Message *string `json:"message,omitempty"`
+}
+
+// AckReport is the acknowledgement dashboard of a memo that requires acknowledgement
+// Recipients are listed pending first, so the people to chase are at the top
+type AckReport struct {
+	MemoID       string          `json:"memoId"`
+	Subject      string          `json:"subject"`
+	Total        int             `json:"total"`
+	Acknowledged int             `json:"acknowledged"`
+	Pending      int             `json:"pending"`
+	Recipients   []*MemoDelivery `json:"recipients"`
+}
+
+// ReplyMemoRequest represents the API request payload for replying to a memo
+type ReplyMemoRequest struct {
+	Subject  string `json:"subject"`                    // Defaults to "Re: " and the original subject
+	Message  string `json:"message" binding:"required"` // Reply body content
+	ReplyAll bool   `json:"replyAll"`                   // Reply to every participant instead of just the sender
+	TTLDays  *int   `json:"ttlDays,omitempty"`          // Optional custom TTL (nil = category policy, otherwise 1-365 days)
+
+	AttachmentIDs []string `json:"attachmentIds,omitempty"` // IDs of files uploaded through POST /api/attachments
+}
+
+// Thread is a conversation of a memo and its replies
+// In listings only the latest memo is included; the conversation view includes every memo
+type Thread struct {
+	ID             string    `json:"id"`
+	Subject        string    `json:"subject"`
+	MessageCount   int       `json:"messageCount"`
+	Unread         int       `json:"unread"`
+	LastActivityAt time.Time `json:"lastActivityAt"`
+	Latest         *Memo     `json:"latest,omitempty"`
+	Memos          []*Memo   `json:"memos,omitempty"`
+}
+
+// MemoPage is one page of a memo listing, newest first
+type MemoPage struct {
+	Memos      []*Memo `json:"memos"`
+	NextCursor string  `json:"nextCursor,omitempty"` // Pass as cursor to fetch the next page; empty on the last page
+}
+
+// ThreadPage is one page of a thread listing, most recently active first
+type ThreadPage struct {
+	Threads    []*Thread `json:"threads"`
+	NextCursor string    `json:"nextCursor,omitempty"` // Pass as cursor to fetch the next page; empty on the last page
+}
+
+// UnreadCounter is the number of unread memos of a user
+// It is adjusted as memos are delivered, read, archived, expired and deleted rather than counted on request
+type UnreadCounter struct {
+	Email  string `gorm:"primaryKey;type:varchar(255)"`
+	Unread int64  `gorm:"not null;default:0"`
+}
+
+// SearchResult is a memo matching a search query, with highlighted excerpts
+// Snippet and Subject are HTML-escaped with matches wrapped in <mark> tags
+type SearchResult struct {
+	Memo    *Memo  `json:"memo"`
+	Subject string `json:"subject"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchPage is one page of search results, newest first
+type SearchPage struct {
+	Results    []*SearchResult `json:"results"`
+	NextCursor string          `json:"nextCursor,omitempty"` // Pass as cursor to fetch the next page; empty on the last page
+}
+
+// AddressList is a list of recipient addresses. Each entry is a user email or the
+// name of a distribution list. In JSON it may be given as an array or as a single
+// comma-separated string, so {"to": "bob@example.com"} keeps working.
+type AddressList []string
+
+// UnmarshalJSON accepts either a string or an array of strings
+func (a *AddressList) UnmarshalJSON(data []byte) error {
+	var raw []string
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return err
+		}
+		raw = strings.Split(single, ",")
+	}
+
+	list := make(AddressList, 0, len(raw))
+	for _, addr := range raw {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			list = append(list, addr)
+		}
+	}
+	*a = list
+	return nil
+}
+
+// IsEmailAddress reports whether addr names a user rather than a distribution list
+func IsEmailAddress(addr string) bool {
+	return strings.Contains(addr, "@")
 }
 
 // SendMemoRequest represents the API request payload for creating a new memo
 type SendMemoRequest struct {
-	To          string `json:"to" binding:"required"`      // Recipient email or "broadcast"
-	Subject     string `json:"subject" binding:"required"` // Memo subject line
-	Message     string `json:"message" binding:"required"` // Memo body content
-	IsBroadcast bool   `json:"isBroadcast"`                // Send to all users if true
-	TTLDays     *int   `json:"ttlDays,omitempty"`          // Optional custom TTL (nil = forever, otherwise 1-365 days)
+	To            AddressList `json:"to"`                         // Recipient emails, distribution list names, or "broadcast"
+	CC            AddressList `json:"cc,omitempty"`               // Copied recipient emails or distribution list names
+	Subject       string      `json:"subject" binding:"required"` // Memo subject line
+	Message       string      `json:"message" binding:"required"` // Memo body content
+	IsBroadcast   bool        `json:"isBroadcast"`                // Send to all users if true
+	Audience      *Audience   `json:"audience,omitempty"`         // Send to the users matching these selectors instead of to/cc
+	TTLDays       *int        `json:"ttlDays,omitempty"`          // Optional custom TTL (nil = category policy, otherwise 1-365 days)
+	Category      string      `json:"category,omitempty"`         // Retention category, defaults to "general"
+	RequiresAck   bool        `json:"requiresAck,omitempty"`      // Ask every recipient to acknowledge the memo
+	AttachmentIDs []string    `json:"attachmentIds,omitempty"`    // IDs of files uploaded through POST /api/attachments
+	SendAt        *time.Time  `json:"sendAt,omitempty"`           // Schedule the memo for later instead of sending now
+	VisibleUntil  *time.Time  `json:"visibleUntil,omitempty"`     // Hide the memo from recipients after this time
+}
+
+// MemoTemplate is a reusable memo whose subject and message may contain {{.Name}} placeholders
+// Placeholders are filled from the variables given when sending and, for each recipient,
+// from the directory fields Name, Email, Department and Location
+type MemoTemplate struct {
+	Name        string    `json:"name" gorm:"primaryKey;type:varchar(100)"`
+	Description string    `json:"description,omitempty" gorm:"type:text"`
+	Subject     string    `json:"subject" gorm:"type:text"`
+	Message     string    `json:"message" gorm:"type:text"`
+	Variables   []string  `json:"variables" gorm:"-"` // Placeholder names used by subject and message, in order of first use
+	UpdatedBy   string    `json:"updatedBy" gorm:"type:varchar(255)"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// MemoTemplateRequest is the admin payload for creating or replacing a memo template
+type MemoTemplateRequest struct {
+	Description string `json:"description"`
+	Subject     string `json:"subject" binding:"required"`
+	Message     string `json:"message" binding:"required"`
+}
+
+// SendTemplateRequest sends a memo rendered from a template
+// Addressing and options work like SendMemoRequest; Variables fill the template's placeholders
+type SendTemplateRequest struct {
+	To            AddressList       `json:"to"`
+	CC            AddressList       `json:"cc,omitempty"`
+	IsBroadcast   bool              `json:"isBroadcast"`
+	Audience      *Audience         `json:"audience,omitempty"`
+	Variables     map[string]string `json:"variables,omitempty"` // Placeholder values; they take precedence over directory fields
+	TTLDays       *int              `json:"ttlDays,omitempty"`
+	Category      string            `json:"category,omitempty"`
+	RequiresAck   bool              `json:"requiresAck,omitempty"`
+	AttachmentIDs []string          `json:"attachmentIds,omitempty"`
+	SendAt        *time.Time        `json:"sendAt,omitempty"`
+	VisibleUntil  *time.Time        `json:"visibleUntil,omitempty"`
+}
+
+// UpdateScheduledMemoRequest edits a scheduled memo before it is released
+// Omitted fields keep their current value
+type UpdateScheduledMemoRequest struct {
+	To           *AddressList `json:"to,omitempty"`
+	CC           *AddressList `json:"cc,omitempty"`
+	Subject      *string      `json:"subject,omitempty"`
+	Message      *string      `json:"message,omitempty"`
+	TTLDays      *int         `json:"ttlDays,omitempty"`
+	SendAt       *time.Time   `json:"sendAt,omitempty"`
+	VisibleUntil *time.Time   `json:"visibleUntil,omitempty"`
+}
+
+// RetentionPolicy controls how long memos of a category are kept
+// Memos are archived (hidden from listings) after ArchiveAfterDays and purged after
+// PurgeAfterDays; a legal hold on the policy suspends purging for the whole category
+type RetentionPolicy struct {
+	Category         string    `json:"category" gorm:"primaryKey;type:varchar(50)"`
+	ArchiveAfterDays *int      `json:"archiveAfterDays,omitempty"` // nil to purge without archiving first
+	PurgeAfterDays   int       `json:"purgeAfterDays" gorm:"not null"`
+	LegalHold        bool      `json:"legalHold" gorm:"not null;default:false"`
+	UpdatedBy        string    `json:"updatedBy" gorm:"type:varchar(255)"`
+	UpdatedAt        time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// RetentionPolicyRequest is the admin payload for creating or replacing a retention policy
+type RetentionPolicyRequest struct {
+	ArchiveAfterDays *int `json:"archiveAfterDays,omitempty"`
+	PurgeAfterDays   int  `json:"purgeAfterDays" binding:"required"`
+	LegalHold        bool `json:"legalHold"`
+}
+
+// LegalHoldRequest places or releases a legal hold on a single memo
+type LegalHoldRequest struct {
+	LegalHold bool `json:"legalHold"`
+}
+
+// DeviceToken is a push token of one of a user's devices
+// A token belongs to the user who registered it last, so a handed-over phone stops
+// receiving the previous owner's memos
+type DeviceToken struct {
+	Token     string    `json:"token" gorm:"primaryKey;type:varchar(255)"`
+	Email     string    `json:"email" gorm:"type:varchar(255);index"`
+	Platform  string    `json:"platform,omitempty" gorm:"type:varchar(20)"` // e.g. "android" or "ios", informational only
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+}
+
+// RegisterDeviceRequest registers a device token for push notifications
+type RegisterDeviceRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Platform string `json:"platform,omitempty"`
+}
+
+// MuteKind selects what a push mute matches
+type MuteKind string
+
+const (
+	MuteSender   MuteKind = "sender"   // Memos from a user, by email
+	MuteCategory MuteKind = "category" // Memos of a retention category
+)
+
+// PushMute silences push notifications for memos from a sender or of a category
+// Muted memos are still delivered; only the notification is skipped
+type PushMute struct {
+	Email     string    `json:"-" gorm:"primaryKey;type:varchar(255)"`
+	Kind      MuteKind  `json:"kind" gorm:"primaryKey;type:varchar(20)"`
+	Value     string    `json:"value" gorm:"primaryKey;type:varchar(255)"`
+	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// PushMuteRequest adds or removes a push mute
+type PushMuteRequest struct {
+	Kind  MuteKind `json:"kind" binding:"required"`
+	Value string   `json:"value" binding:"required"`
+}
+
+// PushKind tells what a push outbox entry announces
+type PushKind string
+
+const (
+	PushNewMemo     PushKind = "memo"         // A memo was delivered
+	PushAckReminder PushKind = "ack_reminder" // Recipients have not acknowledged a memo yet
+)
+
+// PushJob is an outbox entry asking for a memo's recipients to be notified
+// It is written in the transaction that delivers the memo, so no notification is
+// lost if the server stops before sending it. Cursor records the last recipient
+// notified, so a retried broadcast resumes instead of notifying everyone twice.
+type PushJob struct {
+	ID            uint64     `gorm:"primaryKey;autoIncrement"`
+	MemoID        string     `gorm:"type:varchar(36);index"`
+	Kind          PushKind   `gorm:"type:varchar(20);not null;default:memo"`
+	Cursor        string     `gorm:"type:varchar(255)"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"index"` // Not picked up before this time; also used as the claim lease
+	ProcessedAt   *time.Time `gorm:"index"` // Set when finished or given up
+	LastError     string     `gorm:"type:text"`
+	CreatedAt     time.Time  `gorm:"autoCreateTime"`
+}
+
+// TableName keeps the outbox table name descriptive
+func (PushJob) TableName() string {
+	return "push_outbox"
+}
+
+// DistributionList is a named group of users, such as a department or project team
+// Lists are expanded into individual recipients when a memo is sent
+type DistributionList struct {
+	Name        string    `json:"name" gorm:"primaryKey;type:varchar(100)"`
+	Description string    `json:"description" gorm:"type:text"`
+	CreatedBy   string    `json:"createdBy" gorm:"type:varchar(255)"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time `json:"updatedAt" gorm:"autoUpdateTime"`
+	Members     []string  `json:"members" gorm:"-"`
+}
+
+// DistributionListMember is a single user's membership of a distribution list
+type DistributionListMember struct {
+	ListName string `gorm:"primaryKey;type:varchar(100)"`
+	Email    string `gorm:"primaryKey;type:varchar(255);index"`
+}
+
+// DistributionListRequest is the admin payload for creating or replacing a distribution list
+type DistributionListRequest struct {
+	Name        string   `json:"name"` // Required on create; ignored on update
+	Description string   `json:"description"`
+	Members     []string `json:"members" binding:"required"`
+}
+
+// AddressType distinguishes users from distribution lists in address lookups
+type AddressType string
+
+const (
+	AddressTypeUser AddressType = "user"
+	AddressTypeList AddressType = "list"
+)
+
+// Address is a single address book entry that can be used in the to or cc of a memo
+type Address struct {
+	Address     string      `json:"address"`
+	Type        AddressType `json:"type"`
+	Description string      `json:"description,omitempty"`
+	MemberCount int         `json:"memberCount,omitempty"`
+}
+
+// Audience selects the recipients of a targeted broadcast by user attributes
+// A user matches when they match every non-empty selector, and a selector matches
+// any of its values, so {"departments": ["hr", "legal"], "locations": ["berlin"]}
+// is HR and legal staff in Berlin
+type Audience struct {
+	Roles       []UserRole `json:"roles,omitempty"`
+	Departments []string   `json:"departments,omitempty"`
+	Locations   []string   `json:"locations,omitempty"`
+}
+
+// IsEmpty reports whether the audience selects nothing, i.e. the broadcast goes to everyone
+func (a *Audience) IsEmpty() bool {
+	return a == nil || (len(a.Roles) == 0 && len(a.Departments) == 0 && len(a.Locations) == 0)
+}
+
+// AudienceValue is an attribute value users can be targeted by, with the number of users having it
+type AudienceValue struct {
+	Value string `json:"value"`
+	Users int    `json:"users"`
+}
+
+// AudienceOptions lists the roles, departments and locations broadcasts can be targeted at
+type AudienceOptions struct {
+	Roles       []AudienceValue `json:"roles"`
+	Departments []AudienceValue `json:"departments"`
+	Locations   []AudienceValue `json:"locations"`
+}
+
+// UserRole controls what a user is allowed to do beyond sending direct memos
+type UserRole string
+
+const (
+	RoleUser        UserRole = "user"        // Can send direct memos and manage their own memos
+	RoleBroadcaster UserRole = "broadcaster" // Can also send broadcasts
+	RoleAdmin       UserRole = "admin"       // Can also delete any memo
+)
+
+// CanBroadcast reports whether the role may send broadcast memos
+func (r UserRole) CanBroadcast() bool {
+	return r == RoleBroadcaster || r == RoleAdmin
 }
 
 // User represents a registered user in the system
 type User struct {
-	Email     string    `json:"email" gorm:"primaryKey;type:varchar(255)"`
-	CreatedAt time.Time `json:"createdAt" gorm:"autoCreateTime"`
+	Email       string    `json:"email" gorm:"primaryKey;type:varchar(255)"`
+	DisplayName string    `json:"displayName,omitempty" gorm:"type:varchar(255);index"` // From the "name" token claim
+	AvatarURL   string    `json:"avatarUrl,omitempty" gorm:"type:varchar(500)"`         // From the "picture" token claim
+	Role        UserRole  `json:"role" gorm:"type:varchar(20);not null;default:user;index"`
+	Department  string    `json:"department,omitempty" gorm:"type:varchar(100);index"` // From the "department" token claim
+	Location    string    `json:"location,omitempty" gorm:"type:varchar(100);index"`   // Office, from the "location" token claim
+	CreatedAt   time.Time `json:"createdAt" gorm:"autoCreateTime"`
+}
+
+// UserPage is one page of the user directory, ordered by email
+type UserPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"nextCursor,omitempty"` // Pass as cursor to fetch the next page; empty on the last page
+}
+
+// UserAttributes are the user record fields synced from token claims at sign-in
+// Empty fields are left unchanged
+type UserAttributes struct {
+	DisplayName string
+	AvatarURL   string
+	Department  string
+	Location    string
 }
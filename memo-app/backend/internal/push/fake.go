@@ -0,0 +1,71 @@
+package push
+
+import (
+	"context"
+	"sync"
+)
+
+// FakeGateway records notifications instead of sending them, for tests and local development
+type FakeGateway struct {
+	mu      sync.Mutex
+	invalid map[string]bool
+	sent    []FakeDelivery
+
+	// Err, if set, is returned by every Send, after recording the first FailAfter tokens
+	// like a gateway whose later requests fail
+	Err       error
+	FailAfter int
+}
+
+// FakeDelivery is one recorded Send call
+type FakeDelivery struct {
+	Message Message
+	Tokens  []string
+}
+
+// NewFakeGateway creates a fake gateway that reports the given tokens as invalid
+func NewFakeGateway(invalidTokens ...string) *FakeGateway {
+	invalid := make(map[string]bool, len(invalidTokens))
+	for _, t := range invalidTokens {
+		invalid[t] = true
+	}
+	return &FakeGateway{invalid: invalid}
+}
+
+// Send records msg for the valid tokens and reports the invalid ones
+func (f *FakeGateway) Send(ctx context.Context, msg Message, tokens []string) (*Result, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.Err != nil {
+		if f.FailAfter <= 0 {
+			return nil, f.Err
+		}
+		return f.record(msg, tokens[:min(f.FailAfter, len(tokens))]), f.Err
+	}
+	return f.record(msg, tokens), nil
+}
+
+// record records msg for the valid tokens and reports the invalid ones
+// Must be called with f.mu held
+func (f *FakeGateway) record(msg Message, tokens []string) *Result {
+	result := &Result{Handled: len(tokens)}
+	valid := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		if f.invalid[t] {
+			result.InvalidTokens = append(result.InvalidTokens, t)
+		} else {
+			valid = append(valid, t)
+		}
+	}
+	result.Sent = len(valid)
+	f.sent = append(f.sent, FakeDelivery{Message: msg, Tokens: valid})
+	return result
+}
+
+// Deliveries returns every recorded Send call in order
+func (f *FakeGateway) Deliveries() []FakeDelivery {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]FakeDelivery(nil), f.sent...)
+}
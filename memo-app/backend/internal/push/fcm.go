@@ -0,0 +1,123 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxTokensPerRequest is the most device tokens sent in one FCM request
+const maxTokensPerRequest = 500
+
+// fcmInvalidTokenErrors are the per-token errors meaning a token will never work again
+var fcmInvalidTokenErrors = map[string]bool{
+	"NotRegistered":       true,
+	"InvalidRegistration": true,
+}
+
+// FCMGateway sends notifications through an FCM-style HTTP endpoint, which takes
+// a multicast request with registration_ids and answers with one result per token
+type FCMGateway struct {
+	endpoint  string
+	serverKey string
+	client    *http.Client
+}
+
+// NewFCMGateway creates a gateway posting to endpoint, authenticated with serverKey
+func NewFCMGateway(endpoint string, serverKey string, client *http.Client) *FCMGateway {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &FCMGateway{endpoint: endpoint, serverKey: serverKey, client: client}
+}
+
+type fcmRequest struct {
+	RegistrationIDs []string          `json:"registration_ids"`
+	Priority        string            `json:"priority"`
+	Notification    fcmNotification   `json:"notification"`
+	Data            map[string]string `json:"data,omitempty"`
+}
+
+type fcmNotification struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+}
+
+type fcmResponse struct {
+	Success int `json:"success"`
+	Failure int `json:"failure"`
+	Results []struct {
+		MessageID string `json:"message_id"`
+		Error     string `json:"error"`
+	} `json:"results"`
+}
+
+// Send delivers msg to tokens, maxTokensPerRequest at a time
+// If a request fails, the result covers the requests sent before it
+func (g *FCMGateway) Send(ctx context.Context, msg Message, tokens []string) (*Result, error) {
+	result := &Result{}
+	for start := 0; start < len(tokens); start += maxTokensPerRequest {
+		end := start + maxTokensPerRequest
+		if end > len(tokens) {
+			end = len(tokens)
+		}
+		if err := g.send(ctx, msg, tokens[start:end], result); err != nil {
+			return result, err
+		}
+		result.Handled = end
+	}
+	return result, nil
+}
+
+// send posts a single multicast request and adds its outcome to result
+func (g *FCMGateway) send(ctx context.Context, msg Message, tokens []string, result *Result) error {
+	body, err := json.Marshal(fcmRequest{
+		RegistrationIDs: tokens,
+		Priority:        "high",
+		Notification:    fcmNotification{Title: msg.Title, Body: msg.Body},
+		Data:            msg.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "key="+g.serverKey)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("fcm: %s: %s", resp.Status, bytes.TrimSpace(detail))
+	}
+
+	var decoded fcmResponse
+	if err := json.NewDecoder(resp.Body).Decode(&decoded); err != nil {
+		return fmt.Errorf("fcm: decoding response: %w", err)
+	}
+	if len(decoded.Results) != len(tokens) {
+		return fmt.Errorf("fcm: got %d results for %d tokens", len(decoded.Results), len(tokens))
+	}
+
+	// Results are in the same order as the tokens
+	for i, r := range decoded.Results {
+		switch {
+		case r.Error == "":
+			result.Sent++
+		case fcmInvalidTokenErrors[r.Error]:
+			result.InvalidTokens = append(result.InvalidTokens, tokens[i])
+		}
+	}
+	return nil
+}
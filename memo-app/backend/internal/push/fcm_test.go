@@ -0,0 +1,186 @@
+package push
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeFCM is an FCM-style endpoint that fails the tokens in errors and accepts the rest
+type fakeFCM struct {
+	mu       sync.Mutex
+	requests []fcmRequest
+	errors   map[string]string
+}
+
+func (f *fakeFCM) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Header.Get("Authorization") != "key=secret" {
+		http.Error(w, "bad key", http.StatusUnauthorized)
+		return
+	}
+	var req fcmRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.requests = append(f.requests, req)
+	f.mu.Unlock()
+
+	var resp fcmResponse
+	for i, token := range req.RegistrationIDs {
+		entry := struct {
+			MessageID string `json:"message_id"`
+			Error     string `json:"error"`
+		}{}
+		if e, ok := f.errors[token]; ok {
+			entry.Error = e
+			resp.Failure++
+		} else {
+			entry.MessageID = fmt.Sprintf("m%d", i)
+			resp.Success++
+		}
+		resp.Results = append(resp.Results, entry)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
+
+func TestFCMGatewaySend(t *testing.T) {
+	fcm := &fakeFCM{errors: map[string]string{
+		"gone":      "NotRegistered",
+		"malformed": "InvalidRegistration",
+		"busy":      "Unavailable",
+	}}
+	server := httptest.NewServer(fcm)
+	defer server.Close()
+
+	gateway := NewFCMGateway(server.URL, "secret", server.Client())
+	msg := Message{Title: "alice@example.com", Body: "Lunch?", Data: map[string]string{"memoId": "m1"}}
+	result, err := gateway.Send(context.Background(), msg, []string{"ok1", "gone", "busy", "malformed", "ok2"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	if result.Sent != 2 {
+		t.Errorf("Sent = %d, want 2", result.Sent)
+	}
+	if got := strings.Join(result.InvalidTokens, ","); got != "gone,malformed" {
+		t.Errorf("InvalidTokens = %s, want gone,malformed; transient errors are not invalid", got)
+	}
+
+	if len(fcm.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(fcm.requests))
+	}
+	req := fcm.requests[0]
+	if req.Notification.Title != msg.Title || req.Notification.Body != msg.Body || req.Data["memoId"] != "m1" {
+		t.Errorf("request = %+v, want the message's title, body and data", req)
+	}
+}
+
+func TestFCMGatewayChunksLargeSends(t *testing.T) {
+	fcm := &fakeFCM{}
+	server := httptest.NewServer(fcm)
+	defer server.Close()
+
+	tokens := make([]string, maxTokensPerRequest*2+1)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("t%d", i)
+	}
+
+	result, err := NewFCMGateway(server.URL, "secret", server.Client()).Send(context.Background(), Message{Title: "hi"}, tokens)
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if result.Sent != len(tokens) {
+		t.Errorf("Sent = %d, want %d", result.Sent, len(tokens))
+	}
+	if len(fcm.requests) != 3 {
+		t.Fatalf("got %d requests, want 3", len(fcm.requests))
+	}
+	for i, want := range []int{maxTokensPerRequest, maxTokensPerRequest, 1} {
+		if got := len(fcm.requests[i].RegistrationIDs); got != want {
+			t.Errorf("request %d has %d tokens, want %d", i, got, want)
+		}
+	}
+}
+
+func TestFCMGatewayHTTPErrorIsRetryable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := NewFCMGateway(server.URL, "secret", server.Client()).Send(context.Background(), Message{}, []string{"t"})
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Send error = %v, want a 503 error", err)
+	}
+}
+
+func TestFCMGatewayReportsProgressOfFailedSend(t *testing.T) {
+	fcm := &fakeFCM{errors: map[string]string{"t0": "NotRegistered"}}
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 2 {
+			http.Error(w, "try later", http.StatusServiceUnavailable)
+			return
+		}
+		fcm.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	tokens := make([]string, maxTokensPerRequest*2+1)
+	for i := range tokens {
+		tokens[i] = fmt.Sprintf("t%d", i)
+	}
+
+	result, err := NewFCMGateway(server.URL, "secret", server.Client()).Send(context.Background(), Message{Title: "hi"}, tokens)
+	if err == nil {
+		t.Fatal("Send succeeded although the second request failed")
+	}
+	if result == nil || result.Handled != maxTokensPerRequest || result.Sent != maxTokensPerRequest-1 {
+		t.Fatalf("result = %+v, want the first request's %d tokens handled", result, maxTokensPerRequest)
+	}
+	if len(result.InvalidTokens) != 1 || result.InvalidTokens[0] != "t0" {
+		t.Errorf("InvalidTokens = %v, want t0 from the first request", result.InvalidTokens)
+	}
+	if requests != 2 {
+		t.Errorf("got %d requests, want none after the failure", requests)
+	}
+}
+
+func TestFCMGatewayRejectedKey(t *testing.T) {
+	server := httptest.NewServer(&fakeFCM{})
+	defer server.Close()
+
+	if _, err := NewFCMGateway(server.URL, "wrong", server.Client()).Send(context.Background(), Message{}, []string{"t"}); err == nil {
+		t.Error("Send succeeded with a rejected server key")
+	}
+}
+
+func TestFakeGateway(t *testing.T) {
+	fake := NewFakeGateway("stale")
+	result, err := fake.Send(context.Background(), Message{Title: "hi"}, []string{"a", "stale", "b"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if result.Sent != 2 || len(result.InvalidTokens) != 1 || result.InvalidTokens[0] != "stale" || result.Handled != 3 {
+		t.Errorf("result = %+v, want 2 sent and stale invalid", result)
+	}
+	deliveries := fake.Deliveries()
+	if len(deliveries) != 1 || strings.Join(deliveries[0].Tokens, ",") != "a,b" {
+		t.Errorf("Deliveries = %+v, want one delivery to a,b", deliveries)
+	}
+
+	fake.Err, fake.FailAfter = errors.New("unavailable"), 1
+	result, err = fake.Send(context.Background(), Message{Title: "hi"}, []string{"c", "d"})
+	if err == nil || result == nil || result.Handled != 1 {
+		t.Errorf("failing Send = %+v, %v; want c handled and an error", result, err)
+	}
+}
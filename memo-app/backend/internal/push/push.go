@@ -0,0 +1,27 @@
+package push
+
+import "context"
+
+// Message is a notification shown on a user's devices
+type Message struct {
+	Title string
+	Body  string
+	Data  map[string]string // Passed to the app, e.g. the memo ID to open
+}
+
+// Result reports the outcome of a send
+type Result struct {
+	Sent          int      // Tokens the gateway accepted
+	InvalidTokens []string // Tokens the gateway reported as unregistered or malformed; they should be removed
+	Handled       int      // Leading tokens the gateway has handled, all of them unless the send failed part way
+}
+
+// Gateway delivers notifications to device tokens
+// Implementations must be safe for concurrent use
+type Gateway interface {
+	// Send delivers msg to every token. An error means the send failed and may be retried;
+	// per-token failures are reported in the result instead. A gateway that sends in
+	// several requests also returns the result of those that went out before the failure,
+	// so that a retry can skip the first Handled tokens.
+	Send(ctx context.Context, msg Message, tokens []string) (*Result, error)
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often buckets that have refilled completely are dropped
+const sweepInterval = time.Minute
+
+// MemoryLimiter is a Limiter that keeps buckets in process memory
+// Counts are per instance; use RedisLimiter when running several
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucketState
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// bucketState is the fill of one bucket at a point in time
+type bucketState struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled completely
+}
+
+// NewMemoryLimiter creates an empty in-process limiter
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{buckets: make(map[string]*bucketState), now: time.Now}
+}
+
+// Take removes each bucket's cost from it, or nothing if one of them holds too few tokens
+func (ml *MemoryLimiter) Take(ctx context.Context, buckets ...Bucket) (time.Duration, error) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+
+	now := ml.now()
+	ml.sweep(now)
+
+	tokens := make([]float64, len(buckets))
+	var wait time.Duration
+	for i, b := range buckets {
+		if b.Quota.Unlimited() {
+			continue
+		}
+		tokens[i] = float64(b.Quota.Burst)
+		if state, ok := ml.buckets[b.Key]; ok {
+			tokens[i] = refill(state.tokens, now.Sub(state.updated), b.Quota)
+		}
+		if w := waitFor(tokens[i], b.need(), b.Quota); w > wait {
+			wait = w
+		}
+	}
+	if wait > 0 {
+		return wait, nil
+	}
+
+	for i, b := range buckets {
+		if b.Quota.Unlimited() {
+			continue
+		}
+		left := tokens[i] - float64(b.cost())
+		ml.buckets[b.Key] = &bucketState{
+			tokens:  left,
+			updated: now,
+			full:    now.Add(time.Duration((float64(b.Quota.Burst) - left) * float64(b.Quota.Period) / float64(b.Quota.Burst))),
+		}
+	}
+	return 0, nil
+}
+
+// sweep drops buckets that are full again, which behave like missing ones
+// Must be called with ml.mu held
+func (ml *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(ml.lastSweep) < sweepInterval {
+		return
+	}
+	ml.lastSweep = now
+	for key, state := range ml.buckets {
+		if !now.Before(state.full) {
+			delete(ml.buckets, key)
+		}
+	}
+}
+
+// refill returns the tokens of a bucket that held tokens elapsed ago
+func refill(tokens float64, elapsed time.Duration, q Quota) float64 {
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	return math.Min(float64(q.Burst), tokens+float64(elapsed)*float64(q.Burst)/float64(q.Period))
+}
+
+// waitFor returns how long a bucket holding tokens needs to hold need of them
+func waitFor(tokens float64, need float64, q Quota) time.Duration {
+	if tokens >= need {
+		return 0
+	}
+	return time.Duration(math.Ceil((need - tokens) * float64(q.Period) / float64(q.Burst)))
+}
@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Quota is a token bucket: it holds up to Burst tokens and refills evenly,
+// from empty to full, over Period. A zero quota is unlimited
+type Quota struct {
+	Burst  int
+	Period time.Duration
+}
+
+// Unlimited reports whether the quota imposes no limit
+func (q Quota) Unlimited() bool {
+	return q.Burst <= 0 || q.Period <= 0
+}
+
+// String formats the quota the way ParseQuota reads it
+func (q Quota) String() string {
+	if q.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", q.Burst, q.Period)
+}
+
+// ParseQuota reads a quota written as burst/period, e.g. "60/1h" for up to 60 sends
+// at once refilling at one a minute; "off" or "0" disables the limit
+func ParseQuota(s string) (Quota, error) {
+	s = strings.TrimSpace(s)
+	if s == "off" || s == "0" {
+		return Quota{}, nil
+	}
+	burst, period, ok := strings.Cut(s, "/")
+	if !ok {
+		return Quota{}, fmt.Errorf("quota %q: want burst/period, e.g. 60/1h", s)
+	}
+	b, err := strconv.Atoi(burst)
+	if err != nil || b < 1 {
+		return Quota{}, fmt.Errorf("quota %q: burst must be a positive number", s)
+	}
+	p, err := time.ParseDuration(period)
+	if err != nil || p < time.Millisecond {
+		return Quota{}, fmt.Errorf("quota %q: period must be a duration such as 10m or 1h", s)
+	}
+	return Quota{Burst: b, Period: p}, nil
+}
+
+// Bucket is one token bucket to take from: Key identifies it across requests and instances
+type Bucket struct {
+	Key   string
+	Quota Quota
+	Cost  int // Tokens to take, 1 if zero
+}
+
+// need returns the tokens b must hold before its cost is taken
+// A cost above the burst is taken from a full bucket and leaves it in debt
+func (b Bucket) need() float64 {
+	return math.Min(float64(b.cost()), float64(b.Quota.Burst))
+}
+
+// cost returns the tokens taken from b
+func (b Bucket) cost() int {
+	if b.Cost < 1 {
+		return 1
+	}
+	return b.Cost
+}
+
+// Limiter keeps token buckets
+// Implementations must be safe for concurrent use
+type Limiter interface {
+	// Take removes each bucket's cost from it, or nothing from any if one of them holds
+	// too few tokens. It returns zero when the tokens were taken, and otherwise how long
+	// to wait until every bucket holds enough. Unlimited buckets are ignored.
+	Take(ctx context.Context, buckets ...Bucket) (time.Duration, error)
+}
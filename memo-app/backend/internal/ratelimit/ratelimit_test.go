@@ -0,0 +1,191 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// clock is a settable time source shared by a test and its limiters
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time          { return c.t }
+func (c *clock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+// limiters returns a fresh instance of every Limiter implementation driven by clk
+func limiters(t *testing.T, clk *clock) map[string]Limiter {
+	memory := NewMemoryLimiter()
+	memory.now = clk.now
+
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	t.Cleanup(func() { client.Close() })
+	shared := NewRedisLimiter(client, "test:")
+	shared.now = clk.now
+
+	return map[string]Limiter{"memory": memory, "redis": shared}
+}
+
+func take(t *testing.T, l Limiter, buckets ...Bucket) time.Duration {
+	t.Helper()
+	wait, err := l.Take(context.Background(), buckets...)
+	if err != nil {
+		t.Fatalf("Take: %v", err)
+	}
+	return wait
+}
+
+func TestBucketAllowsBurstThenRefills(t *testing.T) {
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	for name, l := range limiters(t, clk) {
+		t.Run(name, func(t *testing.T) {
+			b := Bucket{Key: name + ":alice", Quota: Quota{Burst: 3, Period: 3 * time.Minute}}
+			for i := 0; i < 3; i++ {
+				if wait := take(t, l, b); wait != 0 {
+					t.Fatalf("take %d: wait %v within the burst", i+1, wait)
+				}
+			}
+			if wait := take(t, l, b); wait != time.Minute {
+				t.Fatalf("wait %v on an empty bucket, want 1m", wait)
+			}
+
+			clk.advance(30 * time.Second)
+			if wait := take(t, l, b); wait != 30*time.Second {
+				t.Fatalf("wait %v half way to a token, want 30s", wait)
+			}
+			clk.advance(30 * time.Second)
+			if wait := take(t, l, b); wait != 0 {
+				t.Fatalf("wait %v after a token refilled", wait)
+			}
+
+			// Other keys have buckets of their own
+			if wait := take(t, l, Bucket{Key: name + ":bob", Quota: b.Quota}); wait != 0 {
+				t.Fatalf("bob waits %v for alice's bucket", wait)
+			}
+		})
+	}
+}
+
+func TestTakeIsAllOrNothing(t *testing.T) {
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	for name, l := range limiters(t, clk) {
+		t.Run(name, func(t *testing.T) {
+			send := Bucket{Key: name + ":send", Quota: Quota{Burst: 5, Period: time.Hour}}
+			broadcast := Bucket{Key: name + ":broadcast", Quota: Quota{Burst: 1, Period: time.Hour}}
+
+			if wait := take(t, l, send, broadcast); wait != 0 {
+				t.Fatalf("first broadcast waits %v", wait)
+			}
+			if wait := take(t, l, send, broadcast); wait != time.Hour {
+				t.Fatalf("second broadcast waits %v, want 1h", wait)
+			}
+			// The refused broadcast took nothing from the send bucket: four sends are left
+			for i := 0; i < 4; i++ {
+				if wait := take(t, l, send); wait != 0 {
+					t.Fatalf("send %d waits %v", i+1, wait)
+				}
+			}
+			if wait := take(t, l, send); wait == 0 {
+				t.Fatal("send bucket allowed more than its burst")
+			}
+		})
+	}
+}
+
+func TestUnlimitedBucketsAreIgnored(t *testing.T) {
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	for name, l := range limiters(t, clk) {
+		t.Run(name, func(t *testing.T) {
+			for i := 0; i < 100; i++ {
+				if wait := take(t, l, Bucket{Key: name + ":admin"}); wait != 0 {
+					t.Fatalf("unlimited bucket waits %v", wait)
+				}
+			}
+		})
+	}
+}
+
+func TestTakeChargesCost(t *testing.T) {
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	for name, l := range limiters(t, clk) {
+		t.Run(name, func(t *testing.T) {
+			quota := Quota{Burst: 10, Period: 10 * time.Minute}
+			if wait := take(t, l, Bucket{Key: name + ":alice", Quota: quota, Cost: 8}); wait != 0 {
+				t.Fatalf("taking 8 of 10 tokens waits %v", wait)
+			}
+			if wait := take(t, l, Bucket{Key: name + ":alice", Quota: quota, Cost: 3}); wait != time.Minute {
+				t.Fatalf("taking 3 of 2 tokens waits %v, want 1m", wait)
+			}
+
+			// A cost above the burst is taken from a full bucket, which then has to refill from the debt
+			if wait := take(t, l, Bucket{Key: name + ":bob", Quota: quota, Cost: 15}); wait != 0 {
+				t.Fatalf("taking 15 from a full bucket of 10 waits %v", wait)
+			}
+			if wait := take(t, l, Bucket{Key: name + ":bob", Quota: quota}); wait != 6*time.Minute {
+				t.Fatalf("taking 1 from a bucket 5 in debt waits %v, want 6m", wait)
+			}
+		})
+	}
+}
+
+func TestRedisBucketsAreSharedAndExpire(t *testing.T) {
+	server := miniredis.RunT(t)
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	instance := func() *RedisLimiter {
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		l := NewRedisLimiter(client, "test:")
+		l.now = clk.now
+		return l
+	}
+	first, second := instance(), instance()
+
+	b := Bucket{Key: "alice", Quota: Quota{Burst: 1, Period: time.Minute}}
+	if wait := take(t, first, b); wait != 0 {
+		t.Fatalf("first instance waits %v", wait)
+	}
+	if wait := take(t, second, b); wait != time.Minute {
+		t.Fatalf("second instance waits %v, want the shared bucket's 1m", wait)
+	}
+	if ttl := server.TTL("test:ratelimit:alice"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("bucket TTL %v, want at most the refill period", ttl)
+	}
+}
+
+func TestMemoryLimiterDropsRefilledBuckets(t *testing.T) {
+	clk := &clock{t: time.Unix(1700000000, 0)}
+	l := NewMemoryLimiter()
+	l.now = clk.now
+	take(t, l, Bucket{Key: "alice", Quota: Quota{Burst: 2, Period: time.Minute}})
+
+	clk.advance(2 * sweepInterval)
+	take(t, l, Bucket{Key: "bob", Quota: Quota{Burst: 2, Period: time.Hour}})
+	if _, ok := l.buckets["alice"]; ok {
+		t.Fatal("refilled bucket was kept")
+	}
+	if _, ok := l.buckets["bob"]; !ok {
+		t.Fatal("bucket in use was dropped")
+	}
+}
+
+func TestParseQuota(t *testing.T) {
+	valid := map[string]Quota{
+		"60/1h":  {Burst: 60, Period: time.Hour},
+		" 5/10m": {Burst: 5, Period: 10 * time.Minute},
+		"off":    {},
+		"0":      {},
+	}
+	for s, want := range valid {
+		got, err := ParseQuota(s)
+		if err != nil || got != want {
+			t.Errorf("ParseQuota(%q) = %v, %v; want %v", s, got, err, want)
+		}
+	}
+	for _, s := range []string{"", "60", "0/1h", "-1/1h", "60/hour", "60/0s", "x/1h"} {
+		if _, err := ParseQuota(s); err == nil {
+			t.Errorf("ParseQuota(%q) accepted an invalid quota", s)
+		}
+	}
+}
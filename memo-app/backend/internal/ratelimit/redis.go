@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript takes the cost of every bucket in KEYS, or nothing from any of them, atomically
+// ARGV is the current time in milliseconds followed by the burst, period in milliseconds,
+// cost and tokens needed before taking the cost of each bucket. It returns 0 when the
+// tokens were taken, and otherwise the milliseconds until every bucket holds enough.
+var takeScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local tokens = {}
+local wait = 0
+for i, key in ipairs(KEYS) do
+  local burst = tonumber(ARGV[i * 4 - 2])
+  local period = tonumber(ARGV[i * 4 - 1])
+  local need = tonumber(ARGV[i * 4 + 1])
+  local state = redis.call('HMGET', key, 'tokens', 'updated')
+  local t = tonumber(state[1])
+  local updated = tonumber(state[2])
+  if t == nil or updated == nil then
+    t = burst
+  else
+    t = math.min(burst, t + math.max(0, now - updated) * burst / period)
+  end
+  tokens[i] = t
+  if t < need then
+    wait = math.max(wait, math.ceil((need - t) * period / burst))
+  end
+end
+if wait > 0 then
+  return wait
+end
+for i, key in ipairs(KEYS) do
+  local burst = tonumber(ARGV[i * 4 - 2])
+  local period = tonumber(ARGV[i * 4 - 1])
+  local left = tokens[i] - tonumber(ARGV[i * 4])
+  redis.call('HSET', key, 'tokens', tostring(left), 'updated', tostring(now))
+  redis.call('PEXPIRE', key, math.ceil((burst - left) * period / burst))
+end
+return 0
+`)
+
+// RedisLimiter is a Limiter whose buckets live on a Redis-protocol server, so that
+// every instance shares them. Buckets expire once they would have refilled completely.
+type RedisLimiter struct {
+	client *redis.Client
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedisLimiter keeps buckets on the server behind client, under keys starting with prefix
+func NewRedisLimiter(client *redis.Client, prefix string) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: prefix + "ratelimit:", now: time.Now}
+}
+
+// Take removes each bucket's cost from it, or nothing if one of them holds too few tokens
+func (rl *RedisLimiter) Take(ctx context.Context, buckets ...Bucket) (time.Duration, error) {
+	keys := make([]string, 0, len(buckets))
+	args := []interface{}{rl.now().UnixMilli()}
+	for _, b := range buckets {
+		if b.Quota.Unlimited() {
+			continue
+		}
+		keys = append(keys, rl.prefix+b.Key)
+		args = append(args, b.Quota.Burst, strconv.FormatInt(b.Quota.Period.Milliseconds(), 10), b.cost(),
+			strconv.FormatFloat(b.need(), 'f', -1, 64))
+	}
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	wait, err := takeScript.Run(ctx, rl.client, keys, args...).Int64()
+	if err != nil {
+		return 0, err
+	}
+	return time.Duration(wait) * time.Millisecond, nil
+}
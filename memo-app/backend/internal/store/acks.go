@@ -0,0 +1,175 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+)
+
+// ErrAckNotRequired is returned when acknowledging, or reporting on, a memo that does not require acknowledgement
+var ErrAckNotRequired = errors.New("memo does not require acknowledgement")
+
+// unacknowledgedSQL selects deliveries that are due for an acknowledgement reminder
+// Arguments are the reminder limit and the time the last reminder must be older than
+const unacknowledgedSQL = "memo_deliveries.acknowledged_at IS NULL AND memo_deliveries.ack_reminders < ? AND " +
+	"COALESCE(memo_deliveries.last_reminded_at, memo_deliveries.created_at) <= ?"
+
+// Acknowledge records that recipient has read and confirms a memo that requires acknowledgement
+// Acknowledging also marks the memo read; acknowledging twice keeps the first time
+func (s *DBStore) Acknowledge(id string, recipient string) (*models.MemoDelivery, error) {
+	memo, ok := s.Get(id)
+	if !ok {
+		return nil, ErrMemoNotFound
+	}
+	if !memo.RequiresAck {
+		return nil, ErrAckNotRequired
+	}
+	if memo.RecalledAt != nil {
+		return nil, ErrRecalled
+	}
+	if err := s.UpdateStatus(id, recipient, models.StatusRead); err != nil {
+		return nil, err
+	}
+
+	if err := s.db.Model(&models.MemoDelivery{}).Where("memo_id = ? AND recipient = ?", id, recipient).
+		Update("acknowledged_at", gorm.Expr("COALESCE(acknowledged_at, ?)", time.Now())).Error; err != nil {
+		return nil, err
+	}
+	var delivery models.MemoDelivery
+	if err := s.db.Where("memo_id = ? AND recipient = ?", id, recipient).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+
+	s.cache.InvalidateUserMemos(memo.From)
+	s.cache.InvalidateUserMemos(recipient)
+
+	// Let the sender's dashboard update live
+	s.events.Publish(events.Event{
+		Type: events.MemoAcknowledged,
+		Data: map[string]interface{}{
+			"id":             memo.ID,
+			"recipient":      recipient,
+			"acknowledgedAt": delivery.AcknowledgedAt,
+		},
+		Recipients: []string{memo.From, recipient},
+	})
+	return &delivery, nil
+}
+
+// GetAckReport lists who has and has not acknowledged a memo
+// Only the sender of the memo or an admin can see it
+func (s *DBStore) GetAckReport(id string, actor *models.User) (*models.AckReport, error) {
+	memo, ok := s.Get(id)
+	if !ok {
+		return nil, ErrMemoNotFound
+	}
+	if actor == nil || (memo.From != actor.Email && actor.Role != models.RoleAdmin) {
+		return nil, ErrForbidden
+	}
+	if !memo.RequiresAck {
+		return nil, ErrAckNotRequired
+	}
+
+	var deliveries []*models.MemoDelivery
+	if err := s.db.Where("memo_id = ?", id).
+		Order("acknowledged_at IS NOT NULL, acknowledged_at, recipient").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+
+	report := &models.AckReport{MemoID: memo.ID, Subject: memo.Subject, Total: len(deliveries), Recipients: deliveries}
+	for _, d := range deliveries {
+		if d.AcknowledgedAt != nil {
+			report.Acknowledged++
+		}
+	}
+	report.Pending = report.Total - report.Acknowledged
+	return report, nil
+}
+
+// StartAckReminders periodically reminds recipients who have not acknowledged
+// a memo yet, until the context is cancelled
+func (s *DBStore) StartAckReminders(ctx context.Context) {
+	ticker := time.NewTicker(config.AckReminderCheckMinutes * time.Minute)
+	defer ticker.Stop()
+
+	log.Printf("Acknowledgement reminders started - checking every %d minutes", config.AckReminderCheckMinutes)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Acknowledgement reminders stopped")
+			return
+		case now := <-ticker.C:
+			s.remindUnacknowledged(now)
+		}
+	}
+}
+
+// remindUnacknowledged reminds the recipients of visible memos requiring acknowledgement
+// whose last reminder, or delivery, is older than AckReminderIntervalHours.
+// Recipients are reminded at most AckMaxReminders times.
+func (s *DBStore) remindUnacknowledged(now time.Time) {
+	cutoff := now.Add(-config.AckReminderIntervalHours * time.Hour)
+
+	var ids []string
+	if err := s.db.Model(&models.MemoDelivery{}).Distinct("memo_deliveries.memo_id").
+		Joins("JOIN memos ON memos.id = memo_deliveries.memo_id").
+		Where("memos.requires_ack = ?", true).
+		Where(unacknowledgedSQL, config.AckMaxReminders, cutoff).
+		Where("memos.recalled_at IS NULL").
+		Where(notArchivedSQL).
+		Where(recipientVisibleSQL, now).
+		Limit(config.AckReminderBatchSize).Pluck("memo_deliveries.memo_id", &ids).Error; err != nil {
+		log.Printf("Reminders: failed to find unacknowledged memos: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		recipients, err := s.remind(id, now, cutoff)
+		if err != nil {
+			log.Printf("Reminders: failed to remind recipients of memo %s: %v", id, err)
+			continue
+		}
+		if len(recipients) == 0 {
+			continue
+		}
+
+		s.events.Publish(events.Event{
+			Type:       events.MemoAckReminder,
+			Data:       map[string]interface{}{"id": id},
+			Recipients: recipients,
+		})
+		log.Printf("Reminders: reminded %d recipients to acknowledge memo %s", len(recipients), id)
+	}
+}
+
+// remind records a reminder for the due recipients of a memo and queues their notification
+// The rows are locked so that servers running side by side remind each recipient once
+func (s *DBStore) remind(id string, now, cutoff time.Time) ([]string, error) {
+	var recipients []string
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.MemoDelivery{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("memo_deliveries.memo_id = ?", id).
+			Where(unacknowledgedSQL, config.AckMaxReminders, cutoff).
+			Pluck("recipient", &recipients).Error; err != nil {
+			return err
+		}
+		if len(recipients) == 0 {
+			return nil
+		}
+		if err := tx.Model(&models.MemoDelivery{}).Where("memo_id = ? AND recipient IN ?", id, recipients).
+			Updates(map[string]interface{}{"ack_reminders": gorm.Expr("ack_reminders + 1"), "last_reminded_at": now}).Error; err != nil {
+			return err
+		}
+		return s.enqueuePushJob(tx, id, models.PushAckReminder, now)
+	})
+	return recipients, err
+}
@@ -0,0 +1,87 @@
+package store
+
+import (
+	"testing"
+	"time"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+// reminders returns how often each recipient of a memo has been reminded
+func reminders(t *testing.T, s *DBStore, memoID string) map[string]int {
+	t.Helper()
+	var deliveries []*models.MemoDelivery
+	if err := s.db.Where("memo_id = ?", memoID).Find(&deliveries).Error; err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int, len(deliveries))
+	for _, d := range deliveries {
+		counts[d.Recipient] = d.AckReminders
+	}
+	return counts
+}
+
+func TestRemindUnacknowledgedSelectsDueRecipients(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com", "carol@example.com")
+
+	id := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Policy", Message: "Please confirm", RequiresAck: true},
+		"bob@example.com", "carol@example.com")
+	plain := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "FYI", Message: "No action"}, "carol@example.com")
+	recalled := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Old policy", Message: "Please confirm", RequiresAck: true}, "carol@example.com")
+	alice, err := s.GetUserByEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Recall(recalled, alice); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Acknowledge(id, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	interval := config.AckReminderIntervalHours * time.Hour
+	now := time.Now()
+
+	// Nobody is due before a full interval has passed since delivery
+	s.remindUnacknowledged(now.Add(interval / 2))
+	if got := reminders(t, s, id); got["carol@example.com"] != 0 {
+		t.Fatalf("reminders %v before the interval", got)
+	}
+
+	now = now.Add(interval + time.Minute)
+	s.remindUnacknowledged(now)
+	if got := reminders(t, s, id); got["bob@example.com"] != 0 || got["carol@example.com"] != 1 {
+		t.Fatalf("reminders %v, want only carol reminded", got)
+	}
+	var jobs int64
+	s.db.Model(&models.PushJob{}).Where("memo_id = ? AND kind = ?", id, models.PushAckReminder).Count(&jobs)
+	if jobs != 1 {
+		t.Fatalf("%d reminder push jobs, want 1", jobs)
+	}
+
+	// A second run at the same time, as by another server, reminds nobody again
+	s.remindUnacknowledged(now)
+	if got := reminders(t, s, id); got["carol@example.com"] != 1 {
+		t.Fatalf("reminders %v after a repeated run", got)
+	}
+
+	// Reminders stop after AckMaxReminders
+	for i := 0; i < config.AckMaxReminders+1; i++ {
+		now = now.Add(interval)
+		s.remindUnacknowledged(now)
+	}
+	if got := reminders(t, s, id); got["carol@example.com"] != config.AckMaxReminders {
+		t.Fatalf("reminders %v, want %d for carol", got, config.AckMaxReminders)
+	}
+
+	// Memos not requiring acknowledgement and recalled memos are never reminded about
+	if got := reminders(t, s, plain); got["carol@example.com"] != 0 {
+		t.Errorf("reminded about a memo not requiring acknowledgement: %v", got)
+	}
+	if got := reminders(t, s, recalled); got["carol@example.com"] != 0 {
+		t.Errorf("reminded about a recalled memo: %v", got)
+	}
+}
@@ -0,0 +1,313 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+var (
+	// ErrAttachmentNotFound is returned when an attachment does not exist or is not visible to the caller
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentType is returned when an upload's detected content type is not allowed
+	ErrAttachmentType = errors.New("attachment type not allowed")
+	// ErrAttachmentTooLarge is returned when an upload exceeds the per-file size limit
+	ErrAttachmentTooLarge = errors.New("attachment too large")
+	// ErrAttachmentQuota is returned when an upload would exceed the uploader's storage quota
+	ErrAttachmentQuota = errors.New("attachment storage quota exceeded")
+	// ErrInvalidAttachment is returned when a memo references attachments it cannot use
+	ErrInvalidAttachment = errors.New("invalid attachment")
+)
+
+// SaveAttachment stores an uploaded file for uploader until it is sent with a memo
+// The content type is sniffed from the first bytes and checked against the allowlist;
+// the file must fit both the per-file limit and the uploader's remaining quota
+func (s *DBStore) SaveAttachment(ctx context.Context, uploader string, filename string, r io.Reader) (*models.Attachment, error) {
+	head := make([]byte, config.AttachmentSniffBytes)
+	n, err := io.ReadFull(r, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidAttachment)
+	}
+	head = head[:n]
+
+	contentType := http.DetectContentType(head)
+	if !allowedAttachmentType(contentType) {
+		return nil, fmt.Errorf("%w: %s", ErrAttachmentType, contentType)
+	}
+
+	// Checked again when the upload is recorded; this only bounds how much is read
+	var usage models.AttachmentUsage
+	if err := s.db.Where("uploader = ?", uploader).Limit(1).Find(&usage).Error; err != nil {
+		return nil, err
+	}
+	remaining := int64(config.AttachmentQuotaBytes) - usage.Bytes
+	if remaining <= 0 {
+		return nil, ErrAttachmentQuota
+	}
+	limit := int64(config.MaxAttachmentBytes)
+	if remaining < limit {
+		limit = remaining
+	}
+
+	attachment := &models.Attachment{
+		ID:          uuid.New().String(),
+		Uploader:    uploader,
+		Filename:    sanitizeFilename(filename),
+		ContentType: contentType,
+	}
+
+	// Read one byte past the limit so oversized uploads can be detected
+	counter := &countingReader{r: io.LimitReader(io.MultiReader(bytes.NewReader(head), r), limit+1)}
+	if err := s.blobs.Put(ctx, attachment.ID, counter); err != nil {
+		return nil, err
+	}
+	if counter.n > limit {
+		s.deleteBlob(attachment.ID)
+		if limit < config.MaxAttachmentBytes {
+			return nil, ErrAttachmentQuota
+		}
+		return nil, fmt.Errorf("%w: the limit is %d MiB", ErrAttachmentTooLarge, config.MaxAttachmentBytes>>20)
+	}
+	attachment.Size = counter.n
+
+	if err := s.recordAttachment(attachment); err != nil {
+		s.deleteBlob(attachment.ID)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// recordAttachment inserts a stored upload and adds it to the uploader's usage
+// The usage row is locked while the quota is checked, so concurrent uploads
+// by the same user cannot together exceed it
+func (s *DBStore) recordAttachment(attachment *models.Attachment) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.AttachmentUsage{Uploader: attachment.Uploader}).Error; err != nil {
+			return err
+		}
+		var usage models.AttachmentUsage
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&usage, "uploader = ?", attachment.Uploader).Error; err != nil {
+			return err
+		}
+		if usage.Bytes+attachment.Size > config.AttachmentQuotaBytes {
+			return ErrAttachmentQuota
+		}
+		if err := tx.Create(attachment).Error; err != nil {
+			return err
+		}
+		return tx.Model(&usage).Update("bytes", gorm.Expr("bytes + ?", attachment.Size)).Error
+	})
+}
+
+// OpenAttachment returns an attachment and its contents for user
+// The uploader can always fetch it; once sent, so can the memo's sender and
+// recipients, including everyone a broadcast was delivered to
+func (s *DBStore) OpenAttachment(ctx context.Context, id string, user string) (*models.Attachment, io.ReadCloser, error) {
+	var attachment models.Attachment
+	if err := s.db.First(&attachment, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		return nil, nil, err
+	}
+
+	if attachment.Uploader != user {
+		if attachment.MemoID == nil {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		memo, ok := s.Get(*attachment.MemoID)
+		if !ok {
+			return nil, nil, ErrAttachmentNotFound
+		}
+		if !s.isParticipant(memo, user) {
+			return nil, nil, ErrForbidden
+		}
+	}
+
+	rc, err := s.blobs.Open(ctx, attachment.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &attachment, rc, nil
+}
+
+// linkAttachments attaches the sender's pending uploads in memo.AttachmentIDs to the memo
+// Must be called inside the transaction that creates the memo
+func linkAttachments(tx *gorm.DB, memo *models.Memo) error {
+	ids := uniqueStrings(memo.AttachmentIDs)
+	if len(ids) == 0 {
+		return nil
+	}
+	if len(ids) > config.MaxAttachmentsPerMemo {
+		return fmt.Errorf("%w: at most %d attachments per memo", ErrInvalidAttachment, config.MaxAttachmentsPerMemo)
+	}
+
+	result := tx.Model(&models.Attachment{}).
+		Where("id IN ? AND uploader = ? AND memo_id IS NULL", ids, memo.From).
+		Update("memo_id", memo.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected != int64(len(ids)) {
+		return fmt.Errorf("%w: unknown or already sent attachment", ErrInvalidAttachment)
+	}
+
+	return tx.Where("memo_id = ?", memo.ID).Order("created_at").Find(&memo.Attachments).Error
+}
+
+// attachAttachments fills in Attachments on memos with their file metadata
+func (s *DBStore) attachAttachments(memos []*models.Memo) {
+	if len(memos) == 0 {
+		return
+	}
+	ids := make([]string, 0, len(memos))
+	byMemo := make(map[string][]*models.Memo, len(memos))
+	for _, m := range memos {
+		ids = append(ids, m.ID)
+		byMemo[m.ID] = append(byMemo[m.ID], m)
+		m.Attachments = nil
+	}
+
+	var attachments []*models.Attachment
+	s.db.Where("memo_id IN ?", ids).Order("created_at").Find(&attachments)
+	for _, a := range attachments {
+		for _, m := range byMemo[*a.MemoID] {
+			m.Attachments = append(m.Attachments, a)
+		}
+	}
+}
+
+// deleteAttachmentsWhere removes matching attachment rows and their stored files,
+// releasing their space from the uploaders' usage
+func (s *DBStore) deleteAttachmentsWhere(query interface{}, args ...interface{}) int {
+	var attachments []*models.Attachment
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Locked so that a concurrent delete of the same rows does not release them twice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "uploader", "size").
+			Where(query, args...).Find(&attachments).Error; err != nil || len(attachments) == 0 {
+			return err
+		}
+		ids := make([]string, 0, len(attachments))
+		released := make(map[string]int64)
+		for _, a := range attachments {
+			ids = append(ids, a.ID)
+			released[a.Uploader] += a.Size
+		}
+		if err := tx.Delete(&models.Attachment{}, "id IN ?", ids).Error; err != nil {
+			return err
+		}
+		for uploader, size := range released {
+			if err := tx.Model(&models.AttachmentUsage{}).Where("uploader = ?", uploader).
+				Update("bytes", gorm.Expr("GREATEST(bytes - ?, 0)", size)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error deleting attachments: %v", err)
+		return 0
+	}
+	for _, a := range attachments {
+		s.deleteBlob(a.ID)
+	}
+	return len(attachments)
+}
+
+// cleanupAttachments removes files of deleted memos and uploads that were never sent
+func (s *DBStore) cleanupAttachments(now time.Time) {
+	orphaned := s.deleteAttachmentsWhere("memo_id IS NOT NULL AND memo_id NOT IN (?)", s.db.Model(&models.Memo{}).Select("id"))
+	if orphaned > 0 {
+		log.Printf("Cleaned up %d attachments of deleted memos", orphaned)
+	}
+
+	cutoff := now.Add(-config.UnlinkedAttachmentHours * time.Hour)
+	unlinked := s.deleteAttachmentsWhere("memo_id IS NULL AND created_at < ?", cutoff)
+	if unlinked > 0 {
+		log.Printf("Cleaned up %d attachments never sent with a memo", unlinked)
+	}
+}
+
+// deleteBlob removes a stored file, logging failures
+func (s *DBStore) deleteBlob(id string) {
+	if err := s.blobs.Delete(context.Background(), id); err != nil {
+		log.Printf("Error deleting attachment blob %s: %v", id, err)
+	}
+}
+
+// allowedAttachmentType reports whether a sniffed content type is in the allowlist
+func allowedAttachmentType(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, allowed := range config.AllowedAttachmentTypes {
+		if mediaType == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeFilename keeps only the base name of an uploaded file
+func sanitizeFilename(name string) string {
+	name = strings.TrimSpace(filepath.Base(strings.ReplaceAll(name, "\\", "/")))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		name = "attachment"
+	}
+	if len(name) > 255 {
+		name = name[len(name)-255:]
+	}
+	return name
+}
+
+// uniqueStrings returns values without duplicates, keeping the first occurrence
+func uniqueStrings(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,121 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+// attachmentUsage returns the bytes recorded against uploader's quota
+func attachmentUsage(t *testing.T, s *DBStore, uploader string) int64 {
+	t.Helper()
+	var usage models.AttachmentUsage
+	if err := s.db.Where("uploader = ?", uploader).Limit(1).Find(&usage).Error; err != nil {
+		t.Fatal(err)
+	}
+	return usage.Bytes
+}
+
+func TestSanitizeFilename(t *testing.T) {
+	for name, want := range map[string]string{
+		"report.pdf":             "report.pdf",
+		"../../etc/passwd":       "passwd",
+		`C:\Users\ada\notes.txt`: "notes.txt",
+		" \"quoted\"\r\n.txt ":   "quoted.txt",
+		"":                       "attachment",
+		"dir/":                   "dir",
+		"/":                      "attachment",
+		strings.Repeat("a", 300): strings.Repeat("a", 255),
+	} {
+		if got := sanitizeFilename(name); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestSaveAttachmentChecksTheFile(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	ctx := context.Background()
+
+	attachment, err := s.SaveAttachment(ctx, "alice@example.com", "notes.txt", strings.NewReader("Some notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attachment.ContentType != "text/plain; charset=utf-8" || attachment.Size != 10 {
+		t.Fatalf("attachment = %+v", attachment)
+	}
+
+	if _, err := s.SaveAttachment(ctx, "alice@example.com", "setup.exe", bytes.NewReader(append([]byte("MZ\x90\x00"), make([]byte, 64)...))); !errors.Is(err, ErrAttachmentType) {
+		t.Fatalf("executable upload: %v, want ErrAttachmentType", err)
+	}
+	if _, err := s.SaveAttachment(ctx, "alice@example.com", "empty.txt", strings.NewReader("")); !errors.Is(err, ErrInvalidAttachment) {
+		t.Fatalf("empty upload: %v, want ErrInvalidAttachment", err)
+	}
+	big := strings.NewReader(strings.Repeat("x", config.MaxAttachmentBytes+1))
+	if _, err := s.SaveAttachment(ctx, "alice@example.com", "big.txt", big); !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Fatalf("oversized upload: %v, want ErrAttachmentTooLarge", err)
+	}
+
+	// Only the accepted upload is stored and counted
+	var stored int64
+	s.db.Model(&models.Attachment{}).Where("uploader = ?", "alice@example.com").Count(&stored)
+	if stored != 1 {
+		t.Fatalf("%d attachments stored, want 1", stored)
+	}
+	if got := attachmentUsage(t, s, "alice@example.com"); got != 10 {
+		t.Fatalf("usage %d, want 10", got)
+	}
+}
+
+func TestAttachmentQuotaIsCheckedWhenRecorded(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	ctx := context.Background()
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+	if err := s.db.Create(&models.AttachmentUsage{Uploader: "alice@example.com", Bytes: config.AttachmentQuotaBytes - 1000}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.SaveAttachment(ctx, "alice@example.com", "first.txt", strings.NewReader(strings.Repeat("a", 600)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.SaveAttachment(ctx, "alice@example.com", "second.txt", strings.NewReader(strings.Repeat("b", 600))); !errors.Is(err, ErrAttachmentQuota) {
+		t.Fatalf("upload over the quota: %v, want ErrAttachmentQuota", err)
+	}
+
+	// An upload that passed the early check alongside the first one is refused when recorded
+	racing := &models.Attachment{ID: uuid.New().String(), Uploader: "alice@example.com", Filename: "racing.txt", Size: 600}
+	if err := s.recordAttachment(racing); !errors.Is(err, ErrAttachmentQuota) {
+		t.Fatalf("recording past the quota: %v, want ErrAttachmentQuota", err)
+	}
+	if err := s.db.First(&models.Attachment{}, "id = ?", racing.ID).Error; err == nil {
+		t.Fatal("attachment refused by the quota was stored")
+	}
+	if got := attachmentUsage(t, s, "alice@example.com"); got != config.AttachmentQuotaBytes-400 {
+		t.Fatalf("usage %d, want %d", got, config.AttachmentQuotaBytes-400)
+	}
+
+	// Deleting the memo it was sent with frees the space again
+	id := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Files", Message: "Attached", AttachmentIDs: []string{first.ID}}, "bob@example.com")
+	alice, err := s.GetUserByEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(id, alice); err != nil {
+		t.Fatal(err)
+	}
+	if got := attachmentUsage(t, s, "alice@example.com"); got != config.AttachmentQuotaBytes-1000 {
+		t.Fatalf("usage after delete %d, want %d", got, config.AttachmentQuotaBytes-1000)
+	}
+	if _, err := s.SaveAttachment(ctx, "alice@example.com", "third.txt", strings.NewReader(strings.Repeat("c", 1000))); err != nil {
+		t.Fatalf("upload filling the quota exactly: %v", err)
+	}
+}
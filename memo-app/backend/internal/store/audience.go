@@ -0,0 +1,108 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/models"
+)
+
+// ErrInvalidAudience is returned when a broadcast audience is malformed or matches nobody
+var ErrInvalidAudience = errors.New("invalid audience")
+
+// validateAudience normalizes the audience of memo and checks its roles
+// An empty audience is dropped, making the memo an ordinary broadcast
+func validateAudience(memo *models.Memo) error {
+	if memo.Audience.IsEmpty() {
+		memo.Audience = nil
+		return nil
+	}
+	for _, role := range memo.Audience.Roles {
+		if role != models.RoleUser && role != models.RoleBroadcaster && role != models.RoleAdmin {
+			return fmt.Errorf("%w: unknown role %q", ErrInvalidAudience, role)
+		}
+	}
+	memo.Audience.Departments = trimValues(memo.Audience.Departments)
+	memo.Audience.Locations = trimValues(memo.Audience.Locations)
+	if memo.Audience.IsEmpty() {
+		memo.Audience = nil
+	}
+	memo.IsBroadcast = true
+	return nil
+}
+
+// trimValues trims values and drops empty ones
+func trimValues(values []string) []string {
+	trimmed := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			trimmed = append(trimmed, v)
+		}
+	}
+	return trimmed
+}
+
+// whereAudience restricts a users query to the users matching audience
+// Every selector is an indexed IN condition, so matching stays cheap for large directories
+func whereAudience(q *gorm.DB, audience *models.Audience) *gorm.DB {
+	if audience.IsEmpty() {
+		return q
+	}
+	if len(audience.Roles) > 0 {
+		q = q.Where("role IN ?", audience.Roles)
+	}
+	if len(audience.Departments) > 0 {
+		q = q.Where("department IN ?", audience.Departments)
+	}
+	if len(audience.Locations) > 0 {
+		q = q.Where("location IN ?", audience.Locations)
+	}
+	return q
+}
+
+// UpdateUserAttributes stores the attributes of a user taken from their token claims
+// Empty attributes keep their stored value
+func (s *DBStore) UpdateUserAttributes(email string, attrs models.UserAttributes) error {
+	updates := map[string]interface{}{}
+	if attrs.DisplayName != "" {
+		updates["display_name"] = attrs.DisplayName
+	}
+	if attrs.AvatarURL != "" {
+		updates["avatar_url"] = attrs.AvatarURL
+	}
+	if attrs.Department != "" {
+		updates["department"] = attrs.Department
+	}
+	if attrs.Location != "" {
+		updates["location"] = attrs.Location
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return s.db.Model(&models.User{}).Where("email = ?", email).Updates(updates).Error
+}
+
+// GetAudienceOptions lists the roles, departments and locations users have, with
+// the number of users for each, so senders can pick a broadcast audience
+func (s *DBStore) GetAudienceOptions() (*models.AudienceOptions, error) {
+	options := &models.AudienceOptions{}
+	for column, dest := range map[string]*[]models.AudienceValue{
+		"role":       &options.Roles,
+		"department": &options.Departments,
+		"location":   &options.Locations,
+	} {
+		values := []models.AudienceValue{}
+		if err := s.db.Model(&models.User{}).
+			Select(column + " AS value, COUNT(*) AS users").
+			Where(column + " <> ''").
+			Group(column).Order(column).
+			Scan(&values).Error; err != nil {
+			return nil, err
+		}
+		*dest = values
+	}
+	return options, nil
+}
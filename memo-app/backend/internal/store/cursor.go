@@ -0,0 +1,74 @@
+package store
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/models"
+)
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// cursor marks a position in a list ordered by creation time and ID, newest first
+type cursor struct {
+	CreatedAt time.Time
+	ID        string
+}
+
+// keysetSQL selects memos after a cursor in a list ordered by creation time and ID, newest first
+const keysetSQL = "(memos.created_at < ? OR (memos.created_at = ? AND memos.id < ?))"
+
+// after restricts q to memos after the cursor; a nil cursor is the start of the list
+func (c *cursor) after(q *gorm.DB) *gorm.DB {
+	if c == nil {
+		return q
+	}
+	return q.Where(keysetSQL, c.CreatedAt, c.CreatedAt, c.ID)
+}
+
+// newMemoPage builds a page from up to limit+1 memos; the extra memo only
+// signals that there is a next page
+func newMemoPage(memos []*models.Memo, limit int) *models.MemoPage {
+	page := &models.MemoPage{Memos: memos}
+	if len(memos) > limit {
+		page.Memos = memos[:limit]
+		last := page.Memos[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	if page.Memos == nil {
+		page.Memos = []*models.Memo{}
+	}
+	return page
+}
+
+// encodeCursor returns an opaque token for the position after a memo
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := strconv.FormatInt(createdAt.UnixNano(), 10) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor parses a token from encodeCursor; an empty token is the start of the list
+func decodeCursor(token string) (*cursor, error) {
+	if token == "" {
+		return nil, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	nanos, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return nil, ErrInvalidCursor
+	}
+	n, err := strconv.ParseInt(nanos, 10, 64)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor{CreatedAt: time.Unix(0, n), ID: id}, nil
+}
@@ -2,6 +2,7 @@ package store
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"sync"
@@ -12,21 +13,37 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
 
+	"memo-app/internal/blob"
 	"memo-app/internal/cache"
 	"memo-app/internal/config"
+	"memo-app/internal/events"
 	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+var (
+	// ErrMemoNotFound is returned when a memo does not exist or is not visible to the caller
+	ErrMemoNotFound = errors.New("memo not found")
+	// ErrForbidden is returned when the caller is not allowed to perform an action on a memo
+	ErrForbidden = errors.New("forbidden")
 )
 
 // DBStore implements persistent storage for memos using GORM with MySQL
 type DBStore struct {
-	db    *gorm.DB
-	cache *cache.CacheManager
-	mu    sync.Mutex
+	db     *gorm.DB
+	cache  cache.Cache
+	events *events.Hub
+	blobs  blob.Store
+	push   push.Gateway
+	mu     sync.Mutex
 }
 
 // NewDBStore creates a new database store with the given MySQL DSN
 // DSN format: username:password@tcp(host:port)/dbname?charset=utf8mb4&parseTime=True&loc=Local
-func NewDBStore(dsn string, cache *cache.CacheManager) (*DBStore, error) {
+// Memo changes are published to hub for real-time delivery; attachment files are kept in blobs.
+// New memos are announced to mobile devices through gateway; nil disables push notifications.
+// The schema is not changed here: it must already be migrated with `memo-app migrate up`
+func NewDBStore(dsn string, cache cache.Cache, hub *events.Hub, blobs blob.Store, gateway push.Gateway) (*DBStore, error) {
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 	})
@@ -34,8 +51,8 @@ func NewDBStore(dsn string, cache *cache.CacheManager) (*DBStore, error) {
 		return nil, err
 	}
 
-	// Auto-migrate memo model to create/update table schema
-	if err := db.AutoMigrate(&models.Memo{}, &models.User{}); err != nil {
+	// Refuse to serve a database this build does not know how to use
+	if err := checkSchema(db); err != nil {
 		return nil, err
 	}
 
@@ -46,9 +63,9 @@ func NewDBStore(dsn string, cache *cache.CacheManager) (*DBStore, error) {
 		sqlDB.SetMaxOpenConns(config.MaxOpenConns)
 	}
 
-	s := &DBStore{db: db, cache: cache}
+	s := &DBStore{db: db, cache: cache, events: hub, blobs: blobs, push: gateway}
 
-	log.Println("Database connection established and schema migrated successfully")
+	log.Println("Database connection established and schema is up to date")
 
 	// Background pinger and simple reconnect logic
 	go func(dsn string, store *DBStore) {
@@ -79,8 +96,8 @@ func NewDBStore(dsn string, cache *cache.CacheManager) (*DBStore, error) {
 					log.Printf("dbstore: reconnect open failed: %v", err)
 					continue
 				}
-				if err := newDB.AutoMigrate(&models.Memo{}, &models.User{}); err != nil {
-					log.Printf("dbstore: reconnect migrate failed: %v", err)
+				if err := checkSchema(newDB); err != nil {
+					log.Printf("dbstore: reconnect schema check failed: %v", err)
 					continue
 				}
 				if sqlNew, err := newDB.DB(); err == nil {
@@ -104,49 +121,198 @@ func NewDBStore(dsn string, cache *cache.CacheManager) (*DBStore, error) {
 	return s, nil
 }
 
-// Add creates a new memo in the database
-func (s *DBStore) Add(memo *models.Memo) string {
+// Add creates a new memo in the database addressed to the given to and cc addresses
+// Addresses are user emails or distribution list names; lists are expanded here so
+// the recipient set is frozen at send time. to and cc are ignored for broadcasts.
+// A memo with a future SendAt is scheduled instead and released by StartScheduler.
+// A memo with an Audience is a broadcast to the matching users only.
+// Only senders with a privileged role can send broadcasts
+func (s *DBStore) Add(memo *models.Memo, to []string, cc []string) (string, error) {
+	if err := s.sendAll([]*outgoingMemo{{memo: memo, to: to, cc: cc}}); err != nil {
+		return "", err
+	}
+	return memo.ID, nil
+}
+
+// outgoingMemo is a memo being sent with the addresses it was sent to
+type outgoingMemo struct {
+	memo     *models.Memo
+	to       []string
+	cc       []string
+	resolved *resolvedRecipients // Recipients frozen at send time, nil for a scheduled memo
+}
+
+// sendAll validates every memo and resolves its recipients, then sends or schedules
+// them all in one transaction, so that either every memo goes out or none does
+func (s *DBStore) sendAll(outgoing []*outgoingMemo) error {
+	for _, o := range outgoing {
+		if err := s.prepare(o); err != nil {
+			return err
+		}
+	}
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		for _, o := range outgoing {
+			if err := tx.Create(o.memo).Error; err != nil {
+				return err
+			}
+			if err := linkAttachments(tx, o.memo); err != nil {
+				return err
+			}
+			if o.resolved == nil {
+				continue
+			}
+			if err := dispatch(tx, o.memo, o.resolved); err != nil {
+				return err
+			}
+			if err := s.enqueuePush(tx, o.memo); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error creating memo: %v", err)
+		return err
+	}
+
+	for _, o := range outgoing {
+		if o.resolved == nil {
+			s.cache.SetMemo(o.memo)
+			s.cache.InvalidateUserMemos(o.memo.From)
+			log.Printf("Memo %s scheduled for %s", o.memo.ID, o.memo.SendAt.Format(time.RFC3339))
+			continue
+		}
+		s.announce(o.memo, o.resolved.all())
+	}
+	return nil
+}
+
+// prepare validates an outgoing memo and fills in its ID, time and status
+// A memo sent now has its recipients resolved; a scheduled one only has its addresses checked
+func (s *DBStore) prepare(o *outgoingMemo) error {
+	memo := o.memo
+	if err := validateAudience(memo); err != nil {
+		return err
+	}
+	if memo.IsBroadcast {
+		sender, err := s.GetUserByEmail(memo.From)
+		if err != nil || !sender.Role.CanBroadcast() {
+			return ErrForbidden
+		}
+	}
+
+	if err := s.validateCategory(memo); err != nil {
+		return err
+	}
+
 	if memo.ID == "" {
 		memo.ID = uuid.New().String()
 	}
-	memo.Status = models.StatusSent
+	if memo.ThreadID == "" {
+		memo.ThreadID = memo.ID
+	}
 	memo.CreatedAt = time.Now()
 
-	if err := s.db.Create(memo).Error; err != nil {
-		log.Printf("Error creating memo: %v", err)
-		return ""
+	if err := validateSchedule(memo.SendAt, memo.VisibleUntil, memo.CreatedAt); err != nil {
+		return err
 	}
+	if memo.SendAt != nil && memo.SendAt.After(memo.CreatedAt) {
+		return s.prepareSchedule(memo, o.to, o.cc)
+	}
+	memo.SendAt = nil
+	memo.Status = models.StatusSent
 
-	// Add recipient to users table if not broadcast (async)
-	// Note: Sender already exists because they passed through authentication middleware
-	if !memo.IsBroadcast {
-		go func() {
-			result := s.db.FirstOrCreate(&models.User{Email: memo.To})
-			if result.RowsAffected > 0 {
-				// New user was created, invalidate cache
-				s.cache.InvalidateUserList()
-			}
-		}()
+	// Freeze the recipient set at send time
+	resolved, err := s.resolveMemoRecipients(memo, o.to, o.cc)
+	if err != nil {
+		return err
+	}
+	o.resolved = resolved
+	return nil
+}
+
+// resolveMemoRecipients resolves the recipients of memo and fills in its To,
+// Recipients and CC; broadcasts go to every known user but the sender,
+// or to those matching the memo's audience
+func (s *DBStore) resolveMemoRecipients(memo *models.Memo, to []string, cc []string) (*resolvedRecipients, error) {
+	resolved := &resolvedRecipients{}
+	if memo.IsBroadcast {
+		memo.To = config.BroadcastRecipient
+		q := whereAudience(s.db.Model(&models.User{}).Where("email <> ?", memo.From), memo.Audience)
+		if err := q.Pluck("email", &resolved.to).Error; err != nil {
+			log.Printf("Error resolving broadcast recipients: %v", err)
+			return nil, err
+		}
+		if len(resolved.to) == 0 && !memo.Audience.IsEmpty() {
+			return nil, fmt.Errorf("%w: no users match the audience", ErrInvalidAudience)
+		}
+		return resolved, nil
+	}
+
+	resolved, err := s.resolveRecipients(memo.From, to, cc)
+	if err != nil {
+		return nil, err
 	}
+	recipients := resolved.all()
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+	}
+	memo.To = recipients[0]
+	memo.Recipients = resolved.to
+	memo.CC = resolved.cc
+	return resolved, nil
+}
+
+// dispatch creates the delivery rows that make memo visible to its recipients
+// Recipients are known users: resolveRecipients rejects any other address
+func dispatch(tx *gorm.DB, memo *models.Memo, resolved *resolvedRecipients) error {
+	if err := createDeliveries(tx, memo.ID, resolved.to, models.RecipientTo); err != nil {
+		return err
+	}
+	if err := createDeliveries(tx, memo.ID, resolved.cc, models.RecipientCC); err != nil {
+		return err
+	}
+	return incrementUnread(tx, resolved.all())
+}
 
+// announce refreshes caches and pushes a newly delivered memo to its recipients
+func (s *DBStore) announce(memo *models.Memo, recipients []string) {
 	// Cache the memo
 	s.cache.SetMemo(memo)
 
 	// Invalidate sender's sent memo cache
 	s.cache.InvalidateUserMemos(memo.From)
 
-	// Invalidate recipient's received memo cache (or all if broadcast)
-	if memo.IsBroadcast {
+	// Invalidate recipients' received memo caches (or all if broadcast to everyone)
+	if memo.IsBroadcast && memo.Audience.IsEmpty() {
 		s.cache.InvalidateBroadcastMemos()
 	} else {
-		s.cache.InvalidateUserMemos(memo.To)
+		for _, r := range recipients {
+			s.cache.InvalidateUserMemos(r)
+		}
 	}
 
-	return memo.ID
+	// Push the new memo to connected recipients
+	s.events.Publish(events.Event{
+		Type:       events.MemoCreated,
+		Data:       memo,
+		Recipients: recipients,
+	})
 }
 
 // Get retrieves a memo by its ID
+// Archived memos are not found, as they are hidden until purged
 func (s *DBStore) Get(id string) (*models.Memo, bool) {
+	memo, ok := s.getMemo(id)
+	if !ok || memo.ArchivedAt != nil {
+		return nil, false
+	}
+	return memo, true
+}
+
+// getMemo retrieves a memo by its ID, including archived memos
+func (s *DBStore) getMemo(id string) (*models.Memo, bool) {
 	// Try cache first
 	if memo, ok := s.cache.GetMemo(id); ok {
 		return memo, true
@@ -157,6 +323,8 @@ func (s *DBStore) Get(id string) (*models.Memo, bool) {
 	if err := s.db.First(&memo, "id = ?", id).Error; err != nil {
 		return nil, false
 	}
+	s.attachAddressees([]*models.Memo{&memo})
+	s.attachAttachments([]*models.Memo{&memo})
 
 	// Cache for future requests
 	s.cache.SetMemo(&memo)
@@ -164,119 +332,236 @@ func (s *DBStore) Get(id string) (*models.Memo, bool) {
 	return &memo, true
 }
 
-// GetActiveUsers retrieves a list of all unique users
-func (s *DBStore) GetActiveUsers() []string {
-	// Try cache first
-	if users, ok := s.cache.GetUserList(); ok {
-		return users
+// GetSentMemos retrieves a page of memos sent by a specific user, newest first
+// Pass the previous page's NextCursor as cursorToken to continue; only first pages are cached
+func (s *DBStore) GetSentMemos(userEmail string, cursorToken string, limit int) (*models.MemoPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
 	}
 
-	// Fetch from database
-	var users []string
-	s.db.Model(&models.User{}).Pluck("email", &users)
-
-	// Cache the result
-	s.cache.SetUserList(users)
-
-	return users
-}
-
-// GetSentMemos retrieves all memos sent by a specific user with pagination
-func (s *DBStore) GetSentMemos(userEmail string, limit int, offset int) []*models.Memo {
-	// Generate cache key
-	cacheKey := fmt.Sprintf("sent:%s:%d:%d", userEmail, limit, offset)
-
-	// Try cache first
-	if memos, ok := s.cache.GetMemoList(cacheKey); ok {
-		return memos
+	// Try cache first; the namespace is taken before the query so a concurrent invalidation wins
+	cacheKey := fmt.Sprintf("sent:%d", limit)
+	namespace, cacheable := s.cache.Namespace(userEmail)
+	cacheable = cacheable && cur == nil
+	if cacheable {
+		if memos, ok := s.cache.GetMemoList(namespace, cacheKey); ok {
+			return newMemoPage(memos, limit), nil
+		}
 	}
 
-	// Fetch from database
+	// Fetch from database, one extra row to tell whether there is a next page
 	var memos []*models.Memo
-	s.db.Order("created_at desc").Where("`from` = ?", userEmail).Limit(limit).Offset(offset).Find(&memos)
+	q := s.db.Model(&models.Memo{}).Where("memos.`from` = ?", userEmail).Where(notArchivedSQL)
+	if err := cur.after(q).Order("memos.created_at desc, memos.id desc").Limit(limit + 1).Find(&memos).Error; err != nil {
+		return nil, err
+	}
+
+	// Attach addressees and read receipt summaries for the sender
+	s.attachAddressees(memos)
+	s.attachAttachments(memos)
+	s.attachReceiptSummaries(memos)
 
 	// Cache the result
-	s.cache.SetMemoList(cacheKey, memos)
+	if cacheable {
+		s.cache.SetMemoList(namespace, cacheKey, memos)
+	}
 
-	return memos
+	return newMemoPage(memos, limit), nil
 }
 
-// GetReceivedMemos retrieves all memos received by a user with pagination
-// Includes both direct messages (status=sent) and all broadcast messages
-func (s *DBStore) GetReceivedMemos(userEmail string, limit int, offset int) []*models.Memo {
-	// Generate cache key
-	cacheKey := fmt.Sprintf("received:%s:%d:%d", userEmail, limit, offset)
+// GetReceivedMemos retrieves a page of memos received by a user, newest first
+// Includes both direct and broadcast messages; status, deliveredAt and readAt
+// reflect the caller's own delivery state. Only first pages are cached.
+func (s *DBStore) GetReceivedMemos(userEmail string, cursorToken string, limit int) (*models.MemoPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Try cache first; the namespace is taken before the query so a concurrent invalidation wins
+	cacheKey := fmt.Sprintf("received:%d", limit)
+	namespace, cacheable := s.cache.Namespace(userEmail)
+	cacheable = cacheable && cur == nil
+	if cacheable {
+		if memos, ok := s.cache.GetMemoList(namespace, cacheKey); ok {
+			return newMemoPage(memos, limit), nil
+		}
+	}
 
-	// Try cache first
-	if memos, ok := s.cache.GetMemoList(cacheKey); ok {
-		return memos
+	// Fetch from database, one extra row to tell whether there is a next page
+	var rows []receivedRow
+	q := s.db.Model(&models.Memo{}).
+		Select("memos.*, d.delivered_at AS recipient_delivered_at, d.read_at AS recipient_read_at, "+
+			"d.acknowledged_at AS recipient_acknowledged_at").
+		Joins("JOIN memo_deliveries d ON d.memo_id = memos.id AND d.recipient = ?", userEmail).
+		Where(recipientVisibleSQL, time.Now()).
+		Where(notRecalledSQL).
+		Where(notArchivedSQL)
+	if err := cur.after(q).Order("memos.created_at desc, memos.id desc").Limit(limit + 1).Scan(&rows).Error; err != nil {
+		return nil, err
 	}
 
-	// Fetch from database
-	var memos []*models.Memo
-	s.db.Order("created_at desc").Where(
-		"(`to` = ? AND status = ?) OR is_broadcast = ?",
-		userEmail, models.StatusSent, true,
-	).Limit(limit).Offset(offset).Find(&memos)
+	memos := make([]*models.Memo, 0, len(rows))
+	for i := range rows {
+		memos = append(memos, rows[i].toRecipientView())
+	}
+	s.attachAddressees(memos)
+	s.attachAttachments(memos)
 
 	// Cache the result
-	s.cache.SetMemoList(cacheKey, memos)
+	if cacheable {
+		s.cache.SetMemoList(namespace, cacheKey, memos)
+	}
 
-	return memos
+	return newMemoPage(memos, limit), nil
 }
 
-// UpdateStatus updates the status of a memo
-func (s *DBStore) UpdateStatus(id string, status models.MemoStatus) bool {
-	updates := map[string]interface{}{"status": status}
-
-	if status == models.StatusDelivered {
-		now := time.Now()
-		updates["delivered_at"] = &now
+// UpdateStatus records that recipient has received or read a memo
+// The memo itself is marked delivered once every recipient has received it
+// Only recipients can change delivery state; anyone else gets ErrForbidden
+func (s *DBStore) UpdateStatus(id string, recipient string, status models.MemoStatus) error {
+	if _, ok := s.Get(id); !ok {
+		return ErrMemoNotFound
+	}
+	var delivery models.MemoDelivery
+	if err := s.db.Where("memo_id = ? AND recipient = ?", id, recipient).First(&delivery).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		return ErrForbidden
 	}
 
-	result := s.db.Model(&models.Memo{}).Where("id = ?", id).Updates(updates)
+	now := time.Now()
+	if status != models.StatusDelivered && status != models.StatusRead {
+		return fmt.Errorf("invalid status %q", status)
+	}
 
-	if result.RowsAffected > 0 {
-		// Invalidate cached memo
-		s.cache.InvalidateMemo(id)
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.MemoDelivery{}).Where("memo_id = ? AND recipient = ?", id, recipient).
+			Update("delivered_at", gorm.Expr("COALESCE(delivered_at, ?)", now)).Error; err != nil {
+			return err
+		}
+		if status != models.StatusRead {
+			return nil
+		}
 
-		// Get the memo to invalidate related caches
-		if memo, ok := s.Get(id); ok {
-			s.cache.InvalidateUserMemos(memo.From)
-			s.cache.InvalidateUserMemos(memo.To)
+		// Only the first read takes the memo off the recipient's unread counter
+		result := tx.Model(&models.MemoDelivery{}).Where("memo_id = ? AND recipient = ? AND read_at IS NULL", id, recipient).
+			Update("read_at", now)
+		if result.Error != nil {
+			return result.Error
 		}
+		if result.RowsAffected > 0 {
+			if memo, ok := s.Get(id); ok && countsAsUnread(memo) {
+				return decrementUnread(tx, recipient)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error updating delivery for memo %s: %v", id, err)
+		return err
+	}
+
+	// Flip the memo to delivered once nobody is left waiting
+	var pending int64
+	s.db.Model(&models.MemoDelivery{}).Where("memo_id = ? AND delivered_at IS NULL", id).Count(&pending)
+	if pending == 0 {
+		s.db.Model(&models.Memo{}).Where("id = ? AND status = ?", id, models.StatusSent).
+			Updates(map[string]interface{}{"status": models.StatusDelivered, "delivered_at": &now})
+	}
 
-		return true
+	// Invalidate cached memo
+	s.cache.InvalidateMemo(id)
+
+	// Get the memo to invalidate related caches
+	if memo, ok := s.Get(id); ok {
+		s.cache.InvalidateUserMemos(memo.From)
+		s.cache.InvalidateUserMemos(recipient)
+
+		// Notify the sender and recipient of the status change
+		s.db.Where("memo_id = ? AND recipient = ?", id, recipient).First(&delivery)
+		s.events.Publish(events.Event{
+			Type: events.MemoStatusChanged,
+			Data: map[string]interface{}{
+				"id":          memo.ID,
+				"recipient":   recipient,
+				"status":      status,
+				"deliveredAt": delivery.DeliveredAt,
+				"readAt":      delivery.ReadAt,
+				"memoStatus":  memo.Status,
+			},
+			Recipients: []string{memo.From, recipient},
+		})
 	}
 
-	return false
+	return nil
 }
 
-// Delete removes a memo from the database
-func (s *DBStore) Delete(id string) bool {
-	// Get the memo first to invalidate related caches
-	memo, exists := s.Get(id)
+// Delete removes a memo from the database, archived or not
+// Only the sender of the memo or an admin can delete it, and not while it is under legal hold
+func (s *DBStore) Delete(id string, actor *models.User) error {
+	// Get the memo first to check ownership and invalidate related caches
+	memo, exists := s.getMemo(id)
+	if !exists {
+		return ErrMemoNotFound
+	}
+	if actor == nil || (memo.From != actor.Email && actor.Role != models.RoleAdmin) {
+		return ErrForbidden
+	}
+	if s.underLegalHold(memo) {
+		return ErrLegalHold
+	}
+	recipients := s.memoRecipients(id)
+
+	var deleted int64
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := releaseUnread(tx, []string{id}); err != nil {
+			return err
+		}
+		result := tx.Delete(&models.Memo{}, "id = ?", id)
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		if err := tx.Delete(&models.MemoRevision{}, "memo_id = ?", id).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.MemoDelivery{}, "memo_id = ?", id).Error
+	})
+	if err != nil {
+		return err
+	}
 
-	result := s.db.Delete(&models.Memo{}, "id = ?", id)
+	if deleted > 0 {
+		s.deleteAttachmentsWhere("memo_id = ?", id)
 
-	if result.RowsAffected > 0 {
 		// Invalidate cached memo
 		s.cache.InvalidateMemo(id)
 
-		// Invalidate related user caches if memo was found
-		if exists {
-			s.cache.InvalidateUserMemos(memo.From)
-			if memo.IsBroadcast {
-				s.cache.InvalidateBroadcastMemos()
-			} else {
-				s.cache.InvalidateUserMemos(memo.To)
+		// Invalidate related user caches
+		s.cache.InvalidateUserMemos(memo.From)
+		if memo.IsBroadcast && memo.Audience.IsEmpty() {
+			s.cache.InvalidateBroadcastMemos()
+		} else {
+			for _, r := range recipients {
+				s.cache.InvalidateUserMemos(r)
 			}
 		}
 
-		return true
+		// Let connected clients drop the memo
+		s.events.Publish(events.Event{
+			Type:       events.MemoDeleted,
+			Data:       map[string]interface{}{"id": id},
+			Recipients: append([]string{memo.From}, recipients...),
+			Broadcast:  memo.IsBroadcast && memo.Audience.IsEmpty(),
+		})
+
+		return nil
 	}
 
-	return false
+	return ErrMemoNotFound
 }
 
 // GetUserByEmail retrieves a user by their email address
@@ -292,25 +577,28 @@ func (s *DBStore) GetUserByEmail(email string) (*models.User, error) {
 func (s *DBStore) CreateUser(email string) (*models.User, error) {
 	user := &models.User{
 		Email: email,
+		Role:  models.RoleUser,
 	}
 	
 	if err := s.db.Create(user).Error; err != nil {
 		return nil, err
 	}
 	
-	// Invalidate user list cache since we added a new user
-	s.cache.InvalidateUserList()
-	
 	return user, nil
 }
 
-// StartCleanup periodically removes old memos based on TTL and delivery status
-// Runs cleanup every hour until the context is cancelled
+// UpdateUserRole sets the role of a user, e.g. from a role claim in their token
+func (s *DBStore) UpdateUserRole(email string, role models.UserRole) error {
+	return s.db.Model(&models.User{}).Where("email = ?", email).Update("role", role).Error
+}
+
+// StartCleanup periodically archives and purges memos according to their retention policies
+// Runs cleanup every CleanupIntervalMinutes until the context is cancelled
 func (s *DBStore) StartCleanup(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Hour)
+	ticker := time.NewTicker(config.CleanupIntervalMinutes * time.Minute)
 	defer ticker.Stop()
 
-	log.Println("Cleanup routine started - running every hour")
+	log.Printf("Cleanup routine started - running every %d minutes", config.CleanupIntervalMinutes)
 
 	for {
 		select {
@@ -323,41 +611,31 @@ func (s *DBStore) StartCleanup(ctx context.Context) {
 	}
 }
 
-// cleanup removes expired memos based on the following rules:
-// 1. Delivered messages older than 1 hour
-// 2. Messages with custom TTL that have expired
-// 3. Sent messages older than 24 hours (with no custom TTL)
+// cleanup applies retention policies and removes leftovers of deleted memos:
+// 1. Memos past their custom TTL or their category's archive age are archived
+// 2. Memos past their category's purge age are deleted unless under legal hold
+// 3. Attachments and delivery rows of deleted memos, and abandoned uploads, are removed
+// 4. Push outbox entries processed long ago are removed
 func (s *DBStore) cleanup() {
 	now := time.Now()
 
-	// Delete delivered memos older than 1 hour
-	cutoffDelivered := now.Add(-1 * time.Hour)
-	result := s.db.Where("status = ? AND delivered_at IS NOT NULL AND delivered_at < ?",
-		models.StatusDelivered, cutoffDelivered).Delete(&models.Memo{})
-	if result.RowsAffected > 0 {
-		log.Printf("Cleaned up %d delivered memos older than 1 hour", result.RowsAffected)
-	}
-
-	// Delete memos with custom TTL that have expired
-	var memosWithTTL []*models.Memo
-	s.db.Where("ttl_days IS NOT NULL AND status = ?", models.StatusSent).Find(&memosWithTTL)
-	deletedCount := 0
-	for _, memo := range memosWithTTL {
-		expiryTime := memo.CreatedAt.Add(time.Duration(*memo.TTLDays) * 24 * time.Hour)
-		if now.After(expiryTime) {
-			s.db.Delete(memo)
-			deletedCount++
-		}
+	if archived := s.archiveExpired(now); archived > 0 {
+		log.Printf("Archived %d memos past their retention period", archived)
 	}
-	if deletedCount > 0 {
-		log.Printf("Cleaned up %d memos with expired custom TTL", deletedCount)
+	if purged := s.purgeExpired(now); purged > 0 {
+		log.Printf("Purged %d memos past their retention period", purged)
 	}
 
-	// Delete sent memos older than 24 hours (only if no custom TTL)
-	cutoffSent := now.Add(-24 * time.Hour)
-	result = s.db.Where("status = ? AND created_at < ? AND ttl_days IS NULL",
-		models.StatusSent, cutoffSent).Delete(&models.Memo{})
+	// Remove attachment files of deleted memos and abandoned uploads
+	s.cleanupAttachments(now)
+
+	// Remove delivery rows left behind by deleted memos
+	result := s.db.Where("memo_id NOT IN (?)", s.db.Model(&models.Memo{}).Select("id")).Delete(&models.MemoDelivery{})
 	if result.RowsAffected > 0 {
-		log.Printf("Cleaned up %d sent memos older than 24 hours", result.RowsAffected)
+		log.Printf("Cleaned up %d orphaned delivery records", result.RowsAffected)
+	}
+
+	if removed := s.cleanupPushOutbox(now); removed > 0 {
+		log.Printf("Cleaned up %d processed push outbox entries", removed)
 	}
 }
@@ -0,0 +1,77 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"memo-app/internal/blob"
+	"memo-app/internal/cache"
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+	"memo-app/internal/store/storetest"
+)
+
+// newTestDatabase creates a migrated test database and returns its DSN
+// Tests using it are skipped unless storetest.DSNEnv names a MySQL server
+func newTestDatabase(t *testing.T) string {
+	t.Helper()
+	dsn := storetest.NewDatabase(t)
+	migrator, err := NewMigrator(dsn)
+	if err != nil {
+		t.Fatalf("NewMigrator: %v", err)
+	}
+	defer migrator.Close()
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating test database: %v", err)
+	}
+	return dsn
+}
+
+// newTestDBStore opens a store on the database at dsn, as one server of a deployment would
+// Push notifications go to gateway and attachments to a temporary directory
+func newTestDBStore(t *testing.T, dsn string, gateway push.Gateway) *DBStore {
+	t.Helper()
+	blobs, err := blob.NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hub := events.NewHub(config.SSEReplayBufferSize, config.SSESubscriberBuffer)
+	s, err := NewDBStore(dsn, cache.NewMemoryCache(time.Minute), hub, blobs, gateway)
+	if err != nil {
+		t.Fatalf("NewDBStore: %v", err)
+	}
+	return s
+}
+
+// createUsers signs up users with the default role
+func createUsers(t *testing.T, s *DBStore, emails ...string) {
+	t.Helper()
+	for _, email := range emails {
+		if _, err := s.CreateUser(email); err != nil {
+			t.Fatalf("CreateUser(%s): %v", email, err)
+		}
+	}
+}
+
+// sendMemo sends a direct memo and returns its ID
+func sendMemo(t *testing.T, s *DBStore, memo *models.Memo, to ...string) string {
+	t.Helper()
+	id, err := s.Add(memo, to, nil)
+	if err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+	return id
+}
+
+// unreadCount returns user's unread counter
+func unreadCount(t *testing.T, s *DBStore, user string) int64 {
+	t.Helper()
+	n, err := s.UnreadCount(user)
+	if err != nil {
+		t.Fatalf("UnreadCount(%s): %v", user, err)
+	}
+	return n
+}
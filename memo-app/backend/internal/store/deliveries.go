@@ -0,0 +1,162 @@
+package store
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/models"
+)
+
+// deliveryBatchSize bounds the number of delivery rows inserted per statement
+const deliveryBatchSize = 500
+
+// createDeliveries creates one pending delivery row per recipient of a memo
+func createDeliveries(tx *gorm.DB, memoID string, recipients []string, kind models.RecipientKind) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	rows := make([]models.MemoDelivery, 0, len(recipients))
+	for _, r := range recipients {
+		rows = append(rows, models.MemoDelivery{MemoID: memoID, Recipient: r, Kind: kind})
+	}
+	return tx.CreateInBatches(rows, deliveryBatchSize).Error
+}
+
+// memoRecipients returns every recipient of a memo
+func (s *DBStore) memoRecipients(memoID string) []string {
+	var recipients []string
+	s.db.Model(&models.MemoDelivery{}).Where("memo_id = ?", memoID).Pluck("recipient", &recipients)
+	return recipients
+}
+
+// attachAddressees fills in Recipients and CC on direct memos from their delivery rows
+// Broadcasts are skipped; their recipient set is everyone
+func (s *DBStore) attachAddressees(memos []*models.Memo) {
+	ids := make([]string, 0, len(memos))
+	for _, m := range memos {
+		switch {
+		case m.Status == models.StatusScheduled || m.Status == models.StatusFailed:
+			// Not resolved yet; show the addresses as the author entered them
+			m.Recipients, m.CC = m.ScheduledTo, m.ScheduledCC
+		case !m.IsBroadcast:
+			ids = append(ids, m.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	var deliveries []models.MemoDelivery
+	s.db.Select("memo_id, recipient, kind").Where("memo_id IN ?", ids).
+		Order("created_at, recipient").Find(&deliveries)
+
+	byMemo := make(map[string]*models.Memo, len(ids))
+	for _, m := range memos {
+		if !m.IsBroadcast && m.Status != models.StatusScheduled && m.Status != models.StatusFailed {
+			byMemo[m.ID] = m
+			m.Recipients, m.CC = nil, nil
+		}
+	}
+	for _, d := range deliveries {
+		m, ok := byMemo[d.MemoID]
+		if !ok {
+			continue
+		}
+		if d.Kind == models.RecipientCC {
+			m.CC = append(m.CC, d.Recipient)
+		} else {
+			m.Recipients = append(m.Recipients, d.Recipient)
+		}
+	}
+}
+
+// receivedRow is a memo joined with the calling recipient's delivery state
+type receivedRow struct {
+	models.Memo             `gorm:"embedded"`
+	RecipientDeliveredAt    *time.Time
+	RecipientReadAt         *time.Time
+	RecipientAcknowledgedAt *time.Time
+	IsRecipient             bool // Only selected for thread views, where the caller may be the sender
+}
+
+// toRecipientView returns the memo with status and timestamps from the recipient's point of view
+func (r *receivedRow) toRecipientView() *models.Memo {
+	memo := r.Memo
+	memo.DeliveredAt = r.RecipientDeliveredAt
+	memo.ReadAt = r.RecipientReadAt
+	memo.AcknowledgedAt = r.RecipientAcknowledgedAt
+	switch {
+	case r.RecipientReadAt != nil:
+		memo.Status = models.StatusRead
+	case r.RecipientDeliveredAt != nil:
+		memo.Status = models.StatusDelivered
+	default:
+		memo.Status = models.StatusSent
+	}
+	return &memo
+}
+
+// receiptSummaries computes delivery summaries for a set of memos in one query
+func (s *DBStore) receiptSummaries(memoIDs []string) map[string]*models.ReceiptSummary {
+	summaries := make(map[string]*models.ReceiptSummary, len(memoIDs))
+	if len(memoIDs) == 0 {
+		return summaries
+	}
+
+	var rows []struct {
+		MemoID       string
+		Total        int
+		Delivered    int
+		Read         int
+		Acknowledged int
+	}
+	s.db.Model(&models.MemoDelivery{}).
+		Select("memo_id, COUNT(*) AS total, COUNT(delivered_at) AS delivered, COUNT(read_at) AS `read`, "+
+			"COUNT(acknowledged_at) AS acknowledged").
+		Where("memo_id IN ?", memoIDs).
+		Group("memo_id").
+		Scan(&rows)
+
+	for _, r := range rows {
+		summaries[r.MemoID] = &models.ReceiptSummary{Total: r.Total, Delivered: r.Delivered, Read: r.Read, Acknowledged: r.Acknowledged}
+	}
+	return summaries
+}
+
+// attachReceiptSummaries fills in Receipts on memos listed for their sender
+func (s *DBStore) attachReceiptSummaries(memos []*models.Memo) {
+	ids := make([]string, 0, len(memos))
+	for _, m := range memos {
+		ids = append(ids, m.ID)
+	}
+	summaries := s.receiptSummaries(ids)
+	for _, m := range memos {
+		if summary, ok := summaries[m.ID]; ok {
+			m.Receipts = summary
+		} else {
+			m.Receipts = &models.ReceiptSummary{}
+		}
+	}
+}
+
+// GetReceipts returns the per-recipient delivery state of a memo with its summary
+func (s *DBStore) GetReceipts(memoID string) *models.MemoReceipts {
+	var deliveries []*models.MemoDelivery
+	s.db.Where("memo_id = ?", memoID).Order("recipient").Find(&deliveries)
+
+	receipts := &models.MemoReceipts{Recipients: deliveries}
+	receipts.Summary.Total = len(deliveries)
+	for _, d := range deliveries {
+		if d.DeliveredAt != nil {
+			receipts.Summary.Delivered++
+		}
+		if d.ReadAt != nil {
+			receipts.Summary.Read++
+		}
+		if d.AcknowledgedAt != nil {
+			receipts.Summary.Acknowledged++
+		}
+	}
+	return receipts
+}
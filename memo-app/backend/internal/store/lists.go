@@ -0,0 +1,301 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+var (
+	// ErrListNotFound is returned when a distribution list does not exist
+	ErrListNotFound = errors.New("distribution list not found")
+	// ErrListExists is returned when creating a distribution list whose name is taken
+	ErrListExists = errors.New("distribution list already exists")
+	// ErrInvalidAddress is returned when a memo or list contains an unusable address
+	ErrInvalidAddress = errors.New("invalid address")
+)
+
+// ListDistributionLists returns every distribution list with its members
+func (s *DBStore) ListDistributionLists() ([]*models.DistributionList, error) {
+	var lists []*models.DistributionList
+	if err := s.db.Order("name").Find(&lists).Error; err != nil {
+		return nil, err
+	}
+
+	var members []models.DistributionListMember
+	if err := s.db.Order("email").Find(&members).Error; err != nil {
+		return nil, err
+	}
+	byList := make(map[string][]string, len(lists))
+	for _, m := range members {
+		byList[m.ListName] = append(byList[m.ListName], m.Email)
+	}
+	for _, l := range lists {
+		l.Members = byList[l.Name]
+		if l.Members == nil {
+			l.Members = []string{}
+		}
+	}
+	return lists, nil
+}
+
+// GetDistributionList returns a single distribution list with its members
+func (s *DBStore) GetDistributionList(name string) (*models.DistributionList, error) {
+	var list models.DistributionList
+	if err := s.db.First(&list, "name = ?", name).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrListNotFound
+		}
+		return nil, err
+	}
+
+	list.Members = []string{}
+	if err := s.db.Model(&models.DistributionListMember{}).Where("list_name = ?", name).
+		Order("email").Pluck("email", &list.Members).Error; err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// CreateDistributionList stores a new distribution list and its members
+// Members must be known users
+func (s *DBStore) CreateDistributionList(list *models.DistributionList) error {
+	if err := validateListName(list.Name); err != nil {
+		return err
+	}
+	members, err := normalizeMembers(list.Members)
+	if err != nil {
+		return err
+	}
+	list.Members = members
+
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.DistributionList{}).Where("name = ?", list.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrListExists
+		}
+		if err := tx.Create(list).Error; err != nil {
+			return err
+		}
+		return setListMembers(tx, list.Name, members)
+	})
+}
+
+// UpdateDistributionList replaces the description and members of a distribution list
+// Memos already sent to the list keep the recipients they were expanded to
+func (s *DBStore) UpdateDistributionList(name string, description string, members []string) (*models.DistributionList, error) {
+	members, err := normalizeMembers(members)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.DistributionList{}).Where("name = ?", name).Update("description", description)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var count int64
+			if err := tx.Model(&models.DistributionList{}).Where("name = ?", name).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrListNotFound
+			}
+		}
+		if err := tx.Delete(&models.DistributionListMember{}, "list_name = ?", name).Error; err != nil {
+			return err
+		}
+		return setListMembers(tx, name, members)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetDistributionList(name)
+}
+
+// DeleteDistributionList removes a distribution list and its memberships
+func (s *DBStore) DeleteDistributionList(name string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Delete(&models.DistributionList{}, "name = ?", name)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrListNotFound
+		}
+		return tx.Delete(&models.DistributionListMember{}, "list_name = ?", name).Error
+	})
+}
+
+// LookupAddresses returns distribution lists, then users, whose address starts with query
+// Users also match on a display name prefix. Both use indexed prefix queries, so the cost
+// does not grow with the size of the directory. An empty query returns the first addresses.
+func (s *DBStore) LookupAddresses(query string, limit int) ([]models.Address, error) {
+	query = strings.TrimSpace(query)
+
+	q := s.db.Model(&models.DistributionList{}).Order("name").Limit(limit)
+	if query != "" {
+		q = q.Where("name LIKE ?", likeEscaper.Replace(query)+"%")
+	}
+	var lists []models.DistributionList
+	if err := q.Find(&lists).Error; err != nil {
+		return nil, err
+	}
+
+	addresses := make([]models.Address, 0, limit)
+	if len(lists) > 0 {
+		names := make([]string, len(lists))
+		for i, l := range lists {
+			names[i] = l.Name
+		}
+		var counts []struct {
+			ListName string
+			Members  int
+		}
+		if err := s.db.Model(&models.DistributionListMember{}).Select("list_name, COUNT(*) AS members").
+			Where("list_name IN ?", names).Group("list_name").Scan(&counts).Error; err != nil {
+			return nil, err
+		}
+		members := make(map[string]int, len(counts))
+		for _, c := range counts {
+			members[c.ListName] = c.Members
+		}
+		for _, l := range lists {
+			addresses = append(addresses, models.Address{
+				Address:     l.Name,
+				Type:        models.AddressTypeList,
+				Description: l.Description,
+				MemberCount: members[l.Name],
+			})
+		}
+	}
+
+	if remaining := limit - len(addresses); remaining > 0 {
+		page, err := s.SearchUsers(query, "", remaining)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range page.Users {
+			addresses = append(addresses, models.Address{Address: u.Email, Type: models.AddressTypeUser})
+		}
+	}
+	return addresses, nil
+}
+
+// resolvedRecipients is the frozen recipient set of a memo
+type resolvedRecipients struct {
+	to []string
+	cc []string
+}
+
+// all returns every recipient, direct recipients first
+func (r *resolvedRecipients) all() []string {
+	return append(append([]string{}, r.to...), r.cc...)
+}
+
+// resolveRecipients expands distribution lists and de-duplicates addresses
+// A user addressed both directly and in cc is only counted as a direct recipient.
+// The sender is skipped when they are only reached through a list.
+// Addresses that do not belong to a known user are rejected.
+func (s *DBStore) resolveRecipients(sender string, to []string, cc []string) (*resolvedRecipients, error) {
+	seen := make(map[string]bool)
+	var direct []string
+	expand := func(addrs []string) ([]string, error) {
+		var out []string
+		for _, addr := range addrs {
+			if models.IsEmailAddress(addr) {
+				if !seen[addr] {
+					seen[addr] = true
+					direct = append(direct, addr)
+					out = append(out, addr)
+				}
+				continue
+			}
+
+			var members []string
+			if err := s.db.Model(&models.DistributionListMember{}).Where("list_name = ?", addr).
+				Order("email").Pluck("email", &members).Error; err != nil {
+				return nil, err
+			}
+			if len(members) == 0 {
+				var count int64
+				s.db.Model(&models.DistributionList{}).Where("name = ?", addr).Count(&count)
+				if count == 0 {
+					return nil, fmt.Errorf("%w: unknown distribution list %q", ErrInvalidAddress, addr)
+				}
+			}
+			for _, m := range members {
+				if m != sender && !seen[m] {
+					seen[m] = true
+					out = append(out, m)
+				}
+			}
+		}
+		return out, nil
+	}
+
+	resolved := &resolvedRecipients{}
+	var err error
+	if resolved.to, err = expand(to); err != nil {
+		return nil, err
+	}
+	if resolved.cc, err = expand(cc); err != nil {
+		return nil, err
+	}
+	if err := requireKnownUsers(s.db, direct); err != nil {
+		return nil, err
+	}
+	return resolved, nil
+}
+
+// setListMembers inserts membership rows; every member must be a known user
+func setListMembers(tx *gorm.DB, name string, members []string) error {
+	if len(members) == 0 {
+		return nil
+	}
+	if err := requireKnownUsers(tx, members); err != nil {
+		return err
+	}
+	rows := make([]models.DistributionListMember, 0, len(members))
+	for _, email := range members {
+		rows = append(rows, models.DistributionListMember{ListName: name, Email: email})
+	}
+	return tx.CreateInBatches(rows, deliveryBatchSize).Error
+}
+
+// validateListName rejects names that could be mistaken for an email or broadcast
+func validateListName(name string) error {
+	if name == "" || len(name) > 100 || models.IsEmailAddress(name) || strings.ContainsAny(name, ", ") {
+		return fmt.Errorf("%w: list names must be 1-100 characters without '@', ',' or spaces", ErrInvalidAddress)
+	}
+	if name == config.BroadcastRecipient {
+		return fmt.Errorf("%w: %q is reserved", ErrInvalidAddress, name)
+	}
+	return nil
+}
+
+// normalizeMembers trims, validates and de-duplicates member emails
+func normalizeMembers(members []string) ([]string, error) {
+	seen := make(map[string]bool, len(members))
+	out := make([]string, 0, len(members))
+	for _, m := range members {
+		m = strings.TrimSpace(m)
+		if !models.IsEmailAddress(m) {
+			return nil, fmt.Errorf("%w: %q is not an email address", ErrInvalidAddress, m)
+		}
+		if !seen[m] {
+			seen[m] = true
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
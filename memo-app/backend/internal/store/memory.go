@@ -0,0 +1,729 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"memo-app/internal/cache"
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+)
+
+// MemoryStore is a MemoStore that keeps memos and users in process memory
+// It goes through the cache and publishes events like DBStore, which makes it suitable for
+// handler tests and trying the API without MySQL. Distribution lists, attachments,
+// scheduled sending and non-default categories need the database and are rejected.
+type MemoryStore struct {
+	mu         sync.RWMutex
+	memos      map[string]*models.Memo
+	deliveries map[string][]*models.MemoDelivery // by memo ID, in recipient order
+	users      map[string]*models.User
+	cache      cache.Cache
+	events     *events.Hub
+}
+
+// NewMemoryStore creates an empty in-memory store using cache and publishing to hub
+func NewMemoryStore(cache cache.Cache, hub *events.Hub) *MemoryStore {
+	return &MemoryStore{
+		memos:      make(map[string]*models.Memo),
+		deliveries: make(map[string][]*models.MemoDelivery),
+		users:      make(map[string]*models.User),
+		cache:      cache,
+		events:     hub,
+	}
+}
+
+// Add sends memo to the given to and cc user emails, or to the matching users for broadcasts
+// Only senders with a privileged role can send broadcasts
+func (s *MemoryStore) Add(memo *models.Memo, to []string, cc []string) (string, error) {
+	if err := validateAudience(memo); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	if memo.IsBroadcast {
+		sender, ok := s.users[memo.From]
+		if !ok || !sender.Role.CanBroadcast() {
+			s.mu.Unlock()
+			return "", ErrForbidden
+		}
+	}
+	recipients, err := s.prepare(memo, to, cc)
+	if err != nil {
+		s.mu.Unlock()
+		return "", err
+	}
+	stored := *memo
+	s.memos[memo.ID] = &stored
+	s.mu.Unlock()
+
+	s.cache.SetMemo(s.copyOf(memo.ID))
+	s.cache.InvalidateUserMemos(memo.From)
+	if memo.IsBroadcast && memo.Audience.IsEmpty() {
+		s.cache.InvalidateBroadcastMemos()
+	} else {
+		for _, r := range recipients {
+			s.cache.InvalidateUserMemos(r)
+		}
+	}
+
+	s.events.Publish(events.Event{
+		Type:       events.MemoCreated,
+		Data:       memo,
+		Recipients: recipients,
+	})
+	return memo.ID, nil
+}
+
+// prepare validates memo, fills in its generated fields and creates its deliveries
+// Must be called with s.mu held
+func (s *MemoryStore) prepare(memo *models.Memo, to []string, cc []string) ([]string, error) {
+	if memo.Category == "" {
+		memo.Category = config.DefaultMemoCategory
+	}
+	if memo.Category != config.DefaultMemoCategory {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidCategory, memo.Category)
+	}
+	if len(memo.AttachmentIDs) > 0 {
+		return nil, fmt.Errorf("%w: attachments need the database store", ErrInvalidAttachment)
+	}
+
+	if memo.ID == "" {
+		memo.ID = uuid.New().String()
+	}
+	if memo.ThreadID == "" {
+		memo.ThreadID = memo.ID
+	}
+	memo.CreatedAt = time.Now()
+	if err := validateSchedule(memo.SendAt, memo.VisibleUntil, memo.CreatedAt); err != nil {
+		return nil, err
+	}
+	if memo.SendAt != nil && memo.SendAt.After(memo.CreatedAt) {
+		return nil, fmt.Errorf("%w: scheduled sending needs the database store", ErrInvalidSchedule)
+	}
+	memo.SendAt = nil
+	memo.Status = models.StatusSent
+
+	resolved := &resolvedRecipients{}
+	if memo.IsBroadcast {
+		memo.To = config.BroadcastRecipient
+		for _, email := range s.sortedEmails() {
+			if email != memo.From && matchesAudience(s.users[email], memo.Audience) {
+				resolved.to = append(resolved.to, email)
+			}
+		}
+		if len(resolved.to) == 0 && !memo.Audience.IsEmpty() {
+			return nil, fmt.Errorf("%w: no users match the audience", ErrInvalidAudience)
+		}
+	} else {
+		seen := make(map[string]bool)
+		expand := func(addrs []string) ([]string, error) {
+			var out []string
+			for _, addr := range addrs {
+				if !models.IsEmailAddress(addr) {
+					return nil, fmt.Errorf("%w: unknown distribution list %q", ErrInvalidAddress, addr)
+				}
+				if !seen[addr] {
+					seen[addr] = true
+					out = append(out, addr)
+				}
+			}
+			return out, nil
+		}
+		var err error
+		if resolved.to, err = expand(to); err != nil {
+			return nil, err
+		}
+		if resolved.cc, err = expand(cc); err != nil {
+			return nil, err
+		}
+		if len(resolved.all()) == 0 {
+			return nil, fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+		}
+		known := make(map[string]bool, len(s.users))
+		for email := range s.users {
+			known[strings.ToLower(email)] = true
+		}
+		if err := unknownUsersError(resolved.all(), known); err != nil {
+			return nil, err
+		}
+		memo.To = resolved.all()[0]
+		memo.Recipients = resolved.to
+		memo.CC = resolved.cc
+	}
+
+	deliveries := make([]*models.MemoDelivery, 0, len(resolved.all()))
+	for _, r := range resolved.to {
+		deliveries = append(deliveries, &models.MemoDelivery{MemoID: memo.ID, Recipient: r, Kind: models.RecipientTo, CreatedAt: memo.CreatedAt})
+	}
+	for _, r := range resolved.cc {
+		deliveries = append(deliveries, &models.MemoDelivery{MemoID: memo.ID, Recipient: r, Kind: models.RecipientCC, CreatedAt: memo.CreatedAt})
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].Recipient < deliveries[j].Recipient })
+	s.deliveries[memo.ID] = deliveries
+	return resolved.all(), nil
+}
+
+// Get retrieves a memo by its ID
+func (s *MemoryStore) Get(id string) (*models.Memo, bool) {
+	if memo, ok := s.cache.GetMemo(id); ok {
+		return memo, true
+	}
+	memo := s.copyOf(id)
+	if memo == nil {
+		return nil, false
+	}
+	s.cache.SetMemo(memo)
+	return memo, true
+}
+
+// copyOf returns a copy of the stored memo, or nil if it does not exist
+func (s *MemoryStore) copyOf(id string) *models.Memo {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	stored, ok := s.memos[id]
+	if !ok {
+		return nil
+	}
+	memo := *stored
+	return &memo
+}
+
+// GetSentMemos retrieves a page of memos sent by a user, newest first, with receipt summaries
+// Only first pages are cached
+func (s *MemoryStore) GetSentMemos(userEmail string, cursorToken string, limit int) (*models.MemoPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+	// The namespace is taken before reading so a concurrent invalidation wins
+	cacheKey := fmt.Sprintf("sent:%d", limit)
+	namespace, cacheable := s.cache.Namespace(userEmail)
+	cacheable = cacheable && cur == nil
+	if cacheable {
+		if memos, ok := s.cache.GetMemoList(namespace, cacheKey); ok {
+			return newMemoPage(memos, limit), nil
+		}
+	}
+
+	s.mu.RLock()
+	var memos []*models.Memo
+	for _, m := range s.newestFirst() {
+		if m.From == userEmail && m.ArchivedAt == nil && cur.includes(m) {
+			memos = append(memos, s.senderView(m))
+			if len(memos) > limit {
+				break
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if cacheable {
+		s.cache.SetMemoList(namespace, cacheKey, memos)
+	}
+	return newMemoPage(memos, limit), nil
+}
+
+// GetReceivedMemos retrieves a page of memos received by a user, newest first, with
+// status and timestamps from the user's delivery. Only first pages are cached
+func (s *MemoryStore) GetReceivedMemos(userEmail string, cursorToken string, limit int) (*models.MemoPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+	// The namespace is taken before reading so a concurrent invalidation wins
+	cacheKey := fmt.Sprintf("received:%d", limit)
+	namespace, cacheable := s.cache.Namespace(userEmail)
+	cacheable = cacheable && cur == nil
+	if cacheable {
+		if memos, ok := s.cache.GetMemoList(namespace, cacheKey); ok {
+			return newMemoPage(memos, limit), nil
+		}
+	}
+
+	now := time.Now()
+	s.mu.RLock()
+	var memos []*models.Memo
+	for _, m := range s.newestFirst() {
+		if d := s.visibleDelivery(m, userEmail, now); d != nil && cur.includes(m) {
+			memos = append(memos, recipientView(m, d))
+			if len(memos) > limit {
+				break
+			}
+		}
+	}
+	s.mu.RUnlock()
+
+	if cacheable {
+		s.cache.SetMemoList(namespace, cacheKey, memos)
+	}
+	return newMemoPage(memos, limit), nil
+}
+
+// GetSentThreads lists a page of threads in which user sent a memo, most recently active first
+// Each thread carries the latest memo the user sent in it
+func (s *MemoryStore) GetSentThreads(user string, cursorToken string, limit int) (*models.ThreadPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var sent []*models.Memo
+	for _, m := range s.newestFirst() {
+		if m.From == user && m.ArchivedAt == nil {
+			sent = append(sent, s.senderView(m))
+		}
+	}
+	return threadPage(sent, nil, cur, limit), nil
+}
+
+// GetReceivedThreads lists a page of threads in which user received a memo, most recently active first
+// Each thread carries the latest memo the user received in it and the user's unread count
+func (s *MemoryStore) GetReceivedThreads(user string, cursorToken string, limit int) (*models.ThreadPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var received []*models.Memo
+	unread := map[string]int{}
+	for _, m := range s.newestFirst() {
+		if d := s.visibleDelivery(m, user, now); d != nil {
+			received = append(received, recipientView(m, d))
+			if d.ReadAt == nil {
+				unread[m.ThreadID]++
+			}
+		}
+	}
+	return threadPage(received, unread, cur, limit), nil
+}
+
+// threadPage groups memos, newest first, into a page of threads after cur
+func threadPage(memos []*models.Memo, unread map[string]int, cur *cursor, limit int) *models.ThreadPage {
+	var summaries []threadSummary
+	index := map[string]int{}
+	for _, m := range memos {
+		i, ok := index[m.ThreadID]
+		if !ok {
+			i = len(summaries)
+			index[m.ThreadID] = i
+			summaries = append(summaries, threadSummary{ThreadID: m.ThreadID, LastActivityAt: m.CreatedAt, Unread: unread[m.ThreadID]})
+		}
+		summaries[i].MessageCount++
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].LastActivityAt.Equal(summaries[j].LastActivityAt) {
+			return summaries[i].LastActivityAt.After(summaries[j].LastActivityAt)
+		}
+		return summaries[i].ThreadID > summaries[j].ThreadID
+	})
+
+	after := summaries[:0:0]
+	for _, sum := range summaries {
+		if cur == nil || sum.LastActivityAt.Before(cur.CreatedAt) ||
+			(sum.LastActivityAt.Equal(cur.CreatedAt) && sum.ThreadID < cur.ID) {
+			after = append(after, sum)
+		}
+	}
+	if len(after) > limit+1 {
+		after = after[:limit+1]
+	}
+	page, after := newThreadPage(after, limit)
+	page.Threads = buildThreads(after, memos)
+	return page
+}
+
+// UnreadCount returns how many visible memos user has received but not read
+func (s *MemoryStore) UnreadCount(user string) (int64, error) {
+	now := time.Now()
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var unread int64
+	for _, m := range s.memos {
+		if d := s.visibleDelivery(m, user, now); d != nil && d.ReadAt == nil {
+			unread++
+		}
+	}
+	return unread, nil
+}
+
+// UpdateStatus records that recipient has received or read a memo
+// The memo itself is marked delivered once every recipient has received it
+// Only recipients can change delivery state; anyone else gets ErrForbidden
+func (s *MemoryStore) UpdateStatus(id string, recipient string, status models.MemoStatus) error {
+	if status != models.StatusDelivered && status != models.StatusRead {
+		return fmt.Errorf("invalid status %q", status)
+	}
+
+	s.mu.Lock()
+	memo, ok := s.memos[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrMemoNotFound
+	}
+	delivery := findDelivery(s.deliveries[id], recipient)
+	if delivery == nil {
+		s.mu.Unlock()
+		return ErrForbidden
+	}
+
+	now := time.Now()
+	if delivery.DeliveredAt == nil {
+		delivery.DeliveredAt = &now
+	}
+	if status == models.StatusRead && delivery.ReadAt == nil {
+		delivery.ReadAt = &now
+	}
+	pending := 0
+	for _, d := range s.deliveries[id] {
+		if d.DeliveredAt == nil {
+			pending++
+		}
+	}
+	if pending == 0 && memo.Status == models.StatusSent {
+		memo.Status = models.StatusDelivered
+		memo.DeliveredAt = &now
+	}
+	state := *delivery
+	memoStatus := memo.Status
+	from := memo.From
+	s.mu.Unlock()
+
+	s.cache.InvalidateMemo(id)
+	s.cache.InvalidateUserMemos(from)
+	s.cache.InvalidateUserMemos(recipient)
+
+	s.events.Publish(events.Event{
+		Type: events.MemoStatusChanged,
+		Data: map[string]interface{}{
+			"id":          id,
+			"recipient":   recipient,
+			"status":      status,
+			"deliveredAt": state.DeliveredAt,
+			"readAt":      state.ReadAt,
+			"memoStatus":  memoStatus,
+		},
+		Recipients: []string{from, recipient},
+	})
+	return nil
+}
+
+// GetReceipts returns the per-recipient delivery state of a memo with its summary
+func (s *MemoryStore) GetReceipts(memoID string) *models.MemoReceipts {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	receipts := &models.MemoReceipts{Recipients: []*models.MemoDelivery{}}
+	for _, d := range s.deliveries[memoID] {
+		delivery := *d
+		receipts.Recipients = append(receipts.Recipients, &delivery)
+	}
+	receipts.Summary = summarize(s.deliveries[memoID])
+	return receipts
+}
+
+// Delete removes a memo
+// Only the sender of the memo or an admin can delete it, and not while it is under legal hold
+func (s *MemoryStore) Delete(id string, actor *models.User) error {
+	s.mu.Lock()
+	memo, ok := s.memos[id]
+	if !ok {
+		s.mu.Unlock()
+		return ErrMemoNotFound
+	}
+	if actor == nil || (memo.From != actor.Email && actor.Role != models.RoleAdmin) {
+		s.mu.Unlock()
+		return ErrForbidden
+	}
+	if memo.LegalHold {
+		s.mu.Unlock()
+		return ErrLegalHold
+	}
+	recipients := make([]string, 0, len(s.deliveries[id]))
+	for _, d := range s.deliveries[id] {
+		recipients = append(recipients, d.Recipient)
+	}
+	delete(s.memos, id)
+	delete(s.deliveries, id)
+	s.mu.Unlock()
+
+	s.cache.InvalidateMemo(id)
+	s.cache.InvalidateUserMemos(memo.From)
+	everyone := memo.IsBroadcast && memo.Audience.IsEmpty()
+	if everyone {
+		s.cache.InvalidateBroadcastMemos()
+	} else {
+		for _, r := range recipients {
+			s.cache.InvalidateUserMemos(r)
+		}
+	}
+
+	s.events.Publish(events.Event{
+		Type:       events.MemoDeleted,
+		Data:       map[string]interface{}{"id": id},
+		Recipients: append([]string{memo.From}, recipients...),
+		Broadcast:  everyone,
+	})
+	return nil
+}
+
+// SearchUsers lists a page of the user directory, ordered by email
+// A non-empty query keeps users whose email or display name starts with it, ignoring case
+func (s *MemoryStore) SearchUsers(query string, cursorToken string, limit int) (*models.UserPage, error) {
+	after, err := decodeUserCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+	prefix := strings.ToLower(strings.TrimSpace(query))
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var users []models.User
+	for _, email := range s.sortedEmails() {
+		user := s.users[email]
+		if email <= after {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(email), prefix) && !strings.HasPrefix(strings.ToLower(user.DisplayName), prefix) {
+			continue
+		}
+		users = append(users, *user)
+		if len(users) > limit {
+			break
+		}
+	}
+	return userPage(users, limit), nil
+}
+
+// GetUserByEmail retrieves a user, returning gorm.ErrRecordNotFound like DBStore for unknown users
+func (s *MemoryStore) GetUserByEmail(email string) (*models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	user, ok := s.users[email]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	copied := *user
+	return &copied, nil
+}
+
+// CreateUser registers a user with the default role
+func (s *MemoryStore) CreateUser(email string) (*models.User, error) {
+	s.mu.Lock()
+	if _, ok := s.users[email]; ok {
+		s.mu.Unlock()
+		return nil, fmt.Errorf("user %s already exists", email)
+	}
+	user := &models.User{Email: email, Role: models.RoleUser, CreatedAt: time.Now()}
+	s.users[email] = user
+	s.mu.Unlock()
+
+	copied := *user
+	return &copied, nil
+}
+
+// UpdateUserRole sets the role of a user
+func (s *MemoryStore) UpdateUserRole(email string, role models.UserRole) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if user, ok := s.users[email]; ok {
+		user.Role = role
+	}
+	return nil
+}
+
+// UpdateUserAttributes stores the non-empty attributes of a user
+func (s *MemoryStore) UpdateUserAttributes(email string, attrs models.UserAttributes) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user, ok := s.users[email]
+	if !ok {
+		return nil
+	}
+	if attrs.DisplayName != "" {
+		user.DisplayName = attrs.DisplayName
+	}
+	if attrs.AvatarURL != "" {
+		user.AvatarURL = attrs.AvatarURL
+	}
+	if attrs.Department != "" {
+		user.Department = attrs.Department
+	}
+	if attrs.Location != "" {
+		user.Location = attrs.Location
+	}
+	return nil
+}
+
+// GetAudienceOptions lists the roles, departments and locations users have, with the number of users for each
+func (s *MemoryStore) GetAudienceOptions() (*models.AudienceOptions, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	roles, departments, locations := map[string]int{}, map[string]int{}, map[string]int{}
+	for _, u := range s.users {
+		roles[string(u.Role)]++
+		if u.Department != "" {
+			departments[u.Department]++
+		}
+		if u.Location != "" {
+			locations[u.Location]++
+		}
+	}
+	return &models.AudienceOptions{
+		Roles:       audienceValues(roles),
+		Departments: audienceValues(departments),
+		Locations:   audienceValues(locations),
+	}, nil
+}
+
+// audienceValues turns value counts into AudienceValues ordered by value
+func audienceValues(counts map[string]int) []models.AudienceValue {
+	values := make([]models.AudienceValue, 0, len(counts))
+	for value, users := range counts {
+		values = append(values, models.AudienceValue{Value: value, Users: users})
+	}
+	sort.Slice(values, func(i, j int) bool { return values[i].Value < values[j].Value })
+	return values
+}
+
+// matchesAudience reports whether user is selected by audience; an empty audience selects everyone
+func matchesAudience(user *models.User, audience *models.Audience) bool {
+	if audience.IsEmpty() {
+		return true
+	}
+	if len(audience.Roles) > 0 && !containsRole(audience.Roles, user.Role) {
+		return false
+	}
+	if len(audience.Departments) > 0 && !containsString(audience.Departments, user.Department) {
+		return false
+	}
+	if len(audience.Locations) > 0 && !containsString(audience.Locations, user.Location) {
+		return false
+	}
+	return true
+}
+
+func containsRole(roles []models.UserRole, role models.UserRole) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// newestFirst returns the stored memos ordered by creation time and ID, newest first
+// Must be called with s.mu held
+func (s *MemoryStore) newestFirst() []*models.Memo {
+	memos := make([]*models.Memo, 0, len(s.memos))
+	for _, m := range s.memos {
+		memos = append(memos, m)
+	}
+	sort.Slice(memos, func(i, j int) bool {
+		if !memos[i].CreatedAt.Equal(memos[j].CreatedAt) {
+			return memos[i].CreatedAt.After(memos[j].CreatedAt)
+		}
+		return memos[i].ID > memos[j].ID
+	})
+	return memos
+}
+
+// sortedEmails returns the emails of every user in order
+// Must be called with s.mu held
+func (s *MemoryStore) sortedEmails() []string {
+	emails := make([]string, 0, len(s.users))
+	for email := range s.users {
+		emails = append(emails, email)
+	}
+	sort.Strings(emails)
+	return emails
+}
+
+// visibleDelivery returns user's delivery of memo if the memo is listed for them:
+// not archived, still visible and not recalled before they read it
+// Must be called with s.mu held
+func (s *MemoryStore) visibleDelivery(memo *models.Memo, user string, now time.Time) *models.MemoDelivery {
+	d := findDelivery(s.deliveries[memo.ID], user)
+	if d == nil || memo.ArchivedAt != nil || (memo.VisibleUntil != nil && !memo.VisibleUntil.After(now)) {
+		return nil
+	}
+	if memo.RecalledAt != nil && (d.ReadAt == nil || d.ReadAt.After(*memo.RecalledAt)) {
+		return nil
+	}
+	return d
+}
+
+// senderView returns a copy of memo with its receipt summary, as listed for its sender
+// Must be called with s.mu held
+func (s *MemoryStore) senderView(memo *models.Memo) *models.Memo {
+	view := *memo
+	summary := summarize(s.deliveries[memo.ID])
+	view.Receipts = &summary
+	return &view
+}
+
+// recipientView returns a copy of memo with status and timestamps from delivery d
+func recipientView(memo *models.Memo, d *models.MemoDelivery) *models.Memo {
+	row := receivedRow{
+		Memo:                    *memo,
+		RecipientDeliveredAt:    d.DeliveredAt,
+		RecipientReadAt:         d.ReadAt,
+		RecipientAcknowledgedAt: d.AcknowledgedAt,
+	}
+	return row.toRecipientView()
+}
+
+// summarize counts how many deliveries have been received, read and acknowledged
+func summarize(deliveries []*models.MemoDelivery) models.ReceiptSummary {
+	summary := models.ReceiptSummary{Total: len(deliveries)}
+	for _, d := range deliveries {
+		if d.DeliveredAt != nil {
+			summary.Delivered++
+		}
+		if d.ReadAt != nil {
+			summary.Read++
+		}
+		if d.AcknowledgedAt != nil {
+			summary.Acknowledged++
+		}
+	}
+	return summary
+}
+
+// findDelivery returns the delivery of recipient among deliveries, or nil
+func findDelivery(deliveries []*models.MemoDelivery, recipient string) *models.MemoDelivery {
+	for _, d := range deliveries {
+		if d.Recipient == recipient {
+			return d
+		}
+	}
+	return nil
+}
+
+// includes reports whether memo comes after the cursor in a newest-first list; a nil cursor includes everything
+func (c *cursor) includes(memo *models.Memo) bool {
+	if c == nil {
+		return true
+	}
+	return memo.CreatedAt.Before(c.CreatedAt) || (memo.CreatedAt.Equal(c.CreatedAt) && memo.ID < c.ID)
+}
@@ -0,0 +1,470 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"memo-app/internal/config"
+)
+
+// migrationFiles holds the schema changes, as NNNN_name.up.sql and NNNN_name.down.sql pairs
+// Statements end with a semicolon at the end of a line; lines starting with -- are comments
+//
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// ErrSchemaOutdated is returned when the database has migrations this build needs still pending
+var ErrSchemaOutdated = errors.New("database schema is out of date; run `memo-app migrate up`")
+
+// Migration is a numbered schema change embedded in the binary
+// Never edit a migration that has been released; add a new one instead
+type Migration struct {
+	Version int
+	Name    string
+	up      string
+	down    string
+}
+
+// MigrationStatus is a migration and when it was applied, nil while pending
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// migrationPattern matches the names of migration files
+var migrationPattern = regexp.MustCompile(`^(\d{4})_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// migrations are the embedded migrations in version order
+var migrations = mustLoadMigrations()
+
+// legacyMigrations maps the IDs recorded by the migrations that ran after AutoMigrate to their versions
+var legacyMigrations = map[string]int{
+	"0001_memos_fulltext":           2,
+	"0002_acknowledgment_retention": 3,
+	"0003_memos_sender_keyset":      4,
+}
+
+// versionTableSQL creates the table recording applied migrations
+const versionTableSQL = "CREATE TABLE IF NOT EXISTS schema_versions (" +
+	"version INT NOT NULL PRIMARY KEY, name VARCHAR(100) NOT NULL, applied_at DATETIME(3) NOT NULL)"
+
+// mustLoadMigrations parses the embedded migration files
+// They are part of the binary, so a malformed set is a build error and panics
+func mustLoadMigrations() []Migration {
+	loaded, err := loadMigrations(migrationFiles)
+	if err != nil {
+		panic(err)
+	}
+	return loaded
+}
+
+// loadMigrations reads the migrations in the migrations directory of fsys, checking
+// that every version has both directions and that versions are numbered from 1 without gaps
+func loadMigrations(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, "migrations")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		match := migrationPattern.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.up.sql or NNNN_name.down.sql", entry.Name())
+		}
+		content, err := fs.ReadFile(fsys, "migrations/"+entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migration %04d is named both %s and %s", version, m.Name, match[2])
+		}
+		if match[3] == "up" {
+			m.up = string(content)
+		} else {
+			m.down = string(content)
+		}
+	}
+
+	loaded := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.up == "" || m.down == "" {
+			return nil, fmt.Errorf("migration %04d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		loaded = append(loaded, *m)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Version < loaded[j].Version })
+	for i, m := range loaded {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migration %04d_%s is out of sequence, expected version %04d", m.Version, m.Name, i+1)
+		}
+	}
+	return loaded, nil
+}
+
+// statements splits a migration file into its statements, dropping comments
+// An empty result is allowed, for down migrations that deliberately keep data
+func statements(script string) []string {
+	var stmts []string
+	var current strings.Builder
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			stmts = append(stmts, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		stmts = append(stmts, rest)
+	}
+	return stmts
+}
+
+// checkSchema verifies that every migration of this build has been applied to db
+// It never changes the schema, so servers can start and reconnect while another instance migrates
+func checkSchema(db *gorm.DB) error {
+	if !db.Migrator().HasTable("schema_versions") {
+		return fmt.Errorf("%w: no migrations have been applied", ErrSchemaOutdated)
+	}
+	var versions []int
+	if err := db.Table("schema_versions").Pluck("version", &versions).Error; err != nil {
+		return err
+	}
+	applied := make(map[int]bool, len(versions))
+	latest := 0
+	for _, v := range versions {
+		applied[v] = true
+		if v > latest {
+			latest = v
+		}
+	}
+
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			return fmt.Errorf("%w: migration %04d_%s is pending", ErrSchemaOutdated, m.Version, m.Name)
+		}
+	}
+	// A newer release has migrated already; older servers keep running during a rolling deploy
+	if known := migrations[len(migrations)-1].Version; latest > known {
+		log.Printf("dbstore: database schema is at version %d, newer than this build (%d)", latest, known)
+	}
+	return nil
+}
+
+// Migrator applies and rolls back the embedded migrations
+type Migrator struct {
+	db *sql.DB
+}
+
+// NewMigrator connects to the MySQL database at dsn for migrating
+func NewMigrator(dsn string) (*Migrator, error) {
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Warn),
+	})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: sqlDB}, nil
+}
+
+// Close closes the database connection
+func (m *Migrator) Close() error {
+	return m.db.Close()
+}
+
+// Up applies the pending migrations in order and returns them
+// A database last run with AutoMigrate is adopted first: the migrations it already
+// reflects are recorded as applied instead of being run
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			if applied, err = adoptLegacySchema(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		for _, migration := range migrations {
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			if err := execScript(ctx, conn, migration.up); err != nil {
+				return fmt.Errorf("migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if err := recordVersion(ctx, conn, migration); err != nil {
+				return err
+			}
+			log.Printf("Applied migration %04d_%s", migration.Version, migration.Name)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest steps applied migrations, newest first, and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if err := execScript(ctx, conn, migration.down); err != nil {
+				return fmt.Errorf("rolling back migration %04d_%s: %w", migration.Version, migration.Name, err)
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_versions WHERE version = ?", migration.Version); err != nil {
+				return err
+			}
+			log.Printf("Rolled back migration %04d_%s", migration.Version, migration.Name)
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status lists every migration of this build with when it was applied
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, versionTableSQL); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		entry := MigrationStatus{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			entry.AppliedAt = &at
+		}
+		status = append(status, entry)
+	}
+	return status, nil
+}
+
+// locked runs fn on a single connection holding the migration advisory lock, so that
+// instances started side by side migrate one at a time
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	var acquired sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)",
+		config.MigrationLockName, config.MigrationLockTimeoutSeconds).Scan(&acquired); err != nil {
+		return err
+	}
+	if acquired.Int64 != 1 {
+		return fmt.Errorf("another instance is migrating; timed out after %d seconds waiting for it", config.MigrationLockTimeoutSeconds)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", config.MigrationLockName)
+
+	if _, err := conn.ExecContext(ctx, versionTableSQL); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// adoptLegacySchema records the migrations a database created by AutoMigrate already has,
+// after adding whatever part of the initial schema its release predates
+// Empty databases are left alone and get every migration
+func adoptLegacySchema(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	applied := map[int]time.Time{}
+	if !hasColumn(ctx, conn, "memos", "id") {
+		return applied, nil
+	}
+	// Releases before the last one using AutoMigrate created only part of the initial schema
+	if err := completeInitialSchema(ctx, conn); err != nil {
+		return nil, fmt.Errorf("bringing the existing tables up to the initial schema: %w", err)
+	}
+
+	adopted := []Migration{migrations[0]}
+	if hasColumn(ctx, conn, "schema_migrations", "id") {
+		rows, err := conn.QueryContext(ctx, "SELECT id FROM schema_migrations")
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var id string
+			if err := rows.Scan(&id); err != nil {
+				return nil, err
+			}
+			if version, ok := legacyMigrations[id]; ok {
+				adopted = append(adopted, migrations[version-1])
+			}
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, migration := range adopted {
+		if err := recordVersion(ctx, conn, migration); err != nil {
+			return nil, err
+		}
+		applied[migration.Version] = time.Now()
+		log.Printf("Adopted migration %04d_%s from the existing schema", migration.Version, migration.Name)
+	}
+	return applied, nil
+}
+
+// completeInitialSchema adds the tables, columns and indexes of the initial migration
+// that a database created by an older AutoMigrate release lacks, such as the first
+// release's memos and users tables. Existing definitions are left as they are.
+func completeInitialSchema(ctx context.Context, conn *sql.Conn) error {
+	for _, stmt := range statements(migrations[0].up) {
+		match := createTablePattern.FindStringSubmatch(stmt)
+		if match == nil {
+			return fmt.Errorf("unexpected statement in migration 0001: %.40s", stmt)
+		}
+		table := match[1]
+		if !hasTable(ctx, conn, table) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return err
+			}
+			log.Printf("Created table %s of the initial schema", table)
+			continue
+		}
+
+		// Columns come before indexes in the statement, so indexes find their columns added
+		for _, line := range strings.Split(stmt, "\n")[1:] {
+			definition := strings.TrimSuffix(strings.TrimSpace(line), ",")
+			var missing bool
+			if m := indexDefinitionPattern.FindStringSubmatch(definition); m != nil {
+				missing = !hasIndex(ctx, conn, table, m[1])
+			} else if m := columnDefinitionPattern.FindStringSubmatch(definition); m != nil {
+				missing = !hasColumn(ctx, conn, table, m[1])
+				definition = "COLUMN " + definition
+			}
+			if !missing {
+				continue
+			}
+			if _, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE `%s` ADD %s", table, definition)); err != nil {
+				return err
+			}
+			log.Printf("Added %s to table %s", definition, table)
+		}
+	}
+	return nil
+}
+
+// Patterns for the statements of the initial migration, as read by completeInitialSchema
+var (
+	createTablePattern      = regexp.MustCompile("^CREATE TABLE `(\\w+)` \\(")
+	columnDefinitionPattern = regexp.MustCompile("^`(\\w+)` ")
+	indexDefinitionPattern  = regexp.MustCompile("^INDEX `(\\w+)` ")
+)
+
+// hasTable reports whether the current database has table
+func hasTable(ctx context.Context, conn *sql.Conn, table string) bool {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.tables "+
+		"WHERE table_schema = DATABASE() AND table_name = ?", table).Scan(&count)
+	return err == nil && count > 0
+}
+
+// hasIndex reports whether table in the current database has the index called name
+func hasIndex(ctx context.Context, conn *sql.Conn, table, name string) bool {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.statistics "+
+		"WHERE table_schema = DATABASE() AND table_name = ? AND index_name = ?", table, name).Scan(&count)
+	return err == nil && count > 0
+}
+
+// hasColumn reports whether the current database has table with column
+func hasColumn(ctx context.Context, conn *sql.Conn, table, column string) bool {
+	var count int
+	err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM information_schema.columns "+
+		"WHERE table_schema = DATABASE() AND table_name = ? AND column_name = ?", table, column).Scan(&count)
+	return err == nil && count > 0
+}
+
+// appliedVersions returns the applied migrations with when they were applied
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version, applied_at FROM schema_versions")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	applied := map[int]time.Time{}
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+	return applied, rows.Err()
+}
+
+// execScript runs the statements of a migration file in order
+// MySQL commits DDL implicitly, so a failing script is not rolled back and must be fixed by hand
+func execScript(ctx context.Context, conn *sql.Conn, script string) error {
+	for _, stmt := range statements(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordVersion marks migration as applied
+func recordVersion(ctx context.Context, conn *sql.Conn, migration Migration) error {
+	_, err := conn.ExecContext(ctx, "INSERT INTO schema_versions (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now())
+	return err
+}
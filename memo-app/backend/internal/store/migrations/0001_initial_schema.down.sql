@@ -0,0 +1,12 @@
+DROP TABLE `memo_revisions`;
+DROP TABLE `push_outbox`;
+DROP TABLE `push_mutes`;
+DROP TABLE `device_tokens`;
+DROP TABLE `unread_counters`;
+DROP TABLE `retention_policies`;
+DROP TABLE `attachments`;
+DROP TABLE `distribution_list_members`;
+DROP TABLE `distribution_lists`;
+DROP TABLE `memo_deliveries`;
+DROP TABLE `users`;
+DROP TABLE `memos`;
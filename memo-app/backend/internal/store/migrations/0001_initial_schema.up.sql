@@ -0,0 +1,157 @@
+-- The schema as it stood when versioned migrations replaced AutoMigrate
+
+CREATE TABLE `memos` (
+  `id` varchar(36),
+  `from` varchar(255),
+  `to` varchar(255),
+  `subject` text,
+  `message` text,
+  `status` varchar(20),
+  `is_broadcast` boolean,
+  `ttl_days` bigint,
+  `created_at` datetime(3) NULL,
+  `delivered_at` datetime(3) NULL,
+  `parent_id` varchar(36),
+  `thread_id` varchar(36),
+  `audience` text,
+  `category` varchar(50) NOT NULL DEFAULT 'general',
+  `archived_at` datetime(3) NULL,
+  `legal_hold` boolean NOT NULL DEFAULT false,
+  `requires_ack` boolean NOT NULL DEFAULT false,
+  `recalled_at` datetime(3) NULL,
+  `edited_at` datetime(3) NULL,
+  `revision` bigint NOT NULL DEFAULT 0,
+  `send_at` datetime(3) NULL,
+  `visible_until` datetime(3) NULL,
+  `scheduled_to` text,
+  `scheduled_cc` text,
+  PRIMARY KEY (`id`),
+  INDEX `idx_memos_from` (`from`),
+  INDEX `idx_memos_to` (`to`),
+  INDEX `idx_memos_status` (`status`),
+  INDEX `idx_memos_is_broadcast` (`is_broadcast`),
+  INDEX `idx_memos_delivered_at` (`delivered_at`),
+  INDEX `idx_memos_parent_id` (`parent_id`),
+  INDEX `idx_memos_thread_id` (`thread_id`),
+  INDEX `idx_memos_category` (`category`),
+  INDEX `idx_memos_archived_at` (`archived_at`),
+  INDEX `idx_memos_requires_ack` (`requires_ack`),
+  INDEX `idx_memos_recalled_at` (`recalled_at`),
+  INDEX `idx_memos_send_at` (`send_at`),
+  INDEX `idx_memos_visible_until` (`visible_until`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `users` (
+  `email` varchar(255),
+  `role` varchar(20) NOT NULL DEFAULT 'user',
+  `department` varchar(100),
+  `location` varchar(100),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`email`),
+  INDEX `idx_users_role` (`role`),
+  INDEX `idx_users_department` (`department`),
+  INDEX `idx_users_location` (`location`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `memo_deliveries` (
+  `memo_id` varchar(36),
+  `recipient` varchar(255),
+  `kind` varchar(10) NOT NULL DEFAULT 'to',
+  `delivered_at` datetime(3) NULL,
+  `read_at` datetime(3) NULL,
+  `created_at` datetime(3) NULL,
+  `acknowledged_at` datetime(3) NULL,
+  `ack_reminders` bigint NOT NULL DEFAULT 0,
+  `last_reminded_at` datetime(3) NULL,
+  PRIMARY KEY (`memo_id`,`recipient`),
+  INDEX `idx_memo_deliveries_recipient` (`recipient`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `distribution_lists` (
+  `name` varchar(100),
+  `description` text,
+  `created_by` varchar(255),
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `distribution_list_members` (
+  `list_name` varchar(100),
+  `email` varchar(255),
+  PRIMARY KEY (`list_name`,`email`),
+  INDEX `idx_distribution_list_members_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `attachments` (
+  `id` varchar(36),
+  `memo_id` varchar(36),
+  `uploader` varchar(255),
+  `filename` varchar(255),
+  `content_type` varchar(100),
+  `size` bigint,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_attachments_memo_id` (`memo_id`),
+  INDEX `idx_attachments_uploader` (`uploader`),
+  INDEX `idx_attachments_created_at` (`created_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `retention_policies` (
+  `category` varchar(50),
+  `archive_after_days` bigint,
+  `purge_after_days` bigint NOT NULL,
+  `legal_hold` boolean NOT NULL DEFAULT false,
+  `updated_by` varchar(255),
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`category`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `unread_counters` (
+  `email` varchar(255),
+  `unread` bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `device_tokens` (
+  `token` varchar(255),
+  `email` varchar(255),
+  `platform` varchar(20),
+  `created_at` datetime(3) NULL,
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`token`),
+  INDEX `idx_device_tokens_email` (`email`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `push_mutes` (
+  `email` varchar(255),
+  `kind` varchar(20),
+  `value` varchar(255),
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`email`,`kind`,`value`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `push_outbox` (
+  `id` bigint unsigned AUTO_INCREMENT,
+  `memo_id` varchar(36),
+  `kind` varchar(20) NOT NULL DEFAULT 'memo',
+  `cursor` varchar(255),
+  `attempts` bigint NOT NULL DEFAULT 0,
+  `next_attempt_at` datetime(3) NULL,
+  `processed_at` datetime(3) NULL,
+  `last_error` text,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`id`),
+  INDEX `idx_push_outbox_memo_id` (`memo_id`),
+  INDEX `idx_push_outbox_next_attempt_at` (`next_attempt_at`),
+  INDEX `idx_push_outbox_processed_at` (`processed_at`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE `memo_revisions` (
+  `memo_id` varchar(36),
+  `revision` bigint,
+  `subject` text,
+  `message` text,
+  `created_at` datetime(3) NULL,
+  PRIMARY KEY (`memo_id`,`revision`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
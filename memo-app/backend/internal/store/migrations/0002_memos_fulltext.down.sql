@@ -0,0 +1 @@
+ALTER TABLE memos DROP INDEX ft_memos_search;
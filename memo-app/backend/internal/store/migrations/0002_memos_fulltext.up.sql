@@ -0,0 +1,2 @@
+-- Backs memo search over subject, body and sender
+ALTER TABLE memos ADD FULLTEXT INDEX ft_memos_search (subject, message, `from`);
@@ -0,0 +1,2 @@
+-- Keeps the policy if an admin has changed it since
+DELETE FROM retention_policies WHERE category = 'acknowledgment' AND updated_by = 'system';
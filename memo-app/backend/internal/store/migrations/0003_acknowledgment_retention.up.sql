@@ -0,0 +1,3 @@
+-- Legal requires acknowledgment memos to be kept for a year
+INSERT IGNORE INTO retention_policies (category, purge_after_days, legal_hold, updated_by, updated_at)
+VALUES ('acknowledgment', 365, FALSE, 'system', NOW());
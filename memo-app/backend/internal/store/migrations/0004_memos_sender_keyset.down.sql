@@ -0,0 +1 @@
+DROP INDEX idx_memos_from_created ON memos;
@@ -0,0 +1,2 @@
+-- Serves the keyset pagination of sent memos
+CREATE INDEX idx_memos_from_created ON memos (`from`, created_at, id);
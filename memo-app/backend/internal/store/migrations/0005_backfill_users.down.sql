@@ -0,0 +1 @@
+-- Registered users are kept; they are indistinguishable from users who signed in
@@ -0,0 +1,7 @@
+-- Registers senders and recipients of memos sent before users were tracked
+-- (formerly the separate migrate_users command)
+INSERT IGNORE INTO users (email, role, created_at)
+SELECT `from`, 'user', NOW(3) FROM memos WHERE `from` <> '';
+
+INSERT IGNORE INTO users (email, role, created_at)
+SELECT `to`, 'user', NOW(3) FROM memos WHERE is_broadcast = FALSE AND `to` <> '' AND `to` <> 'broadcast';
@@ -0,0 +1 @@
+-- Delivery rows are what memos are read from now, so they are kept
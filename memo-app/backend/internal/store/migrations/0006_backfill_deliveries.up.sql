@@ -0,0 +1,12 @@
+-- Memos sent before per-recipient tracking get their delivery rows
+-- Scheduled memos get theirs when released, and failed ones were never delivered
+-- A direct memo used to become 'delivered' when its recipient read it, so those are read
+INSERT INTO memo_deliveries (memo_id, recipient, delivered_at, read_at, created_at)
+SELECT m.id, m.`to`, m.delivered_at, CASE WHEN m.status = 'delivered' THEN COALESCE(m.delivered_at, m.created_at) END, m.created_at FROM memos m
+WHERE m.is_broadcast = FALSE AND m.status NOT IN ('scheduled', 'failed')
+  AND NOT EXISTS (SELECT 1 FROM memo_deliveries d WHERE d.memo_id = m.id);
+
+INSERT INTO memo_deliveries (memo_id, recipient, created_at)
+SELECT m.id, u.email, m.created_at FROM memos m JOIN users u ON u.email <> m.`from`
+WHERE m.is_broadcast = TRUE AND m.status NOT IN ('scheduled', 'failed')
+  AND NOT EXISTS (SELECT 1 FROM memo_deliveries d WHERE d.memo_id = m.id);
@@ -0,0 +1 @@
+-- Thread IDs are kept; every memo needs one
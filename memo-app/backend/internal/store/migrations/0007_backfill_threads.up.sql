@@ -0,0 +1,2 @@
+-- Memos sent before threading each start their own thread
+UPDATE memos SET thread_id = id WHERE thread_id IS NULL OR thread_id = '';
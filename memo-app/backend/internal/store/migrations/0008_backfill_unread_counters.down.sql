@@ -0,0 +1 @@
+-- Counters are maintained as memos are sent and read, so they are kept
@@ -0,0 +1,8 @@
+-- Unread counters start from the deliveries that already exist
+-- Counts what countsAsUnread counts: unread, not archived, not recalled and visible
+INSERT INTO unread_counters (email, unread)
+SELECT d.recipient, COUNT(*) FROM memo_deliveries d JOIN memos ON memos.id = d.memo_id
+WHERE d.read_at IS NULL AND memos.archived_at IS NULL AND memos.recalled_at IS NULL
+  AND (memos.visible_until IS NULL OR memos.visible_until > NOW(3))
+GROUP BY d.recipient
+ON DUPLICATE KEY UPDATE unread = VALUES(unread);
@@ -0,0 +1,4 @@
+ALTER TABLE users
+  DROP INDEX `idx_users_display_name`,
+  DROP COLUMN `avatar_url`,
+  DROP COLUMN `display_name`;
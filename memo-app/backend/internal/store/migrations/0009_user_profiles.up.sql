@@ -0,0 +1,5 @@
+-- Directory profile fields, filled from the "name" and "picture" token claims
+ALTER TABLE users
+  ADD COLUMN `display_name` varchar(255),
+  ADD COLUMN `avatar_url` varchar(500),
+  ADD INDEX `idx_users_display_name` (`display_name`);
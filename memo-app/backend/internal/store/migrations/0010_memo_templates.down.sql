@@ -0,0 +1 @@
+DROP TABLE `memo_templates`;
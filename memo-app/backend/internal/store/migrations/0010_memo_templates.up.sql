@@ -0,0 +1,10 @@
+-- Reusable memos with {{.Name}} placeholders, managed by admins
+CREATE TABLE `memo_templates` (
+  `name` varchar(100),
+  `description` text,
+  `subject` text,
+  `message` text,
+  `updated_by` varchar(255),
+  `updated_at` datetime(3) NULL,
+  PRIMARY KEY (`name`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,3 @@
+ALTER TABLE memos
+  DROP INDEX `idx_memos_expired_at`,
+  DROP COLUMN `expired_at`;
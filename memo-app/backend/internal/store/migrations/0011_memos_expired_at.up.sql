@@ -0,0 +1,7 @@
+-- Marks memos whose end of visibility the scheduler has handled, so each expiry is handled once
+ALTER TABLE memos
+  ADD COLUMN `expired_at` datetime(3) NULL,
+  ADD INDEX `idx_memos_expired_at` (`expired_at`);
+
+-- Windows that ended before this release were handled by the scheduler or by the unread counter backfill
+UPDATE memos SET expired_at = visible_until WHERE visible_until <= NOW(3);
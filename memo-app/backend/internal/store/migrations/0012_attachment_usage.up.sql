@@ -0,0 +1,9 @@
+-- Per-user attachment storage, locked by uploads so the quota cannot be overrun by concurrent uploads
+CREATE TABLE `attachment_usages` (
+  `uploader` varchar(255),
+  `bytes` bigint NOT NULL DEFAULT 0,
+  PRIMARY KEY (`uploader`)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+INSERT INTO attachment_usages (uploader, bytes)
+SELECT uploader, SUM(size) FROM attachments GROUP BY uploader;
@@ -0,0 +1,216 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"memo-app/internal/store/storetest"
+)
+
+func TestEmbeddedMigrationsAreNumberedInSequence(t *testing.T) {
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Fatalf("migration %d has version %d", i, m.Version)
+		}
+		if len(statements(m.up)) == 0 {
+			t.Errorf("migration %04d_%s has no up statements", m.Version, m.Name)
+		}
+	}
+	for id, version := range legacyMigrations {
+		if version < 1 || version > len(migrations) || !strings.HasSuffix(id, migrations[version-1].Name) {
+			t.Errorf("legacy migration %s maps to version %d", id, version)
+		}
+	}
+}
+
+func TestLoadMigrationsRejectsBrokenSets(t *testing.T) {
+	file := func(sql string) *fstest.MapFile { return &fstest.MapFile{Data: []byte(sql)} }
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"migrations/0001_init.up.sql": file("CREATE TABLE a (id INT);"),
+		},
+		"gap": {
+			"migrations/0001_init.up.sql":   file("CREATE TABLE a (id INT);"),
+			"migrations/0001_init.down.sql": file("DROP TABLE a;"),
+			"migrations/0003_more.up.sql":   file("CREATE TABLE b (id INT);"),
+			"migrations/0003_more.down.sql": file("DROP TABLE b;"),
+		},
+		"bad name": {
+			"migrations/1_init.sql": file("CREATE TABLE a (id INT);"),
+		},
+		"renamed half": {
+			"migrations/0001_init.up.sql":    file("CREATE TABLE a (id INT);"),
+			"migrations/0001_other.down.sql": file("DROP TABLE a;"),
+		},
+	}
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := loadMigrations(fsys); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
+
+func TestLoadMigrationsOrdersByVersion(t *testing.T) {
+	fsys := fstest.MapFS{
+		"migrations/0002_more.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"migrations/0002_more.down.sql": {Data: []byte("DROP TABLE b;")},
+		"migrations/0001_init.up.sql":   {Data: []byte("CREATE TABLE a (id INT);")},
+		"migrations/0001_init.down.sql": {Data: []byte("DROP TABLE a;")},
+	}
+	loaded, err := loadMigrations(fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(loaded) != 2 || loaded[0].Name != "init" || loaded[1].Name != "more" {
+		t.Fatalf("unexpected migrations: %+v", loaded)
+	}
+}
+
+func TestStatements(t *testing.T) {
+	script := "-- a comment\n" +
+		"CREATE TABLE a (\n  id INT\n);\n" +
+		"\n" +
+		"INSERT INTO a VALUES (1);\n" +
+		"-- trailing comment\n"
+	want := []string{"CREATE TABLE a (\n  id INT\n)", "INSERT INTO a VALUES (1)"}
+	if got := statements(script); !reflect.DeepEqual(got, want) {
+		t.Fatalf("statements() = %q, want %q", got, want)
+	}
+	if got := statements("-- data is kept\n"); len(got) != 0 {
+		t.Fatalf("comment-only script gave %q", got)
+	}
+}
+
+func TestInitialSchemaDefinitionsAreRecognized(t *testing.T) {
+	for _, stmt := range statements(migrations[0].up) {
+		if !createTablePattern.MatchString(stmt) {
+			t.Fatalf("not a CREATE TABLE statement: %.40s", stmt)
+		}
+		for _, line := range strings.Split(stmt, "\n")[1:] {
+			definition := strings.TrimSpace(line)
+			switch {
+			case columnDefinitionPattern.MatchString(definition), indexDefinitionPattern.MatchString(definition),
+				strings.HasPrefix(definition, "PRIMARY KEY"), strings.HasPrefix(definition, ")"):
+			default:
+				t.Errorf("completeInitialSchema would skip %q", definition)
+			}
+		}
+	}
+}
+
+// baselineSchema is what the first release created with AutoMigrate
+var baselineSchema = []string{
+	"CREATE TABLE `memos` (`id` varchar(36), `from` varchar(255), `to` varchar(255), `subject` text, " +
+		"`message` text, `status` varchar(20), `is_broadcast` boolean, `ttl_days` bigint, " +
+		"`created_at` datetime(3) NULL, `delivered_at` datetime(3) NULL, PRIMARY KEY (`id`), " +
+		"INDEX `idx_memos_from` (`from`), INDEX `idx_memos_to` (`to`), INDEX `idx_memos_status` (`status`), " +
+		"INDEX `idx_memos_is_broadcast` (`is_broadcast`), INDEX `idx_memos_delivered_at` (`delivered_at`))",
+	"CREATE TABLE `users` (`email` varchar(255), `created_at` datetime(3) NULL, PRIMARY KEY (`email`))",
+}
+
+func TestUpAdoptsBaselineDatabase(t *testing.T) {
+	dsn := storetest.NewDatabase(t)
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	for _, stmt := range baselineSchema {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("creating baseline schema: %v", err)
+		}
+	}
+	seed := []string{
+		"INSERT INTO users (email, created_at) VALUES ('alice@example.com', NOW()), ('bob@example.com', NOW()), ('carol@example.com', NOW())",
+		"INSERT INTO memos (id, `from`, `to`, subject, message, status, is_broadcast, created_at) VALUES " +
+			"('direct', 'alice@example.com', 'bob@example.com', 's', 'm', 'sent', FALSE, NOW()), " +
+			"('broadcast', 'alice@example.com', 'broadcast', 's', 'm', 'delivered', TRUE, NOW()), " +
+			"('scheduled', 'alice@example.com', 'bob@example.com', 's', 'm', 'scheduled', FALSE, NOW()), " +
+			"('failed', 'alice@example.com', 'broadcast', 's', 'm', 'failed', TRUE, NOW())",
+		"INSERT INTO memos (id, `from`, `to`, subject, message, status, is_broadcast, created_at, delivered_at) VALUES " +
+			"('read', 'alice@example.com', 'bob@example.com', 's', 'm', 'delivered', FALSE, NOW(), NOW())",
+	}
+	for _, stmt := range seed {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("seeding baseline data: %v", err)
+		}
+	}
+
+	migrator, err := NewMigrator(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer migrator.Close()
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrating baseline database: %v", err)
+	}
+
+	status, err := migrator.Status(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range status {
+		if s.AppliedAt == nil {
+			t.Errorf("migration %04d_%s still pending", s.Version, s.Name)
+		}
+	}
+
+	var category string
+	var requiresAck bool
+	if err := db.QueryRow("SELECT category, requires_ack FROM memos WHERE id = 'direct'").Scan(&category, &requiresAck); err != nil {
+		t.Fatalf("reading added columns: %v", err)
+	}
+	if category != "general" || requiresAck {
+		t.Fatalf("added columns have category %q, requires_ack %v", category, requiresAck)
+	}
+
+	deliveries := map[string]int{}
+	rows, err := db.Query("SELECT memo_id, COUNT(*) FROM memo_deliveries GROUP BY memo_id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id string
+		var count int
+		if err := rows.Scan(&id, &count); err != nil {
+			t.Fatal(err)
+		}
+		deliveries[id] = count
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]int{"direct": 1, "read": 1, "broadcast": 2}
+	if !reflect.DeepEqual(deliveries, want) {
+		t.Fatalf("backfilled deliveries = %v, want %v", deliveries, want)
+	}
+
+	// A baseline 'delivered' memo had been read by its recipient
+	var readAt sql.NullTime
+	for id, wantRead := range map[string]bool{"direct": false, "read": true} {
+		if err := db.QueryRow("SELECT read_at FROM memo_deliveries WHERE memo_id = ?", id).Scan(&readAt); err != nil {
+			t.Fatal(err)
+		}
+		if readAt.Valid != wantRead {
+			t.Errorf("memo %s backfilled with read_at %v, want read %v", id, readAt, wantRead)
+		}
+	}
+	var unread int64
+	if err := db.QueryRow("SELECT unread FROM unread_counters WHERE email = 'bob@example.com'").Scan(&unread); err != nil {
+		t.Fatal(err)
+	}
+	if unread != 2 {
+		t.Errorf("bob's unread counter is %d, want 2 for the unread direct memo and the broadcast", unread)
+	}
+}
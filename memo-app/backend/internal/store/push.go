@@ -0,0 +1,324 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+var (
+	// ErrDeviceNotFound is returned when unregistering a token the caller has not registered
+	ErrDeviceNotFound = errors.New("device not found")
+	// ErrInvalidDevice is returned when a device token is malformed
+	ErrInvalidDevice = errors.New("invalid device token")
+	// ErrMuteNotFound is returned when removing a mute that does not exist
+	ErrMuteNotFound = errors.New("mute not found")
+	// ErrInvalidMute is returned when a mute has an unknown kind or an empty value
+	ErrInvalidMute = errors.New("invalid mute")
+)
+
+// notMutedSQL skips recipients who muted the memo's sender or category
+const notMutedSQL = "NOT EXISTS (SELECT 1 FROM push_mutes m WHERE m.email = memo_deliveries.recipient AND " +
+	"((m.kind = ? AND m.value = ?) OR (m.kind = ? AND m.value = ?)))"
+
+// RegisterDevice registers a push token for user's device
+// Registering a token again moves it to the current user
+func (s *DBStore) RegisterDevice(user string, token string, platform string) (*models.DeviceToken, error) {
+	token = strings.TrimSpace(token)
+	if token == "" || len(token) > 255 {
+		return nil, fmt.Errorf("%w: tokens must be 1-255 characters", ErrInvalidDevice)
+	}
+	device := &models.DeviceToken{Token: token, Email: user, Platform: platform}
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"email", "platform", "updated_at"}),
+	}).Create(device).Error
+	if err != nil {
+		return nil, err
+	}
+	return device, nil
+}
+
+// UnregisterDevice removes one of user's push tokens, e.g. on logout
+func (s *DBStore) UnregisterDevice(user string, token string) error {
+	result := s.db.Delete(&models.DeviceToken{}, "token = ? AND email = ?", token, user)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+// ListMutes returns user's push mutes by kind and value
+func (s *DBStore) ListMutes(user string) ([]*models.PushMute, error) {
+	mutes := []*models.PushMute{}
+	if err := s.db.Where("email = ?", user).Order("kind, value").Find(&mutes).Error; err != nil {
+		return nil, err
+	}
+	return mutes, nil
+}
+
+// AddMute stops push notifications to user for memos from a sender or of a category
+// Adding an existing mute is not an error
+func (s *DBStore) AddMute(user string, kind models.MuteKind, value string) (*models.PushMute, error) {
+	value = strings.TrimSpace(value)
+	if kind != models.MuteSender && kind != models.MuteCategory {
+		return nil, fmt.Errorf("%w: kind must be %q or %q", ErrInvalidMute, models.MuteSender, models.MuteCategory)
+	}
+	if value == "" || len(value) > 255 {
+		return nil, fmt.Errorf("%w: value must be 1-255 characters", ErrInvalidMute)
+	}
+	mute := &models.PushMute{Email: user, Kind: kind, Value: value}
+	if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(mute).Error; err != nil {
+		return nil, err
+	}
+	return mute, nil
+}
+
+// RemoveMute lets push notifications for a sender or category through again
+func (s *DBStore) RemoveMute(user string, kind models.MuteKind, value string) error {
+	result := s.db.Delete(&models.PushMute{}, "email = ? AND kind = ? AND value = ?", user, kind, value)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrMuteNotFound
+	}
+	return nil
+}
+
+// enqueuePush records in the outbox that memo's recipients should be notified
+// Must be called inside the transaction that creates the memo's deliveries;
+// does nothing when push notifications are disabled
+func (s *DBStore) enqueuePush(tx *gorm.DB, memo *models.Memo) error {
+	return s.enqueuePushJob(tx, memo.ID, models.PushNewMemo, time.Now())
+}
+
+// enqueuePushJob adds an outbox entry of the given kind, created at the given time
+func (s *DBStore) enqueuePushJob(tx *gorm.DB, memoID string, kind models.PushKind, at time.Time) error {
+	if s.push == nil {
+		return nil
+	}
+	return tx.Create(&models.PushJob{MemoID: memoID, Kind: kind, NextAttemptAt: at, CreatedAt: at}).Error
+}
+
+// StartPushDispatcher periodically sends the notifications queued in the outbox
+// until the context is cancelled. Several servers can run it side by side.
+func (s *DBStore) StartPushDispatcher(ctx context.Context) {
+	if s.push == nil {
+		return
+	}
+	ticker := time.NewTicker(config.PushIntervalSeconds * time.Second)
+	defer ticker.Stop()
+
+	log.Printf("Push dispatcher started - running every %d seconds", config.PushIntervalSeconds)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Push dispatcher stopped")
+			return
+		case now := <-ticker.C:
+			s.dispatchPush(ctx, now)
+		}
+	}
+}
+
+// dispatchPush processes the outbox entries that are due
+func (s *DBStore) dispatchPush(ctx context.Context, now time.Time) {
+	var jobs []*models.PushJob
+	if err := s.db.Where("processed_at IS NULL AND next_attempt_at <= ?", now).
+		Order("id").Limit(config.PushJobsPerRun).Find(&jobs).Error; err != nil {
+		log.Printf("Push: failed to read outbox: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return
+		}
+		if s.claimPushJob(job, now) {
+			s.processPushJob(ctx, job)
+		}
+	}
+}
+
+// claimPushJob reserves job for this server for the lease time
+// Moving next_attempt_at forward doubles as the claim, so only one server sends each job;
+// if the server stops, the job becomes due again when the lease runs out
+func (s *DBStore) claimPushJob(job *models.PushJob, now time.Time) bool {
+	result := s.db.Model(&models.PushJob{}).
+		Where("id = ? AND processed_at IS NULL AND next_attempt_at <= ?", job.ID, now).
+		Update("next_attempt_at", pushLease(now))
+	return result.Error == nil && result.RowsAffected > 0
+}
+
+// processPushJob notifies the recipients of a job's memo in batches of PushBatchSize,
+// recording progress after every batch so that a retry resumes where it stopped
+func (s *DBStore) processPushJob(ctx context.Context, job *models.PushJob) {
+	var memo models.Memo
+	err := s.db.First(&memo, "id = ?", job.MemoID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		s.finishPushJob(job, "memo deleted")
+		return
+	}
+	if err != nil {
+		s.retryPushJob(job, err)
+		return
+	}
+	msg := pushMessage(&memo, job.Kind)
+
+	// New memos go to recipients who have not read them yet; reminders to the
+	// recipients reminded when the entry was written
+	pending, pendingArgs := "read_at IS NULL", []interface{}{}
+	if job.Kind == models.PushAckReminder {
+		pending, pendingArgs = "acknowledged_at IS NULL AND last_reminded_at >= ?", []interface{}{job.CreatedAt}
+	}
+
+	for {
+		// Memos that are no longer visible are not worth a notification
+		now := time.Now()
+		if !countsAsUnread(&memo) || (memo.VisibleUntil != nil && !memo.VisibleUntil.After(now)) {
+			s.finishPushJob(job, "")
+			return
+		}
+
+		var recipients []string
+		err := s.db.Model(&models.MemoDelivery{}).
+			Where("memo_id = ? AND recipient > ?", memo.ID, job.Cursor).
+			Where(pending, pendingArgs...).
+			Where(notMutedSQL, models.MuteSender, memo.From, models.MuteCategory, memo.Category).
+			Order("recipient").Limit(config.PushBatchSize).Pluck("recipient", &recipients).Error
+		if err != nil {
+			s.retryPushJob(job, err)
+			return
+		}
+		if len(recipients) == 0 {
+			s.finishPushJob(job, "")
+			return
+		}
+
+		// Record progress, even of a batch that failed part way, and extend the lease for the next batch
+		notified, err := s.notify(ctx, msg, recipients)
+		if notified != "" {
+			job.Cursor = notified
+			s.db.Model(job).Updates(map[string]interface{}{"cursor": job.Cursor, "next_attempt_at": pushLease(time.Now())})
+		}
+		if err != nil {
+			s.retryPushJob(job, err)
+			return
+		}
+	}
+}
+
+// notify sends msg to every device of recipients, which must be in order, and prunes
+// the tokens the gateway rejects. It returns the last recipient whose devices were all
+// handled: the last one, unless the gateway failed part way. A recipient whose devices
+// straddle the failure is notified again on every device when the job is retried.
+func (s *DBStore) notify(ctx context.Context, msg push.Message, recipients []string) (string, error) {
+	var devices []*models.DeviceToken
+	if err := s.db.Where("email IN ?", recipients).Order("email, token").Find(&devices).Error; err != nil {
+		return "", err
+	}
+	if len(devices) == 0 {
+		return recipients[len(recipients)-1], nil
+	}
+	tokens := make([]string, len(devices))
+	for i, d := range devices {
+		tokens[i] = d.Token
+	}
+
+	result, err := s.push.Send(ctx, msg, tokens)
+	if result != nil && len(result.InvalidTokens) > 0 {
+		if err := s.db.Delete(&models.DeviceToken{}, "token IN ?", result.InvalidTokens).Error; err != nil {
+			log.Printf("Push: failed to prune invalid tokens: %v", err)
+		} else {
+			log.Printf("Push: pruned %d invalid device tokens", len(result.InvalidTokens))
+		}
+	}
+	if err == nil {
+		return recipients[len(recipients)-1], nil
+	}
+	if result == nil {
+		return "", err
+	}
+	if result.Handled >= len(devices) {
+		return recipients[len(recipients)-1], err
+	}
+
+	// Recipients before the owner of the first device not handled are done
+	for i, r := range recipients {
+		if r == devices[result.Handled].Email {
+			if i == 0 {
+				return "", err
+			}
+			return recipients[i-1], err
+		}
+	}
+	return "", err
+}
+
+// finishPushJob marks job as processed, with a note if it was skipped
+func (s *DBStore) finishPushJob(job *models.PushJob, note string) {
+	now := time.Now()
+	s.db.Model(job).Updates(map[string]interface{}{"processed_at": &now, "last_error": note})
+}
+
+// retryPushJob schedules job again with exponential backoff, or gives up after PushMaxAttempts
+func (s *DBStore) retryPushJob(job *models.PushJob, cause error) {
+	job.Attempts++
+	updates := map[string]interface{}{"attempts": job.Attempts, "last_error": cause.Error()}
+	if job.Attempts >= config.PushMaxAttempts {
+		now := time.Now()
+		updates["processed_at"] = &now
+		log.Printf("Push: giving up on memo %s after %d attempts: %v", job.MemoID, job.Attempts, cause)
+	} else {
+		delay := time.Duration(config.PushRetryBaseSeconds<<(job.Attempts-1)) * time.Second
+		updates["next_attempt_at"] = time.Now().Add(delay)
+		log.Printf("Push: attempt %d for memo %s failed, retrying in %v: %v", job.Attempts, job.MemoID, delay, cause)
+	}
+	s.db.Model(job).Updates(updates)
+}
+
+// cleanupPushOutbox removes outbox entries processed more than PushOutboxRetentionHours ago
+func (s *DBStore) cleanupPushOutbox(now time.Time) int64 {
+	cutoff := now.Add(-config.PushOutboxRetentionHours * time.Hour)
+	return s.db.Where("processed_at < ?", cutoff).Delete(&models.PushJob{}).RowsAffected
+}
+
+// pushLease is the end of a claim taken at now
+func pushLease(now time.Time) time.Time {
+	return now.Add(config.PushLeaseSeconds * time.Second)
+}
+
+// pushMessage builds the notification of the given kind for memo; the app opens the memo from Data
+func pushMessage(memo *models.Memo, kind models.PushKind) push.Message {
+	title := memo.From
+	switch {
+	case kind == models.PushAckReminder:
+		title = "Please acknowledge: memo from " + memo.From
+	case memo.IsBroadcast:
+		title = "Broadcast from " + memo.From
+	}
+	return push.Message{
+		Title: title,
+		Body:  memo.Subject,
+		Data: map[string]string{
+			"memoId":   memo.ID,
+			"threadId": memo.ThreadID,
+			"category": memo.Category,
+			"kind":     string(kind),
+		},
+	}
+}
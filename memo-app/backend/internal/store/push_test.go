@@ -0,0 +1,125 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+// pushJob loads the outbox entry queued for memo
+func pushJob(t *testing.T, s *DBStore, memoID string) *models.PushJob {
+	t.Helper()
+	var job models.PushJob
+	if err := s.db.First(&job, "memo_id = ?", memoID).Error; err != nil {
+		t.Fatalf("loading push job of memo %s: %v", memoID, err)
+	}
+	return &job
+}
+
+// registerDevices registers tokens for user
+func registerDevices(t *testing.T, s *DBStore, user string, tokens ...string) {
+	t.Helper()
+	for _, token := range tokens {
+		if _, err := s.RegisterDevice(user, token, "android"); err != nil {
+			t.Fatalf("RegisterDevice: %v", err)
+		}
+	}
+}
+
+func TestClaimPushJobOnce(t *testing.T) {
+	dsn := newTestDatabase(t)
+	s := newTestDBStore(t, dsn, push.NewFakeGateway())
+	replica := newTestDBStore(t, dsn, push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+
+	id := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Hi", Message: "Hello"}, "bob@example.com")
+	job := pushJob(t, s, id)
+
+	now := time.Now()
+	if !s.claimPushJob(job, now) {
+		t.Fatal("the first server could not claim a due job")
+	}
+	if replica.claimPushJob(job, now) {
+		t.Fatal("a second server claimed a job under lease")
+	}
+	// A server that stopped while holding the job loses it when the lease runs out
+	if !replica.claimPushJob(job, pushLease(now).Add(time.Second)) {
+		t.Fatal("the job could not be claimed after its lease ran out")
+	}
+}
+
+func TestProcessPushJobResumesAfterPartialFailure(t *testing.T) {
+	gateway := push.NewFakeGateway("stale")
+	s := newTestDBStore(t, newTestDatabase(t), gateway)
+	createUsers(t, s, "alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com")
+	registerDevices(t, s, "bob@example.com", "b1", "b2")
+	registerDevices(t, s, "carol@example.com", "c1", "stale")
+	registerDevices(t, s, "dave@example.com", "d1")
+
+	id := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Hi", Message: "Hello"},
+		"bob@example.com", "carol@example.com", "dave@example.com")
+
+	// The gateway gets through bob's devices and one of carol's before failing
+	gateway.Err, gateway.FailAfter = errors.New("unavailable"), 3
+	s.processPushJob(context.Background(), pushJob(t, s, id))
+	job := pushJob(t, s, id)
+	if job.Cursor != "bob@example.com" || job.Attempts != 1 || job.ProcessedAt != nil {
+		t.Fatalf("job after failure = cursor %q, %d attempts, processed %v; want a retry after bob", job.Cursor, job.Attempts, job.ProcessedAt)
+	}
+
+	gateway.Err = nil
+	s.processPushJob(context.Background(), job)
+	job = pushJob(t, s, id)
+	if job.ProcessedAt == nil {
+		t.Fatal("job not finished after a successful retry")
+	}
+
+	deliveries := gateway.Deliveries()
+	if len(deliveries) != 2 {
+		t.Fatalf("got %d gateway sends, want 2", len(deliveries))
+	}
+	if got := strings.Join(deliveries[0].Tokens, ","); got != "b1,b2,c1" {
+		t.Errorf("first send reached %s, want b1,b2,c1", got)
+	}
+	if got := strings.Join(deliveries[1].Tokens, ","); got != "c1,d1" {
+		t.Errorf("retry reached %s, want carol and dave but not bob again", got)
+	}
+
+	var remaining int64
+	s.db.Model(&models.DeviceToken{}).Where("token = ?", "stale").Count(&remaining)
+	if remaining != 0 {
+		t.Error("the token the gateway reported invalid was not pruned")
+	}
+}
+
+func TestProcessPushJobSkipsMutedRecipients(t *testing.T) {
+	gateway := push.NewFakeGateway()
+	s := newTestDBStore(t, newTestDatabase(t), gateway)
+	createUsers(t, s, "alice@example.com", "bob@example.com", "carol@example.com", "dave@example.com")
+	registerDevices(t, s, "bob@example.com", "b1")
+	registerDevices(t, s, "carol@example.com", "c1")
+	registerDevices(t, s, "dave@example.com", "d1")
+	if _, err := s.AddMute("carol@example.com", models.MuteSender, "alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AddMute("dave@example.com", models.MuteCategory, "general"); err != nil {
+		t.Fatal(err)
+	}
+
+	id := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Hi", Message: "Hello", Category: "general"},
+		"bob@example.com", "carol@example.com", "dave@example.com")
+	s.processPushJob(context.Background(), pushJob(t, s, id))
+
+	deliveries := gateway.Deliveries()
+	if len(deliveries) != 1 || strings.Join(deliveries[0].Tokens, ",") != "b1" {
+		t.Fatalf("deliveries = %+v, want only bob's device", deliveries)
+	}
+	if job := pushJob(t, s, id); job.ProcessedAt == nil {
+		t.Error("job not finished")
+	}
+}
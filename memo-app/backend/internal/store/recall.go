@@ -0,0 +1,207 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+)
+
+var (
+	// ErrRecalled is returned when changing a memo that has been recalled
+	ErrRecalled = errors.New("memo has been recalled")
+	// ErrNotSent is returned when recalling or editing a memo that is still scheduled
+	ErrNotSent = errors.New("memo has not been sent yet; change or cancel the scheduled memo instead")
+	// ErrEditWindowClosed is returned when editing a memo after the grace period
+	ErrEditWindowClosed = errors.New("memo can no longer be edited")
+	// ErrInvalidEdit is returned when an edit would leave the subject or body empty
+	ErrInvalidEdit = errors.New("invalid edit")
+	// ErrEditConflict is returned when a memo was edited by another request at the same time
+	ErrEditConflict = errors.New("memo was edited concurrently; reload and try again")
+)
+
+// notRecalledSQL hides recalled memos from recipients who had not read them before the recall
+// Must be used with the recipient's delivery row joined as d
+const notRecalledSQL = "(memos.recalled_at IS NULL OR d.read_at <= memos.recalled_at)"
+
+// Recall withdraws a sent memo. Recipients who had not read it no longer see it;
+// those who had keep it, flagged with RecalledAt.
+// Only the sender of the memo or an admin can recall it
+func (s *DBStore) Recall(id string, actor *models.User) (*models.Memo, error) {
+	memo, err := s.sentMemo(id)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil || (memo.From != actor.Email && actor.Role != models.RoleAdmin) {
+		return nil, ErrForbidden
+	}
+	if memo.RecalledAt != nil {
+		return nil, ErrRecalled
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// The hidden deliveries are exactly the unread ones, which stop counting as unread
+		if err := releaseUnread(tx, []string{id}); err != nil {
+			return err
+		}
+		result := tx.Model(&models.Memo{}).Where("id = ? AND recalled_at IS NULL", id).Update("recalled_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRecalled
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.invalidateMemos([]string{id})
+	s.events.Publish(events.Event{
+		Type:       events.MemoRecalled,
+		Data:       map[string]interface{}{"id": id, "recalledAt": now},
+		Recipients: append([]string{memo.From}, s.memoRecipients(id)...),
+	})
+
+	recalled, ok := s.Get(id)
+	if !ok {
+		return nil, ErrMemoNotFound
+	}
+	return recalled, nil
+}
+
+// Edit corrects the subject or body of a memo within EditGraceMinutes of sending it
+// The previous text is kept as a revision recipients can view. Only the sender can edit a memo.
+// Acknowledgements confirm the text that was acknowledged, so editing a memo that requires
+// them asks every recipient to acknowledge it again
+func (s *DBStore) Edit(id string, actor *models.User, req *models.EditMemoRequest) (*models.Memo, error) {
+	memo, err := s.sentMemo(id)
+	if err != nil {
+		return nil, err
+	}
+	if actor == nil || memo.From != actor.Email {
+		return nil, ErrForbidden
+	}
+	if memo.RecalledAt != nil {
+		return nil, ErrRecalled
+	}
+	now := time.Now()
+	if now.Sub(memo.CreatedAt) > config.EditGraceMinutes*time.Minute {
+		return nil, fmt.Errorf("%w: memos can only be edited within %d minutes of sending", ErrEditWindowClosed, config.EditGraceMinutes)
+	}
+
+	subject, message := memo.Subject, memo.Message
+	if req.Subject != nil {
+		subject = strings.TrimSpace(*req.Subject)
+	}
+	if req.Message != nil {
+		message = *req.Message
+	}
+	if subject == "" || strings.TrimSpace(message) == "" {
+		return nil, fmt.Errorf("%w: subject and message can't be empty", ErrInvalidEdit)
+	}
+	if subject == memo.Subject && message == memo.Message {
+		if unchanged, ok := s.Get(id); ok {
+			return unchanged, nil
+		}
+		return nil, ErrMemoNotFound
+	}
+
+	previous := &models.MemoRevision{
+		MemoID:    memo.ID,
+		Revision:  memo.Revision,
+		Subject:   memo.Subject,
+		Message:   memo.Message,
+		CreatedAt: memo.CreatedAt,
+	}
+	if memo.EditedAt != nil {
+		previous.CreatedAt = *memo.EditedAt
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		// The revision check makes concurrent edits fail instead of losing one of them
+		result := tx.Model(&models.Memo{}).Where("id = ? AND revision = ? AND recalled_at IS NULL", id, memo.Revision).
+			Updates(map[string]interface{}{"subject": subject, "message": message, "revision": memo.Revision + 1, "edited_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrEditConflict
+		}
+		if memo.RequiresAck {
+			// Reminders restart from the edit rather than from delivery
+			if err := tx.Model(&models.MemoDelivery{}).Where("memo_id = ?", id).
+				Updates(map[string]interface{}{"acknowledged_at": nil, "ack_reminders": 0, "last_reminded_at": now}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(previous).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Every recipient's cached lists and search results hold the old text
+	s.invalidateMemos([]string{id})
+	s.events.Publish(events.Event{
+		Type: events.MemoEdited,
+		Data: map[string]interface{}{
+			"id":       id,
+			"subject":  subject,
+			"message":  message,
+			"revision": memo.Revision + 1,
+			"editedAt": now,
+		},
+		Recipients: append([]string{memo.From}, s.memoRecipients(id)...),
+	})
+
+	edited, ok := s.Get(id)
+	if !ok {
+		return nil, ErrMemoNotFound
+	}
+	return edited, nil
+}
+
+// GetRevisions returns the earlier texts of a memo, oldest first; the memo itself holds the current one
+// Only the sender, admins and recipients who can still see the memo can view them
+func (s *DBStore) GetRevisions(id string, actor *models.User) ([]*models.MemoRevision, error) {
+	memo, ok := s.Get(id)
+	if !ok {
+		return nil, ErrMemoNotFound
+	}
+	if actor == nil {
+		return nil, ErrForbidden
+	}
+	if memo.From != actor.Email && actor.Role != models.RoleAdmin && !s.visibleToRecipient(id, actor.Email) {
+		return nil, ErrMemoNotFound
+	}
+
+	revisions := []*models.MemoRevision{}
+	if err := s.db.Where("memo_id = ?", id).Order("revision").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	return revisions, nil
+}
+
+// sentMemo loads a memo that has been sent, bypassing the cache
+func (s *DBStore) sentMemo(id string) (*models.Memo, error) {
+	var memo models.Memo
+	if err := s.db.First(&memo, "id = ?", id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMemoNotFound
+		}
+		return nil, err
+	}
+	if memo.Status == models.StatusScheduled || memo.Status == models.StatusFailed {
+		return nil, ErrNotSent
+	}
+	return &memo, nil
+}
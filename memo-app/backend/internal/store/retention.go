@@ -0,0 +1,258 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+var (
+	// ErrPolicyNotFound is returned when a category has no stored retention policy
+	ErrPolicyNotFound = errors.New("retention policy not found")
+	// ErrInvalidPolicy is returned when a retention policy's category or ages are invalid
+	ErrInvalidPolicy = errors.New("invalid retention policy")
+	// ErrInvalidCategory is returned when a memo names a category without a retention policy
+	ErrInvalidCategory = errors.New("unknown memo category")
+	// ErrLegalHold is returned when deleting a memo that is under legal hold
+	ErrLegalHold = errors.New("memo is under legal hold")
+)
+
+// notArchivedSQL hides archived memos from listings; they stay in the database until purged
+const notArchivedSQL = "memos.archived_at IS NULL"
+
+// categoryPattern is the allowed form of category names
+var categoryPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,49}$`)
+
+// unreleasedStatuses are the statuses of memos that retention leaves alone until they are sent
+var unreleasedStatuses = []models.MemoStatus{models.StatusScheduled, models.StatusFailed}
+
+// defaultRetentionPolicy is the policy applied to categories without a stored one
+func defaultRetentionPolicy(category string) *models.RetentionPolicy {
+	return &models.RetentionPolicy{Category: category, PurgeAfterDays: config.DefaultTTLDays}
+}
+
+// ListRetentionPolicies returns every retention policy by category
+// The default category is always included, with the configured default if it has no stored policy
+func (s *DBStore) ListRetentionPolicies() ([]*models.RetentionPolicy, error) {
+	var policies []*models.RetentionPolicy
+	if err := s.db.Order("category").Find(&policies).Error; err != nil {
+		return nil, err
+	}
+	for _, p := range policies {
+		if p.Category == config.DefaultMemoCategory {
+			return policies, nil
+		}
+	}
+	return append([]*models.RetentionPolicy{defaultRetentionPolicy(config.DefaultMemoCategory)}, policies...), nil
+}
+
+// GetRetentionPolicy returns the retention policy of a category
+func (s *DBStore) GetRetentionPolicy(category string) (*models.RetentionPolicy, error) {
+	var policy models.RetentionPolicy
+	err := s.db.First(&policy, "category = ?", category).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		if category == config.DefaultMemoCategory {
+			return defaultRetentionPolicy(category), nil
+		}
+		return nil, ErrPolicyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SaveRetentionPolicy creates or replaces the retention policy of a category
+// Memos must be archived before they are purged, so ArchiveAfterDays must be below PurgeAfterDays
+func (s *DBStore) SaveRetentionPolicy(policy *models.RetentionPolicy) error {
+	if !categoryPattern.MatchString(policy.Category) {
+		return fmt.Errorf("%w: categories must be 1-50 lowercase letters, digits, '-' or '_'", ErrInvalidPolicy)
+	}
+	if policy.PurgeAfterDays < 1 {
+		return fmt.Errorf("%w: purgeAfterDays must be at least 1", ErrInvalidPolicy)
+	}
+	if a := policy.ArchiveAfterDays; a != nil && (*a < 1 || *a >= policy.PurgeAfterDays) {
+		return fmt.Errorf("%w: archiveAfterDays must be at least 1 and less than purgeAfterDays", ErrInvalidPolicy)
+	}
+	policy.UpdatedAt = time.Now()
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(policy).Error
+}
+
+// DeleteRetentionPolicy removes the retention policy of a category
+// Existing memos of the category fall back to the default retention; new memos can no longer use it
+func (s *DBStore) DeleteRetentionPolicy(category string) error {
+	result := s.db.Delete(&models.RetentionPolicy{}, "category = ?", category)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrPolicyNotFound
+	}
+	return nil
+}
+
+// validateCategory defaults the category of memo and checks that it has a retention policy
+// Memos requiring acknowledgement default to the acknowledgment category while it has a
+// policy, so that the acknowledgements are kept as long as it says
+func (s *DBStore) validateCategory(memo *models.Memo) error {
+	if memo.Category == "" {
+		memo.Category = config.DefaultMemoCategory
+		if memo.RequiresAck {
+			if _, err := s.GetRetentionPolicy(config.AckMemoCategory); err == nil {
+				memo.Category = config.AckMemoCategory
+			}
+		}
+	}
+	_, err := s.GetRetentionPolicy(memo.Category)
+	if errors.Is(err, ErrPolicyNotFound) {
+		return fmt.Errorf("%w: %q", ErrInvalidCategory, memo.Category)
+	}
+	return err
+}
+
+// SetLegalHold places or releases a legal hold on a single memo
+// A held memo can still be archived but is never purged or deleted
+func (s *DBStore) SetLegalHold(id string, hold bool) (*models.Memo, error) {
+	if err := s.db.Model(&models.Memo{}).Where("id = ?", id).Update("legal_hold", hold).Error; err != nil {
+		return nil, err
+	}
+	s.cache.InvalidateMemo(id)
+
+	memo, ok := s.getMemo(id)
+	if !ok {
+		return nil, ErrMemoNotFound
+	}
+	return memo, nil
+}
+
+// underLegalHold reports whether memo, or its whole category, is under legal hold
+func (s *DBStore) underLegalHold(memo *models.Memo) bool {
+	if memo.LegalHold {
+		return true
+	}
+	var held int64
+	s.db.Model(&models.RetentionPolicy{}).Where("category = ? AND legal_hold = ?", memo.Category, true).Count(&held)
+	return held > 0
+}
+
+// archiveExpired archives released memos past their custom TTL or their category's
+// archive age, RetentionBatchSize at a time, and returns how many were archived
+func (s *DBStore) archiveExpired(now time.Time) int {
+	total := 0
+	for {
+		// Memos with neither a TTL nor an archive age compare against NULL and are skipped
+		var ids []string
+		err := s.db.Model(&models.Memo{}).
+			Joins("LEFT JOIN retention_policies p ON p.category = memos.category").
+			Where("memos.archived_at IS NULL AND memos.status NOT IN ?", unreleasedStatuses).
+			Where("memos.created_at < DATE_SUB(?, INTERVAL COALESCE(memos.ttl_days, p.archive_after_days) DAY)", now).
+			Limit(config.RetentionBatchSize).Pluck("memos.id", &ids).Error
+		if err != nil {
+			log.Printf("Retention: failed to select memos to archive: %v", err)
+			return total
+		}
+		if len(ids) == 0 {
+			return total
+		}
+
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := releaseUnread(tx, ids); err != nil {
+				return err
+			}
+			return tx.Model(&models.Memo{}).Where("id IN ?", ids).Update("archived_at", now).Error
+		})
+		if err != nil {
+			log.Printf("Retention: failed to archive memos: %v", err)
+			return total
+		}
+		s.invalidateMemos(ids)
+
+		total += len(ids)
+		if len(ids) < config.RetentionBatchSize {
+			return total
+		}
+	}
+}
+
+// purgeExpired permanently deletes released memos past their category's purge age,
+// with their deliveries and attachments, RetentionBatchSize at a time
+// A custom TTL can extend but never shorten the purge age, and memos under legal
+// hold, directly or through their category, are kept
+func (s *DBStore) purgeExpired(now time.Time) int {
+	total := 0
+	for {
+		var ids []string
+		err := s.db.Model(&models.Memo{}).
+			Joins("LEFT JOIN retention_policies p ON p.category = memos.category").
+			Where("memos.status NOT IN ? AND memos.legal_hold = ? AND COALESCE(p.legal_hold, ?) = ?",
+				unreleasedStatuses, false, false, false).
+			Where("memos.created_at < DATE_SUB(?, INTERVAL GREATEST(COALESCE(memos.ttl_days, 0), COALESCE(p.purge_after_days, ?)) DAY)",
+				now, config.DefaultTTLDays).
+			Limit(config.RetentionBatchSize).Pluck("memos.id", &ids).Error
+		if err != nil {
+			log.Printf("Retention: failed to select memos to purge: %v", err)
+			return total
+		}
+		if len(ids) == 0 {
+			return total
+		}
+
+		// Invalidate while the delivery rows still tell who could see the memos
+		s.invalidateMemos(ids)
+		err = s.db.Transaction(func(tx *gorm.DB) error {
+			if err := releaseUnread(tx, ids); err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.MemoRevision{}, "memo_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			if err := tx.Delete(&models.MemoDelivery{}, "memo_id IN ?", ids).Error; err != nil {
+				return err
+			}
+			return tx.Delete(&models.Memo{}, "id IN ?", ids).Error
+		})
+		if err != nil {
+			log.Printf("Retention: failed to purge memos: %v", err)
+			return total
+		}
+		s.deleteAttachmentsWhere("memo_id IN ?", ids)
+
+		total += len(ids)
+		if len(ids) < config.RetentionBatchSize {
+			return total
+		}
+	}
+}
+
+// invalidateMemos drops cached copies of memos and the listings of everyone who could see them
+func (s *DBStore) invalidateMemos(ids []string) {
+	for _, id := range ids {
+		s.cache.InvalidateMemo(id)
+	}
+
+	var senders, recipients []string
+	s.db.Model(&models.Memo{}).Where("id IN ?", ids).Distinct().Pluck("from", &senders)
+	for _, sender := range senders {
+		s.cache.InvalidateUserMemos(sender)
+	}
+
+	var broadcasts int64
+	s.db.Model(&models.Memo{}).Where("id IN ? AND is_broadcast = ?", ids, true).Count(&broadcasts)
+	if broadcasts > 0 {
+		s.cache.InvalidateBroadcastMemos()
+	}
+	s.db.Model(&models.MemoDelivery{}).
+		Where("memo_id IN (?)", s.db.Model(&models.Memo{}).Select("id").Where("id IN ? AND is_broadcast = ?", ids, false)).
+		Distinct().Pluck("recipient", &recipients)
+	for _, r := range recipients {
+		s.cache.InvalidateUserMemos(r)
+	}
+}
@@ -0,0 +1,174 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+// backdate moves a memo's creation days into the past, as if it had been sent then
+func backdate(t *testing.T, s *DBStore, id string, days int) {
+	t.Helper()
+	if err := s.db.Model(&models.Memo{}).Where("id = ?", id).
+		Update("created_at", time.Now().AddDate(0, 0, -days)).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.cache.InvalidateMemo(id)
+}
+
+// memoExists reports whether a memo is still in the database, archived or not
+func memoExists(t *testing.T, s *DBStore, id string) bool {
+	t.Helper()
+	var n int64
+	if err := s.db.Model(&models.Memo{}).Where("id = ?", id).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func TestArchivedMemosAreHidden(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+	archiveAfter := 30
+	if err := s.SaveRetentionPolicy(&models.RetentionPolicy{Category: "hr", ArchiveAfterDays: &archiveAfter, PurgeAfterDays: 60}); err != nil {
+		t.Fatal(err)
+	}
+
+	old := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Payroll", Message: "March", Category: "hr"}, "bob@example.com")
+	recent := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Payroll", Message: "April", Category: "hr"}, "bob@example.com")
+	backdate(t, s, old, archiveAfter+1)
+	backdate(t, s, recent, archiveAfter-1)
+	if _, ok := s.Get(old); !ok {
+		t.Fatal("memo not found before archiving")
+	}
+
+	if n := s.archiveExpired(time.Now()); n != 1 {
+		t.Fatalf("archived %d memos, want 1", n)
+	}
+	if _, ok := s.Get(old); ok {
+		t.Error("Get returned an archived memo")
+	}
+	if _, ok := s.Get(recent); !ok {
+		t.Error("memo archived early")
+	}
+	if err := s.UpdateStatus(old, "bob@example.com", models.StatusRead); !errors.Is(err, ErrMemoNotFound) {
+		t.Errorf("UpdateStatus on an archived memo: %v, want ErrMemoNotFound", err)
+	}
+	if got := unreadCount(t, s, "bob@example.com"); got != 1 {
+		t.Errorf("bob has %d unread, want only the recent memo", got)
+	}
+	page, err := s.GetReceivedMemos("bob@example.com", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(page.Memos) != 1 || page.Memos[0].ID != recent {
+		t.Errorf("received %d memos, want only the recent one", len(page.Memos))
+	}
+
+	// Archived memos stay until purged, and can still be held
+	held, err := s.SetLegalHold(old, true)
+	if err != nil {
+		t.Fatalf("SetLegalHold on an archived memo: %v", err)
+	}
+	if !held.LegalHold || held.ArchivedAt == nil {
+		t.Errorf("held memo = %+v", held)
+	}
+}
+
+func TestPurgeRespectsLegalHold(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+	if err := s.SaveRetentionPolicy(&models.RetentionPolicy{Category: "legal", PurgeAfterDays: 30, LegalHold: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveRetentionPolicy(&models.RetentionPolicy{Category: "hr", PurgeAfterDays: 30}); err != nil {
+		t.Fatal(err)
+	}
+
+	attachment, err := s.SaveAttachment(context.Background(), "alice@example.com", "notes.txt", strings.NewReader("Notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ttl := 60
+	purged := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Old", Message: "x", Category: "hr", AttachmentIDs: []string{attachment.ID}}, "bob@example.com")
+	held := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Held", Message: "x", Category: "hr"}, "bob@example.com")
+	heldCategory := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Case", Message: "x", Category: "legal"}, "bob@example.com")
+	extended := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Kept", Message: "x", Category: "hr", TTLDays: &ttl}, "bob@example.com")
+	for _, id := range []string{purged, held, heldCategory, extended} {
+		backdate(t, s, id, 31)
+	}
+	if _, err := s.SetLegalHold(held, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := s.purgeExpired(time.Now()); n != 1 {
+		t.Fatalf("purged %d memos, want 1", n)
+	}
+	if memoExists(t, s, purged) {
+		t.Error("expired memo not purged")
+	}
+	for name, id := range map[string]string{"held": held, "held category": heldCategory, "longer TTL": extended} {
+		if !memoExists(t, s, id) {
+			t.Errorf("%s memo was purged", name)
+		}
+	}
+	if err := s.db.First(&models.Attachment{}, "id = ?", attachment.ID).Error; err == nil {
+		t.Error("attachment of a purged memo kept")
+	}
+	if got := unreadCount(t, s, "bob@example.com"); got != 3 {
+		t.Errorf("bob has %d unread after the purge, want 3", got)
+	}
+
+	alice, err := s.GetUserByEmail("alice@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Delete(held, alice); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("deleting a held memo: %v, want ErrLegalHold", err)
+	}
+	if err := s.Delete(heldCategory, alice); !errors.Is(err, ErrLegalHold) {
+		t.Errorf("deleting a memo of a held category: %v, want ErrLegalHold", err)
+	}
+
+	// Once released, the memo is purged on the next run
+	if _, err := s.SetLegalHold(held, false); err != nil {
+		t.Fatal(err)
+	}
+	if n := s.purgeExpired(time.Now()); n != 1 || memoExists(t, s, held) {
+		t.Errorf("purged %d memos after releasing the hold", n)
+	}
+}
+
+func TestAcknowledgementsOutliveTheDefaultRetention(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+
+	plain := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "FYI", Message: "x"}, "bob@example.com")
+	acked := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Policy", Message: "Please confirm", RequiresAck: true}, "bob@example.com")
+	if _, err := s.Acknowledge(acked, "bob@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if memo, _ := s.Get(acked); memo.Category != config.AckMemoCategory {
+		t.Fatalf("category %q, want %q", memo.Category, config.AckMemoCategory)
+	}
+	backdate(t, s, plain, config.DefaultTTLDays+1)
+	backdate(t, s, acked, config.DefaultTTLDays+1)
+
+	if n := s.purgeExpired(time.Now()); n != 1 || memoExists(t, s, plain) {
+		t.Fatalf("purged %d memos, want only the plain one", n)
+	}
+	if !memoExists(t, s, acked) {
+		t.Fatal("memo requiring acknowledgement purged with the default retention")
+	}
+	var acknowledged int64
+	s.db.Model(&models.MemoDelivery{}).Where("memo_id = ? AND acknowledged_at IS NOT NULL", acked).Count(&acknowledged)
+	if acknowledged != 1 {
+		t.Fatal("acknowledgement record purged")
+	}
+}
@@ -0,0 +1,299 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/config"
+	"memo-app/internal/events"
+	"memo-app/internal/models"
+)
+
+var (
+	// ErrNotScheduled is returned when editing or cancelling a memo that was already released
+	ErrNotScheduled = errors.New("memo is not scheduled")
+	// ErrInvalidSchedule is returned when sendAt or visibleUntil are inconsistent
+	ErrInvalidSchedule = errors.New("invalid schedule")
+
+	// errAlreadyReleased signals that another instance released the memo first
+	errAlreadyReleased = errors.New("memo already released")
+)
+
+// recipientVisibleSQL restricts memos to those still inside their visibility window
+const recipientVisibleSQL = "(memos.visible_until IS NULL OR memos.visible_until > ?)"
+
+// notExpiredSQL restricts memos to those the scheduler has not expired yet
+const notExpiredSQL = "memos.expired_at IS NULL"
+
+// validateSchedule checks that visibleUntil is in the future and after sendAt
+func validateSchedule(sendAt, visibleUntil *time.Time, now time.Time) error {
+	if visibleUntil == nil {
+		return nil
+	}
+	if !visibleUntil.After(now) {
+		return fmt.Errorf("%w: visibleUntil must be in the future", ErrInvalidSchedule)
+	}
+	if sendAt != nil && !visibleUntil.After(*sendAt) {
+		return fmt.Errorf("%w: visibleUntil must be after sendAt", ErrInvalidSchedule)
+	}
+	return nil
+}
+
+// prepareSchedule readies memo to be stored for release at its SendAt time
+// Addresses are validated now but only expanded into recipients on release,
+// so recipients cannot see the memo before then
+func (s *DBStore) prepareSchedule(memo *models.Memo, to []string, cc []string) error {
+	if !memo.IsBroadcast {
+		resolved, err := s.resolveRecipients(memo.From, to, cc)
+		if err != nil {
+			return err
+		}
+		if len(resolved.all()) == 0 {
+			return fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+		}
+		memo.To = resolved.all()[0]
+	} else {
+		memo.To = config.BroadcastRecipient
+	}
+	memo.Status = models.StatusScheduled
+	memo.ScheduledTo = to
+	memo.ScheduledCC = cc
+	memo.Recipients = to
+	memo.CC = cc
+	return nil
+}
+
+// GetScheduledMemos lists memos author has scheduled, and failed releases, by send time
+func (s *DBStore) GetScheduledMemos(author string) []*models.Memo {
+	var memos []*models.Memo
+	s.db.Where("`from` = ? AND status IN ?", author, []models.MemoStatus{models.StatusScheduled, models.StatusFailed}).
+		Order("send_at asc").Find(&memos)
+	s.attachAddressees(memos)
+	s.attachAttachments(memos)
+	return memos
+}
+
+// UpdateScheduled edits a scheduled memo before release; only its author can edit it
+// Editing a memo whose release failed schedules it again
+func (s *DBStore) UpdateScheduled(id string, actor *models.User, req *models.UpdateScheduledMemoRequest) (*models.Memo, error) {
+	var memo models.Memo
+	if err := s.db.First(&memo, "id = ?", id).Error; err != nil {
+		return nil, ErrMemoNotFound
+	}
+	if actor == nil || memo.From != actor.Email {
+		return nil, ErrForbidden
+	}
+	if memo.Status != models.StatusScheduled && memo.Status != models.StatusFailed {
+		return nil, ErrNotScheduled
+	}
+
+	if req.To != nil {
+		memo.ScheduledTo = *req.To
+	}
+	if req.CC != nil {
+		memo.ScheduledCC = *req.CC
+	}
+	if req.Subject != nil {
+		memo.Subject = *req.Subject
+	}
+	if req.Message != nil {
+		memo.Message = *req.Message
+	}
+	if req.TTLDays != nil {
+		memo.TTLDays = req.TTLDays
+	}
+	if req.SendAt != nil {
+		memo.SendAt = req.SendAt
+	}
+	if req.VisibleUntil != nil {
+		memo.VisibleUntil = req.VisibleUntil
+	}
+
+	now := time.Now()
+	if !memo.SendAt.After(now) {
+		return nil, fmt.Errorf("%w: sendAt must be in the future", ErrInvalidSchedule)
+	}
+	if err := validateSchedule(memo.SendAt, memo.VisibleUntil, now); err != nil {
+		return nil, err
+	}
+	if !memo.IsBroadcast {
+		resolved, err := s.resolveRecipients(memo.From, memo.ScheduledTo, memo.ScheduledCC)
+		if err != nil {
+			return nil, err
+		}
+		if len(resolved.all()) == 0 {
+			return nil, fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+		}
+		memo.To = resolved.all()[0]
+	}
+	memo.Status = models.StatusScheduled
+
+	result := s.db.Model(&memo).Where("status IN ?", []models.MemoStatus{models.StatusScheduled, models.StatusFailed}).
+		Select("to", "subject", "message", "ttl_days", "send_at", "visible_until", "scheduled_to", "scheduled_cc", "status").
+		Updates(&memo)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotScheduled
+	}
+
+	s.cache.InvalidateMemo(id)
+	s.cache.InvalidateUserMemos(memo.From)
+	s.attachAddressees([]*models.Memo{&memo})
+	s.attachAttachments([]*models.Memo{&memo})
+	return &memo, nil
+}
+
+// CancelScheduled deletes a memo that has not been released yet; only its author can cancel it
+func (s *DBStore) CancelScheduled(id string, actor *models.User) error {
+	var memo models.Memo
+	if err := s.db.First(&memo, "id = ?", id).Error; err != nil {
+		return ErrMemoNotFound
+	}
+	if actor == nil || memo.From != actor.Email {
+		return ErrForbidden
+	}
+	if memo.Status != models.StatusScheduled && memo.Status != models.StatusFailed {
+		return ErrNotScheduled
+	}
+	return s.Delete(id, actor)
+}
+
+// StartScheduler periodically releases scheduled memos that are due and hides
+// memos whose visibility window has ended, until the context is cancelled
+func (s *DBStore) StartScheduler(ctx context.Context) {
+	ticker := time.NewTicker(config.SchedulerIntervalSeconds * time.Second)
+	defer ticker.Stop()
+
+	log.Printf("Scheduler started - running every %d seconds", config.SchedulerIntervalSeconds)
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Scheduler stopped")
+			return
+		case now := <-ticker.C:
+			s.releaseDue(now)
+			s.expireVisibility(now)
+		}
+	}
+}
+
+// releaseDue releases every scheduled memo whose send time has passed
+func (s *DBStore) releaseDue(now time.Time) {
+	var ids []string
+	s.db.Model(&models.Memo{}).Where("status = ? AND send_at <= ?", models.StatusScheduled, now).
+		Order("send_at").Limit(config.SchedulerBatchSize).Pluck("id", &ids)
+	for _, id := range ids {
+		s.release(id)
+	}
+}
+
+// release resolves the recipients of a scheduled memo and delivers it
+// The status change doubles as a claim so that only one server releases each memo
+func (s *DBStore) release(id string) {
+	var memo models.Memo
+	if err := s.db.First(&memo, "id = ? AND status = ?", id, models.StatusScheduled).Error; err != nil {
+		return
+	}
+
+	resolved, err := s.resolveMemoRecipients(&memo, memo.ScheduledTo, memo.ScheduledCC)
+	if err != nil {
+		log.Printf("Scheduler: failed to resolve recipients of memo %s: %v", id, err)
+		s.db.Model(&models.Memo{}).Where("id = ? AND status = ?", id, models.StatusScheduled).Update("status", models.StatusFailed)
+		s.cache.InvalidateMemo(id)
+		s.cache.InvalidateUserMemos(memo.From)
+		return
+	}
+
+	now := time.Now()
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Memo{}).Where("id = ? AND status = ?", id, models.StatusScheduled).
+			Updates(map[string]interface{}{"status": models.StatusSent, "to": memo.To, "created_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errAlreadyReleased
+		}
+		if err := dispatch(tx, &memo, resolved); err != nil {
+			return err
+		}
+		return s.enqueuePush(tx, &memo)
+	})
+	if errors.Is(err, errAlreadyReleased) {
+		return
+	}
+	if err != nil {
+		log.Printf("Scheduler: failed to release memo %s: %v", id, err)
+		return
+	}
+
+	memo.Status = models.StatusSent
+	memo.CreatedAt = now
+	s.attachAttachments([]*models.Memo{&memo})
+	s.cache.InvalidateMemo(id)
+	s.announce(&memo, resolved.all())
+	log.Printf("Scheduler: released memo %s to %d recipients", id, len(resolved.all()))
+}
+
+// expireVisibility handles memos whose visibility window has ended and that no run
+// has expired yet, including those that ended while no server was running
+func (s *DBStore) expireVisibility(now time.Time) {
+	var ids []string
+	s.db.Model(&models.Memo{}).Where("visible_until <= ? AND "+notExpiredSQL+" AND status NOT IN ?", now,
+		[]models.MemoStatus{models.StatusScheduled, models.StatusFailed}).
+		Order("visible_until").Limit(config.SchedulerBatchSize).Pluck("id", &ids)
+	for _, id := range ids {
+		s.expire(id, now)
+	}
+}
+
+// expire takes a memo whose visibility window has ended off its recipients' unread
+// counters and tells them to drop it
+// Setting expired_at doubles as a claim so that only one server expires each memo
+func (s *DBStore) expire(id string, now time.Time) {
+	var memo models.Memo
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// A server losing the race waits for the row lock and then finds the memo expired
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			First(&memo, "id = ? AND "+notExpiredSQL, id).Error; err != nil {
+			return err
+		}
+		if err := releaseUnread(tx, []string{id}); err != nil {
+			return err
+		}
+		return tx.Model(&models.Memo{}).Where("id = ?", id).Update("expired_at", now).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return
+	}
+	if err != nil {
+		log.Printf("Scheduler: failed to expire memo %s: %v", id, err)
+		return
+	}
+
+	recipients := s.memoRecipients(id)
+	s.cache.InvalidateMemo(id)
+	s.cache.InvalidateUserMemos(memo.From)
+	if memo.IsBroadcast {
+		s.cache.InvalidateBroadcastMemos()
+	} else {
+		for _, r := range recipients {
+			s.cache.InvalidateUserMemos(r)
+		}
+	}
+
+	s.events.Publish(events.Event{
+		Type:       events.MemoExpired,
+		Data:       map[string]interface{}{"id": id},
+		Recipients: recipients,
+	})
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+func TestExpireVisibilityHandlesMissedWindowsOnce(t *testing.T) {
+	dsn := newTestDatabase(t)
+	s := newTestDBStore(t, dsn, push.NewFakeGateway())
+	replica := newTestDBStore(t, dsn, push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+
+	visibleUntil := time.Now().Add(time.Hour)
+	expiring := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Offer", Message: "Today only", VisibleUntil: &visibleUntil}, "bob@example.com")
+	sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Hello", Message: "Hi"}, "bob@example.com")
+	if n := unreadCount(t, s, "bob@example.com"); n != 2 {
+		t.Fatalf("unread before expiry = %d, want 2", n)
+	}
+
+	// The window ended a day ago, while no scheduler was running
+	if err := s.db.Model(&models.Memo{}).Where("id = ?", expiring).
+		Update("visible_until", time.Now().Add(-24*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// Every server runs the scheduler; only the first one to claim the memo expires it
+	for _, server := range []*DBStore{s, replica, s} {
+		server.expireVisibility(time.Now())
+	}
+
+	if n := unreadCount(t, s, "bob@example.com"); n != 1 {
+		t.Errorf("unread after expiry = %d, want 1", n)
+	}
+	var memo models.Memo
+	if err := s.db.First(&memo, "id = ?", expiring).Error; err != nil {
+		t.Fatal(err)
+	}
+	if memo.ExpiredAt == nil {
+		t.Error("expired memo has no expired_at")
+	}
+
+	// Reading a memo the scheduler has expired does not take it off the counter again
+	if err := s.UpdateStatus(expiring, "bob@example.com", models.StatusDelivered); err != nil {
+		t.Fatalf("UpdateStatus: %v", err)
+	}
+	if n := unreadCount(t, s, "bob@example.com"); n != 1 {
+		t.Errorf("unread after reading the expired memo = %d, want 1", n)
+	}
+}
+
+func TestEndedVisibilityHidesAttachmentsAndReplies(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com")
+
+	attachment, err := s.SaveAttachment(context.Background(), "alice@example.com", "offer.txt", strings.NewReader("Half price"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	visibleUntil := time.Now().Add(time.Hour)
+	id := sendMemo(t, s, &models.Memo{From: "alice@example.com", Subject: "Offer", Message: "Today only",
+		VisibleUntil: &visibleUntil, AttachmentIDs: []string{attachment.ID}}, "bob@example.com")
+	_, content, err := s.OpenAttachment(context.Background(), attachment.ID, "bob@example.com")
+	if err != nil {
+		t.Fatalf("download inside the window: %v", err)
+	}
+	content.Close()
+
+	// The window ends
+	if err := s.db.Model(&models.Memo{}).Where("id = ?", id).Update("visible_until", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+	s.cache.InvalidateMemo(id)
+
+	if _, _, err := s.OpenAttachment(context.Background(), attachment.ID, "bob@example.com"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("download after the window: %v, want ErrForbidden", err)
+	}
+	if _, err := s.Reply(id, &models.Memo{From: "bob@example.com", Message: "Too late?"}, false); !errors.Is(err, ErrForbidden) {
+		t.Errorf("reply after the window: %v, want ErrForbidden", err)
+	}
+	_, content, err = s.OpenAttachment(context.Background(), attachment.ID, "alice@example.com")
+	if err != nil {
+		t.Fatalf("sender download after the window: %v", err)
+	}
+	content.Close()
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"memo-app/internal/models"
+)
+
+// ErrInvalidSearch is returned when a search query has no searchable terms
+var ErrInvalidSearch = errors.New("search query has no searchable terms")
+
+const (
+	snippetLength  = 200 // bytes of message text shown around the first match
+	snippetContext = 60  // bytes shown before the first match
+
+	// minTermLength matches InnoDB's default innodb_ft_min_token_size; shorter
+	// words are not indexed and would make a required term unmatchable
+	minTermLength = 3
+)
+
+// booleanOperators are characters with special meaning in MySQL boolean mode
+const booleanOperators = `+-<>()~*"@`
+
+// searchTerm is a single word, prefix or phrase from a user's query
+type searchTerm struct {
+	text   string
+	phrase bool
+	prefix bool
+}
+
+// Search finds memos that user sent or received, including broadcasts, matching query
+// in their subject, body or sender. Words must all match; "quoted phrases" match
+// exactly and a trailing * matches by prefix. Results are newest first and paged by cursor.
+func (s *DBStore) Search(user string, query string, cursorToken string, limit int) (*models.SearchPage, error) {
+	terms := parseSearchQuery(query)
+	if len(terms) == 0 {
+		return nil, ErrInvalidSearch
+	}
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	expr := booleanExpression(terms)
+	cacheKey := fmt.Sprintf("search:%s:%s:%d", expr, cursorToken, limit)
+	namespace, cacheable := s.cache.Namespace(user)
+	if cacheable {
+		if page, ok := s.cache.GetSearchPage(namespace, cacheKey); ok {
+			return page, nil
+		}
+	}
+
+	q := s.db.Model(&models.Memo{}).
+		Select("memos.*, d.delivered_at AS recipient_delivered_at, d.read_at AS recipient_read_at, d.acknowledged_at AS recipient_acknowledged_at, d.recipient IS NOT NULL AS is_recipient").
+		Joins("LEFT JOIN memo_deliveries d ON d.memo_id = memos.id AND d.recipient = ?", user).
+		Where("MATCH(memos.subject, memos.message, memos.`from`) AGAINST (? IN BOOLEAN MODE)", expr).
+		Where("(memos.`from` = ? OR (d.recipient IS NOT NULL AND "+recipientVisibleSQL+" AND "+notRecalledSQL+"))", user, time.Now()).
+		Where(notArchivedSQL)
+	q = cur.after(q)
+
+	var rows []receivedRow
+	if err := q.Order("memos.created_at desc, memos.id desc").Limit(limit + 1).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	page := &models.SearchPage{Results: make([]*models.SearchResult, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+
+	highlighter := highlightPattern(terms)
+	memos := make([]*models.Memo, 0, len(rows))
+	for i := range rows {
+		memo := rows[i].threadView()
+		memos = append(memos, memo)
+		page.Results = append(page.Results, &models.SearchResult{
+			Memo:    memo,
+			Subject: highlight(memo.Subject, highlighter),
+			Snippet: snippet(memo.Message, highlighter),
+		})
+	}
+	s.attachAddressees(memos)
+	s.attachAttachments(memos)
+
+	if cacheable {
+		s.cache.SetSearchPage(namespace, cacheKey, page)
+	}
+	return page, nil
+}
+
+// parseSearchQuery splits a query into words, prefixes and quoted phrases,
+// dropping boolean-mode operators so user input cannot change the query's meaning
+func parseSearchQuery(query string) []searchTerm {
+	var terms []searchTerm
+	for query = strings.TrimSpace(query); query != ""; query = strings.TrimSpace(query) {
+		if query[0] == '"' {
+			end := strings.IndexByte(query[1:], '"')
+			var raw string
+			if end < 0 {
+				raw, query = query[1:], ""
+			} else {
+				raw, query = query[1:end+1], query[end+2:]
+			}
+			if words := strings.Fields(stripOperators(raw)); len(words) > 0 {
+				terms = append(terms, searchTerm{text: strings.Join(words, " "), phrase: len(words) > 1})
+			}
+			continue
+		}
+
+		word := query
+		if i := strings.IndexAny(query, " \t\n\""); i >= 0 {
+			word, query = query[:i], query[i:]
+		} else {
+			query = ""
+		}
+		prefix := strings.HasSuffix(word, "*")
+		for _, w := range strings.Fields(stripOperators(word)) {
+			if utf8.RuneCountInString(w) >= minTermLength {
+				terms = append(terms, searchTerm{text: w, prefix: prefix})
+			}
+		}
+	}
+	return terms
+}
+
+// stripOperators replaces boolean-mode operator characters with spaces
+func stripOperators(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(booleanOperators, r) {
+			return ' '
+		}
+		return r
+	}, s)
+}
+
+// booleanExpression builds a MySQL boolean-mode query requiring every term
+func booleanExpression(terms []searchTerm) string {
+	parts := make([]string, 0, len(terms))
+	for _, t := range terms {
+		switch {
+		case t.phrase:
+			parts = append(parts, `+"`+t.text+`"`)
+		case t.prefix:
+			parts = append(parts, "+"+t.text+"*")
+		default:
+			parts = append(parts, "+"+t.text)
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// highlightPattern matches any of the terms case-insensitively
+func highlightPattern(terms []searchTerm) *regexp.Regexp {
+	alternatives := make([]string, 0, len(terms))
+	for _, t := range terms {
+		words := strings.Fields(t.text)
+		for i, w := range words {
+			words[i] = regexp.QuoteMeta(w)
+		}
+		pattern := `\b` + strings.Join(words, `\W+`)
+		if t.prefix {
+			pattern += `\w*`
+		} else {
+			pattern += `\b`
+		}
+		alternatives = append(alternatives, pattern)
+	}
+	return regexp.MustCompile(`(?i)` + strings.Join(alternatives, "|"))
+}
+
+// snippet returns an excerpt of text around the first match, highlighted
+func snippet(text string, pattern *regexp.Regexp) string {
+	start := 0
+	if loc := pattern.FindStringIndex(text); loc != nil && loc[0] > snippetContext {
+		start = loc[0] - snippetContext
+	}
+	end := start + snippetLength
+	if end > len(text) {
+		end = len(text)
+	}
+	// Keep the excerpt on UTF-8 boundaries
+	for start > 0 && start < len(text) && !utf8.RuneStart(text[start]) {
+		start++
+	}
+	for end < len(text) && !utf8.RuneStart(text[end]) {
+		end--
+	}
+
+	excerpt := highlight(text[start:end], pattern)
+	if start > 0 {
+		excerpt = "…" + excerpt
+	}
+	if end < len(text) {
+		excerpt += "…"
+	}
+	return excerpt
+}
+
+// highlight HTML-escapes text and wraps every match in <mark> tags
+func highlight(text string, pattern *regexp.Regexp) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range pattern.FindAllStringIndex(text, -1) {
+		b.WriteString(html.EscapeString(text[last:loc[0]]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[loc[0]:loc[1]]))
+		b.WriteString("</mark>")
+		last = loc[1]
+	}
+	b.WriteString(html.EscapeString(text[last:]))
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package store
+
+import "memo-app/internal/models"
+
+// MemoStore is the storage the core memo and user handlers depend on
+// DBStore is the production implementation; MemoryStore keeps everything in process
+type MemoStore interface {
+	// Add sends memo to the given to and cc addresses, or to everyone for broadcasts, and returns its ID
+	Add(memo *models.Memo, to []string, cc []string) (string, error)
+	// Get retrieves a memo by its ID
+	Get(id string) (*models.Memo, bool)
+	// GetSentMemos retrieves a page of memos sent by a user, newest first
+	GetSentMemos(userEmail string, cursorToken string, limit int) (*models.MemoPage, error)
+	// GetReceivedMemos retrieves a page of memos received by a user, newest first
+	GetReceivedMemos(userEmail string, cursorToken string, limit int) (*models.MemoPage, error)
+	// GetSentThreads lists a page of threads in which a user sent a memo, most recently active first
+	GetSentThreads(user string, cursorToken string, limit int) (*models.ThreadPage, error)
+	// GetReceivedThreads lists a page of threads in which a user received a memo, most recently active first
+	GetReceivedThreads(user string, cursorToken string, limit int) (*models.ThreadPage, error)
+	// UnreadCount returns how many visible memos a user has received but not read
+	UnreadCount(user string) (int64, error)
+	// UpdateStatus records that recipient has received or read a memo
+	UpdateStatus(id string, recipient string, status models.MemoStatus) error
+	// GetReceipts returns the per-recipient delivery state of a memo
+	GetReceipts(memoID string) *models.MemoReceipts
+	// Delete removes a memo on behalf of its sender or an admin
+	Delete(id string, actor *models.User) error
+
+	// SearchUsers lists a page of the user directory, optionally filtered by an email or display name prefix
+	SearchUsers(query string, cursorToken string, limit int) (*models.UserPage, error)
+	// GetUserByEmail retrieves a user, returning gorm.ErrRecordNotFound for unknown users
+	GetUserByEmail(email string) (*models.User, error)
+	// CreateUser registers a user with the default role
+	CreateUser(email string) (*models.User, error)
+	// UpdateUserRole sets the role of a user
+	UpdateUserRole(email string, role models.UserRole) error
+	// UpdateUserAttributes stores the non-empty attributes of a user
+	UpdateUserAttributes(email string, attrs models.UserAttributes) error
+	// GetAudienceOptions lists the roles, departments and locations broadcasts can target
+	GetAudienceOptions() (*models.AudienceOptions, error)
+}
+
+var (
+	_ MemoStore = (*DBStore)(nil)
+	_ MemoStore = (*MemoryStore)(nil)
+)
@@ -0,0 +1,58 @@
+// Package storetest provides throwaway MySQL databases for tests of the database store
+package storetest
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DSNEnv names the environment variable with the DSN of the MySQL server tests use,
+// e.g. root:secret@tcp(127.0.0.1:3306)/?parseTime=True&loc=UTC
+// The user must be allowed to create and drop databases. Tests needing a database
+// are skipped when it is unset.
+const DSNEnv = "MEMO_TEST_MYSQL_DSN"
+
+// NewDatabase creates an empty database on the test server and returns its DSN
+// The database is dropped when the test ends. The test is skipped if DSNEnv is unset.
+func NewDatabase(t testing.TB) string {
+	t.Helper()
+	serverDSN := os.Getenv(DSNEnv)
+	if serverDSN == "" {
+		t.Skipf("%s is not set", DSNEnv)
+	}
+	cfg, err := mysql.ParseDSN(serverDSN)
+	if err != nil {
+		t.Fatalf("parsing %s: %v", DSNEnv, err)
+	}
+	cfg.DBName = ""
+	cfg.ParseTime = true
+
+	server, err := sql.Open("mysql", cfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("connecting to the test server: %v", err)
+	}
+	t.Cleanup(func() { server.Close() })
+
+	suffix := make([]byte, 6)
+	if _, err := rand.Read(suffix); err != nil {
+		t.Fatal(err)
+	}
+	name := "memo_test_" + hex.EncodeToString(suffix)
+	if _, err := server.Exec(fmt.Sprintf("CREATE DATABASE `%s` CHARACTER SET utf8mb4", name)); err != nil {
+		t.Fatalf("creating test database: %v", err)
+	}
+	t.Cleanup(func() {
+		if _, err := server.Exec(fmt.Sprintf("DROP DATABASE `%s`", name)); err != nil {
+			t.Errorf("dropping test database %s: %v", name, err)
+		}
+	})
+
+	cfg.DBName = name
+	return cfg.FormatDSN()
+}
@@ -0,0 +1,316 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+)
+
+var (
+	// ErrTemplateNotFound is returned when a memo template does not exist
+	ErrTemplateNotFound = errors.New("memo template not found")
+	// ErrInvalidTemplate is returned when a template's name or placeholders are invalid
+	ErrInvalidTemplate = errors.New("invalid memo template")
+	// ErrMissingVariable is returned when sending from a template without a value for every placeholder
+	ErrMissingVariable = errors.New("missing template variable")
+)
+
+// templateNamePattern is the allowed form of template names
+var templateNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,99}$`)
+
+// placeholderPattern matches a {{.Name}} placeholder, allowing spaces inside the braces
+// Nothing else is interpreted, so rendering a template can't run code
+var placeholderPattern = regexp.MustCompile(`\{\{\s*\.([A-Za-z][A-Za-z0-9_]*)\s*\}\}`)
+
+// directoryFields are the placeholders filled in for each recipient from the user directory
+var directoryFields = map[string]func(*models.User) string{
+	"Name": func(u *models.User) string {
+		if u.DisplayName != "" {
+			return u.DisplayName
+		}
+		return u.Email
+	},
+	"Email":      func(u *models.User) string { return u.Email },
+	"Department": func(u *models.User) string { return u.Department },
+	"Location":   func(u *models.User) string { return u.Location },
+}
+
+// templateText is a parsed subject or message: literal text around placeholders
+// names[i] sits between literals[i] and literals[i+1]
+type templateText struct {
+	literals []string
+	names    []string
+}
+
+// parseTemplateText splits text into literals and placeholders
+// Braces that don't form a {{.Name}} placeholder are rejected rather than sent as is
+func parseTemplateText(text string) (*templateText, error) {
+	t := &templateText{}
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(text, -1) {
+		t.literals = append(t.literals, text[last:m[0]])
+		t.names = append(t.names, text[m[2]:m[3]])
+		last = m[1]
+	}
+	t.literals = append(t.literals, text[last:])
+	for _, literal := range t.literals {
+		if i := strings.Index(literal, "{{"); i >= 0 {
+			snippet := literal[i:]
+			if len(snippet) > 20 {
+				snippet = snippet[:20]
+			}
+			return nil, fmt.Errorf("%w: unsupported placeholder at %q; use {{.Name}}", ErrInvalidTemplate, snippet)
+		}
+	}
+	return t, nil
+}
+
+// render substitutes values for the placeholders
+// Values are inserted as is, so placeholders inside them are not expanded
+func (t *templateText) render(values map[string]string) string {
+	var b strings.Builder
+	for i, literal := range t.literals {
+		b.WriteString(literal)
+		if i < len(t.names) {
+			b.WriteString(values[t.names[i]])
+		}
+	}
+	return b.String()
+}
+
+// compiledTemplate is a memo template ready to render
+type compiledTemplate struct {
+	subject *templateText
+	message *templateText
+}
+
+// compileTemplate parses the subject and message of tmpl and fills in its Variables
+func compileTemplate(tmpl *models.MemoTemplate) (*compiledTemplate, error) {
+	subject, err := parseTemplateText(tmpl.Subject)
+	if err != nil {
+		return nil, err
+	}
+	message, err := parseTemplateText(tmpl.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	tmpl.Variables = []string{}
+	for _, name := range append(append([]string{}, subject.names...), message.names...) {
+		if !seen[name] {
+			seen[name] = true
+			tmpl.Variables = append(tmpl.Variables, name)
+		}
+	}
+	return &compiledTemplate{subject: subject, message: message}, nil
+}
+
+// render returns the subject and message for the given values, or the
+// placeholders without a non-empty value
+func (t *compiledTemplate) render(values map[string]string) (string, string, []string) {
+	var missing []string
+	seen := make(map[string]bool)
+	for _, name := range append(append([]string{}, t.subject.names...), t.message.names...) {
+		if values[name] == "" && !seen[name] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return "", "", missing
+	}
+	return t.subject.render(values), t.message.render(values), nil
+}
+
+// ListTemplates returns every memo template by name
+func (s *DBStore) ListTemplates() ([]*models.MemoTemplate, error) {
+	var templates []*models.MemoTemplate
+	if err := s.db.Order("name").Find(&templates).Error; err != nil {
+		return nil, err
+	}
+	for _, tmpl := range templates {
+		if _, err := compileTemplate(tmpl); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// GetTemplate returns a memo template with its variables
+func (s *DBStore) GetTemplate(name string) (*models.MemoTemplate, error) {
+	var tmpl models.MemoTemplate
+	err := s.db.First(&tmpl, "name = ?", name).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTemplateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := compileTemplate(&tmpl); err != nil {
+		return nil, err
+	}
+	return &tmpl, nil
+}
+
+// SaveTemplate creates or replaces a memo template
+// Its subject and message may only contain {{.Name}} placeholders
+func (s *DBStore) SaveTemplate(tmpl *models.MemoTemplate) error {
+	if !templateNamePattern.MatchString(tmpl.Name) {
+		return fmt.Errorf("%w: names must be 1-100 lowercase letters, digits, '-' or '_'", ErrInvalidTemplate)
+	}
+	if _, err := compileTemplate(tmpl); err != nil {
+		return err
+	}
+	tmpl.UpdatedAt = time.Now()
+	return s.db.Clauses(clause.OnConflict{UpdateAll: true}).Create(tmpl).Error
+}
+
+// DeleteTemplate removes a memo template; memos already sent from it are unaffected
+func (s *DBStore) DeleteTemplate(name string) error {
+	result := s.db.Delete(&models.MemoTemplate{}, "name = ?", name)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTemplateNotFound
+	}
+	return nil
+}
+
+// RenderedTemplate is a memo rendered from a template, ready to be sent with SendRendered
+// Memos holds the memos that will be sent, one per recipient when directory fields are used
+type RenderedTemplate struct {
+	Memos    []*models.Memo
+	outgoing []*outgoingMemo
+}
+
+// AddFromTemplate renders the named template into memo and sends it like Add
+// It returns the memos sent; see RenderTemplate and SendRendered
+func (s *DBStore) AddFromTemplate(name string, memo *models.Memo, to []string, cc []string, variables map[string]string) ([]*models.Memo, error) {
+	rendered, err := s.RenderTemplate(name, memo, to, cc, variables)
+	if err != nil {
+		return nil, err
+	}
+	return s.SendRendered(rendered)
+}
+
+// SendRendered sends the memos of a rendered template in one transaction, so that
+// a failure sends none of them, and returns them
+func (s *DBStore) SendRendered(rendered *RenderedTemplate) ([]*models.Memo, error) {
+	if err := s.sendAll(rendered.outgoing); err != nil {
+		return nil, err
+	}
+	return rendered.Memos, nil
+}
+
+// RenderTemplate renders the named template into memo, addressed to to and cc, without sending it
+// Placeholders are filled from variables first, then from the recipient's directory fields.
+// When directory fields are needed every recipient gets a memo of their own, so that one
+// recipient's name never shows up in another's memo; broadcasts and attachments can't be
+// used then. Every memo is rendered, so a missing variable returns an error before anything is sent.
+func (s *DBStore) RenderTemplate(name string, memo *models.Memo, to []string, cc []string, variables map[string]string) (*RenderedTemplate, error) {
+	tmpl, err := s.GetTemplate(name)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileTemplate(tmpl)
+	if err != nil {
+		return nil, err
+	}
+
+	var merged, missing []string
+	for _, v := range tmpl.Variables {
+		if variables[v] != "" {
+			continue
+		}
+		if _, ok := directoryFields[v]; ok {
+			merged = append(merged, v)
+		} else {
+			missing = append(missing, v)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingVariable, strings.Join(missing, ", "))
+	}
+
+	if len(merged) == 0 {
+		memo.Subject, memo.Message, _ = compiled.render(variables)
+		return &RenderedTemplate{
+			Memos:    []*models.Memo{memo},
+			outgoing: []*outgoingMemo{{memo: memo, to: to, cc: cc}},
+		}, nil
+	}
+
+	if memo.IsBroadcast {
+		return nil, fmt.Errorf("%w: broadcasts can't use the per-recipient fields %s", ErrInvalidTemplate, strings.Join(merged, ", "))
+	}
+	if len(memo.AttachmentIDs) > 0 {
+		return nil, fmt.Errorf("%w: memos rendered for each recipient can't carry attachments", ErrInvalidAttachment)
+	}
+	resolved, err := s.resolveRecipients(memo.From, to, cc)
+	if err != nil {
+		return nil, err
+	}
+	recipients := resolved.all()
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("%w: no recipients", ErrInvalidAddress)
+	}
+	if len(recipients) > config.MaxTemplateMergeRecipients {
+		return nil, fmt.Errorf("%w: memos rendered for each recipient can go to at most %d users",
+			ErrInvalidTemplate, config.MaxTemplateMergeRecipients)
+	}
+
+	var users []models.User
+	if err := s.db.Where("email IN ?", recipients).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	byEmail := make(map[string]*models.User, len(users))
+	for i := range users {
+		byEmail[strings.ToLower(users[i].Email)] = &users[i]
+	}
+
+	memos := make([]*models.Memo, 0, len(recipients))
+	for _, r := range recipients {
+		user, ok := byEmail[strings.ToLower(r)]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown user %s", ErrInvalidAddress, r)
+		}
+		values := make(map[string]string, len(merged)+len(variables))
+		for _, field := range merged {
+			values[field] = directoryFields[field](user)
+		}
+		for k, v := range variables {
+			if v != "" {
+				values[k] = v
+			}
+		}
+		subject, message, missing := compiled.render(values)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%w: %s has no %s in the directory", ErrMissingVariable, r, strings.Join(missing, ", "))
+		}
+		m := *memo
+		m.Subject = subject
+		m.Message = message
+		memos = append(memos, &m)
+	}
+
+	outgoing := make([]*outgoingMemo, len(memos))
+	for i, m := range memos {
+		outgoing[i] = &outgoingMemo{memo: m}
+		if i < len(resolved.to) {
+			outgoing[i].to = []string{recipients[i]}
+		} else {
+			outgoing[i].cc = []string{recipients[i]}
+		}
+	}
+	return &RenderedTemplate{Memos: memos, outgoing: outgoing}, nil
+}
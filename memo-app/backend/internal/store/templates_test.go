@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"memo-app/internal/config"
+	"memo-app/internal/models"
+	"memo-app/internal/push"
+)
+
+func TestCompileTemplateListsVariables(t *testing.T) {
+	tmpl := &models.MemoTemplate{
+		Subject: "Welcome, {{.Name}}",
+		Message: "Hi {{ .Name }}, your first day is {{.StartDate}} in {{.Location}}.",
+	}
+	compiled, err := compileTemplate(tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"Name", "StartDate", "Location"}; !reflect.DeepEqual(tmpl.Variables, want) {
+		t.Fatalf("variables %v, want %v", tmpl.Variables, want)
+	}
+
+	subject, message, missing := compiled.render(map[string]string{"Name": "Ada", "StartDate": "Monday", "Location": "Berlin"})
+	if len(missing) != 0 || subject != "Welcome, Ada" || message != "Hi Ada, your first day is Monday in Berlin." {
+		t.Fatalf("rendered %q / %q, missing %v", subject, message, missing)
+	}
+}
+
+func TestRenderReportsMissingValues(t *testing.T) {
+	compiled, err := compileTemplate(&models.MemoTemplate{Subject: "{{.A}}", Message: "{{.B}} {{.A}} {{.C}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, missing := compiled.render(map[string]string{"B": "x", "C": ""}); !reflect.DeepEqual(missing, []string{"A", "C"}) {
+		t.Fatalf("missing %v, want [A C]", missing)
+	}
+}
+
+func TestRenderDoesNotExpandValues(t *testing.T) {
+	compiled, err := compileTemplate(&models.MemoTemplate{Subject: "Hi", Message: "{{.Note}} and {{.Other}}"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, message, _ := compiled.render(map[string]string{"Note": "{{.Other}}", "Other": "secret"})
+	if message != "{{.Other}} and secret" {
+		t.Fatalf("message %q", message)
+	}
+}
+
+func TestCompileTemplateRejectsOtherActions(t *testing.T) {
+	for _, text := range []string{
+		`{{printf "%s" .Name}}`,
+		`{{range .Items}}x{{end}}`,
+		`{{.Name | html}}`,
+		`{{template "other"}}`,
+		`{{ .Name`,
+		`{{}}`,
+		`{{.user.email}}`,
+	} {
+		_, err := compileTemplate(&models.MemoTemplate{Subject: "Hi", Message: text})
+		if !errors.Is(err, ErrInvalidTemplate) {
+			t.Errorf("%q: error %v, want ErrInvalidTemplate", text, err)
+		}
+	}
+}
+
+func TestAddFromTemplateLimitsMergeRecipients(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	users := make([]models.User, config.MaxTemplateMergeRecipients+1)
+	to := make([]string, len(users))
+	for i := range users {
+		users[i] = models.User{Email: fmt.Sprintf("user%d@example.com", i), Role: models.RoleUser}
+		to[i] = users[i].Email
+	}
+	if err := s.db.CreateInBatches(users, 500).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveTemplate(&models.MemoTemplate{Name: "hello", Subject: "Hi {{.Name}}", Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := s.AddFromTemplate("hello", &models.Memo{From: "user0@example.com"}, to, nil, nil)
+	if !errors.Is(err, ErrInvalidTemplate) {
+		t.Fatalf("sending to %d recipients: err = %v, want ErrInvalidTemplate", len(to), err)
+	}
+	var sent int64
+	s.db.Model(&models.Memo{}).Count(&sent)
+	if sent != 0 {
+		t.Errorf("%d memos were sent", sent)
+	}
+}
+
+func TestAddFromTemplateSendsAllOrNothing(t *testing.T) {
+	s := newTestDBStore(t, newTestDatabase(t), push.NewFakeGateway())
+	createUsers(t, s, "alice@example.com", "bob@example.com", "carol@example.com")
+	if err := s.SaveTemplate(&models.MemoTemplate{Name: "hello", Subject: "Hi {{.Name}}", Message: "Hello"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every per-recipient copy of a memo with a fixed ID collides with the first one
+	memo := &models.Memo{ID: "fixed", From: "alice@example.com"}
+	if _, err := s.AddFromTemplate("hello", memo, []string{"bob@example.com", "carol@example.com"}, nil, nil); err == nil {
+		t.Fatal("expected the second insert to fail")
+	}
+	var sent, deliveries int64
+	s.db.Model(&models.Memo{}).Count(&sent)
+	s.db.Model(&models.MemoDelivery{}).Count(&deliveries)
+	if sent != 0 || deliveries != 0 {
+		t.Errorf("a failed merge left %d memos and %d deliveries", sent, deliveries)
+	}
+	if n := unreadCount(t, s, "bob@example.com"); n != 0 {
+		t.Errorf("bob's unread counter is %d after a failed merge", n)
+	}
+}
@@ -0,0 +1,266 @@
+package store
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/models"
+)
+
+// replySubjectPrefix is prepended to the parent's subject when a reply has none
+const replySubjectPrefix = "Re: "
+
+// Reply sends memo as a reply to the memo parentID within the same thread
+// A plain reply goes to the parent's sender; replyAll also goes to the parent's
+// recipients and cc. Replying to your own memo follows up with its recipients.
+// Only participants of the parent memo can reply. Replies keep the parent's category.
+func (s *DBStore) Reply(parentID string, memo *models.Memo, replyAll bool) (string, error) {
+	parent, ok := s.Get(parentID)
+	if !ok {
+		return "", ErrMemoNotFound
+	}
+	if !s.isParticipant(parent, memo.From) {
+		return "", ErrForbidden
+	}
+
+	var to, cc []string
+	if parent.From != memo.From {
+		to = append(to, parent.From)
+	}
+	if replyAll || parent.From == memo.From {
+		to = append(to, without(parent.Recipients, memo.From)...)
+		cc = without(parent.CC, memo.From)
+	}
+
+	if strings.TrimSpace(memo.Subject) == "" {
+		memo.Subject = parent.Subject
+		if !strings.HasPrefix(memo.Subject, replySubjectPrefix) {
+			memo.Subject = replySubjectPrefix + memo.Subject
+		}
+	}
+	memo.ParentID = &parent.ID
+	memo.ThreadID = parent.ThreadID
+	memo.Category = parent.Category
+
+	return s.Add(memo, to, cc)
+}
+
+// GetThread returns every memo of a thread that user sent or received, oldest first
+// Received memos carry the user's own delivery state; sent memos carry receipt summaries
+func (s *DBStore) GetThread(threadID string, user string) (*models.Thread, error) {
+	var rows []receivedRow
+	s.db.Model(&models.Memo{}).
+		Select("memos.*, d.delivered_at AS recipient_delivered_at, d.read_at AS recipient_read_at, d.acknowledged_at AS recipient_acknowledged_at, d.recipient IS NOT NULL AS is_recipient").
+		Joins("LEFT JOIN memo_deliveries d ON d.memo_id = memos.id AND d.recipient = ?", user).
+		Where("memos.thread_id = ? AND (memos.`from` = ? OR (d.recipient IS NOT NULL AND "+recipientVisibleSQL+" AND "+notRecalledSQL+"))", threadID, user, time.Now()).
+		Where(notArchivedSQL).
+		Order("memos.created_at asc").
+		Scan(&rows)
+	if len(rows) == 0 {
+		return nil, ErrMemoNotFound
+	}
+
+	memos := make([]*models.Memo, 0, len(rows))
+	var sent []*models.Memo
+	thread := &models.Thread{ID: threadID}
+	for i := range rows {
+		memo := rows[i].threadView()
+		if memo.From == user && !rows[i].IsRecipient {
+			sent = append(sent, memo)
+		} else if memo.ReadAt == nil {
+			thread.Unread++
+		}
+		memos = append(memos, memo)
+	}
+	s.attachAddressees(memos)
+	s.attachAttachments(memos)
+	s.attachReceiptSummaries(sent)
+
+	thread.Subject = memos[0].Subject
+	thread.MessageCount = len(memos)
+	thread.LastActivityAt = memos[len(memos)-1].CreatedAt
+	thread.Memos = memos
+	return thread, nil
+}
+
+// GetSentThreads lists a page of threads in which user sent a memo, most recently active first
+// Each thread carries the latest memo the user sent in it
+func (s *DBStore) GetSentThreads(user string, cursorToken string, limit int) (*models.ThreadPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []threadSummary
+	q := s.db.Model(&models.Memo{}).
+		Select("thread_id, MAX(created_at) AS last_activity_at, COUNT(*) AS message_count").
+		Where("`from` = ?", user).Where(notArchivedSQL).
+		Group("thread_id")
+	if err := cur.afterThread(q).Order("last_activity_at desc, thread_id desc").Limit(limit + 1).Scan(&summaries).Error; err != nil {
+		return nil, err
+	}
+	page, summaries := newThreadPage(summaries, limit)
+	if len(summaries) == 0 {
+		return page, nil
+	}
+
+	var latest []*models.Memo
+	s.db.Where("`from` = ? AND thread_id IN ?", user, threadIDs(summaries)).Where(notArchivedSQL).
+		Order("created_at desc").Find(&latest)
+	s.attachAddressees(latest)
+	s.attachAttachments(latest)
+	s.attachReceiptSummaries(latest)
+
+	page.Threads = buildThreads(summaries, latest)
+	return page, nil
+}
+
+// GetReceivedThreads lists a page of threads in which user received a memo, most recently active first
+// Each thread carries the latest memo the user received in it and the user's unread count
+func (s *DBStore) GetReceivedThreads(user string, cursorToken string, limit int) (*models.ThreadPage, error) {
+	cur, err := decodeCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	var summaries []threadSummary
+	q := s.db.Model(&models.Memo{}).
+		Select("memos.thread_id, MAX(memos.created_at) AS last_activity_at, COUNT(*) AS message_count, "+
+			"SUM(CASE WHEN d.read_at IS NULL THEN 1 ELSE 0 END) AS unread").
+		Joins("JOIN memo_deliveries d ON d.memo_id = memos.id AND d.recipient = ?", user).
+		Where(recipientVisibleSQL, time.Now()).
+		Where(notRecalledSQL).
+		Where(notArchivedSQL).
+		Group("memos.thread_id")
+	if err := cur.afterThread(q).Order("last_activity_at desc, thread_id desc").Limit(limit + 1).Scan(&summaries).Error; err != nil {
+		return nil, err
+	}
+	page, summaries := newThreadPage(summaries, limit)
+	if len(summaries) == 0 {
+		return page, nil
+	}
+
+	var rows []receivedRow
+	s.db.Model(&models.Memo{}).
+		Select("memos.*, d.delivered_at AS recipient_delivered_at, d.read_at AS recipient_read_at, d.acknowledged_at AS recipient_acknowledged_at").
+		Joins("JOIN memo_deliveries d ON d.memo_id = memos.id AND d.recipient = ?", user).
+		Where("memos.thread_id IN ?", threadIDs(summaries)).
+		Where(recipientVisibleSQL, time.Now()).
+		Where(notRecalledSQL).
+		Where(notArchivedSQL).
+		Order("memos.created_at desc").
+		Scan(&rows)
+	latest := make([]*models.Memo, 0, len(rows))
+	for i := range rows {
+		latest = append(latest, rows[i].toRecipientView())
+	}
+	s.attachAddressees(latest)
+	s.attachAttachments(latest)
+
+	page.Threads = buildThreads(summaries, latest)
+	return page, nil
+}
+
+// afterThread restricts a grouped thread query to threads after the cursor, which
+// holds the last activity time and ID of the previous page's last thread
+func (c *cursor) afterThread(q *gorm.DB) *gorm.DB {
+	if c == nil {
+		return q
+	}
+	return q.Having("(last_activity_at < ? OR (last_activity_at = ? AND thread_id < ?))", c.CreatedAt, c.CreatedAt, c.ID)
+}
+
+// newThreadPage trims up to limit+1 summaries to a page and sets its next cursor
+func newThreadPage(summaries []threadSummary, limit int) (*models.ThreadPage, []threadSummary) {
+	page := &models.ThreadPage{Threads: []*models.Thread{}}
+	if len(summaries) > limit {
+		summaries = summaries[:limit]
+		last := summaries[limit-1]
+		page.NextCursor = encodeCursor(last.LastActivityAt, last.ThreadID)
+	}
+	return page, summaries
+}
+
+// threadSummary is the aggregated activity of a thread for one user
+type threadSummary struct {
+	ThreadID       string
+	LastActivityAt time.Time
+	MessageCount   int
+	Unread         int
+}
+
+// threadView returns a thread memo from the caller's point of view: their own
+// delivery state if they received it, the memo as sent otherwise
+func (r *receivedRow) threadView() *models.Memo {
+	if r.IsRecipient {
+		return r.toRecipientView()
+	}
+	memo := r.Memo
+	return &memo
+}
+
+// isParticipant reports whether user sent memo or received it and can still see it
+func (s *DBStore) isParticipant(memo *models.Memo, user string) bool {
+	return memo.From == user || s.visibleToRecipient(memo.ID, user)
+}
+
+// visibleToRecipient reports whether user received a memo that is still shown to them:
+// inside its visibility window, and not recalled before they read it
+func (s *DBStore) visibleToRecipient(memoID string, user string) bool {
+	var count int64
+	s.db.Table("memo_deliveries d").Joins("JOIN memos ON memos.id = d.memo_id").
+		Where("d.memo_id = ? AND d.recipient = ?", memoID, user).
+		Where(recipientVisibleSQL, time.Now()).
+		Where(notRecalledSQL).
+		Count(&count)
+	return count > 0
+}
+
+// buildThreads pairs thread summaries with the newest memo of each thread
+// latest must be ordered newest first
+func buildThreads(summaries []threadSummary, latest []*models.Memo) []*models.Thread {
+	newest := make(map[string]*models.Memo, len(summaries))
+	for _, m := range latest {
+		if _, ok := newest[m.ThreadID]; !ok {
+			newest[m.ThreadID] = m
+		}
+	}
+
+	threads := make([]*models.Thread, 0, len(summaries))
+	for _, sum := range summaries {
+		thread := &models.Thread{
+			ID:             sum.ThreadID,
+			MessageCount:   sum.MessageCount,
+			Unread:         sum.Unread,
+			LastActivityAt: sum.LastActivityAt,
+			Latest:         newest[sum.ThreadID],
+		}
+		if thread.Latest != nil {
+			thread.Subject = thread.Latest.Subject
+		}
+		threads = append(threads, thread)
+	}
+	return threads
+}
+
+// threadIDs returns the thread IDs of summaries
+func threadIDs(summaries []threadSummary) []string {
+	ids := make([]string, 0, len(summaries))
+	for _, sum := range summaries {
+		ids = append(ids, sum.ThreadID)
+	}
+	return ids
+}
+
+// without returns addrs with every occurrence of addr removed
+func without(addrs []string, addr string) []string {
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if a != addr {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,66 @@
+package store
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"memo-app/internal/models"
+)
+
+// UnreadCount returns how many visible memos user has received but not read
+func (s *DBStore) UnreadCount(user string) (int64, error) {
+	var counter models.UnreadCounter
+	err := s.db.First(&counter, "email = ?", user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return counter.Unread, nil
+}
+
+// incrementUnread adds one unread memo to each recipient's counter
+// Must be called inside the transaction that creates the memo's deliveries
+func incrementUnread(tx *gorm.DB, recipients []string) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	counters := make([]models.UnreadCounter, 0, len(recipients))
+	for _, r := range recipients {
+		counters = append(counters, models.UnreadCounter{Email: r, Unread: 1})
+	}
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{"unread": gorm.Expr("unread + 1")}),
+	}).CreateInBatches(counters, deliveryBatchSize).Error
+}
+
+// decrementUnread removes one unread memo from recipient's counter
+func decrementUnread(tx *gorm.DB, recipient string) error {
+	return tx.Model(&models.UnreadCounter{}).Where("email = ? AND unread > 0", recipient).
+		Update("unread", gorm.Expr("unread - 1")).Error
+}
+
+// releaseUnread subtracts the unread deliveries of memos that are about to stop
+// counting, because they are archived, expire, are recalled or are deleted, from their recipients' counters
+// Memos are only subtracted if they still count, so that memos already archived,
+// expired or recalled are not subtracted twice
+// Must be called before the memos are archived, expired or recalled or their deliveries deleted
+func releaseUnread(tx *gorm.DB, memoIDs []string) error {
+	if len(memoIDs) == 0 {
+		return nil
+	}
+	return tx.Exec("UPDATE unread_counters u JOIN ("+
+		"SELECT d.recipient, COUNT(*) AS n FROM memo_deliveries d JOIN memos ON memos.id = d.memo_id "+
+		"WHERE d.memo_id IN ? AND d.read_at IS NULL AND "+notArchivedSQL+" AND "+notRecalledSQL+" AND "+notExpiredSQL+" "+
+		"GROUP BY d.recipient) x ON x.recipient = u.email "+
+		"SET u.unread = GREATEST(u.unread - x.n, 0)", memoIDs).Error
+}
+
+// countsAsUnread reports whether an unread delivery of memo is included in unread counters
+// A memo past its visibility window keeps counting until the scheduler has expired it
+func countsAsUnread(memo *models.Memo) bool {
+	return memo.ArchivedAt == nil && memo.RecalledAt == nil && memo.ExpiredAt == nil
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"memo-app/internal/models"
+)
+
+// likeEscaper escapes the LIKE wildcards in user input
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// SearchUsers lists a page of the user directory, ordered by email
+// A non-empty query keeps users whose email or display name starts with it, ignoring case.
+// Pass the previous page's NextCursor as cursorToken to continue.
+func (s *DBStore) SearchUsers(query string, cursorToken string, limit int) (*models.UserPage, error) {
+	after, err := decodeUserCursor(cursorToken)
+	if err != nil {
+		return nil, err
+	}
+
+	q := s.db.Model(&models.User{})
+	if query = strings.TrimSpace(query); query != "" {
+		prefix := likeEscaper.Replace(query) + "%"
+		q = q.Where("email LIKE ? OR display_name LIKE ?", prefix, prefix)
+	}
+	if after != "" {
+		q = q.Where("email > ?", after)
+	}
+
+	var users []models.User
+	if err := q.Order("email").Limit(limit + 1).Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return userPage(users, limit), nil
+}
+
+// userPage trims users, fetched with one extra row, to limit and sets the cursor
+// of the next page if there is one
+func userPage(users []models.User, limit int) *models.UserPage {
+	page := &models.UserPage{Users: users}
+	if page.Users == nil {
+		page.Users = []models.User{}
+	}
+	if len(users) > limit {
+		page.Users = users[:limit]
+		page.NextCursor = encodeUserCursor(users[limit-1].Email)
+	}
+	return page
+}
+
+// encodeUserCursor returns an opaque token for the directory position after email
+func encodeUserCursor(email string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(email))
+}
+
+// decodeUserCursor parses a token from encodeUserCursor; an empty token is the start of the directory
+func decodeUserCursor(token string) (string, error) {
+	if token == "" {
+		return "", nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || !models.IsEmailAddress(string(raw)) {
+		return "", ErrInvalidCursor
+	}
+	return string(raw), nil
+}
+
+// requireKnownUsers rejects emails that do not belong to a registered user, so that
+// a mistyped address fails instead of creating a user nobody will ever sign in as
+func requireKnownUsers(db *gorm.DB, emails []string) error {
+	known := make(map[string]bool, len(emails))
+	for start := 0; start < len(emails); start += deliveryBatchSize {
+		end := start + deliveryBatchSize
+		if end > len(emails) {
+			end = len(emails)
+		}
+		var found []string
+		if err := db.Model(&models.User{}).Where("email IN ?", emails[start:end]).
+			Pluck("email", &found).Error; err != nil {
+			return err
+		}
+		for _, email := range found {
+			known[strings.ToLower(email)] = true
+		}
+	}
+	return unknownUsersError(emails, known)
+}
+
+// unknownUsersError returns ErrInvalidAddress naming the emails missing from known,
+// which is keyed by lowercase email, or nil if there are none
+func unknownUsersError(emails []string, known map[string]bool) error {
+	var unknown []string
+	for _, email := range emails {
+		if !known[strings.ToLower(email)] {
+			unknown = append(unknown, email)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown user %s", ErrInvalidAddress, strings.Join(unknown, ", "))
+}
@@ -0,0 +1,61 @@
+package tickets
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps tickets in process memory
+// A ticket can only be redeemed on the instance that issued it; use RedisStore when running several
+type MemoryStore struct {
+	mu      sync.Mutex
+	tickets map[string]issued
+	now     func() time.Time
+}
+
+// issued is the user and expiry of one ticket
+type issued struct {
+	email   string
+	expires time.Time
+}
+
+// NewMemoryStore creates an empty in-process ticket store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{tickets: make(map[string]issued), now: time.Now}
+}
+
+// Issue returns a new ticket for email that can be redeemed once within ttl
+func (ms *MemoryStore) Issue(ctx context.Context, email string, ttl time.Duration) (string, error) {
+	ticket, err := newTicket()
+	if err != nil {
+		return "", err
+	}
+
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	now := ms.now()
+	// Tickets live for seconds, so dropping expired ones on every issue keeps the map small
+	for t, entry := range ms.tickets {
+		if !now.Before(entry.expires) {
+			delete(ms.tickets, t)
+		}
+	}
+	ms.tickets[ticket] = issued{email: email, expires: now.Add(ttl)}
+	return ticket, nil
+}
+
+// Redeem returns the email a ticket was issued for and invalidates the ticket
+func (ms *MemoryStore) Redeem(ctx context.Context, ticket string) (string, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	entry, ok := ms.tickets[ticket]
+	if !ok {
+		return "", ErrInvalidTicket
+	}
+	delete(ms.tickets, ticket)
+	if !ms.now().Before(entry.expires) {
+		return "", ErrInvalidTicket
+	}
+	return entry.email, nil
+}
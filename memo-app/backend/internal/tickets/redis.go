@@ -0,0 +1,45 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps tickets on a Redis-protocol server, so that any instance can redeem them
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisStore keeps tickets on the server behind client, under keys starting with prefix
+func NewRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{client: client, prefix: prefix + "ticket:"}
+}
+
+// Issue returns a new ticket for email that can be redeemed once within ttl
+func (rs *RedisStore) Issue(ctx context.Context, email string, ttl time.Duration) (string, error) {
+	ticket, err := newTicket()
+	if err != nil {
+		return "", err
+	}
+	if err := rs.client.Set(ctx, rs.prefix+ticket, email, ttl).Err(); err != nil {
+		return "", err
+	}
+	return ticket, nil
+}
+
+// Redeem returns the email a ticket was issued for and invalidates the ticket
+// GETDEL reads and deletes in one step, so concurrent redemptions cannot both succeed
+func (rs *RedisStore) Redeem(ctx context.Context, ticket string) (string, error) {
+	email, err := rs.client.GetDel(ctx, rs.prefix+ticket).Result()
+	if errors.Is(err, redis.Nil) {
+		return "", ErrInvalidTicket
+	}
+	if err != nil {
+		return "", err
+	}
+	return email, nil
+}
@@ -0,0 +1,30 @@
+package tickets
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"time"
+)
+
+// ErrInvalidTicket is returned when redeeming a ticket that is unknown, expired or already used
+var ErrInvalidTicket = errors.New("invalid or expired ticket")
+
+// Store issues short-lived, single-use tickets that stand in for a user's credentials
+// where a client cannot send them in a header, e.g. EventSource connections
+type Store interface {
+	// Issue returns a new ticket for email that can be redeemed once within ttl
+	Issue(ctx context.Context, email string, ttl time.Duration) (string, error)
+	// Redeem returns the email a ticket was issued for and invalidates the ticket
+	Redeem(ctx context.Context, ticket string) (string, error)
+}
+
+// newTicket returns 256 random bits, URL-safe so the ticket can travel in a query string
+func newTicket() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,72 @@
+package tickets
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// stores returns a fresh instance of every Store implementation, and a function
+// that moves their clock forward
+func stores(t *testing.T) (map[string]Store, func(time.Duration)) {
+	clock := time.Unix(1700000000, 0)
+	memory := NewMemoryStore()
+	memory.now = func() time.Time { return clock }
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	advance := func(d time.Duration) {
+		clock = clock.Add(d)
+		server.FastForward(d)
+	}
+	return map[string]Store{"memory": memory, "redis": NewRedisStore(client, "test:")}, advance
+}
+
+func TestTicketsAreSingleUse(t *testing.T) {
+	all, _ := stores(t)
+	for name, s := range all {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			ticket, err := s.Issue(ctx, "alice@example.com", time.Minute)
+			if err != nil {
+				t.Fatalf("Issue: %v", err)
+			}
+			email, err := s.Redeem(ctx, ticket)
+			if err != nil || email != "alice@example.com" {
+				t.Fatalf("Redeem = %q, %v; want alice@example.com", email, err)
+			}
+			if _, err := s.Redeem(ctx, ticket); !errors.Is(err, ErrInvalidTicket) {
+				t.Fatalf("second Redeem = %v, want ErrInvalidTicket", err)
+			}
+			if _, err := s.Redeem(ctx, "made-up"); !errors.Is(err, ErrInvalidTicket) {
+				t.Fatalf("unknown ticket = %v, want ErrInvalidTicket", err)
+			}
+		})
+	}
+}
+
+func TestTicketsExpire(t *testing.T) {
+	all, advance := stores(t)
+	ctx := context.Background()
+	issued := map[string]string{}
+	for name, s := range all {
+		ticket, err := s.Issue(ctx, "alice@example.com", 30*time.Second)
+		if err != nil {
+			t.Fatalf("%s: Issue: %v", name, err)
+		}
+		issued[name] = ticket
+	}
+
+	advance(30 * time.Second)
+	for name, s := range all {
+		if _, err := s.Redeem(ctx, issued[name]); !errors.Is(err, ErrInvalidTicket) {
+			t.Errorf("%s: expired ticket = %v, want ErrInvalidTicket", name, err)
+		}
+	}
+}
@@ -1,5 +1,5 @@
 import axios from 'axios';
-import { Memo } from './types';
+import { Memo, MemoPage, UserPage } from './types';
 import { bridge } from './bridge';
 
 const API_URL = (import.meta as any).env?.VITE_API_URL || 'http://192.168.1.100:8080/api';
@@ -61,30 +61,39 @@ export const sendMemo = async (
 };
 
 /**
- * Retrieve all memos sent by the current user
+ * Retrieve a page of memos sent by the current user, newest first
+ * Pass the previous page's nextCursor to load the next page
  */
-export const getSentMemos = async (limit?: number, offset?: number) => {
+export const getSentMemos = async (limit?: number, cursor?: string) => {
   const params = new URLSearchParams();
   if (limit) params.append('limit', limit.toString());
-  if (offset) params.append('offset', offset.toString());
+  if (cursor) params.append('cursor', cursor);
 
-  const response = await api.get<Memo[]>(`/memos/sent?${params.toString()}`);
+  const response = await api.get<MemoPage>(`/memos/sent?${params.toString()}`);
   return response.data;
 };
 
 /**
- * Retrieve all memos received by the current user
+ * Retrieve a page of memos received by the current user, newest first
  * Includes both direct messages and broadcast messages
  */
-export const getReceivedMemos = async (limit?: number, offset?: number) => {
+export const getReceivedMemos = async (limit?: number, cursor?: string) => {
   const params = new URLSearchParams();
   if (limit) params.append('limit', limit.toString());
-  if (offset) params.append('offset', offset.toString());
+  if (cursor) params.append('cursor', cursor);
 
-  const response = await api.get<Memo[]>(`/memos/received?${params.toString()}`);
+  const response = await api.get<MemoPage>(`/memos/received?${params.toString()}`);
   return response.data;
 };
 
+/**
+ * Get the number of received memos the current user has not read yet
+ */
+export const getUnreadCount = async (): Promise<number> => {
+  const response = await api.get<{ unread: number }>('/memos/unread-count');
+  return response.data.unread;
+};
+
 /**
  * Update the delivery status of a memo
  */
@@ -100,9 +109,21 @@ export const deleteMemo = async (id: string) => {
 };
 
 /**
- * Get list of all active users (email addresses)
+ * Search the user directory by email or display name prefix, one page at a time
  */
-export const getUsers = async (): Promise<string[]> => {
-  const response = await api.get<string[]>('/users');
+export const searchUsers = async (query?: string, limit?: number, cursor?: string) => {
+  const params = new URLSearchParams();
+  if (query) params.append('q', query);
+  if (limit) params.append('limit', limit.toString());
+  if (cursor) params.append('cursor', cursor);
+  const response = await api.get<UserPage>(`/users?${params.toString()}`);
   return response.data;
 };
+
+/**
+ * Get the email addresses of the first page of the user directory
+ */
+export const getUsers = async (): Promise<string[]> => {
+  const page = await searchUsers(undefined, 100);
+  return page.users.map(user => user.email);
+};
@@ -19,8 +19,8 @@ export const useMemos = (userEmail: string) => {
   const [deletingMemoIds, setDeletingMemoIds] = useState<Set<string>>(new Set());
 
   // Simple refs for pagination and preventing duplicate operations
-  const sentOffsetRef = useRef(0);
-  const receivedOffsetRef = useRef(0);
+  const sentCursorRef = useRef<string | undefined>(undefined);
+  const receivedCursorRef = useRef<string | undefined>(undefined);
   const loadingSentRef = useRef(false);
   const loadingReceivedRef = useRef(false);
   const deletingRef = useRef<Set<string>>(new Set());
@@ -30,8 +30,8 @@ export const useMemos = (userEmail: string) => {
     if (!userEmail) {
       setSentMemos([]);
       setReceivedMemos([]);
-      sentOffsetRef.current = 0;
-      receivedOffsetRef.current = 0;
+      sentCursorRef.current = undefined;
+      receivedCursorRef.current = undefined;
       setHasMoreSent(true);
       setHasMoreReceived(true);
       setDeletingMemoIds(new Set());
@@ -67,19 +67,19 @@ export const useMemos = (userEmail: string) => {
     // STABILITY FIX: Don't clear existing memos immediately when refreshing
     // This prevents the "flash of empty content" and keeps data visible if fetch fails
     if (!append) {
-      sentOffsetRef.current = 0;
+      sentCursorRef.current = undefined;
     }
 
     try {
-      const offset = append ? sentOffsetRef.current : 0;
-      const memos = await getSentMemos(CONFIG.PAGE_SIZE, offset);
+      const cursor = append ? sentCursorRef.current : undefined;
+      const page = await getSentMemos(CONFIG.PAGE_SIZE, cursor);
+      const memos = page.memos;
 
       // Filter out any memos that are currently being deleted to prevent race conditions
       const validMemos = memos.filter(m => !deletingRef.current.has(m.id));
 
-      // Calculate new offset based on actual fetched count (before filtering) to keep pagination consistent
-      const newOffset = append ? (offset + memos.length) : memos.length;
-      sentOffsetRef.current = newOffset;
+      // Continue after the last memo of this page, unaffected by memos sent since
+      sentCursorRef.current = page.nextCursor;
 
       // Simple: append or replace
       setSentMemos(prev => {
@@ -87,7 +87,7 @@ export const useMemos = (userEmail: string) => {
         // Double check against deletingRef one last time in case it changed while rendering
         return current.filter(m => !deletingRef.current.has(m.id));
       });
-      setHasMoreSent(memos.length === CONFIG.PAGE_SIZE);
+      setHasMoreSent(!!page.nextCursor);
     } catch (error) {
       console.error('Failed to load sent memos:', error);
       // On error, ONLY clear if we were trying to load the first page (refresh) and it failed hard
@@ -109,15 +109,11 @@ export const useMemos = (userEmail: string) => {
     setLoadingReceived(true);
 
     try {
-      const offset = append ? receivedOffsetRef.current : 0;
-
-      // Reset offset when not appending
-      if (!append) {
-        receivedOffsetRef.current = 0;
-      }
+      const cursor = append ? receivedCursorRef.current : undefined;
 
       // Fetch from server
-      const serverMemos = await getReceivedMemos(CONFIG.PAGE_SIZE, offset);
+      const page = await getReceivedMemos(CONFIG.PAGE_SIZE, cursor);
+      const serverMemos = page.memos;
 
       // Get currently saved memos
       const savedMemos = await bridge.getSavedMemos();
@@ -157,10 +153,9 @@ export const useMemos = (userEmail: string) => {
       const allMemos = await bridge.getSavedMemos();
       setReceivedMemos(allMemos);
 
-      // Update offset properly
-      const newOffset = append ? (offset + serverMemos.length) : serverMemos.length;
-      receivedOffsetRef.current = newOffset;
-      setHasMoreReceived(serverMemos.length === CONFIG.PAGE_SIZE);
+      // Continue after the last memo of this page
+      receivedCursorRef.current = page.nextCursor;
+      setHasMoreReceived(!!page.nextCursor);
     } catch (error) {
       console.error('Failed to load received memos:', error);
       // Don't show alert on background polling or simple refresh to avoid annoyance
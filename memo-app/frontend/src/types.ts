@@ -18,6 +18,24 @@ export interface Memo {
   deliveredAt?: string;
 }
 
+export interface MemoPage {
+  memos: Memo[];
+  nextCursor?: string; // Absent on the last page
+}
+
+export interface DirectoryUser {
+  email: string;
+  displayName?: string;
+  avatarUrl?: string;
+  department?: string;
+  location?: string;
+}
+
+export interface UserPage {
+  users: DirectoryUser[];
+  nextCursor?: string; // Absent on the last page
+}
+
 export interface ReceivedMemo extends Omit<Memo, 'status'> {
   savedAt: string;
 }